@@ -0,0 +1,158 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"odoo-signup/internal/app"
+	"odoo-signup/internal/privacy"
+)
+
+const accountsUsage = `Usage: odoo-signup-ctl accounts <list|run|erasures> [flags]
+
+  list       List owners' export and deletion requests, newest first
+  run        Expire unconfirmed links and old exports, and erase tenants whose
+             cooling-off period ended, like the server does
+  erasures   List the proofs of erasure and check that their chain is intact
+`
+
+// runAccounts inspects and processes self-service account requests
+func runAccounts(a *app.App, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, accountsUsage)
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("accounts "+args[0], flag.ExitOnError)
+	format := flags.String("o", formatTable, "output format: table or json")
+	flags.Parse(args[1:])
+
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		requests, err := privacy.NewStore(a.Config.AccountRequestsPath)
+		if err != nil {
+			return err
+		}
+		list, err := requests.List()
+		if err != nil {
+			return err
+		}
+		return printAccountRequests(*format, list)
+
+	case "run":
+		p, err := newPipeline(a, 1)
+		if err != nil {
+			return err
+		}
+		defer p.Close()
+
+		result := p.Accounts.Run(time.Now())
+		if err := printAccountRun(*format, result); err != nil {
+			return err
+		}
+		if len(result.Failures) > 0 {
+			return fmt.Errorf("%d account requests failed", len(result.Failures))
+		}
+		return nil
+
+	case "erasures":
+		erasures, err := privacy.NewErasureLog(a.Config.ErasureLogPath)
+		if err != nil {
+			return err
+		}
+		proofs, err := erasures.List()
+		if err != nil {
+			return err
+		}
+		broken, err := erasures.Verify()
+		if err != nil {
+			return err
+		}
+		if err := printErasures(*format, proofs); err != nil {
+			return err
+		}
+		if broken >= 0 {
+			return fmt.Errorf("the erasure log was altered at proof %d (%s)", broken+1, proofs[broken].RequestID)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown accounts command %q", args[0])
+}
+
+// printAccountRequests writes account requests without their email address
+func printAccountRequests(format string, list []privacy.Request) error {
+	if format == formatJSON {
+		return printJSON(list)
+	}
+
+	rows := make([][]string, 0, len(list))
+	for _, req := range list {
+		due := ""
+		switch {
+		case req.DeleteAt != nil:
+			due = req.DeleteAt.Format(time.RFC3339)
+		case req.ExportExpiresAt != nil && req.ExportKey != "":
+			due = req.ExportExpiresAt.Format(time.RFC3339)
+		}
+		rows = append(rows, []string{req.ID, req.Database, req.Kind, req.Status, req.CreatedAt.Format(time.RFC3339), due, req.Error})
+	}
+	return printTable([]string{"ID", "DATABASE", "KIND", "STATUS", "REQUESTED", "DUE", "ERROR"}, rows)
+}
+
+// printAccountRun writes what a pass over the requests did, then its failures
+func printAccountRun(format string, result privacy.RunResult) error {
+	if format == formatJSON {
+		return printJSON(result)
+	}
+
+	rows := [][]string{}
+	for _, id := range result.Expired {
+		rows = append(rows, []string{id, "link expired", ""})
+	}
+	for _, id := range result.Removed {
+		rows = append(rows, []string{id, "export removed", ""})
+	}
+	for _, proof := range result.Erased {
+		rows = append(rows, []string{proof.RequestID, "erased " + proof.Database, ""})
+	}
+
+	ids := make([]string, 0, len(result.Failures))
+	for id := range result.Failures {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		rows = append(rows, []string{id, "failed", result.Failures[id]})
+	}
+	return printTable([]string{"REQUEST", "ACTION", "ERROR"}, rows)
+}
+
+// printErasures writes the proofs of erasure, oldest first
+func printErasures(format string, proofs []privacy.Proof) error {
+	if format == formatJSON {
+		return printJSON(proofs)
+	}
+
+	rows := make([][]string, 0, len(proofs))
+	for _, proof := range proofs {
+		rows = append(rows, []string{
+			proof.ErasedAt.Format(time.RFC3339),
+			proof.Database,
+			proof.RequestID,
+			strconv.Itoa(proof.Purged.Backups),
+			strconv.Itoa(proof.Purged.Jobs),
+			strconv.Itoa(proof.Purged.OutboxMessages),
+			proof.Hash[:16],
+		})
+	}
+	return printTable([]string{"ERASED", "DATABASE", "REQUEST", "BACKUPS", "JOBS", "OUTBOX", "HASH"}, rows)
+}
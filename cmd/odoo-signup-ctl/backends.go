@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"odoo-signup/internal/app"
+	"odoo-signup/internal/backends"
+	"odoo-signup/internal/tenants"
+)
+
+const backendsUsage = `Usage: odoo-signup-ctl backends <list|drain|undrain|release> [flags]
+
+  list                List the Odoo servers of BACKENDS_PATH with their tenant counts
+  drain -name NAME    Stop placing new tenants on a backend; its tenants keep running
+  undrain -name NAME  Place new tenants on a drained backend again
+  release             Drop the sources of migrations whose hold period ended
+`
+
+// runBackends lists and drains the Odoo servers tenants are placed on, and
+// drops migration sources
+func runBackends(a *app.App, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, backendsUsage)
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("backends "+args[0], flag.ExitOnError)
+	format := flags.String("o", formatTable, "output format: table or json")
+	name := flags.String("name", "", "backend name")
+	flags.Parse(args[1:])
+
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	var backend backends.Backend
+	var err error
+	switch args[0] {
+	case "list":
+		return printBackends(*format, a.Backends.List())
+	case "release":
+		return releaseHeld(a, *format)
+	case "drain", "undrain":
+		if *name == "" {
+			return fmt.Errorf("-name is required")
+		}
+		if args[0] == "drain" {
+			backend, err = a.Backends.Drain(*name, actor())
+		} else {
+			backend, err = a.Backends.Undrain(*name)
+		}
+	default:
+		return fmt.Errorf("unknown backends command %q", args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	if *format == formatJSON {
+		return printJSON(backend)
+	}
+	fmt.Printf("Backend %s draining: %t\n", backend.Name, backend.Draining)
+	return nil
+}
+
+// releaseHeld drops the migration sources due now and prints them
+func releaseHeld(a *app.App, format string) error {
+	manager := tenants.NewManager(a.Config, a.Backends, a.Registry, nil, nil, nil)
+	released, err := manager.ReleaseHeld(time.Now())
+	if format == formatJSON {
+		if released == nil {
+			released = []tenants.HeldCopy{}
+		}
+		if printErr := printJSON(released); printErr != nil {
+			return printErr
+		}
+		return err
+	}
+
+	rows := make([][]string, 0, len(released))
+	for _, held := range released {
+		rows = append(rows, []string{held.Database, held.Backend, held.JobID, held.Until.Format(time.RFC3339)})
+	}
+	if printErr := printTable([]string{"DATABASE", "BACKEND", "JOB", "HELD UNTIL"}, rows); printErr != nil {
+		return printErr
+	}
+	return err
+}
+
+// printBackends writes backends as a table or JSON
+func printBackends(format string, list []backends.Status) error {
+	if format == formatJSON {
+		return printJSON(list)
+	}
+
+	rows := make([][]string, 0, len(list))
+	for _, backend := range list {
+		capacity := "-"
+		if backend.Capacity > 0 {
+			capacity = fmt.Sprint(backend.Capacity)
+		}
+		state := "active"
+		if backend.Draining {
+			state = "draining"
+		}
+		rows = append(rows, []string{
+			backend.Name, backend.URL, state, fmt.Sprint(backend.Tenants), capacity,
+			backend.Region, strings.Join(backend.Plans, ","), strings.Join(backend.Templates, ","),
+		})
+	}
+	return printTable([]string{"NAME", "URL", "STATE", "TENANTS", "CAPACITY", "REGION", "PLANS", "TEMPLATES"}, rows)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"odoo-signup/internal/app"
+	"odoo-signup/internal/backups"
+	"odoo-signup/internal/cron"
+)
+
+const backupsUsage = `Usage: odoo-signup-ctl backups <run|list|prune|schedule> [flags]
+
+  run [-name NAME]     Back up one tenant, or every active and suspended tenant,
+                       to BACKUP_STORAGE and apply the retention policy
+  list -name NAME      List the stored backups of a tenant, newest first
+  prune [-name NAME]   Delete the backups the retention policy does not keep
+  schedule [-n N]      Show the next N times BACKUP_SCHEDULE fires
+`
+
+// runBackups runs and inspects backups outside the server's schedule
+func runBackups(a *app.App, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, backupsUsage)
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("backups "+args[0], flag.ExitOnError)
+	format := flags.String("o", formatTable, "output format: table or json")
+	name := flags.String("name", "", "tenant database")
+	count := flags.Int("n", 5, "number of upcoming runs to show")
+	flags.Parse(args[1:])
+
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	scheduler, err := a.NewScheduler(nil)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "run":
+		if *name == "" {
+			result := scheduler.RunAll()
+			if err := printBackupRun(*format, result); err != nil {
+				return err
+			}
+			if len(result.Failures) > 0 {
+				return fmt.Errorf("%d backups failed", len(result.Failures))
+			}
+			return nil
+		}
+
+		archive, pruned, err := scheduler.Run(*name)
+		if err != nil {
+			return err
+		}
+		return printBackupRun(*format, backups.RunResult{Archives: []backups.Archive{archive}, Pruned: pruned})
+
+	case "list":
+		if *name == "" {
+			return fmt.Errorf("-name is required")
+		}
+		archives, err := scheduler.List(*name)
+		if err != nil {
+			return err
+		}
+		return printArchives(*format, archives)
+
+	case "prune":
+		names := []string{*name}
+		if *name == "" {
+			names = names[:0]
+			for _, tenant := range a.Registry.List() {
+				names = append(names, tenant.Database)
+			}
+		}
+
+		var pruned []backups.Archive
+		for _, database := range names {
+			deleted, err := scheduler.Prune(database)
+			pruned = append(pruned, deleted...)
+			if err != nil {
+				return err
+			}
+		}
+		return printArchives(*format, pruned)
+
+	case "schedule":
+		if a.Config.BackupSchedule == "" {
+			return fmt.Errorf("BACKUP_SCHEDULE is not set, scheduled backups are disabled")
+		}
+		schedule, err := cron.Parse(a.Config.BackupSchedule, time.UTC)
+		if err != nil {
+			return err
+		}
+
+		runs := []time.Time{}
+		for next := schedule.Next(time.Now()); !next.IsZero() && len(runs) < *count; next = schedule.Next(next) {
+			runs = append(runs, next)
+		}
+		if *format == formatJSON {
+			return printJSON(map[string]interface{}{"schedule": schedule.String(), "next": runs})
+		}
+		rows := make([][]string, 0, len(runs))
+		for _, run := range runs {
+			rows = append(rows, []string{run.Format(time.RFC3339)})
+		}
+		return printTable([]string{"NEXT RUN (" + schedule.String() + ")"}, rows)
+	}
+
+	return fmt.Errorf("unknown backups command %q", args[0])
+}
+
+// printBackupRun writes the backups and prunes of a run, then its failures
+func printBackupRun(format string, result backups.RunResult) error {
+	if format == formatJSON {
+		return printJSON(result)
+	}
+
+	if err := printArchives(format, result.Archives); err != nil {
+		return err
+	}
+	if len(result.Pruned) > 0 {
+		fmt.Printf("\nPruned %d backups\n", len(result.Pruned))
+	}
+
+	databases := make([]string, 0, len(result.Failures))
+	for database := range result.Failures {
+		databases = append(databases, database)
+	}
+	sort.Strings(databases)
+	for _, database := range databases {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", database, result.Failures[database])
+	}
+	return nil
+}
+
+// printArchives writes stored backups
+func printArchives(format string, archives []backups.Archive) error {
+	if archives == nil {
+		archives = []backups.Archive{}
+	}
+	if format == formatJSON {
+		return printJSON(archives)
+	}
+
+	rows := make([][]string, 0, len(archives))
+	for _, archive := range archives {
+		rows = append(rows, []string{
+			archive.Database, archive.CreatedAt.Format(time.RFC3339), archive.Format,
+			fmt.Sprint(archive.Size), archive.Location,
+		})
+	}
+	return printTable([]string{"DATABASE", "CREATED", "FORMAT", "SIZE", "LOCATION"}, rows)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"odoo-signup/internal/app"
+	"odoo-signup/internal/domains"
+	"odoo-signup/internal/routing"
+	"odoo-signup/internal/tenants"
+)
+
+const domainsUsage = `Usage: odoo-signup-ctl domains <list|add|verify|primary|remove> [flags]
+
+  list [-name NAME]                  List the host to database mapping, or the custom
+                                     domains of a tenant with their challenges
+  add -name NAME -domain D           Attach a domain and print the TXT record to publish
+  verify -name NAME -domain D [-primary]
+                                     Look up the TXT record and route the domain to the
+                                     tenant; -primary also makes it the base URL
+  primary -name NAME [-domain D]     Set web.base.url to a verified domain, or back to
+                                     the instance URL without -domain
+  remove -name NAME -domain D        Detach a domain from the tenant
+`
+
+// runDomains manages the custom domains of tenants
+func runDomains(a *app.App, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, domainsUsage)
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("domains "+args[0], flag.ExitOnError)
+	format := flags.String("o", formatTable, "output format: table or json")
+	name := flags.String("name", "", "tenant database")
+	domain := flags.String("domain", "", "custom domain, such as erp.example.com")
+	primary := flags.Bool("primary", false, "make the verified domain the base URL")
+	flags.Parse(args[1:])
+
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	if args[0] == "list" {
+		if *name == "" {
+			return printRoutes(*format, routing.Table(a.Registry))
+		}
+		tenant, ok := a.Registry.Get(*name)
+		if !ok {
+			return tenants.ErrNotFound
+		}
+		return printDomains(*format, tenant.Domains)
+	}
+
+	if *name == "" {
+		return fmt.Errorf("-name is required")
+	}
+	if *domain == "" && args[0] != "primary" {
+		return fmt.Errorf("-domain is required")
+	}
+
+	// The pipeline publishes domain events, so the routing files are rewritten
+	p, err := newPipeline(a, 1)
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+
+	switch args[0] {
+	case "add":
+		added, challenge, err := p.Domains.Add(*name, *domain)
+		if err != nil {
+			return err
+		}
+		if *format == formatJSON {
+			return printJSON(map[string]interface{}{"domain": added, "challenge": challenge})
+		}
+		fmt.Printf("Publish this TXT record, then run 'domains verify -name %s -domain %s':\n\n", *name, added.Name)
+		return printTable([]string{"NAME", "TYPE", "VALUE"}, [][]string{{challenge.Name, "TXT", challenge.Value}})
+
+	case "verify":
+		verified, err := p.Domains.Verify(*name, *domain, actor(), *primary)
+		if err != nil {
+			return err
+		}
+		return printDomains(*format, []tenants.Domain{verified})
+
+	case "primary":
+		set, err := p.Domains.SetPrimary(*name, *domain)
+		if err != nil {
+			return err
+		}
+		if *domain == "" {
+			fmt.Println("Base URL set back to the instance URL")
+			return nil
+		}
+		return printDomains(*format, []tenants.Domain{set})
+
+	case "remove":
+		if err := p.Domains.Remove(*name, *domain, actor()); err != nil {
+			return err
+		}
+		fmt.Printf("Domain %s removed from %s\n", *domain, *name)
+		return nil
+	}
+
+	return fmt.Errorf("unknown domains command %q", args[0])
+}
+
+// printRoutes writes the host to database mapping
+func printRoutes(format string, routes []routing.Route) error {
+	if format == formatJSON {
+		return printJSON(routes)
+	}
+
+	rows := make([][]string, 0, len(routes))
+	for _, route := range routes {
+		rows = append(rows, []string{route.Host, route.Database, route.Status})
+	}
+	return printTable([]string{"HOST", "DATABASE", "STATUS"}, rows)
+}
+
+// printDomains writes custom domains with the challenge of unverified ones
+func printDomains(format string, list []tenants.Domain) error {
+	if format == formatJSON {
+		return printJSON(list)
+	}
+
+	rows := make([][]string, 0, len(list))
+	for _, domain := range list {
+		status, challenge := "pending", ""
+		if domain.Verified() {
+			status = "verified"
+		} else {
+			c := domains.ChallengeFor(domain)
+			challenge = c.Name + " TXT " + c.Value
+		}
+		primary := ""
+		if domain.Primary {
+			primary = "yes"
+		}
+		rows = append(rows, []string{domain.Name, status, primary, challenge})
+	}
+	return printTable([]string{"DOMAIN", "STATUS", "PRIMARY", "CHALLENGE"}, rows)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"odoo-signup/internal/app"
+	"odoo-signup/internal/imports"
+	"odoo-signup/internal/provisioning"
+)
+
+const importsUsage = `Usage: odoo-signup-ctl imports <run|list|show> [flags]
+
+  run -file PATH [-format csv|jsonl] [-concurrency N] [-mode create|clone]
+      [-skip-invalid] [-resume ID]
+                        Validate every row, then provision them as jobs; -resume
+                        continues an earlier import of the same file, skipping
+                        the rows it already created
+  list                  Show the imports, newest first
+  show -id ID           Show the result of every row of an import
+`
+
+// runImports provisions tenants in bulk and shows import reports
+func runImports(a *app.App, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, importsUsage)
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("imports "+args[0], flag.ExitOnError)
+	format := flags.String("o", formatTable, "output format: table or json")
+	id := flags.String("id", "", "import ID")
+	file := flags.String("file", "", "CSV or JSONL file of signup requests")
+	inputFormat := flags.String("format", "", "input format: csv or jsonl (default from the file extension)")
+	concurrency := flags.Int("concurrency", imports.DefaultConcurrency, "tenants provisioned at the same time")
+	mode := flags.String("mode", "", "database mode: create or clone (default DEFAULT_DB_MODE)")
+	skipInvalid := flags.Bool("skip-invalid", false, "provision the valid rows even when others are invalid")
+	resume := flags.String("resume", "", "ID of an earlier import of the same file to continue")
+	flags.Parse(args[1:])
+
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	store := imports.NewStore(a.Config.ImportsDir)
+
+	switch args[0] {
+	case "list":
+		list, err := store.List()
+		if err != nil {
+			return err
+		}
+		for i := range list {
+			list[i].Rows = nil
+		}
+		if *format == formatJSON {
+			return printJSON(list)
+		}
+
+		rows := make([][]string, 0, len(list))
+		for _, report := range list {
+			s := report.Summary
+			rows = append(rows, []string{
+				report.ID, report.Source, report.Status, fmt.Sprint(s.Total), fmt.Sprint(s.Created),
+				fmt.Sprint(s.Skipped), fmt.Sprint(s.Failed), fmt.Sprint(s.Invalid), report.CreatedAt.Format(time.RFC3339),
+			})
+		}
+		return printTable([]string{"ID", "SOURCE", "STATUS", "ROWS", "CREATED", "SKIPPED", "FAILED", "INVALID", "STARTED"}, rows)
+
+	case "show":
+		if *id == "" {
+			return fmt.Errorf("-id is required")
+		}
+		report, err := store.Get(*id)
+		if err != nil {
+			return err
+		}
+		return printImport(*format, report)
+
+	case "run":
+		if *file == "" {
+			return fmt.Errorf("-file is required")
+		}
+		if *mode == "" {
+			*mode = a.Config.DefaultDBMode
+		} else if *mode != provisioning.ModeCreate && *mode != provisioning.ModeClone {
+			return fmt.Errorf("unknown mode %q, use %s or %s", *mode, provisioning.ModeCreate, provisioning.ModeClone)
+		}
+		if *inputFormat == "" {
+			detected, err := imports.DetectFormat(*file)
+			if err != nil {
+				return err
+			}
+			*inputFormat = detected
+		}
+		if *concurrency < 1 {
+			return fmt.Errorf("-concurrency must be at least 1")
+		}
+		return runImport(a, *file, imports.Options{
+			Source:      filepath.Base(*file),
+			Format:      *inputFormat,
+			Actor:       actor(),
+			DbMode:      *mode,
+			Concurrency: *concurrency,
+			SkipInvalid: *skipInvalid,
+			Resume:      *resume,
+		}, *format)
+	}
+
+	return fmt.Errorf("unknown imports command %q", args[0])
+}
+
+// runImport parses, validates and provisions the rows of a file
+func runImport(a *app.App, path string, opts imports.Options, format string) error {
+	input, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	rows, err := imports.Parse(input, opts.Format)
+	input.Close()
+	if err != nil {
+		return err
+	}
+
+	p, err := newPipeline(a, opts.Concurrency)
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+
+	report, err := p.Importer.Prepare(rows, opts)
+	if errors.Is(err, imports.ErrInvalidRows) {
+		if printErr := printImport(format, report); printErr != nil {
+			return printErr
+		}
+		return fmt.Errorf("%w: %d of %d rows, fix them or pass -skip-invalid", err, report.Summary.Invalid, report.Summary.Total)
+	}
+	if err != nil {
+		return err
+	}
+
+	if format == formatTable {
+		fmt.Fprintf(os.Stderr, "Import %s: provisioning %d of %d rows\n", report.ID, report.Summary.Pending, report.Summary.Total)
+	}
+	p.Importer.Run(report, rows)
+
+	if err := printImport(format, report); err != nil {
+		return err
+	}
+	if report.Summary.Failed > 0 {
+		return fmt.Errorf("%d rows failed, run again with -resume %s to retry them", report.Summary.Failed, report.ID)
+	}
+	return nil
+}
+
+// printImport writes the result of every row of an import
+func printImport(format string, report *imports.Report) error {
+	if format == formatJSON {
+		return printJSON(report)
+	}
+
+	rows := make([][]string, 0, len(report.Rows))
+	for _, row := range report.Rows {
+		rows = append(rows, []string{fmt.Sprint(row.Line), row.Username, row.Email, row.Status, row.JobID, row.Reason})
+	}
+	if err := printTable([]string{"LINE", "USERNAME", "EMAIL", "STATUS", "JOB", "REASON"}, rows); err != nil {
+		return err
+	}
+
+	s := report.Summary
+	fmt.Printf("\nImport %s %s: %d created, %d skipped, %d failed, %d invalid, %d pending\n",
+		report.ID, report.Status, s.Created, s.Skipped, s.Failed, s.Invalid, s.Pending)
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"odoo-signup/internal/app"
+	"odoo-signup/internal/jobs"
+)
+
+const jobsUsage = `Usage: odoo-signup-ctl jobs <list|retry> [flags]
+
+  list [-tenant NAME] [-status STATUS] [-limit N]   Show the job history, newest first
+  retry -id ID                                      Run a failed job again and wait for it
+`
+
+// runJobs inspects and retries jobs
+func runJobs(a *app.App, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, jobsUsage)
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("jobs "+args[0], flag.ExitOnError)
+	format := flags.String("o", formatTable, "output format: table or json")
+	id := flags.String("id", "", "job ID")
+	tenant := flags.String("tenant", "", "only jobs of this tenant")
+	status := flags.String("status", "", "only jobs with this status")
+	limit := flags.Int("limit", 50, "maximum number of jobs")
+	flags.Parse(args[1:])
+
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		store, err := jobs.NewStore(a.Config.JobsPath)
+		if err != nil {
+			return fmt.Errorf("failed to load jobs: %w", err)
+		}
+		list, err := store.List(jobs.Filter{Tenant: *tenant, Status: *status, Limit: *limit})
+		if err != nil {
+			return err
+		}
+		return printJobs(*format, list)
+
+	case "retry":
+		if *id == "" {
+			return fmt.Errorf("-id is required")
+		}
+
+		p, err := newPipeline(a, 1)
+		if err != nil {
+			return err
+		}
+		defer p.Close()
+
+		job, done, err := p.Runner.Retry(*id)
+		if err != nil {
+			return err
+		}
+		jobErr := <-done
+
+		if job, err = p.Runner.Store().Get(job.ID); err != nil {
+			return err
+		}
+		if err := printJobs(*format, []jobs.Job{job}); err != nil {
+			return err
+		}
+		if jobErr != nil {
+			return fmt.Errorf("job %s failed: %w", job.ID, jobErr)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown jobs command %q", args[0])
+}
+
+// printJobs writes jobs without their sealed credentials
+func printJobs(format string, list []jobs.Job) error {
+	for i := range list {
+		list[i].Secret = ""
+	}
+	if format == formatJSON {
+		return printJSON(list)
+	}
+
+	rows := make([][]string, 0, len(list))
+	for _, job := range list {
+		rows = append(rows, []string{
+			job.ID, job.Kind, job.Tenant, job.Status, fmt.Sprint(job.Attempts),
+			job.CreatedAt.Format(time.RFC3339), job.Duration().Round(time.Millisecond).String(), job.Error,
+		})
+	}
+	return printTable([]string{"ID", "KIND", "TENANT", "STATUS", "ATTEMPTS", "CREATED", "DURATION", "ERROR"}, rows)
+}
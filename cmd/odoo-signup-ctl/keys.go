@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"odoo-signup/internal/apikeys"
+	"odoo-signup/internal/app"
+)
+
+const keysUsage = `Usage: odoo-signup-ctl keys <create|list|rotate|revoke> [flags]
+
+  create -name NAME -scopes SCOPE[,SCOPE]   Create a key and print its token once
+  list                                      List keys
+  rotate -id ID                             Replace the secret of a key
+  revoke -id ID                             Disable a key permanently
+
+Scopes: ` + "tenants:read, tenants:manage (includes tenants:read), config:manage\n"
+
+// runKeys manages admin API keys
+func runKeys(a *app.App, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, keysUsage)
+		os.Exit(2)
+	}
+
+	keys, err := apikeys.NewStore(a.Config.APIKeysPath)
+	if err != nil {
+		return fmt.Errorf("failed to load API keys: %w", err)
+	}
+
+	flags := flag.NewFlagSet("keys "+args[0], flag.ExitOnError)
+	format := flags.String("o", formatTable, "output format: table or json")
+	name := flags.String("name", "", "key name, e.g. the team or system using it")
+	scopes := flags.String("scopes", "", "comma-separated scopes")
+	id := flags.String("id", "", "key ID")
+	flags.Parse(args[1:])
+
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "create":
+		if *name == "" {
+			return fmt.Errorf("-name is required")
+		}
+		key, token, err := keys.Create(*name, splitList(*scopes))
+		if err != nil {
+			return err
+		}
+		return printToken(*format, key, token)
+
+	case "rotate":
+		if *id == "" {
+			return fmt.Errorf("-id is required")
+		}
+		key, token, err := keys.Rotate(*id)
+		if err != nil {
+			return err
+		}
+		return printToken(*format, key, token)
+
+	case "revoke":
+		if *id == "" {
+			return fmt.Errorf("-id is required")
+		}
+		key, err := keys.Revoke(*id)
+		if err != nil {
+			return err
+		}
+		return printKeys(*format, []apikeys.Key{key})
+
+	case "list":
+		list, err := keys.List()
+		if err != nil {
+			return err
+		}
+		return printKeys(*format, list)
+	}
+
+	return fmt.Errorf("unknown keys command %q", args[0])
+}
+
+// printToken shows a newly issued token
+func printToken(format string, key apikeys.Key, token string) error {
+	if format == formatJSON {
+		return printJSON(map[string]interface{}{
+			"id":     key.ID,
+			"name":   key.Name,
+			"scopes": key.Scopes,
+			"token":  token,
+		})
+	}
+
+	fmt.Printf("ID:     %s\nName:   %s\nScopes: %s\nToken:  %s\n\n", key.ID, key.Name, strings.Join(key.Scopes, ","), token)
+	fmt.Println("Store the token now, it cannot be shown again.")
+	return nil
+}
+
+// printKeys lists keys without their hashes
+func printKeys(format string, keys []apikeys.Key) error {
+	if format == formatJSON {
+		for i := range keys {
+			keys[i].Hash = ""
+		}
+		return printJSON(keys)
+	}
+
+	rows := make([][]string, 0, len(keys))
+	for _, key := range keys {
+		status := "active"
+		if key.RevokedAt != nil {
+			status = "revoked " + key.RevokedAt.Format(time.RFC3339)
+		}
+		rows = append(rows, []string{key.ID, key.Name, strings.Join(key.Scopes, ","), key.CreatedAt.Format(time.RFC3339), status})
+	}
+	return printTable([]string{"ID", "NAME", "SCOPES", "CREATED", "STATUS"}, rows)
+}
+
+// splitList splits a comma-separated flag value
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"odoo-signup/config"
+	"odoo-signup/internal/app"
+
+	"github.com/sirupsen/logrus"
+)
+
+const usage = `Usage: odoo-signup-ctl <command> [flags]
+
+Commands:
+  tenants      List, create, rename, drop, back up and restore tenants
+  domains      Attach, verify and remove custom domains of tenants
+  routing      Show and write the reverse proxy routing generated from the registry
+  templates    Check the template databases of every backend
+  backends     List, drain and undrain the Odoo servers tenants are placed on
+  backups      Run, list and prune scheduled tenant backups
+  trials       List, extend and enforce tenant trials
+  accounts     List owners' export and deletion requests, erase due tenants and verify proofs of erasure
+  imports      Provision tenants in bulk from CSV or JSONL and show import reports
+  jobs         List and retry provisioning jobs
+  reconcile    Compare the tenant registry with the database list of each backend
+  keys         Create, list, rotate and revoke admin API keys
+
+Every command accepts -o table|json.
+
+Run 'odoo-signup-ctl <command> -h' for the flags of a command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// Keep stdout clean for scripting
+	logrus.SetLevel(logrus.WarnLevel)
+
+	command, args := os.Args[1], os.Args[2:]
+
+	var run func(*app.App, []string) error
+	switch command {
+	case "tenants":
+		run = runTenants
+	case "domains":
+		run = runDomains
+	case "routing":
+		run = runRouting
+	case "templates":
+		run = runTemplates
+	case "backends":
+		run = runBackends
+	case "backups":
+		run = runBackups
+	case "trials":
+		run = runTrials
+	case "accounts":
+		run = runAccounts
+	case "imports":
+		run = runImports
+	case "jobs":
+		run = runJobs
+	case "keys":
+		run = runKeys
+	case "reconcile":
+		run = runReconcile
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+
+	a, err := newApp()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+
+	err = run(a, args)
+	a.Close()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+// newApp loads the server configuration and opens the tenant registry and the backends
+func newApp() (*app.App, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	return app.New(cfg)
+}
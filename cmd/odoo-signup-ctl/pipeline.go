@@ -0,0 +1,19 @@
+package main
+
+import (
+	"odoo-signup/internal/app"
+)
+
+// newPipeline rebuilds the server's provisioning stack in the CLI and starts
+// its runner, so tenants created or dropped here get the same registry records,
+// job history, webhooks, welcome email and CRM lead. Deliveries are queued in
+// the shared outbox and sent by the server. workers bounds the jobs the runner
+// executes at the same time.
+func newPipeline(a *app.App, workers int) (*app.Pipeline, error) {
+	p, err := a.NewPipeline(workers, nil)
+	if err != nil {
+		return nil, err
+	}
+	p.Runner.Start()
+	return p, nil
+}
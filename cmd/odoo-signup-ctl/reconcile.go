@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"odoo-signup/internal/app"
+	"odoo-signup/internal/tenants"
+)
+
+// runReconcile reports orphaned databases and registry drift. It exits with
+// status 3 when differences are found so it can drive alerts from cron.
+func runReconcile(a *app.App, args []string) error {
+	flags := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	format := flags.String("o", formatTable, "output format: table or json")
+	flags.Parse(args)
+
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	manager := tenants.NewManager(a.Config, a.Backends, a.Registry, nil, nil, nil)
+	report, err := manager.Reconcile()
+	if err != nil {
+		return err
+	}
+
+	if *format == formatJSON {
+		err = printJSON(report)
+	} else {
+		rows := make([][]string, 0, len(report.Findings))
+		for _, finding := range report.Findings {
+			rows = append(rows, []string{finding.Database, finding.Kind, finding.Backend, finding.Status, finding.Detail})
+		}
+		err = printTable([]string{"DATABASE", "KIND", "BACKEND", "STATUS", "DETAIL"}, rows)
+		if err == nil {
+			fmt.Printf("\n%d registered, %d databases, %d findings\n", report.Registered, report.Databases, len(report.Findings))
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(report.Findings) > 0 {
+		os.Exit(3)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"odoo-signup/internal/app"
+	"odoo-signup/internal/routing"
+)
+
+const routingUsage = `Usage: odoo-signup-ctl routing <show|write> [flags]
+
+  show -format nginx|caddy|traefik   Print the proxy configuration for the current registry;
+                                     -o does not apply
+  write                              Write the configured routing files and run
+                                     PROXY_RELOAD_COMMAND when one changed
+`
+
+// runRouting generates reverse proxy routing from the tenant registry
+func runRouting(a *app.App, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, routingUsage)
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("routing "+args[0], flag.ExitOnError)
+	output := flags.String("o", formatTable, "output format of write: table or json")
+	format := flags.String("format", a.Config.ProxyConfigFormat, "proxy format: nginx, caddy or traefik (default PROXY_CONFIG_FORMAT)")
+	flags.Parse(args[1:])
+
+	if err := checkFormat(*output); err != nil {
+		return err
+	}
+
+	writer, err := routing.NewWriter(a.Config, a.Registry, a.Backends)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "show":
+		if *format == "" {
+			return fmt.Errorf("-format is required")
+		}
+		data, err := writer.Render(*format)
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(data)
+		return err
+
+	case "write":
+		if !writer.Enabled() {
+			return fmt.Errorf("no routing file is configured, set PROXY_CONFIG_FORMAT and PROXY_CONFIG_PATH")
+		}
+		changed, err := writer.Write()
+		if err != nil {
+			return err
+		}
+		if *output == formatJSON {
+			return printJSON(map[string]bool{"changed": changed})
+		}
+		if changed {
+			fmt.Println("Routing files updated")
+		} else {
+			fmt.Println("Routing files are up to date")
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown routing command %q", args[0])
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"odoo-signup/internal/app"
+	"odoo-signup/internal/provisioning"
+	"odoo-signup/internal/trials"
+)
+
+// runTemplates checks the template databases each backend clones for new tenants. It exits
+// with status 3 when a check fails so it can gate deployments.
+func runTemplates(a *app.App, args []string) error {
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Fprint(os.Stderr, "Usage: odoo-signup-ctl templates check [-o table|json]\n")
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("templates check", flag.ExitOnError)
+	format := flags.String("o", formatTable, "output format: table or json")
+	flags.Parse(args[1:])
+
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	provisioner := provisioning.New(a.Config, a.Backends, nil, a.Registry, trials.Policy{}, nil)
+	checks := provisioner.CheckTemplate()
+
+	healthy := true
+	for _, check := range checks {
+		healthy = healthy && check.OK
+	}
+
+	var err error
+	if *format == formatJSON {
+		err = printJSON(map[string]interface{}{
+			"template": a.Config.TemplateDatabase,
+			"healthy":  healthy,
+			"checks":   checks,
+		})
+	} else {
+		rows := make([][]string, 0, len(checks))
+		for _, check := range checks {
+			result := "ok"
+			if !check.OK {
+				result = "FAIL"
+			}
+			rows = append(rows, []string{check.Backend, check.Template, check.Name, result, check.Detail})
+		}
+		err = printTable([]string{"BACKEND", "TEMPLATE", "CHECK", "RESULT", "DETAIL"}, rows)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !healthy {
+		os.Exit(3)
+	}
+	return nil
+}
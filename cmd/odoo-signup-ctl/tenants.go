@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"odoo-signup/internal/app"
+	"odoo-signup/internal/backups"
+	"odoo-signup/internal/jobs"
+	"odoo-signup/internal/migrations"
+	"odoo-signup/internal/models"
+	"odoo-signup/internal/provisioning"
+	"odoo-signup/internal/tenants"
+)
+
+const tenantsUsage = `Usage: odoo-signup-ctl tenants <list|create|suspend|resume|rename|migrate|drop|backup|restore> [flags]
+
+  list                               List registered tenants and unregistered databases
+  create -username NAME -email EMAIL -first-name F -last-name L -company C -country CC
+         [-password-stdin] [-mode create|clone] [-plan P] [-phone P] [-f request.json]
+                                     Provision a tenant like a signup; a password is
+                                     generated and printed when none is given
+  suspend -name NAME -reason TEXT    Archive every internal user except the service account
+  resume -name NAME                  Reactivate the users archived by the suspension
+  rename -name NAME -to NEW          Rename the database and move it to NEW.DOMAIN; backups
+                                     move with it, only the job history keeps the old name
+  migrate -name NAME -to BACKEND [-dry-run]
+                                     Move the tenant to another Odoo backend as a job; the
+                                     source is dropped after MIGRATION_HOLD_HOURS, -dry-run
+                                     only runs the checks
+  drop -name NAME -yes               Drop the tenant database
+  backup -name NAME                  Back up to BACKUP_STORAGE and apply the retention policy
+  restore -name NAME (-key KEY | -file PATH) [-replace] [-copy] [-neutralize]
+                                     Restore a backup from BACKUP_STORAGE or a local file as a
+                                     job; -replace snapshots and replaces an existing database,
+                                     -copy gives it a new UUID, -neutralize disables its mail
+                                     and crons
+`
+
+// runTenants runs tenant lifecycle operations
+func runTenants(a *app.App, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, tenantsUsage)
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("tenants "+args[0], flag.ExitOnError)
+	format := flags.String("o", formatTable, "output format: table or json")
+	name := flags.String("name", "", "tenant database")
+	yes := flags.Bool("yes", false, "confirm dropping the database")
+	reason := flags.String("reason", "", "why the tenant is suspended, such as non-payment or abuse")
+	to := flags.String("to", "", "new database name, or the backend to migrate to")
+	dryRun := flags.Bool("dry-run", false, "check the migration without suspending or copying the tenant")
+	file := flags.String("file", "", "backup file to restore")
+	key := flags.String("key", "", "backup in BACKUP_STORAGE to restore, as listed by backups list")
+	replace := flags.Bool("replace", false, "replace an existing database after a safety snapshot")
+	copyDB := flags.Bool("copy", false, "restore as a copy with a new database UUID")
+	neutralize := flags.Bool("neutralize", false, "disable outgoing mail, crons and payment providers of the restored database")
+
+	var req models.SignupRequest
+	requestFile := flags.String("f", "", "JSON signup request, in the format of POST /api/signup; flags override its fields")
+	passwordStdin := flags.Bool("password-stdin", false, "read the owner password from the first line of stdin")
+	mode := flags.String("mode", "", "database mode: create or clone (default DEFAULT_DB_MODE)")
+	flags.StringVar(&req.Username, "username", "", "tenant name, also the database name")
+	flags.StringVar(&req.Email, "email", "", "owner email, used as login")
+	flags.StringVar(&req.FirstName, "first-name", "", "owner first name")
+	flags.StringVar(&req.LastName, "last-name", "", "owner last name")
+	flags.StringVar(&req.CompanyName, "company", "", "company name")
+	flags.StringVar(&req.Country.Code, "country", "", "ISO country code")
+	flags.StringVar(&req.Phone, "phone", "", "owner phone number")
+	flags.StringVar(&req.Plan, "plan", "", "plan recorded for the tenant")
+	flags.StringVar(&req.Industry, "industry", "", "company industry")
+	flags.StringVar(&req.CompanySize, "company-size", "", "company size: 1-10, 11-50, 51-200, 201-1000 or 1000+")
+	flags.StringVar(&req.Timezone, "timezone", "", "IANA timezone (default derived from the country)")
+	flags.StringVar(&req.Currency, "currency", "", "ISO currency code (default derived from the country)")
+	flags.Parse(args[1:])
+
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	if args[0] == "list" {
+		manager := tenants.NewManager(a.Config, a.Backends, a.Registry, nil, nil, nil)
+		list, err := manager.List()
+		if err != nil {
+			return err
+		}
+		return printTenants(*format, list)
+	}
+
+	// One worker: a single tenant is provisioned at a time
+	p, err := newPipeline(a, 1)
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+
+	switch args[0] {
+	case "create":
+		if *requestFile != "" {
+			if err := mergeRequestFile(&req, *requestFile, flags); err != nil {
+				return err
+			}
+		}
+		if *mode == "" {
+			*mode = a.Config.DefaultDBMode
+		} else if *mode != provisioning.ModeCreate && *mode != provisioning.ModeClone {
+			return fmt.Errorf("unknown mode %q, use %s or %s", *mode, provisioning.ModeCreate, provisioning.ModeClone)
+		}
+		return createTenant(p, &req, *mode, *passwordStdin, *format)
+
+	case "suspend", "resume":
+		if *name == "" {
+			return fmt.Errorf("-name is required")
+		}
+		var tenant tenants.Tenant
+		var err error
+		if args[0] == "suspend" {
+			tenant, err = p.Manager.Suspend(*name, actor(), *reason)
+		} else {
+			tenant, err = p.Manager.Resume(*name, actor())
+		}
+		if errors.Is(err, tenants.ErrReasonRequired) {
+			return fmt.Errorf("-reason is required")
+		}
+		if err != nil {
+			return err
+		}
+		return printTenants(*format, []tenants.Tenant{tenant})
+
+	case "rename":
+		if *name == "" || *to == "" {
+			return fmt.Errorf("-name and -to are required")
+		}
+		tenant, err := p.Manager.Rename(*name, *to, actor())
+		if err != nil {
+			return err
+		}
+		return printTenants(*format, []tenants.Tenant{tenant})
+
+	case "drop":
+		if *name == "" {
+			return fmt.Errorf("-name is required")
+		}
+		if !*yes {
+			return fmt.Errorf("dropping %s deletes its data permanently, pass -yes to confirm", *name)
+		}
+		if err := p.Manager.Delete(*name); err != nil {
+			return err
+		}
+		if *format == formatJSON {
+			return printJSON(map[string]string{"database": *name, "status": "dropped"})
+		}
+		fmt.Printf("Dropped %s\n", *name)
+		return nil
+
+	case "backup":
+		if *name == "" {
+			return fmt.Errorf("-name is required")
+		}
+		if _, err := p.Manager.Lookup(*name); err != nil {
+			return err
+		}
+		archive, pruned, err := p.Backups.Run(*name)
+		if err != nil {
+			return err
+		}
+		return printBackupRun(*format, backups.RunResult{Archives: []backups.Archive{archive}, Pruned: pruned})
+
+	case "migrate":
+		if *name == "" || *to == "" {
+			return fmt.Errorf("-name and -to are required")
+		}
+
+		job, done, err := p.Migrator.Submit(migrations.Request{Database: *name, Target: *to, DryRun: *dryRun}, actor())
+		if err != nil {
+			return err
+		}
+		jobErr := <-done
+
+		if job, err = p.Runner.Store().Get(job.ID); err != nil {
+			return err
+		}
+		if err := printJobs(*format, []jobs.Job{job}); err != nil {
+			return err
+		}
+		if holdUntil := job.Result["holdUntil"]; holdUntil != "" && jobErr == nil && *format == formatTable {
+			fmt.Printf("\nThe copy on %s is dropped after %s\n", job.Result["source"], holdUntil)
+		}
+		if jobErr != nil {
+			return fmt.Errorf("job %s failed: %w", job.ID, jobErr)
+		}
+		return nil
+
+	case "restore":
+		if *name == "" {
+			return fmt.Errorf("-name is required")
+		}
+		if (*key == "") == (*file == "") {
+			return fmt.Errorf("one of -key or -file is required")
+		}
+
+		job, done, err := p.Restorer.Submit(backups.RestoreRequest{
+			Database:   *name,
+			Key:        *key,
+			Path:       *file,
+			Replace:    *replace,
+			Copy:       *copyDB,
+			Neutralize: *neutralize,
+		}, actor())
+		if errors.Is(err, tenants.ErrExists) {
+			return fmt.Errorf("%w, pass -replace to replace it", err)
+		}
+		if err != nil {
+			return err
+		}
+		jobErr := <-done
+
+		if job, err = p.Runner.Store().Get(job.ID); err != nil {
+			return err
+		}
+		if err := printJobs(*format, []jobs.Job{job}); err != nil {
+			return err
+		}
+		if snapshot := job.Result["snapshot"]; snapshot != "" && *format == formatTable {
+			fmt.Printf("\nThe replaced database was backed up to %s\n", snapshot)
+		}
+		if jobErr != nil {
+			return fmt.Errorf("job %s failed: %w", job.ID, jobErr)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown tenants command %q", args[0])
+}
+
+// createTenant validates a signup request and provisions it as a job
+func createTenant(p *app.Pipeline, req *models.SignupRequest, mode string, passwordStdin bool, format string) error {
+	generated := false
+	if passwordStdin {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("failed to read password from stdin: %w", err)
+		}
+		req.Password = strings.TrimRight(line, "\r\n")
+	} else if req.Password == "" {
+		password, err := tenants.GeneratePassword()
+		if err != nil {
+			return err
+		}
+		req.Password, generated = password, true
+	}
+
+	// The operator accepts the terms on the customer's behalf
+	req.Terms = true
+
+	if err := p.Provisioner.Validate(req); err != nil {
+		var validationErr *provisioning.ValidationError
+		if errors.As(err, &validationErr) {
+			for field, message := range validationErr.Fields {
+				err = fmt.Errorf("%w (%s: %s)", err, field, message)
+			}
+		}
+		return err
+	}
+
+	payload := provisioning.NewJobPayload(req, provisioning.Options{DbMode: mode})
+
+	job, done, err := p.Runner.Submit(provisioning.JobKind, req.Username, actor(), payload, req.Password)
+	if err != nil {
+		return err
+	}
+	if err := <-done; err != nil {
+		return fmt.Errorf("job %s failed: %w", job.ID, err)
+	}
+
+	data := p.Provisioner.Result(req)
+	if format == formatJSON {
+		result := map[string]string{
+			"job":         job.ID,
+			"database":    data.Database,
+			"instanceUrl": data.InstanceURL,
+			"email":       data.Email,
+		}
+		if generated {
+			result["password"] = req.Password
+		}
+		return printJSON(result)
+	}
+
+	fmt.Printf("Job:       %s\nDatabase:  %s\nInstance:  %s\nLogin:     %s\n", job.ID, data.Database, data.InstanceURL, data.Email)
+	if generated {
+		fmt.Printf("Password:  %s\n\nStore the password now, it is not kept.\n", req.Password)
+	}
+	return nil
+}
+
+// mergeRequestFile loads a JSON signup request; flags given on the command line take precedence
+func mergeRequestFile(req *models.SignupRequest, path string, flags *flag.FlagSet) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var fromFile models.SignupRequest
+	if err := json.Unmarshal(data, &fromFile); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	overrides := map[string]*string{
+		"username":     &fromFile.Username,
+		"email":        &fromFile.Email,
+		"first-name":   &fromFile.FirstName,
+		"last-name":    &fromFile.LastName,
+		"company":      &fromFile.CompanyName,
+		"country":      &fromFile.Country.Code,
+		"phone":        &fromFile.Phone,
+		"plan":         &fromFile.Plan,
+		"industry":     &fromFile.Industry,
+		"company-size": &fromFile.CompanySize,
+		"timezone":     &fromFile.Timezone,
+		"currency":     &fromFile.Currency,
+	}
+	for flagName, field := range overrides {
+		if set[flagName] {
+			*field = flags.Lookup(flagName).Value.String()
+		}
+	}
+
+	*req = fromFile
+	return nil
+}
+
+// printTenants writes tenants as a table or JSON
+func printTenants(format string, list []tenants.Tenant) error {
+	if format == formatJSON {
+		return printJSON(list)
+	}
+
+	rows := make([][]string, 0, len(list))
+	for _, tenant := range list {
+		created := ""
+		if !tenant.CreatedAt.IsZero() {
+			created = tenant.CreatedAt.Format(time.RFC3339)
+		}
+		rows = append(rows, []string{tenant.Database, tenant.Status, tenant.Backend, tenant.OwnerEmail, tenant.CompanyName, tenant.Plan, created})
+	}
+	return printTable([]string{"DATABASE", "STATUS", "BACKEND", "OWNER", "COMPANY", "PLAN", "CREATED"}, rows)
+}
+
+// actor identifies the CLI user in the job history
+func actor() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return "cli:" + u.Username
+	}
+	return "cli"
+}
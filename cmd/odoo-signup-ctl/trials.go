@@ -0,0 +1,147 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"odoo-signup/internal/app"
+	"odoo-signup/internal/tenants"
+	"odoo-signup/internal/trials"
+)
+
+const trialsUsage = `Usage: odoo-signup-ctl trials <list|run|extend|end> [flags]
+
+  list                       List tenants on a trial, ending soonest first
+  run                        Send due reminders, suspend expired trials and drop
+                             those past TRIAL_GRACE_DAYS, like the server does
+  extend -name NAME -days N  End the trial N days from now; resumes a tenant
+                             suspended because its trial expired
+  end -name NAME             End the trial and keep the tenant permanently
+`
+
+// runTrials inspects and enforces tenant trials
+func runTrials(a *app.App, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, trialsUsage)
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("trials "+args[0], flag.ExitOnError)
+	format := flags.String("o", formatTable, "output format: table or json")
+	name := flags.String("name", "", "tenant database")
+	days := flags.Int("days", 0, "days from now until the trial ends")
+	flags.Parse(args[1:])
+
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		list := []tenants.Tenant{}
+		for _, tenant := range a.Registry.List() {
+			if tenant.TrialEndsAt != nil {
+				list = append(list, tenant)
+			}
+		}
+		sort.SliceStable(list, func(i, j int) bool { return list[i].TrialEndsAt.Before(*list[j].TrialEndsAt) })
+		return printTrials(*format, list)
+
+	case "run":
+		p, err := newPipeline(a, 1)
+		if err != nil {
+			return err
+		}
+		defer p.Close()
+
+		result := p.Trials.Run(time.Now())
+		if err := printTrialRun(*format, result); err != nil {
+			return err
+		}
+		if len(result.Failures) > 0 {
+			return fmt.Errorf("%d trial checks failed", len(result.Failures))
+		}
+		return nil
+
+	case "extend", "end":
+		if *name == "" {
+			return fmt.Errorf("-name is required")
+		}
+		var endsAt *time.Time
+		if args[0] == "extend" {
+			if *days <= 0 {
+				return fmt.Errorf("-days must be positive")
+			}
+			end := time.Now().AddDate(0, 0, *days)
+			endsAt = &end
+		}
+
+		p, err := newPipeline(a, 1)
+		if err != nil {
+			return err
+		}
+		defer p.Close()
+
+		tenant, err := p.Manager.SetTrial(*name, actor(), endsAt)
+		if err != nil {
+			return err
+		}
+		return printTrials(*format, []tenants.Tenant{tenant})
+	}
+
+	return fmt.Errorf("unknown trials command %q", args[0])
+}
+
+// printTrials writes tenants with their trial end
+func printTrials(format string, list []tenants.Tenant) error {
+	if format == formatJSON {
+		return printJSON(list)
+	}
+
+	rows := make([][]string, 0, len(list))
+	for _, tenant := range list {
+		ends, reminder := "permanent", ""
+		if tenant.TrialEndsAt != nil {
+			ends = tenant.TrialEndsAt.Format(time.RFC3339)
+		}
+		if tenant.TrialReminder > 0 {
+			reminder = fmt.Sprintf("%d days before", tenant.TrialReminder)
+		}
+		rows = append(rows, []string{tenant.Database, tenant.Status, tenant.Plan, tenant.OwnerEmail, ends, reminder})
+	}
+	return printTable([]string{"DATABASE", "STATUS", "PLAN", "OWNER", "TRIAL ENDS", "LAST REMINDER"}, rows)
+}
+
+// printTrialRun writes what a trial check did, then its failures
+func printTrialRun(format string, result trials.RunResult) error {
+	if format == formatJSON {
+		return printJSON(result)
+	}
+
+	rows := [][]string{}
+	for _, outcome := range []struct {
+		action    string
+		databases []string
+	}{
+		{"reminded", result.Reminded},
+		{"suspended", result.Suspended},
+		{"deleted", result.Deleted},
+	} {
+		for _, database := range outcome.databases {
+			rows = append(rows, []string{database, outcome.action, ""})
+		}
+	}
+
+	databases := make([]string, 0, len(result.Failures))
+	for database := range result.Failures {
+		databases = append(databases, database)
+	}
+	sort.Strings(databases)
+	for _, database := range databases {
+		rows = append(rows, []string{database, "failed", result.Failures[database]})
+	}
+	return printTable([]string{"DATABASE", "ACTION", "ERROR"}, rows)
+}
@@ -1,12 +1,25 @@
 package main
 
 import (
+	"errors"
 	"net/http"
+	"strings"
+	"time"
+	_ "time/tzdata" // timezone validation must not depend on the host's zoneinfo
 
 	"odoo-signup/config"
+	"odoo-signup/internal/apikeys"
+	"odoo-signup/internal/app"
+	"odoo-signup/internal/audit"
+	"odoo-signup/internal/auth"
+	"odoo-signup/internal/cron"
 	"odoo-signup/internal/handlers"
-	"odoo-signup/internal/integration/odoo"
 	"odoo-signup/internal/middleware"
+	"odoo-signup/internal/migrations"
+	"odoo-signup/internal/oidc"
+	"odoo-signup/internal/privacy"
+	"odoo-signup/internal/routing"
+	"odoo-signup/internal/webhooks"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -29,17 +42,113 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	// Initialize Odoo client
-	odooClient := odoo.NewClient(cfg.OdooURL, cfg.OdooMasterPass, cfg.AdminUser, cfg.AdminPassword, cfg.TimeoutSeconds)
+	// Open the tenant registry and the Odoo servers tenants are placed on
+	application, err := app.New(cfg)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	defer application.Close()
+
+	// Initialize the provisioning stack; webhook attempts are logged for the admin API
+	deliveryLog, err := webhooks.NewDeliveryLog(cfg.WebhookLogPath)
+	if err != nil {
+		logrus.Fatal("Failed to load webhook delivery log:", err)
+	}
+	pipeline, err := application.NewPipeline(cfg.JobWorkers, deliveryLog)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	defer pipeline.Close()
+
+	// Deliver the outbox; the CLI only queues messages in it
+	pipeline.Outbox.Start()
+
+	// Write the proxy routing now as well, so the proxy finds its configuration before the first change
+	if pipeline.Routing.Enabled() {
+		if _, err := pipeline.Routing.Write(); errors.Is(err, routing.ErrReloadFailed) {
+			logrus.WithError(err).Warn("Proxy routing written, but the proxy was not reloaded")
+		} else if err != nil {
+			logrus.Fatal("Failed to write proxy routing:", err)
+		}
+	}
+
+	// Self-service exports and deletions are served when PUBLIC_URL is set
+	var accounts *privacy.Service
+	if cfg.PublicURL != "" {
+		accounts = pipeline.Accounts
+	}
+
+	// Restores, migrations and exports registered their job kinds, so jobs can resume
+	pipeline.Runner.Start()
+	if err := pipeline.Runner.Resume(); err != nil {
+		logrus.Fatal("Failed to resume jobs:", err)
+	}
+
+	// Start scheduled backups when BACKUP_SCHEDULE is set
+	if cfg.BackupSchedule != "" {
+		schedule, err := cron.Parse(cfg.BackupSchedule, time.UTC)
+		if err != nil {
+			logrus.Fatal("Invalid BACKUP_SCHEDULE:", err)
+		}
+		pipeline.Backups.Start(schedule)
+		defer pipeline.Backups.Stop()
+	}
+
+	// Remind, suspend and finally drop tenants whose trial ended
+	pipeline.Trials.Start(time.Duration(cfg.TrialCheckMinutes) * time.Minute)
+	defer pipeline.Trials.Stop()
+
+	// Drop the sources of migrations once their hold period ended
+	pipeline.Migrator.Start(migrations.ReleaseInterval)
+	defer pipeline.Migrator.Stop()
+
+	// Expire account request links and exports, and erase tenants after their cooling-off period
+	if accounts != nil {
+		accounts.Start(time.Duration(cfg.AccountCheckMinutes) * time.Minute)
+		defer accounts.Stop()
+	}
+
+	// Initialize admin API authentication
+	apiKeys, err := apikeys.NewStore(cfg.APIKeysPath)
+	if err != nil {
+		logrus.Fatal("Failed to load API keys:", err)
+	}
+	auditLog, err := audit.NewLog(cfg.AuditLogPath)
+	if err != nil {
+		logrus.Fatal("Failed to open audit log:", err)
+	}
+
+	// Initialize operator single sign-on (nil when OIDC is not configured)
+	var sso *auth.SSO
+	if cfg.OIDCIssuer != "" {
+		roleMapping, err := auth.ParseRoleMapping(cfg.OIDCRoleMapping)
+		if err != nil {
+			logrus.Fatal("Invalid OIDC_ROLE_MAPPING:", err)
+		}
+		sso = &auth.SSO{
+			Provider: oidc.NewProvider(oidc.Config{
+				Issuer:       cfg.OIDCIssuer,
+				ClientID:     cfg.OIDCClientID,
+				ClientSecret: cfg.OIDCClientSecret,
+				RedirectURL:  cfg.OIDCRedirectURL,
+				Scopes:       strings.Fields(cfg.OIDCScopes),
+				GroupsClaim:  cfg.OIDCGroupsClaim,
+			}, nil),
+			Sessions:     auth.NewSessions(time.Duration(cfg.SessionTTLMinutes) * time.Minute),
+			RoleMapping:  roleMapping,
+			CookieSecure: cfg.SessionCookieSecure,
+		}
+	}
 
 	// Initialize handlers
-	handler := handlers.NewHandler(cfg, odooClient)
+	handler := handlers.NewHandler(cfg, application.Backends, pipeline.Provisioner, pipeline.Dispatcher, pipeline.Manager, pipeline.Runner, pipeline.Importer, pipeline.Backups, pipeline.Restorer, pipeline.Migrator, accounts, pipeline.Domains, auditLog, sso)
 
 	// Create Gin router
 	r := gin.New()
 
 	// Load HTML templates
-	r.LoadHTMLFiles("./static/index.html")
+	r.SetFuncMap(handlers.TemplateFuncs())
+	r.LoadHTMLFiles(append([]string{"./static/index.html", "./static/account.html", "./static/maintenance.html"}, handlers.ConsoleTemplates...)...)
 
 	// Add middleware
 	r.Use(gin.Logger())
@@ -60,12 +169,106 @@ func main() {
 		})
 	})
 
+	// Maintenance page the proxy routes suspended tenants to
+	r.Any(routing.MaintenancePath, handler.HandleMaintenance)
+
 	// API routes
 	api := r.Group("/api")
 	api.Use(middleware.RateLimitMiddleware(limiter))
 	{
 		api.POST("/signup", handler.HandleSignup)
 		api.GET("/health", handler.HandleHealthCheck)
+		api.GET("/countries", handler.HandleCountries)
+		api.POST("/password/strength", handler.HandlePasswordStrength)
+		if accounts != nil {
+			api.POST("/account/requests", handler.HandleCreateAccountRequest)
+		}
+	}
+
+	// Self-service account pages, reached from emailed links
+	if accounts != nil {
+		account := r.Group("/account")
+		account.Use(middleware.RateLimitMiddleware(limiter))
+		{
+			account.GET("", handler.HandleAccountPage)
+			account.POST("", handler.HandleAccountForm)
+			account.GET("/confirm", handler.HandleAccountConfirmPage)
+			account.POST("/confirm", handler.HandleAccountConfirm)
+			account.GET("/export", handler.HandleAccountExport)
+		}
+	}
+
+	// Operator sign-on and admin console routes; the console needs a browser session
+	if sso != nil {
+		r.GET("/admin/login", handler.HandleLogin)
+		r.GET("/admin/callback", handler.HandleLoginCallback)
+
+		console := r.Group("/admin")
+		console.Use(middleware.ConsoleAuthMiddleware(sso, auditLog, handler.ConsoleDeny))
+		{
+			read := middleware.RequireScopeWith(apikeys.ScopeTenantsRead, handler.ConsoleDeny)
+			manage := middleware.RequireScopeWith(apikeys.ScopeTenantsManage, handler.ConsoleDeny)
+
+			console.GET("/", read, handler.HandleConsoleTenants)
+			console.GET("/tenants/:name", read, handler.HandleConsoleTenant)
+			console.POST("/tenants/:name/:action", manage, handler.HandleConsoleTenantAction)
+			console.GET("/jobs", read, handler.HandleConsoleJobs)
+			console.GET("/jobs/:id", read, handler.HandleConsoleJob)
+			console.POST("/jobs/:id/retry", manage, handler.HandleConsoleRetryJob)
+			console.POST("/logout", handler.HandleConsoleLogout)
+		}
+	}
+
+	// Admin API routes
+	admin := r.Group("/api/admin")
+	admin.Use(middleware.AdminAuthMiddleware(apiKeys, sso, auditLog))
+	{
+		admin.GET("/session", handler.HandleSession)
+		if sso != nil {
+			admin.POST("/logout", handler.HandleLogout)
+		}
+
+		read := middleware.RequireScope(apikeys.ScopeTenantsRead)
+		manage := middleware.RequireScope(apikeys.ScopeTenantsManage)
+		configure := middleware.RequireScope(apikeys.ScopeConfigManage)
+
+		admin.GET("/tenants", read, handler.HandleListTenants)
+		admin.GET("/reconcile", read, handler.HandleReconcileTenants)
+		admin.GET("/tenants/:name", read, handler.HandleGetTenant)
+		admin.DELETE("/tenants/:name", manage, handler.HandleDeleteTenant)
+		admin.POST("/tenants/:name/rename", manage, handler.HandleRenameTenant)
+		admin.GET("/tenants/:name/domains", read, handler.HandleListTenantDomains)
+		admin.POST("/tenants/:name/domains", manage, handler.HandleAddTenantDomain)
+		admin.POST("/tenants/:name/domains/:domain/verify", manage, handler.HandleVerifyTenantDomain)
+		admin.DELETE("/tenants/:name/domains/:domain", manage, handler.HandleRemoveTenantDomain)
+		admin.PUT("/tenants/:name/primary-domain", manage, handler.HandleSetPrimaryDomain)
+		admin.GET("/domains", read, handler.HandleListDomains)
+		admin.GET("/backends", read, handler.HandleListBackends)
+		admin.POST("/backends/:name/drain", configure, handler.HandleDrainBackend)
+		admin.POST("/backends/:name/undrain", configure, handler.HandleUndrainBackend)
+		admin.POST("/tenants/:name/suspend", manage, handler.HandleSuspendTenant)
+		admin.POST("/tenants/:name/resume", manage, handler.HandleResumeTenant)
+		admin.POST("/tenants/:name/reset-password", manage, handler.HandleResetTenantPassword)
+		admin.PUT("/tenants/:name/trial", manage, handler.HandleSetTenantTrial)
+		admin.POST("/tenants/:name/backups", manage, handler.HandleBackupTenant)
+		admin.GET("/tenants/:name/backups", read, handler.HandleListTenantBackups)
+		admin.POST("/tenants/:name/restore", manage, handler.HandleRestoreTenant)
+		admin.POST("/tenants/:name/migrate", manage, handler.HandleMigrateTenant)
+		admin.POST("/backups/run", manage, handler.HandleRunBackups)
+		admin.GET("/imports", read, handler.HandleListImports)
+		admin.GET("/imports/:id", read, handler.HandleGetImport)
+		admin.POST("/imports", manage, handler.HandleCreateImport)
+		admin.GET("/jobs", read, handler.HandleListJobs)
+		admin.GET("/jobs/:id", read, handler.HandleGetJob)
+		admin.POST("/jobs/:id/retry", manage, handler.HandleRetryJob)
+
+		admin.GET("/webhooks/deliveries", configure, handler.HandleWebhookDeliveries)
+		admin.GET("/metrics", configure, gin.WrapH(pipeline.Metrics))
+		admin.GET("/audit", configure, handler.HandleAuditLog)
+		if accounts != nil {
+			admin.GET("/account-requests", read, handler.HandleListAccountRequests)
+			admin.GET("/erasures", configure, handler.HandleErasures)
+		}
 	}
 
 	// Start server
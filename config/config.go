@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 
 	"odoo-signup/internal/models"
 
@@ -19,16 +20,78 @@ func Load() (*models.Config, error) {
 	}
 
 	config := &models.Config{
-		Port:             getEnv("PORT", "8080"),
-		OdooURL:          getEnv("ODOO_URL", "http://localhost:8069"),
-		OdooMasterPass:   getEnv("ODOO_MASTER_PASSWORD", ""),
-		OdooCompany:      getEnv("ODOO_COMPANY", "Sample"),
-		Environment:      getEnv("ENVIRONMENT", "development"),
-		Domain:           getEnv("DOMAIN", "odoo.the9o.com"),
-		TemplateDatabase: getEnv("TEMPLATE_DATABASE", "odoo-template"),
-		AdminUser:        getEnv("ADMIN_USER", "admin"),
-		AdminPassword:    getEnv("ADMIN_PASSWORD", "admin"),
-		LogLevel:         getEnv("LOG_LEVEL", "info"),
+		Port:                getEnv("PORT", "8080"),
+		OdooURL:             getEnv("ODOO_URL", "http://localhost:8069"),
+		OdooMasterPass:      getEnv("ODOO_MASTER_PASSWORD", ""),
+		BackendsPath:        getEnv("BACKENDS_PATH", "./data/backends.json"),
+		PlacementStrategy:   getEnv("PLACEMENT_STRATEGY", "least"),
+		PlacementRegions:    getEnv("PLACEMENT_REGIONS", ""),
+		OdooCompany:         getEnv("ODOO_COMPANY", "Sample"),
+		Environment:         getEnv("ENVIRONMENT", "development"),
+		Domain:              getEnv("DOMAIN", "odoo.the9o.com"),
+		TemplateDatabase:    getEnv("TEMPLATE_DATABASE", "odoo-template"),
+		AdminUser:           getEnv("ADMIN_USER", "admin"),
+		AdminPassword:       getEnv("ADMIN_PASSWORD", "admin"),
+		LogLevel:            getEnv("LOG_LEVEL", "info"),
+		CompanySizeField:    getEnv("COMPANY_SIZE_FIELD", ""),
+		OperatorURL:         getEnv("OPERATOR_ODOO_URL", ""),
+		OperatorDatabase:    getEnv("OPERATOR_DATABASE", ""),
+		OperatorUser:        getEnv("OPERATOR_USER", ""),
+		OperatorPassword:    getEnv("OPERATOR_PASSWORD", ""),
+		OutboxPath:          getEnv("OUTBOX_PATH", "./data/outbox.json"),
+		WebhookEndpoints:    getEnv("WEBHOOK_ENDPOINTS", ""),
+		WebhookSecret:       getEnv("WEBHOOK_SECRET", ""),
+		WebhookLogPath:      getEnv("WEBHOOK_LOG_PATH", "./data/webhook-deliveries.json"),
+		APIKeysPath:         getEnv("API_KEYS_PATH", "./data/api-keys.json"),
+		AuditLogPath:        getEnv("AUDIT_LOG_PATH", "./data/audit.log"),
+		OIDCIssuer:          getEnv("OIDC_ISSUER", ""),
+		OIDCClientID:        getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:    getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:     getEnv("OIDC_REDIRECT_URL", ""),
+		OIDCScopes:          getEnv("OIDC_SCOPES", "openid email profile"),
+		OIDCGroupsClaim:     getEnv("OIDC_GROUPS_CLAIM", "groups"),
+		OIDCRoleMapping:     getEnv("OIDC_ROLE_MAPPING", ""),
+		SMTPHost:            getEnv("SMTP_HOST", ""),
+		SMTPPort:            getEnv("SMTP_PORT", "587"),
+		SMTPUsername:        getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:        getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:            getEnv("SMTP_FROM", ""),
+		TenantRegistryPath:  getEnv("TENANT_REGISTRY_PATH", "./data/tenants.db"),
+		MaintenanceMapPath:  getEnv("MAINTENANCE_MAP_PATH", ""),
+		DomainMapPath:       getEnv("DOMAIN_MAP_PATH", ""),
+		DNSResolver:         getEnv("DNS_RESOLVER", ""),
+		DNSProvider:         getEnv("DNS_PROVIDER", ""),
+		DNSRecordType:       strings.ToUpper(getEnv("DNS_RECORD_TYPE", "A")),
+		DNSRecordValue:      getEnv("DNS_RECORD_VALUE", ""),
+		DNSUpdateServer:     getEnv("DNS_RFC2136_SERVER", ""),
+		DNSZone:             getEnv("DNS_RFC2136_ZONE", ""),
+		DNSTSIGKey:          getEnv("DNS_RFC2136_TSIG_KEY", ""),
+		DNSTSIGSecret:       getEnv("DNS_RFC2136_TSIG_SECRET", ""),
+		DNSTSIGAlgorithm:    getEnv("DNS_RFC2136_TSIG_ALGORITHM", "hmac-sha256"),
+		DNSHTTPURL:          getEnv("DNS_HTTP_URL", ""),
+		DNSHTTPToken:        getEnv("DNS_HTTP_TOKEN", ""),
+		ProxyConfigFormat:   getEnv("PROXY_CONFIG_FORMAT", ""),
+		ProxyConfigPath:     getEnv("PROXY_CONFIG_PATH", ""),
+		ProxyCertResolver:   getEnv("PROXY_CERT_RESOLVER", ""),
+		ProxyReloadCommand:  getEnv("PROXY_RELOAD_COMMAND", ""),
+		BackupDir:           getEnv("BACKUP_DIR", "./data/backups"),
+		BackupSchedule:      getEnv("BACKUP_SCHEDULE", ""),
+		BackupFormat:        getEnv("BACKUP_FORMAT", "zip"),
+		BackupStorage:       getEnv("BACKUP_STORAGE", "local"),
+		BackupS3Endpoint:    getEnv("BACKUP_S3_ENDPOINT", ""),
+		BackupS3Region:      getEnv("BACKUP_S3_REGION", "us-east-1"),
+		BackupS3Bucket:      getEnv("BACKUP_S3_BUCKET", ""),
+		BackupS3AccessKey:   getEnv("BACKUP_S3_ACCESS_KEY", ""),
+		BackupS3SecretKey:   getEnv("BACKUP_S3_SECRET_KEY", ""),
+		BackupS3Prefix:      getEnv("BACKUP_S3_PREFIX", ""),
+		JobsPath:            getEnv("JOBS_PATH", "./data/jobs.json"),
+		JobSecret:           getEnv("JOB_SECRET", ""),
+		ImportsDir:          getEnv("IMPORTS_DIR", "./data/imports"),
+		TrialPlans:          getEnv("TRIAL_PLANS", ""),
+		TrialReminderDays:   getEnv("TRIAL_REMINDER_DAYS", "7,3,1"),
+		PublicURL:           strings.TrimSuffix(getEnv("PUBLIC_URL", ""), "/"),
+		AccountRequestsPath: getEnv("ACCOUNT_REQUESTS_PATH", "./data/account-requests.json"),
+		ErasureLogPath:      getEnv("ERASURE_LOG_PATH", "./data/erasures.log"),
 	}
 
 	// Parse rate limiting
@@ -55,11 +118,183 @@ func Load() (*models.Config, error) {
 		config.TimeoutSeconds = 300 // Default 5 minutes
 	}
 
+	// Parse country cache TTL
+	if ttl, err := strconv.Atoi(getEnv("COUNTRY_CACHE_TTL_SECONDS", "3600")); err == nil {
+		config.CountryCacheTTLSeconds = ttl
+	} else {
+		config.CountryCacheTTLSeconds = 3600
+	}
+
+	// Parse password policy
+	if minLength, err := strconv.Atoi(getEnv("PASSWORD_MIN_LENGTH", "8")); err == nil {
+		config.PasswordMinLength = minLength
+	} else {
+		config.PasswordMinLength = 8
+	}
+
+	if minScore, err := strconv.Atoi(getEnv("PASSWORD_MIN_SCORE", "3")); err == nil && minScore >= 0 && minScore <= 4 {
+		config.PasswordMinScore = minScore
+	} else {
+		config.PasswordMinScore = 3
+	}
+
+	// Parse outbox retry interval
+	if interval, err := strconv.Atoi(getEnv("OUTBOX_INTERVAL_SECONDS", "30")); err == nil && interval > 0 {
+		config.OutboxIntervalSeconds = interval
+	} else {
+		config.OutboxIntervalSeconds = 30
+	}
+
+	// Parse event bus workers
+	if workers, err := strconv.Atoi(getEnv("EVENT_BUS_WORKERS", "4")); err == nil && workers > 0 {
+		config.EventBusWorkers = workers
+	} else {
+		config.EventBusWorkers = 4
+	}
+
+	// Parse job workers
+	if workers, err := strconv.Atoi(getEnv("JOB_WORKERS", "4")); err == nil && workers > 0 {
+		config.JobWorkers = workers
+	} else {
+		config.JobWorkers = 4
+	}
+
+	// Parse backup retention; setting all three to 0 keeps every backup
+	if daily, err := strconv.Atoi(getEnv("BACKUP_KEEP_DAILY", "7")); err == nil && daily >= 0 {
+		config.BackupKeepDaily = daily
+	} else {
+		config.BackupKeepDaily = 7
+	}
+
+	if weekly, err := strconv.Atoi(getEnv("BACKUP_KEEP_WEEKLY", "4")); err == nil && weekly >= 0 {
+		config.BackupKeepWeekly = weekly
+	} else {
+		config.BackupKeepWeekly = 4
+	}
+
+	if monthly, err := strconv.Atoi(getEnv("BACKUP_KEEP_MONTHLY", "6")); err == nil && monthly >= 0 {
+		config.BackupKeepMonthly = monthly
+	} else {
+		config.BackupKeepMonthly = 6
+	}
+
+	// Parse trial periods
+	if days, err := strconv.Atoi(getEnv("TRIAL_DAYS", "0")); err == nil && days >= 0 {
+		config.TrialDays = days
+	} else {
+		config.TrialDays = 0
+	}
+
+	if grace, err := strconv.Atoi(getEnv("TRIAL_GRACE_DAYS", "14")); err == nil && grace >= 0 {
+		config.TrialGraceDays = grace
+	} else {
+		config.TrialGraceDays = 14
+	}
+
+	if interval, err := strconv.Atoi(getEnv("TRIAL_CHECK_MINUTES", "60")); err == nil && interval > 0 {
+		config.TrialCheckMinutes = interval
+	} else {
+		config.TrialCheckMinutes = 60
+	}
+
+	// Parse DNS record settings
+	if ttl, err := strconv.Atoi(getEnv("DNS_RECORD_TTL", "300")); err == nil && ttl > 0 {
+		config.DNSRecordTTL = ttl
+	} else {
+		config.DNSRecordTTL = 300
+	}
+
+	if timeout, err := strconv.Atoi(getEnv("DNS_PROPAGATION_TIMEOUT_SECONDS", "120")); err == nil && timeout >= 0 {
+		config.DNSPropagationTimeout = timeout
+	} else {
+		config.DNSPropagationTimeout = 120
+	}
+
+	if config.DNSZone == "" {
+		config.DNSZone = config.Domain
+	}
+
+	// Parse tenant migrations
+	if hours, err := strconv.Atoi(getEnv("MIGRATION_HOLD_HOURS", "72")); err == nil && hours >= 0 {
+		config.MigrationHoldHours = hours
+	} else {
+		config.MigrationHoldHours = 72
+	}
+
+	// Parse self-service account requests
+	if hours, err := strconv.Atoi(getEnv("ACCOUNT_LINK_HOURS", "24")); err == nil && hours > 0 {
+		config.AccountLinkHours = hours
+	} else {
+		config.AccountLinkHours = 24
+	}
+
+	if days, err := strconv.Atoi(getEnv("DELETION_COOLING_OFF_DAYS", "14")); err == nil && days >= 0 {
+		config.DeletionCoolingOffDays = days
+	} else {
+		config.DeletionCoolingOffDays = 14
+	}
+
+	if hours, err := strconv.Atoi(getEnv("EXPORT_RETENTION_HOURS", "72")); err == nil && hours > 0 {
+		config.ExportRetentionHours = hours
+	} else {
+		config.ExportRetentionHours = 72
+	}
+
+	if interval, err := strconv.Atoi(getEnv("ACCOUNT_CHECK_MINUTES", "15")); err == nil && interval > 0 {
+		config.AccountCheckMinutes = interval
+	} else {
+		config.AccountCheckMinutes = 15
+	}
+
+	// The proxy reaches Odoo and this service at their own addresses unless told otherwise
+	config.ProxyOdooUpstream = getEnv("PROXY_ODOO_UPSTREAM", config.OdooURL)
+	config.ProxyMaintenanceUpstream = getEnv("PROXY_MAINTENANCE_UPSTREAM", "http://localhost:"+config.Port)
+
+	// Parse operator session settings
+	if ttl, err := strconv.Atoi(getEnv("SESSION_TTL_MINUTES", "480")); err == nil && ttl > 0 {
+		config.SessionTTLMinutes = ttl
+	} else {
+		config.SessionTTLMinutes = 480
+	}
+
+	if secure, err := strconv.ParseBool(getEnv("SESSION_COOKIE_SECURE", "true")); err == nil {
+		config.SessionCookieSecure = secure
+	} else {
+		config.SessionCookieSecure = true
+	}
+
+	// Parse localization configuration
+	if installLocalization, err := strconv.ParseBool(getEnv("INSTALL_FISCAL_LOCALIZATION", "true")); err == nil {
+		config.InstallLocalization = installLocalization
+	} else {
+		config.InstallLocalization = true
+	}
+
 	// Validate required configuration
 	if config.OdooMasterPass == "" {
 		logrus.Fatal("ODOO_MASTER_PASSWORD environment variable is required")
 	}
 
+	if config.WebhookEndpoints != "" && config.WebhookSecret == "" {
+		logrus.Fatal("WEBHOOK_SECRET environment variable is required when WEBHOOK_ENDPOINTS is set")
+	}
+
+	if config.OIDCIssuer != "" && (config.OIDCClientID == "" || config.OIDCRedirectURL == "" || config.OIDCRoleMapping == "") {
+		logrus.Fatal("OIDC_CLIENT_ID, OIDC_REDIRECT_URL and OIDC_ROLE_MAPPING are required when OIDC_ISSUER is set")
+	}
+
+	if config.BackupFormat != "zip" && config.BackupFormat != "dump" {
+		logrus.Fatal("BACKUP_FORMAT must be zip or dump")
+	}
+
+	if config.PublicURL != "" && config.SMTPHost == "" {
+		logrus.Fatal("SMTP_HOST environment variable is required when PUBLIC_URL is set, to email account request links")
+	}
+
+	if config.SMTPHost != "" && config.SMTPFrom == "" {
+		logrus.Fatal("SMTP_FROM environment variable is required when SMTP_HOST is set")
+	}
+
 	return config, nil
 }
 
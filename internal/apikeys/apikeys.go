@@ -0,0 +1,315 @@
+package apikeys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"odoo-signup/internal/store"
+	"odoo-signup/internal/util"
+)
+
+// Scopes granted to API keys
+const (
+	ScopeTenantsRead   = "tenants:read"   // List and inspect tenants
+	ScopeTenantsManage = "tenants:manage" // Lifecycle operations on tenants, implies tenants:read
+	ScopeConfigManage  = "config:manage"  // Webhooks, metrics and the audit trail
+)
+
+// Scopes lists every valid scope
+var Scopes = []string{ScopeTenantsRead, ScopeTenantsManage, ScopeConfigManage}
+
+// implied maps a scope to the scopes it includes
+var implied = map[string][]string{
+	ScopeTenantsManage: {ScopeTenantsRead},
+}
+
+// tokenPrefix marks API keys so they are easy to recognize in secret scanners
+const tokenPrefix = "osk"
+
+// Errors returned by the key store
+var (
+	ErrInvalidKey   = errors.New("invalid API key")
+	ErrNotFound     = errors.New("API key not found")
+	ErrRevoked      = errors.New("API key is revoked")
+	ErrInvalidScope = errors.New("invalid scope")
+)
+
+// Key is a stored API key. Only the SHA-256 hash of the secret is kept.
+type Key struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Hash      string     `json:"hash"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"createdAt"`
+	RotatedAt *time.Time `json:"rotatedAt,omitempty"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+// HasScope reports whether the key grants a scope directly or through an implied scope
+func (k Key) HasScope(scope string) bool {
+	return Allows(k.Scopes, scope)
+}
+
+// Allows reports whether a set of granted scopes includes scope
+func Allows(granted []string, scope string) bool {
+	for _, g := range granted {
+		if g == scope {
+			return true
+		}
+		for _, included := range implied[g] {
+			if included == scope {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Store keeps API keys in a JSON file. Keys are managed by the CLI while the
+// server only authenticates, so the file is reloaded whenever it changes.
+type Store struct {
+	mu      sync.Mutex
+	file    *store.File
+	keys    map[string]Key
+	modTime time.Time
+}
+
+// NewStore loads the keys persisted at path
+func NewStore(path string) (*Store, error) {
+	s := &Store{
+		file: store.NewFile(path),
+		keys: make(map[string]Key),
+	}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Create adds a key and returns it with its token. The token is not stored and cannot be shown again.
+func (s *Store) Create(name string, scopes []string) (Key, string, error) {
+	if err := ValidateScopes(scopes); err != nil {
+		return Key{}, "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return Key{}, "", err
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return Key{}, "", err
+	}
+
+	key := Key{
+		ID:        util.NewID()[:12],
+		Name:      name,
+		Hash:      hashSecret(secret),
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	s.keys[key.ID] = key
+	if err := s.save(); err != nil {
+		return Key{}, "", err
+	}
+
+	return key, formatToken(key.ID, secret), nil
+}
+
+// Rotate replaces the secret of a key. The previous token stops working immediately.
+func (s *Store) Rotate(id string) (Key, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return Key{}, "", err
+	}
+
+	key, ok := s.keys[id]
+	if !ok {
+		return Key{}, "", ErrNotFound
+	}
+	if key.RevokedAt != nil {
+		return Key{}, "", ErrRevoked
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return Key{}, "", err
+	}
+
+	now := time.Now().UTC()
+	key.Hash = hashSecret(secret)
+	key.RotatedAt = &now
+
+	s.keys[id] = key
+	if err := s.save(); err != nil {
+		return Key{}, "", err
+	}
+
+	return key, formatToken(key.ID, secret), nil
+}
+
+// Revoke disables a key permanently. Revoked keys are kept for the audit trail.
+func (s *Store) Revoke(id string) (Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return Key{}, err
+	}
+
+	key, ok := s.keys[id]
+	if !ok {
+		return Key{}, ErrNotFound
+	}
+	if key.RevokedAt != nil {
+		return key, nil
+	}
+
+	now := time.Now().UTC()
+	key.RevokedAt = &now
+
+	s.keys[id] = key
+	return key, s.save()
+}
+
+// List returns all keys ordered by creation time
+func (s *Store) List() ([]Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+
+	list := make([]Key, 0, len(s.keys))
+	for _, key := range s.keys {
+		list = append(list, key)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+	return list, nil
+}
+
+// Authenticate returns the active key matching a token
+func (s *Store) Authenticate(token string) (Key, error) {
+	id, secret, ok := parseToken(token)
+	if !ok {
+		return Key{}, ErrInvalidKey
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return Key{}, err
+	}
+
+	key, ok := s.keys[id]
+	if !ok || subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hashSecret(secret))) != 1 {
+		return Key{}, ErrInvalidKey
+	}
+	if key.RevokedAt != nil {
+		return Key{}, ErrRevoked
+	}
+
+	return key, nil
+}
+
+// ValidateScopes checks that every scope is known
+func ValidateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("%w: at least one scope is required", ErrInvalidScope)
+	}
+	for _, scope := range scopes {
+		known := false
+		for _, valid := range Scopes {
+			if scope == valid {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+		}
+	}
+	return nil
+}
+
+// reload reads the file again when it changed since the last load
+func (s *Store) reload() error {
+	modTime, err := s.file.ModTime()
+	if err != nil {
+		return err
+	}
+	if !modTime.IsZero() && modTime.Equal(s.modTime) {
+		return nil
+	}
+
+	keys := make(map[string]Key)
+	if err := s.file.Load(&keys); err != nil {
+		return err
+	}
+
+	s.keys = keys
+	s.modTime = modTime
+	return nil
+}
+
+// save writes the keys and remembers the new modification time
+func (s *Store) save() error {
+	if err := s.file.Save(s.keys); err != nil {
+		return err
+	}
+
+	modTime, err := s.file.ModTime()
+	if err != nil {
+		return err
+	}
+	s.modTime = modTime
+	return nil
+}
+
+// newSecret returns 256 random bits
+func newSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashSecret hashes a secret for storage. Secrets are random, so a fast hash is sufficient.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// formatToken builds the token handed to the key owner: osk_<id>_<secret>
+func formatToken(id, secret string) string {
+	return tokenPrefix + "_" + id + "_" + secret
+}
+
+// parseToken splits a token into key ID and secret
+func parseToken(token string) (string, string, bool) {
+	parts := strings.SplitN(token, "_", 3)
+	if len(parts) != 3 || parts[0] != tokenPrefix || parts[1] == "" || parts[2] == "" {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
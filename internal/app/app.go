@@ -0,0 +1,60 @@
+// Package app wires the services the server and the CLI share, so tenants are
+// provisioned, backed up and managed the same way from both
+package app
+
+import (
+	"fmt"
+
+	"odoo-signup/internal/backends"
+	"odoo-signup/internal/backups"
+	"odoo-signup/internal/metrics"
+	"odoo-signup/internal/models"
+	"odoo-signup/internal/tenants"
+)
+
+// App holds the configuration with the tenant registry and the backends,
+// which every command needs
+type App struct {
+	Config   *models.Config
+	Registry *tenants.SQLiteStore
+	Backends *backends.Pool
+}
+
+// New opens the tenant registry and loads the backends
+func New(cfg *models.Config) (*App, error) {
+	registry, err := tenants.NewSQLiteStore(cfg.TenantRegistryPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tenant registry: %w", err)
+	}
+
+	pool, err := backends.NewPool(cfg, registry)
+	if err != nil {
+		registry.Close()
+		return nil, fmt.Errorf("failed to load backends: %w", err)
+	}
+
+	return &App{
+		Config:   cfg,
+		Registry: registry,
+		Backends: pool,
+	}, nil
+}
+
+// Close closes the tenant registry
+func (a *App) Close() error {
+	return a.Registry.Close()
+}
+
+// NewScheduler creates the backup scheduler over the configured storage;
+// metricsRegistry may be nil
+func (a *App) NewScheduler(metricsRegistry *metrics.Registry) (*backups.Scheduler, error) {
+	storage, err := backups.NewStorage(a.Config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup storage: %w", err)
+	}
+	return backups.NewScheduler(a.Backends, a.Registry, storage, a.Config.BackupFormat, backups.Retention{
+		Daily:   a.Config.BackupKeepDaily,
+		Weekly:  a.Config.BackupKeepWeekly,
+		Monthly: a.Config.BackupKeepMonthly,
+	}, metricsRegistry), nil
+}
@@ -0,0 +1,154 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"odoo-signup/internal/backups"
+	"odoo-signup/internal/dns"
+	"odoo-signup/internal/domains"
+	"odoo-signup/internal/events"
+	"odoo-signup/internal/imports"
+	"odoo-signup/internal/integration/operator"
+	"odoo-signup/internal/jobs"
+	"odoo-signup/internal/mailer"
+	"odoo-signup/internal/metrics"
+	"odoo-signup/internal/migrations"
+	"odoo-signup/internal/outbox"
+	"odoo-signup/internal/privacy"
+	"odoo-signup/internal/provisioning"
+	"odoo-signup/internal/routing"
+	"odoo-signup/internal/subscribers"
+	"odoo-signup/internal/tenants"
+	"odoo-signup/internal/trials"
+	"odoo-signup/internal/webhooks"
+)
+
+// Pipeline is the provisioning stack: the event bus with its subscribers, the
+// job runner and the services that publish events or submit jobs. Deliveries
+// are queued in the outbox; only the server starts it.
+type Pipeline struct {
+	Outbox      *outbox.Outbox
+	Dispatcher  *webhooks.Dispatcher
+	Mailer      *mailer.Mailer   // Nil when SMTP is not configured
+	Operator    *operator.Client // Nil when the operator database is not configured
+	Metrics     *metrics.Registry
+	Bus         *events.Bus
+	Routing     *routing.Writer
+	TrialPolicy trials.Policy
+	Provisioner *provisioning.Provisioner
+	Backups     *backups.Scheduler
+	Runner      *jobs.Runner
+	Manager     *tenants.Manager
+	Restorer    *backups.Restorer
+	Migrator    *migrations.Migrator
+	Accounts    *privacy.Service
+	Domains     *domains.Service
+	Importer    *imports.Importer
+	Trials      *trials.Enforcer
+}
+
+// NewPipeline wires the provisioning stack. workers bounds the jobs the
+// runner executes at the same time; deliveries records webhook attempts and
+// may be nil in processes that only queue them. The runner is not started.
+func (a *App) NewPipeline(workers int, deliveries *webhooks.DeliveryLog) (*Pipeline, error) {
+	cfg := a.Config
+	p := &Pipeline{
+		Operator: operator.NewClient(cfg),
+		Mailer:   mailer.New(cfg),
+		Metrics:  metrics.NewRegistry(),
+	}
+
+	var err error
+	p.Outbox, err = outbox.New(cfg.OutboxPath, time.Duration(cfg.OutboxIntervalSeconds)*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load outbox: %w", err)
+	}
+	p.Dispatcher = webhooks.NewDispatcher(webhooks.ParseEndpoints(cfg.WebhookEndpoints), cfg.WebhookSecret, p.Outbox, deliveries, cfg.TimeoutSeconds)
+	p.Outbox.Register(webhooks.DeliveryKind, p.Dispatcher.HandleMessage)
+	if p.Operator != nil {
+		p.Outbox.Register(operator.SyncKind, p.Operator.HandleMessage)
+	}
+	if p.Mailer != nil {
+		p.Outbox.Register(mailer.SendKind, p.Mailer.HandleMessage)
+	}
+
+	jobStore, err := jobs.NewStore(cfg.JobsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load jobs: %w", err)
+	}
+	jobSealer, err := jobs.NewSealer(cfg.JobSecret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JOB_SECRET: %w", err)
+	}
+
+	// Side effects of provisioning are subscribers
+	p.Bus = events.NewBus(cfg.EventBusWorkers)
+	p.Bus.Subscribe("logging", subscribers.Logging())
+	p.Bus.Subscribe("jobs", subscribers.Jobs(jobStore))
+	p.Bus.Subscribe("metrics", subscribers.Metrics(p.Metrics))
+	p.Bus.Subscribe("webhooks", subscribers.Webhooks(p.Dispatcher))
+	if p.Mailer != nil {
+		p.Bus.Subscribe("email", subscribers.Email(p.Outbox, cfg.OdooCompany))
+	}
+	if p.Operator != nil {
+		p.Bus.Subscribe("crm", subscribers.CRMSync(p.Outbox))
+	}
+
+	// Proxy routing is generated from the registry when a routing file is configured
+	p.Routing, err = routing.NewWriter(cfg, a.Registry, a.Backends)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy routing configuration: %w", err)
+	}
+	if p.Routing.Enabled() {
+		p.Bus.Subscribe("routing", subscribers.Routing(p.Routing))
+	}
+
+	// The provisioner records when the trial of a new tenant ends. Tenant
+	// hosts get a DNS record through DNS_PROVIDER when there is no wildcard record.
+	p.TrialPolicy, err = trials.NewPolicy(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trial configuration: %w", err)
+	}
+	dnsProvider, err := provisioning.NewDNSProvider(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DNS provider configuration: %w", err)
+	}
+	p.Provisioner = provisioning.New(cfg, a.Backends, p.Bus, a.Registry, p.TrialPolicy, dnsProvider)
+	if dnsProvider != nil {
+		p.Bus.Subscribe("dns", subscribers.DNSRecords(dnsProvider, p.Provisioner.RecordFor))
+	}
+
+	p.Backups, err = a.NewScheduler(p.Metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	accountRequests, err := privacy.NewStore(cfg.AccountRequestsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load account requests: %w", err)
+	}
+	erasureLog, err := privacy.NewErasureLog(cfg.ErasureLogPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open erasure log: %w", err)
+	}
+
+	// Signups, restores, migrations, exports and retries share the runner's workers
+	p.Runner = jobs.NewRunner(jobStore, jobSealer, workers)
+	p.Runner.Register(provisioning.JobKind, p.Provisioner.RunJob)
+	p.Manager = tenants.NewManager(cfg, a.Backends, a.Registry, p.Backups, jobStore, p.Bus)
+	p.Restorer = backups.NewRestorer(a.Backends, a.Registry, p.Manager, p.Backups, p.Runner, cfg.TemplateDatabase)
+	p.Migrator = migrations.NewMigrator(cfg, a.Backends, a.Registry, p.Manager, p.Backups, p.Runner, p.Bus)
+	p.Accounts = privacy.NewService(cfg, accountRequests, a.Registry, p.Manager, p.Backups, p.Runner, p.Outbox, erasureLog, p.Bus)
+	p.Domains = domains.NewService(cfg, a.Registry, p.Manager, dns.NewResolver(cfg.DNSResolver), p.Bus)
+	p.Importer = imports.NewImporter(p.Provisioner, p.Runner, a.Backends, a.Registry, imports.NewStore(cfg.ImportsDir))
+	p.Trials = trials.NewEnforcer(p.TrialPolicy, a.Registry, p.Manager, p.Backups, p.Bus)
+
+	return p, nil
+}
+
+// Close waits for the running jobs and delivers the remaining events
+func (p *Pipeline) Close() {
+	p.Runner.Stop()
+	p.Bus.Close()
+}
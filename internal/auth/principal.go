@@ -0,0 +1,33 @@
+package auth
+
+import "odoo-signup/internal/apikeys"
+
+// Principal kinds
+const (
+	KindAPIKey  = "api_key"
+	KindSession = "session"
+)
+
+// Principal is the caller of an admin endpoint: an API key or a signed-in operator
+type Principal struct {
+	ID     string   `json:"id"`   // API key ID, or "oidc:<subject>" for operators
+	Name   string   `json:"name"` // Key name, or the operator's email
+	Kind   string   `json:"kind"`
+	Roles  []string `json:"roles,omitempty"`
+	Scopes []string `json:"scopes"`
+}
+
+// HasScope reports whether the principal is allowed a scope
+func (p Principal) HasScope(scope string) bool {
+	return apikeys.Allows(p.Scopes, scope)
+}
+
+// FromKey returns the principal of an API key
+func FromKey(key apikeys.Key) Principal {
+	return Principal{
+		ID:     key.ID,
+		Name:   key.Name,
+		Kind:   KindAPIKey,
+		Scopes: key.Scopes,
+	}
+}
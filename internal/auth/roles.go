@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"odoo-signup/internal/apikeys"
+)
+
+// Operator roles granted through identity provider groups
+const (
+	RoleAdmin    = "admin"    // Every scope
+	RoleOperator = "operator" // Tenant lifecycle operations
+	RoleViewer   = "viewer"   // Read-only access to tenants
+)
+
+// roleScopes maps each role to the API scopes it grants
+var roleScopes = map[string][]string{
+	RoleAdmin:    apikeys.Scopes,
+	RoleOperator: {apikeys.ScopeTenantsManage},
+	RoleViewer:   {apikeys.ScopeTenantsRead},
+}
+
+// ParseRoleMapping parses "group=role,group=role" into a group to role map
+func ParseRoleMapping(value string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		group, role, ok := strings.Cut(pair, "=")
+		group, role = strings.TrimSpace(group), strings.TrimSpace(role)
+		if !ok || group == "" {
+			return nil, fmt.Errorf("invalid role mapping %q, expected group=role", pair)
+		}
+		if _, known := roleScopes[role]; !known {
+			return nil, fmt.Errorf("unknown role %q in mapping for group %q", role, group)
+		}
+		mapping[group] = role
+	}
+	return mapping, nil
+}
+
+// RolesForGroups returns the roles and scopes granted by a user's groups
+func RolesForGroups(groups []string, mapping map[string]string) ([]string, []string) {
+	roleSet := make(map[string]bool)
+	scopeSet := make(map[string]bool)
+	for _, group := range groups {
+		role, ok := mapping[group]
+		if !ok {
+			continue
+		}
+		roleSet[role] = true
+		for _, scope := range roleScopes[role] {
+			scopeSet[scope] = true
+		}
+	}
+	return sortedKeys(roleSet), sortedKeys(scopeSet)
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
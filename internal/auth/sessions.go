@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"sync"
+	"time"
+
+	"odoo-signup/internal/oidc"
+)
+
+// loginTimeout bounds the time between starting a login and the provider's callback
+const loginTimeout = 10 * time.Minute
+
+// Session is a signed-in operator. Sessions live in memory, so a restart signs everyone out.
+type Session struct {
+	ID        string
+	CSRFToken string // Required on state-changing requests authenticated by the session cookie
+	Principal Principal
+	ExpiresAt time.Time
+}
+
+// Login is a login in progress, keyed by its OAuth state
+type Login struct {
+	Verifier  string
+	Nonce     string
+	ReturnTo  string
+	expiresAt time.Time
+}
+
+// Sessions keeps operator sessions and logins in progress
+type Sessions struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	sessions map[string]*Session
+	logins   map[string]Login
+}
+
+// NewSessions creates a session store with the given session lifetime
+func NewSessions(ttl time.Duration) *Sessions {
+	return &Sessions{
+		ttl:      ttl,
+		sessions: make(map[string]*Session),
+		logins:   make(map[string]Login),
+	}
+}
+
+// TTL returns the session lifetime
+func (s *Sessions) TTL() time.Duration {
+	return s.ttl
+}
+
+// BeginLogin stores the PKCE verifier and nonce of a new login and returns its state
+func (s *Sessions) BeginLogin(returnTo string) (string, Login, error) {
+	state, err := oidc.RandomString(24)
+	if err != nil {
+		return "", Login{}, err
+	}
+	verifier, err := oidc.NewVerifier()
+	if err != nil {
+		return "", Login{}, err
+	}
+	nonce, err := oidc.RandomString(24)
+	if err != nil {
+		return "", Login{}, err
+	}
+
+	login := Login{
+		Verifier:  verifier,
+		Nonce:     nonce,
+		ReturnTo:  returnTo,
+		expiresAt: time.Now().Add(loginTimeout),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep()
+	s.logins[state] = login
+	return state, login, nil
+}
+
+// FinishLogin returns and removes the login for a state. Each state is accepted once.
+func (s *Sessions) FinishLogin(state string) (Login, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	login, ok := s.logins[state]
+	delete(s.logins, state)
+	if !ok || time.Now().After(login.expiresAt) {
+		return Login{}, false
+	}
+	return login, true
+}
+
+// Create starts a session for a principal
+func (s *Sessions) Create(principal Principal) (*Session, error) {
+	id, err := oidc.RandomString(32)
+	if err != nil {
+		return nil, err
+	}
+	csrf, err := oidc.RandomString(24)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &Session{
+		ID:        id,
+		CSRFToken: csrf,
+		Principal: principal,
+		ExpiresAt: time.Now().Add(s.ttl),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep()
+	s.sessions[id] = session
+	return session, nil
+}
+
+// Get returns an unexpired session
+func (s *Sessions) Get(id string) (*Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok || time.Now().After(session.ExpiresAt) {
+		return nil, false
+	}
+	return session, true
+}
+
+// Delete ends a session
+func (s *Sessions) Delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+}
+
+// sweep drops expired sessions and logins. Callers hold the lock.
+func (s *Sessions) sweep() {
+	now := time.Now()
+	for id, session := range s.sessions {
+		if now.After(session.ExpiresAt) {
+			delete(s.sessions, id)
+		}
+	}
+	for state, login := range s.logins {
+		if now.After(login.expiresAt) {
+			delete(s.logins, state)
+		}
+	}
+}
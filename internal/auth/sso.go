@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"odoo-signup/internal/oidc"
+)
+
+// Errors returned by single sign-on
+var (
+	ErrInvalidState = errors.New("login expired or was already used")
+	ErrNoRole       = errors.New("none of your groups grants access to the admin console")
+)
+
+// SSO signs operators in through an OpenID Connect provider and maps their groups to roles
+type SSO struct {
+	Provider     *oidc.Provider
+	Sessions     *Sessions
+	RoleMapping  map[string]string // Provider group to role
+	CookieSecure bool
+}
+
+// CookieName returns the session cookie name. Secure cookies use the __Host-
+// prefix so browsers refuse them over HTTP and from other subdomains.
+func (s *SSO) CookieName() string {
+	if s == nil || !s.CookieSecure {
+		return "signup_admin"
+	}
+	return "__Host-signup_admin"
+}
+
+// Start begins a login and returns the provider URL to redirect to
+func (s *SSO) Start(returnTo string) (string, error) {
+	state, login, err := s.Sessions.BeginLogin(safeReturnTo(returnTo))
+	if err != nil {
+		return "", err
+	}
+	return s.Provider.AuthURL(state, login.Nonce, login.Verifier)
+}
+
+// Complete redeems the provider callback and opens a session. It returns
+// where the operator was headed when the login started.
+func (s *SSO) Complete(state, code string) (*Session, string, error) {
+	login, ok := s.Sessions.FinishLogin(state)
+	if !ok {
+		return nil, "", ErrInvalidState
+	}
+
+	identity, err := s.Provider.Exchange(code, login.Verifier, login.Nonce)
+	if err != nil {
+		return nil, "", fmt.Errorf("sign-in failed: %w", err)
+	}
+
+	roles, scopes := RolesForGroups(identity.Groups, s.RoleMapping)
+	if len(roles) == 0 {
+		return nil, "", ErrNoRole
+	}
+
+	name := identity.Email
+	if name == "" {
+		name = identity.Name
+	}
+
+	session, err := s.Sessions.Create(Principal{
+		ID:     "oidc:" + identity.Subject,
+		Name:   name,
+		Kind:   KindSession,
+		Roles:  roles,
+		Scopes: scopes,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return session, login.ReturnTo, nil
+}
+
+// safeReturnTo only allows local paths so the login cannot redirect off-site.
+// Browsers drop tabs and newlines from URLs, so "/\t/host" would become
+// "//host"; any whitespace or control character is refused.
+func safeReturnTo(returnTo string) string {
+	const fallback = "/admin/"
+	if strings.ContainsFunc(returnTo, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsControl(r) || r == '\\'
+	}) {
+		return fallback
+	}
+
+	parsed, err := url.Parse(returnTo)
+	if err != nil || parsed.Scheme != "" || parsed.Host != "" || parsed.User != nil ||
+		!strings.HasPrefix(parsed.Path, "/") || strings.HasPrefix(parsed.Path, "//") {
+		return fallback
+	}
+	return returnTo
+}
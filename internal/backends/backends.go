@@ -0,0 +1,406 @@
+// Package backends keeps the Odoo servers tenant databases are placed on and
+// chooses the server of each new tenant
+package backends
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"odoo-signup/internal/integration/odoo"
+	"odoo-signup/internal/models"
+	"odoo-signup/internal/store"
+	"odoo-signup/internal/tenants"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultName names the backend built from ODOO_URL when no backend file exists
+const DefaultName = "default"
+
+// namePattern matches backend names
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// Errors returned by the pool
+var (
+	ErrNotFound      = errors.New("backend not found")
+	ErrNoCapacity    = errors.New("no backend can take the tenant")
+	ErrDraining      = errors.New("backend is already draining")
+	ErrNotDraining   = errors.New("backend is not draining")
+	ErrLastBackend   = errors.New("the only backend taking tenants cannot be drained")
+	ErrInvalidConfig = errors.New("invalid backend")
+)
+
+// Backend is an Odoo server with its own PostgreSQL host. The first backend
+// is the default: tenants registered before backends existed live on it.
+type Backend struct {
+	Name           string     `json:"name"`
+	URL            string     `json:"url"`
+	Upstream       string     `json:"upstream,omitempty"`       // URL the reverse proxy reaches it at; PROXY_ODOO_UPSTREAM for the default backend, URL for others when empty
+	MasterPassword string     `json:"masterPassword,omitempty"` // ODOO_MASTER_PASSWORD when empty
+	Capacity       int        `json:"capacity,omitempty"`       // Most tenants placed on it; unlimited when 0
+	Templates      []string   `json:"templates,omitempty"`      // Template databases it can clone; TEMPLATE_DATABASE when empty
+	Weight         int        `json:"weight,omitempty"`         // Share of signups under the weighted strategy; 1 when 0
+	Region         string     `json:"region,omitempty"`         // Region of PLACEMENT_REGIONS it serves
+	Countries      []string   `json:"countries,omitempty"`      // Signup countries it serves under the region strategy
+	Plans          []string   `json:"plans,omitempty"`          // Plans pinned to it under the plan strategy
+	Draining       bool       `json:"draining,omitempty"`       // Takes no new tenants
+	DrainedBy      string     `json:"drainedBy,omitempty"`
+	DrainedAt      *time.Time `json:"drainedAt,omitempty"`
+}
+
+// HasTemplate reports whether the backend can clone a template database.
+// Backends listing no templates have the default one.
+func (b Backend) HasTemplate(template, defaultTemplate string) bool {
+	if len(b.Templates) == 0 {
+		return template == defaultTemplate
+	}
+	for _, name := range b.Templates {
+		if name == template {
+			return true
+		}
+	}
+	return false
+}
+
+// Status is a backend with the number of tenants placed on it
+type Status struct {
+	Backend
+	Tenants int `json:"tenants"`
+}
+
+// Pool keeps the backends in a JSON file shared by the server and the CLI,
+// reloaded whenever it changes, and an Odoo client for each
+type Pool struct {
+	mu       sync.Mutex
+	config   *models.Config
+	registry tenants.Store
+	file     *store.File
+	modTime  time.Time
+	backends []Backend
+	clients  map[string]*odoo.Client
+	strategy Strategy
+	placed   map[string]string // Backend of databases placed but not registered yet
+}
+
+// NewPool loads the backends persisted at BACKENDS_PATH. Without the file,
+// the pool holds one backend built from ODOO_URL.
+func NewPool(config *models.Config, registry tenants.Store) (*Pool, error) {
+	strategy, err := NewStrategy(config)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Pool{
+		config:   config,
+		registry: registry,
+		file:     store.NewFile(config.BackendsPath),
+		clients:  make(map[string]*odoo.Client),
+		strategy: strategy,
+		placed:   make(map[string]string),
+	}
+	if err := p.reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// List returns every backend with its tenant count, in file order
+func (p *Pool) List() []Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.refresh()
+
+	counts := p.counts()
+	list := make([]Status, 0, len(p.backends))
+	for _, backend := range p.backends {
+		backend.MasterPassword = ""
+		list = append(list, Status{Backend: backend, Tenants: counts[backend.Name]})
+	}
+	return list
+}
+
+// Get returns a backend; the default backend for an empty name
+func (p *Pool) Get(name string) (Backend, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.refresh()
+	return p.find(name)
+}
+
+// DefaultName returns the name of the default backend
+func (p *Pool) DefaultName() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.refresh()
+	return p.backends[0].Name
+}
+
+// Templates returns the template databases listed by the backends
+func (p *Pool) Templates() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.refresh()
+
+	var templates []string
+	for _, backend := range p.backends {
+		templates = append(templates, backend.Templates...)
+	}
+	return templates
+}
+
+// Client returns the client of a backend; the default backend for an empty name
+func (p *Pool) Client(name string) (*odoo.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.refresh()
+
+	backend, err := p.find(name)
+	if err != nil {
+		return nil, err
+	}
+	return p.clients[backend.Name], nil
+}
+
+// ClientFor returns the client of the backend a registered tenant lives on,
+// or of the default backend for unregistered databases
+func (p *Pool) ClientFor(database string) (*odoo.Client, error) {
+	tenant, _ := p.registry.Get(database)
+	return p.Client(tenant.Backend)
+}
+
+// Default returns the client of the default backend, which serves the
+// template used for the country list
+func (p *Pool) Default() *odoo.Client {
+	client, _ := p.Client("")
+	return client
+}
+
+// Databases lists the databases of each backend by backend name
+func (p *Pool) Databases() (map[string][]string, error) {
+	p.mu.Lock()
+	p.refresh()
+	backends := append([]Backend(nil), p.backends...)
+	clients := p.clients
+	p.mu.Unlock()
+
+	databases := make(map[string][]string, len(backends))
+	for _, backend := range backends {
+		names, err := clients[backend.Name].ListDatabases(newRPCID())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the databases of %s: %w", backend.Name, err)
+		}
+		databases[backend.Name] = names
+	}
+	return databases, nil
+}
+
+// Exists reports whether any backend has the database
+func (p *Pool) Exists(database string) (bool, error) {
+	databases, err := p.Databases()
+	if err != nil {
+		return false, err
+	}
+	for _, names := range databases {
+		for _, name := range names {
+			if name == database {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// Drain stops placing new tenants on a backend. Its tenants keep running.
+func (p *Pool) Drain(name, actor string) (Backend, error) {
+	return p.update(name, func(backend *Backend) error {
+		if backend.Draining {
+			return ErrDraining
+		}
+		for _, other := range p.backends {
+			if other.Name != backend.Name && !other.Draining {
+				now := time.Now().UTC()
+				backend.Draining, backend.DrainedBy, backend.DrainedAt = true, actor, &now
+				return nil
+			}
+		}
+		return ErrLastBackend
+	})
+}
+
+// Undrain places new tenants on a drained backend again
+func (p *Pool) Undrain(name string) (Backend, error) {
+	return p.update(name, func(backend *Backend) error {
+		if !backend.Draining {
+			return ErrNotDraining
+		}
+		backend.Draining, backend.DrainedBy, backend.DrainedAt = false, "", nil
+		return nil
+	})
+}
+
+// update changes a backend and saves the file
+func (p *Pool) update(name string, fn func(*Backend) error) (Backend, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.reload(); err != nil {
+		return Backend{}, err
+	}
+	for n := range p.backends {
+		if p.backends[n].Name != name {
+			continue
+		}
+		if err := fn(&p.backends[n]); err != nil {
+			return Backend{}, err
+		}
+		if err := p.save(); err != nil {
+			return Backend{}, err
+		}
+		backend := p.backends[n]
+		logrus.WithFields(logrus.Fields{"backend": name, "draining": backend.Draining, "actor": backend.DrainedBy}).Info("Backend updated")
+		backend.MasterPassword = ""
+		return backend, nil
+	}
+	return Backend{}, ErrNotFound
+}
+
+// find returns a backend by name; the caller holds the lock
+func (p *Pool) find(name string) (Backend, error) {
+	if name == "" {
+		return p.backends[0], nil
+	}
+	for _, backend := range p.backends {
+		if backend.Name == name {
+			return backend, nil
+		}
+	}
+	return Backend{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+}
+
+// counts returns the tenants of each backend, failed ones aside, with the
+// databases placed but not registered yet; the caller holds the lock
+func (p *Pool) counts() map[string]int {
+	counts := make(map[string]int, len(p.backends))
+	registered := make(map[string]bool)
+	for _, tenant := range p.registry.List() {
+		registered[tenant.Database] = true
+		if tenant.Status == tenants.StatusFailed {
+			continue
+		}
+		name := tenant.Backend
+		if name == "" {
+			name = p.backends[0].Name
+		}
+		counts[name]++
+	}
+
+	for database, name := range p.placed {
+		if registered[database] {
+			delete(p.placed, database)
+			continue
+		}
+		counts[name]++
+	}
+	return counts
+}
+
+// refresh reloads the file for readers, keeping the loaded backends on errors
+func (p *Pool) refresh() {
+	if err := p.reload(); err != nil {
+		logrus.WithError(err).Error("Failed to reload backends")
+	}
+}
+
+// reload reads the file again when it changed since the last load
+func (p *Pool) reload() error {
+	modTime, err := p.file.ModTime()
+	if err != nil {
+		return err
+	}
+	if p.backends != nil && modTime.Equal(p.modTime) {
+		return nil
+	}
+
+	var backends []Backend
+	if err := p.file.Load(&backends); err != nil {
+		return err
+	}
+	if len(backends) == 0 {
+		backends = []Backend{{Name: DefaultName, URL: p.config.OdooURL}}
+	}
+	if err := p.check(backends); err != nil {
+		return err
+	}
+
+	clients := make(map[string]*odoo.Client, len(backends))
+	for _, backend := range backends {
+		masterPassword := backend.MasterPassword
+		if masterPassword == "" {
+			masterPassword = p.config.OdooMasterPass
+		}
+		clients[backend.Name] = odoo.NewClient(backend.URL, masterPassword, p.config.AdminUser, p.config.AdminPassword, p.config.TimeoutSeconds)
+	}
+
+	p.backends, p.clients, p.modTime = backends, clients, modTime
+	return nil
+}
+
+// check validates the backends
+func (p *Pool) check(backends []Backend) error {
+	seen := make(map[string]bool, len(backends))
+	for n := range backends {
+		backend := &backends[n]
+		if !namePattern.MatchString(backend.Name) {
+			return fmt.Errorf("%w: name %q must be lowercase letters, digits and dashes", ErrInvalidConfig, backend.Name)
+		}
+		if seen[backend.Name] {
+			return fmt.Errorf("%w: %s is listed twice", ErrInvalidConfig, backend.Name)
+		}
+		seen[backend.Name] = true
+
+		if !httpURL(backend.URL) {
+			return fmt.Errorf("%w: %s has no http(s) URL", ErrInvalidConfig, backend.Name)
+		}
+		if backend.Upstream != "" && !httpURL(backend.Upstream) {
+			return fmt.Errorf("%w: the upstream of %s is not an http(s) URL", ErrInvalidConfig, backend.Name)
+		}
+		if backend.Capacity < 0 || backend.Weight < 0 {
+			return fmt.Errorf("%w: %s has a negative capacity or weight", ErrInvalidConfig, backend.Name)
+		}
+		for i, country := range backend.Countries {
+			backend.Countries[i] = strings.ToUpper(country)
+		}
+	}
+	return nil
+}
+
+// save writes the backends and remembers the new modification time; the
+// caller holds the lock
+func (p *Pool) save() error {
+	if err := p.file.Save(p.backends); err != nil {
+		return err
+	}
+
+	modTime, err := p.file.ModTime()
+	if err != nil {
+		return err
+	}
+	p.modTime = modTime
+	return nil
+}
+
+// httpURL reports whether raw is an absolute http(s) URL
+func httpURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// newRPCID returns an RPC ID for a single operation
+func newRPCID() int {
+	return int(time.Now().UnixNano() % 1000000)
+}
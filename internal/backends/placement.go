@@ -0,0 +1,191 @@
+package backends
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+
+	"odoo-signup/internal/models"
+)
+
+// Placement strategies
+const (
+	StrategyLeast    = "least"    // Fewest tenants
+	StrategyWeighted = "weighted" // Random, in proportion to the weights
+	StrategyRegion   = "region"   // Backends serving the signup country, then fewest tenants
+	StrategyPlan     = "plan"     // Backends pinned to the plan, then fewest tenants
+)
+
+// Placement describes the tenant to place
+type Placement struct {
+	Database string
+	Plan     string
+	Country  string
+	Template string // Template database to clone; empty for new databases
+}
+
+// Candidate is a backend that can take the tenant, with its tenant count
+type Candidate struct {
+	Backend
+	Tenants int
+}
+
+// Strategy picks the backend of a tenant among candidates, which are never empty
+type Strategy func(placement Placement, candidates []Candidate) Candidate
+
+// NewStrategy returns the strategy selected by PLACEMENT_STRATEGY
+func NewStrategy(config *models.Config) (Strategy, error) {
+	switch config.PlacementStrategy {
+	case "", StrategyLeast:
+		return Least, nil
+	case StrategyWeighted:
+		return Weighted, nil
+	case StrategyRegion:
+		regions, err := parseRegions(config.PlacementRegions)
+		if err != nil {
+			return nil, err
+		}
+		return ByRegion(regions), nil
+	case StrategyPlan:
+		return ByPlan, nil
+	}
+	return nil, fmt.Errorf("unknown placement strategy %q, use %s, %s, %s or %s", config.PlacementStrategy, StrategyLeast, StrategyWeighted, StrategyRegion, StrategyPlan)
+}
+
+// Place chooses the backend of a new tenant among those not draining, below
+// their capacity and holding the template to clone. The tenant counts toward
+// the backend until it is registered.
+func (p *Pool) Place(placement Placement) (Backend, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.refresh()
+
+	counts := p.counts()
+	var candidates []Candidate
+	for _, backend := range p.backends {
+		if backend.Draining {
+			continue
+		}
+		if backend.Capacity > 0 && counts[backend.Name] >= backend.Capacity {
+			continue
+		}
+		if placement.Template != "" && !backend.HasTemplate(placement.Template, p.config.TemplateDatabase) {
+			continue
+		}
+		candidates = append(candidates, Candidate{Backend: backend, Tenants: counts[backend.Name]})
+	}
+	if len(candidates) == 0 {
+		return Backend{}, ErrNoCapacity
+	}
+
+	chosen := p.strategy(placement, candidates).Backend
+	p.placed[placement.Database] = chosen.Name
+	chosen.MasterPassword = ""
+	return chosen, nil
+}
+
+// Least picks the backend with the fewest tenants, the first listed on ties
+func Least(_ Placement, candidates []Candidate) Candidate {
+	chosen := candidates[0]
+	for _, candidate := range candidates[1:] {
+		if candidate.Tenants < chosen.Tenants {
+			chosen = candidate
+		}
+	}
+	return chosen
+}
+
+// Weighted picks a random backend in proportion to the weights
+func Weighted(_ Placement, candidates []Candidate) Candidate {
+	total := 0
+	for _, candidate := range candidates {
+		total += weight(candidate.Backend)
+	}
+
+	n := rand.IntN(total)
+	for _, candidate := range candidates {
+		if n -= weight(candidate.Backend); n < 0 {
+			return candidate
+		}
+	}
+	return candidates[len(candidates)-1]
+}
+
+// ByRegion picks among the backends serving the signup country, listed in
+// their countries or through their region, and among all otherwise
+func ByRegion(regions map[string][]string) Strategy {
+	return func(placement Placement, candidates []Candidate) Candidate {
+		country := strings.ToUpper(placement.Country)
+		serving := match(candidates, func(backend Backend) bool {
+			return contains(backend.Countries, country) || contains(regions[backend.Region], country)
+		})
+		if len(serving) > 0 {
+			return Least(placement, serving)
+		}
+		return Least(placement, candidates)
+	}
+}
+
+// ByPlan picks among the backends pinned to the plan, then among those
+// pinned to no plan, and among all otherwise
+func ByPlan(placement Placement, candidates []Candidate) Candidate {
+	if pinned := match(candidates, func(backend Backend) bool { return contains(backend.Plans, placement.Plan) }); len(pinned) > 0 {
+		return Least(placement, pinned)
+	}
+	if shared := match(candidates, func(backend Backend) bool { return len(backend.Plans) == 0 }); len(shared) > 0 {
+		return Least(placement, shared)
+	}
+	return Least(placement, candidates)
+}
+
+// match returns the candidates whose backend satisfies keep
+func match(candidates []Candidate, keep func(Backend) bool) []Candidate {
+	var kept []Candidate
+	for _, candidate := range candidates {
+		if keep(candidate.Backend) {
+			kept = append(kept, candidate)
+		}
+	}
+	return kept
+}
+
+// weight returns the weight of a backend, 1 when unset
+func weight(backend Backend) int {
+	if backend.Weight <= 0 {
+		return 1
+	}
+	return backend.Weight
+}
+
+// contains reports whether list holds value
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRegions reads PLACEMENT_REGIONS, such as "eu=DE,FR,IT;us=US,CA"
+func parseRegions(value string) (map[string][]string, error) {
+	regions := make(map[string][]string)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		region, countries, ok := strings.Cut(entry, "=")
+		region = strings.TrimSpace(region)
+		if !ok || region == "" {
+			return nil, fmt.Errorf("invalid placement region %q, expected region=CC,CC", entry)
+		}
+		for _, country := range strings.Split(countries, ",") {
+			if country = strings.ToUpper(strings.TrimSpace(country)); country != "" {
+				regions[region] = append(regions[region], country)
+			}
+		}
+	}
+	return regions, nil
+}
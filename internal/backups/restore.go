@@ -0,0 +1,393 @@
+package backups
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"odoo-signup/internal/backends"
+	"odoo-signup/internal/jobs"
+	"odoo-signup/internal/tenants"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RestoreJobKind identifies restore jobs
+const RestoreJobKind = "restore"
+
+// Steps of a restore job
+const (
+	StepSnapshot = "snapshot"
+	StepDrop     = "drop_database"
+	StepRestore  = "restore_database"
+	StepRegister = "register"
+	StepRollback = "rollback"
+)
+
+// progressInterval limits how often upload progress is written to the job history
+const progressInterval = time.Second
+
+// Errors returned by the restorer
+var (
+	ErrNoSource = errors.New("a backup key or file is required")
+	ErrNotFound = errors.New("backup not found")
+)
+
+// RestoreRequest describes a restore. With Replace, an existing database is
+// backed up to a safety snapshot, dropped and restored; without it the
+// database must not exist, which restores a backup under a new name for
+// inspection.
+type RestoreRequest struct {
+	Database   string `json:"database"`       // Database to create
+	Key        string `json:"key,omitempty"`  // Backup in the backup storage
+	Path       string `json:"path,omitempty"` // Backup file on the host running the job; the CLI only
+	Replace    bool   `json:"replace"`
+	Copy       bool   `json:"copy"`       // Give the database a new UUID so it can run next to the original
+	Neutralize bool   `json:"neutralize"` // Disable outgoing mail, crons and payment providers (Odoo 16+)
+}
+
+// Restorer restores tenant databases from backups as tracked jobs
+type Restorer struct {
+	backends         *backends.Pool
+	registry         tenants.Store
+	manager          *tenants.Manager
+	scheduler        *Scheduler
+	runner           *jobs.Runner
+	templateDatabase string
+}
+
+// NewRestorer creates a restorer and registers its job handler with the
+// runner. Registered tenants are restored on their backend, other databases
+// on the default backend.
+func NewRestorer(pool *backends.Pool, registry tenants.Store, manager *tenants.Manager, scheduler *Scheduler, runner *jobs.Runner, templateDatabase string) *Restorer {
+	r := &Restorer{
+		backends:         pool,
+		registry:         registry,
+		manager:          manager,
+		scheduler:        scheduler,
+		runner:           runner,
+		templateDatabase: templateDatabase,
+	}
+	runner.Register(RestoreJobKind, r.RunJob)
+	return r
+}
+
+// Submit checks a restore request and queues it as a job. The returned
+// channel receives the result once the job finishes.
+func (r *Restorer) Submit(req RestoreRequest, actor string) (jobs.Job, <-chan error, error) {
+	if err := r.check(req); err != nil {
+		return jobs.Job{}, nil, err
+	}
+	if _, err := r.size(req); err != nil {
+		return jobs.Job{}, nil, err
+	}
+	active, err := r.runner.Store().Unfinished(req.Database)
+	if err != nil {
+		return jobs.Job{}, nil, err
+	}
+	if err := tenants.ActiveJob(req.Database, active); err != nil {
+		return jobs.Job{}, nil, err
+	}
+
+	return r.runner.Submit(RestoreJobKind, req.Database, actor, req, "")
+}
+
+// RunJob is the job handler for restore jobs
+func (r *Restorer) RunJob(job jobs.Job, _ string) error {
+	var req RestoreRequest
+	if err := json.Unmarshal(job.Payload, &req); err != nil {
+		return fmt.Errorf("invalid restore job payload: %w", err)
+	}
+	if err := r.check(req); err != nil {
+		return err
+	}
+
+	// A migration, rename, drop or another restore of the tenant must not run
+	// meanwhile. Databases restored under a new name have no record to claim.
+	claim, err := r.claim(job, req.Database)
+	if err != nil {
+		return err
+	}
+	if claim != nil {
+		defer r.manager.Unclaim(req.Database, *claim)
+	}
+	store := r.runner.Store()
+	running, err := store.Running(req.Database, job.ID)
+	if err != nil {
+		return err
+	}
+	if err := tenants.ActiveJob(req.Database, running); err != nil {
+		return err
+	}
+
+	logger := logrus.WithFields(logrus.Fields{"job_id": job.ID, "database": req.Database})
+
+	exists, err := r.exists(req.Database)
+	if err != nil {
+		return err
+	}
+
+	// A replaced database is kept in a snapshot until the restore succeeded
+	var snapshot *Archive
+	if exists {
+		err := r.step(job.ID, StepSnapshot, func() error {
+			archive, err := r.scheduler.Snapshot(req.Database)
+			if err != nil {
+				return err
+			}
+			snapshot = &archive
+			return store.SetResult(job.ID, "snapshot", archive.Key)
+		})
+		if err != nil {
+			return err
+		}
+
+		err = r.step(job.ID, StepDrop, func() error {
+			client, err := r.backends.ClientFor(req.Database)
+			if err != nil {
+				return err
+			}
+			return client.DropDatabase(req.Database, int(time.Now().UnixNano()%1000000))
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = r.step(job.ID, StepRestore, func() error {
+		return r.restore(job.ID, req.Database, req.Key, req.Path, req.Copy, req.Neutralize)
+	})
+	if err != nil {
+		if snapshot != nil {
+			r.rollback(job.ID, req.Database, snapshot, logger)
+		}
+		return err
+	}
+
+	err = r.step(job.ID, StepRegister, func() error {
+		return r.register(req.Database)
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.WithFields(logrus.Fields{"key": req.Key, "path": req.Path, "replaced": exists}).Info("Tenant database restored")
+	return nil
+}
+
+// claim takes a registered tenant for the restore job; it returns nil for
+// databases that are not registered
+func (r *Restorer) claim(job jobs.Job, database string) (*tenants.Claim, error) {
+	_, claim, err := r.manager.Claim(database, tenants.OperationRestore, job.ID)
+	if errors.Is(err, tenants.ErrNotRegistered) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &claim, nil
+}
+
+// rollback restores the safety snapshot after a failed replacement
+func (r *Restorer) rollback(jobID, database string, snapshot *Archive, logger *logrus.Entry) {
+	err := r.step(jobID, StepRollback, func() error {
+		// A failed restore may have left a partial database behind
+		if exists, err := r.exists(database); err != nil {
+			return err
+		} else if exists {
+			client, err := r.backends.ClientFor(database)
+			if err != nil {
+				return err
+			}
+			if err := client.DropDatabase(database, int(time.Now().UnixNano()%1000000)); err != nil {
+				return err
+			}
+		}
+		return r.restore(jobID, database, snapshot.Key, "", false, false)
+	})
+	if err != nil {
+		logger.WithError(err).WithField("snapshot", snapshot.Key).Error("Rollback to the safety snapshot failed")
+		return
+	}
+	logger.WithField("snapshot", snapshot.Key).Warn("Restore failed, database rolled back to the safety snapshot")
+}
+
+// restore streams a backup into the backend of the database, recording the
+// upload progress
+func (r *Restorer) restore(jobID, database, key, path string, copy, neutralize bool) error {
+	client, err := r.backends.ClientFor(database)
+	if err != nil {
+		return err
+	}
+	total, err := r.size(RestoreRequest{Key: key, Path: path})
+	if err != nil {
+		return err
+	}
+
+	var source io.ReadCloser
+	if path != "" {
+		source, err = os.Open(path)
+	} else {
+		source, err = r.scheduler.Storage().Get(key)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer source.Close()
+
+	counter := &progressReader{
+		reader: source,
+		report: func(done int64) {
+			progress := &jobs.Progress{Step: StepRestore, Done: done, Total: total}
+			if err := r.runner.Store().SetProgress(jobID, progress); err != nil {
+				logrus.WithError(err).WithField("job_id", jobID).Warn("Failed to record restore progress")
+			}
+		},
+	}
+	defer r.runner.Store().SetProgress(jobID, nil)
+
+	return client.RestoreDatabase(database, counter, copy, neutralize)
+}
+
+// register updates the registry after a restore. A registered tenant becomes
+// active unless it is suspended; the users of a suspended tenant are archived
+// again, since the backup may predate the suspension. Databases restored
+// under a new name stay unregistered.
+func (r *Restorer) register(database string) error {
+	tenant, err := r.registry.Update(database, func(tenant *tenants.Tenant) error {
+		if tenant.Status != tenants.StatusSuspended {
+			tenant.Status = tenants.StatusActive
+			tenant.Error = ""
+		}
+		return nil
+	})
+	if errors.Is(err, tenants.ErrNotRegistered) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if tenant.Status == tenants.StatusSuspended {
+		if _, err := r.manager.Relock(database); err != nil {
+			return fmt.Errorf("the restored database of the suspended tenant has active users: %w", err)
+		}
+	}
+	return nil
+}
+
+// check validates the target and source of a restore
+func (r *Restorer) check(req RestoreRequest) error {
+	if req.Database == "" {
+		return errors.New("a database name is required")
+	}
+	if req.Database == r.templateDatabase {
+		return tenants.ErrProtected
+	}
+	if (req.Key == "") == (req.Path == "") {
+		return ErrNoSource
+	}
+
+	if req.Replace {
+		return nil
+	}
+	exists, err := r.exists(req.Database)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return tenants.ErrExists
+	}
+	return nil
+}
+
+// size returns the size of the backup to restore
+func (r *Restorer) size(req RestoreRequest) (int64, error) {
+	if req.Path != "" {
+		info, err := os.Stat(req.Path)
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, ErrNotFound
+		}
+		if err != nil {
+			return 0, err
+		}
+		return info.Size(), nil
+	}
+
+	object, err := r.scheduler.Storage().Stat(req.Key)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrNotFound, req.Key)
+	}
+	return object.Size, nil
+}
+
+// exists reports whether the backend of the database has it
+func (r *Restorer) exists(database string) (bool, error) {
+	client, err := r.backends.ClientFor(database)
+	if err != nil {
+		return false, err
+	}
+	names, err := client.ListDatabases(int(time.Now().UnixNano() % 1000000))
+	if err != nil {
+		return false, fmt.Errorf("failed to list databases: %w", err)
+	}
+	for _, name := range names {
+		if name == database {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// step runs one step of a restore and records its timing in the job
+func (r *Restorer) step(jobID, name string, fn func() error) error {
+	store := r.runner.Store()
+	started := time.Now()
+	if err := store.StartStep(jobID, name, started.UTC()); err != nil {
+		return err
+	}
+
+	err := fn()
+	failure := ""
+	if err != nil {
+		failure = err.Error()
+	}
+	if recordErr := store.FinishStep(jobID, name, failure, time.Since(started)); recordErr != nil {
+		logrus.WithError(recordErr).WithField("job_id", jobID).Warn("Failed to record restore step")
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// progressReader reports the bytes read, at most once per progressInterval
+type progressReader struct {
+	reader   io.Reader
+	report   func(done int64)
+	mu       sync.Mutex
+	done     int64
+	reported time.Time
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.reader.Read(b)
+
+	p.mu.Lock()
+	p.done += int64(n)
+	done := p.done
+	due := time.Since(p.reported) >= progressInterval || err == io.EOF
+	if due {
+		p.reported = time.Now()
+	}
+	p.mu.Unlock()
+
+	if due {
+		p.report(done)
+	}
+	return n, err
+}
@@ -0,0 +1,69 @@
+package backups
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Retention is a grandfather-father-son policy: the newest backup of each of
+// the Daily most recent days that have one is kept, and likewise for Weekly
+// ISO weeks and Monthly months. The newest backup is always kept. A zero
+// policy keeps everything.
+type Retention struct {
+	Daily   int `json:"daily"`
+	Weekly  int `json:"weekly"`
+	Monthly int `json:"monthly"`
+}
+
+// Enabled reports whether the policy prunes anything
+func (r Retention) Enabled() bool {
+	return r.Daily > 0 || r.Weekly > 0 || r.Monthly > 0
+}
+
+// Apply splits the archives of one database into those to keep and those to prune
+func (r Retention) Apply(archives []Archive) (keep, prune []Archive) {
+	if !r.Enabled() || len(archives) == 0 {
+		return archives, nil
+	}
+
+	sorted := append([]Archive(nil), archives...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].CreatedAt.After(sorted[j].CreatedAt) })
+
+	kept := map[string]bool{sorted[0].Key: true}
+	r.keepNewestPer(sorted, r.Daily, kept, func(a Archive) string {
+		return a.CreatedAt.Format("2006-01-02")
+	})
+	r.keepNewestPer(sorted, r.Weekly, kept, func(a Archive) string {
+		year, week := a.CreatedAt.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	})
+	r.keepNewestPer(sorted, r.Monthly, kept, func(a Archive) string {
+		return a.CreatedAt.Format("2006-01")
+	})
+
+	for _, archive := range sorted {
+		if kept[archive.Key] {
+			keep = append(keep, archive)
+		} else {
+			prune = append(prune, archive)
+		}
+	}
+	return keep, prune
+}
+
+// keepNewestPer marks the newest archive of each of the latest count periods.
+// archives must be sorted newest first.
+func (r Retention) keepNewestPer(archives []Archive, count int, kept map[string]bool, period func(Archive) string) {
+	seen := make(map[string]bool)
+	for _, archive := range archives {
+		if len(seen) >= count {
+			return
+		}
+		p := period(archive)
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		kept[archive.Key] = true
+	}
+}
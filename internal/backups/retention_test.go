@@ -0,0 +1,113 @@
+package backups
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRetentionApply(t *testing.T) {
+	at := func(value string) Archive {
+		created, err := time.Parse("2006-01-02 15:04", value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return Archive{Key: value, CreatedAt: created}
+	}
+
+	tests := []struct {
+		name     string
+		policy   Retention
+		archives []Archive
+		keep     []string
+	}{
+		{
+			name:     "zero policy keeps everything",
+			archives: []Archive{at("2024-01-01 02:00"), at("2024-01-02 02:00")},
+			keep:     []string{"2024-01-01 02:00", "2024-01-02 02:00"},
+		},
+		{
+			name:     "one day keeps only the newest",
+			policy:   Retention{Daily: 1},
+			archives: []Archive{at("2024-01-01 02:00"), at("2024-01-01 14:00")},
+			keep:     []string{"2024-01-01 14:00"},
+		},
+		{
+			name:   "daily keeps the newest of each day across midnight",
+			policy: Retention{Daily: 2},
+			archives: []Archive{
+				at("2024-01-01 23:59"), at("2024-01-02 00:00"), at("2024-01-02 12:00"),
+				at("2024-01-03 00:01"), at("2024-01-01 08:00"),
+			},
+			keep: []string{"2024-01-03 00:01", "2024-01-02 12:00"},
+		},
+		{
+			name:   "daily counts days that have a backup",
+			policy: Retention{Daily: 2},
+			archives: []Archive{
+				at("2024-01-01 02:00"), at("2024-01-05 02:00"), at("2024-01-10 02:00"),
+			},
+			keep: []string{"2024-01-10 02:00", "2024-01-05 02:00"},
+		},
+		{
+			name:   "weekly splits on Monday",
+			policy: Retention{Weekly: 2},
+			archives: []Archive{
+				// Saturday and Sunday of 2024-W02, Monday and Wednesday of 2024-W03
+				at("2024-01-13 02:00"), at("2024-01-14 02:00"),
+				at("2024-01-15 02:00"), at("2024-01-17 02:00"),
+				at("2024-01-07 02:00"),
+			},
+			keep: []string{"2024-01-17 02:00", "2024-01-14 02:00"},
+		},
+		{
+			name:   "weekly follows the ISO year across new year",
+			policy: Retention{Weekly: 2},
+			archives: []Archive{
+				// 2024-12-30 and 2025-01-01 are both in 2025-W01
+				at("2024-12-28 02:00"), at("2024-12-30 02:00"), at("2025-01-01 02:00"),
+				at("2024-12-22 02:00"),
+			},
+			keep: []string{"2025-01-01 02:00", "2024-12-28 02:00"},
+		},
+		{
+			name:   "monthly keeps the newest of each month",
+			policy: Retention{Monthly: 3},
+			archives: []Archive{
+				at("2023-12-31 23:00"), at("2024-01-01 01:00"), at("2024-01-31 02:00"),
+				at("2024-02-29 02:00"), at("2024-03-01 02:00"), at("2023-11-15 02:00"),
+			},
+			keep: []string{"2024-03-01 02:00", "2024-02-29 02:00", "2024-01-31 02:00"},
+		},
+		{
+			name:   "periods combine",
+			policy: Retention{Daily: 2, Weekly: 2, Monthly: 2},
+			archives: []Archive{
+				at("2024-02-01 02:00"), at("2024-02-02 02:00"), at("2024-02-03 02:00"),
+				at("2024-01-20 02:00"), at("2024-01-31 02:00"), at("2023-12-31 02:00"),
+			},
+			// 2024-02-03 and 02-02 by day, 2024-W05 (01-29..02-04) is covered,
+			// 2024-W03 adds 01-20, January adds 01-31
+			keep: []string{"2024-02-03 02:00", "2024-02-02 02:00", "2024-01-31 02:00", "2024-01-20 02:00"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keep, prune := tt.policy.Apply(tt.archives)
+			var got []string
+			for _, archive := range keep {
+				got = append(got, archive.Key)
+			}
+			slices.Sort(got)
+			want := slices.Clone(tt.keep)
+			slices.Sort(want)
+			if !slices.Equal(got, want) {
+				t.Errorf("kept %v, want %v", got, want)
+			}
+			if len(keep)+len(prune) != len(tt.archives) {
+				t.Errorf("kept %d and pruned %d of %d archives", len(keep), len(prune), len(tt.archives))
+			}
+		})
+	}
+}
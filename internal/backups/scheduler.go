@@ -0,0 +1,422 @@
+// Package backups dumps tenant databases to a storage backend on a schedule
+// and prunes them by a retention policy
+package backups
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"odoo-signup/internal/backends"
+	"odoo-signup/internal/cron"
+	"odoo-signup/internal/integration/odoo"
+	"odoo-signup/internal/metrics"
+	"odoo-signup/internal/models"
+	"odoo-signup/internal/storage"
+	"odoo-signup/internal/tenants"
+
+	"github.com/sirupsen/logrus"
+)
+
+// timestampLayout is the time in archive names
+const timestampLayout = "20060102T150405Z"
+
+// Archive is a stored backup of a tenant database
+type Archive struct {
+	Database  string    `json:"database"`
+	Key       string    `json:"key"`
+	Location  string    `json:"location"`
+	Format    string    `json:"format"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// RunResult summarizes a backup run over all tenants
+type RunResult struct {
+	StartedAt  time.Time         `json:"startedAt"`
+	FinishedAt time.Time         `json:"finishedAt"`
+	Archives   []Archive         `json:"archives"`
+	Pruned     []Archive         `json:"pruned"`
+	Failures   map[string]string `json:"failures,omitempty"` // Error by database
+}
+
+// Scheduler backs up registered tenants to a storage backend
+type Scheduler struct {
+	backends  *backends.Pool
+	registry  tenants.Store
+	storage   storage.Storage
+	format    string
+	retention Retention
+	metrics   *metrics.Registry
+
+	running sync.Mutex // One run at a time
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewScheduler creates a scheduler dumping each tenant from its backend;
+// metricsRegistry may be nil
+func NewScheduler(pool *backends.Pool, registry tenants.Store, backend storage.Storage, format string, retention Retention, metricsRegistry *metrics.Registry) *Scheduler {
+	if metricsRegistry != nil {
+		metricsRegistry.Describe("tenant_backups_total", "Scheduled tenant backups, by result")
+		metricsRegistry.Describe("tenant_backup_duration_seconds", "Duration of successful tenant backups")
+		metricsRegistry.Describe("tenant_backups_pruned_total", "Backups deleted by the retention policy")
+	}
+
+	return &Scheduler{
+		backends:  pool,
+		registry:  registry,
+		storage:   backend,
+		format:    format,
+		retention: retention,
+		metrics:   metricsRegistry,
+	}
+}
+
+// NewStorage returns the storage backend selected by the configuration
+func NewStorage(config *models.Config) (storage.Storage, error) {
+	switch config.BackupStorage {
+	case "", "local":
+		return storage.NewLocal(config.BackupDir), nil
+	case "s3":
+		return storage.NewS3(storage.S3Config{
+			Endpoint:  config.BackupS3Endpoint,
+			Region:    config.BackupS3Region,
+			Bucket:    config.BackupS3Bucket,
+			AccessKey: config.BackupS3AccessKey,
+			SecretKey: config.BackupS3SecretKey,
+			Prefix:    config.BackupS3Prefix,
+		})
+	}
+	return nil, fmt.Errorf("unknown backup storage %q, use local or s3", config.BackupStorage)
+}
+
+// Start runs a backup of every tenant each time the schedule fires
+func (s *Scheduler) Start(schedule *cron.Schedule) {
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		for {
+			next := schedule.Next(time.Now())
+			if next.IsZero() {
+				logrus.WithField("schedule", schedule.String()).Warn("Backup schedule never fires")
+				return
+			}
+			logrus.WithField("next_run", next).Info("Next scheduled backup")
+
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-s.stop:
+				timer.Stop()
+				return
+			case <-timer.C:
+				s.RunAll()
+			}
+		}
+	}()
+}
+
+// Stop stops the schedule and waits for a running backup to finish
+func (s *Scheduler) Stop() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+}
+
+// RunAll backs up every active or suspended tenant, then prunes its backups
+func (s *Scheduler) RunAll() RunResult {
+	s.running.Lock()
+	defer s.running.Unlock()
+
+	result := RunResult{
+		StartedAt: time.Now().UTC(),
+		Archives:  []Archive{},
+		Pruned:    []Archive{},
+		Failures:  make(map[string]string),
+	}
+	logger := logrus.WithField("format", s.format)
+	logger.Info("Scheduled backup started")
+
+	for _, tenant := range s.registry.List() {
+		if tenant.Status != tenants.StatusActive && tenant.Status != tenants.StatusSuspended {
+			continue
+		}
+
+		archive, err := s.Backup(tenant.Database)
+		if err != nil {
+			result.Failures[tenant.Database] = err.Error()
+			continue
+		}
+		result.Archives = append(result.Archives, archive)
+
+		pruned, err := s.Prune(tenant.Database)
+		if err != nil {
+			result.Failures[tenant.Database] = err.Error()
+		}
+		result.Pruned = append(result.Pruned, pruned...)
+	}
+
+	result.FinishedAt = time.Now().UTC()
+	logger.WithFields(logrus.Fields{
+		"backups":  len(result.Archives),
+		"pruned":   len(result.Pruned),
+		"failures": len(result.Failures),
+		"duration": result.FinishedAt.Sub(result.StartedAt).String(),
+	}).Info("Scheduled backup finished")
+
+	return result
+}
+
+// Run backs up one database outside the schedule, then prunes its backups as
+// a scheduled run does
+func (s *Scheduler) Run(database string) (Archive, []Archive, error) {
+	archive, err := s.Backup(database)
+	if err != nil {
+		return Archive{}, nil, err
+	}
+	pruned, err := s.Prune(database)
+	if err != nil {
+		return archive, pruned, fmt.Errorf("backup stored, but pruning failed: %w", err)
+	}
+	return archive, pruned, nil
+}
+
+// Storage returns the backend backups are stored in
+func (s *Scheduler) Storage() storage.Storage {
+	return s.storage
+}
+
+// Backup streams a dump of one database in the configured format to the storage backend
+func (s *Scheduler) Backup(database string) (Archive, error) {
+	return s.backup(database, s.format)
+}
+
+// Snapshot backs up a database with its filestore before it is replaced, so
+// it can be restored completely whatever the configured format
+func (s *Scheduler) Snapshot(database string) (Archive, error) {
+	return s.backup(database, odoo.DumpZip)
+}
+
+// backup streams a dump of one database to the storage backend
+func (s *Scheduler) backup(database, format string) (Archive, error) {
+	started := time.Now()
+	createdAt := started.UTC()
+	key := archiveKey(database, createdAt, format)
+	logger := logrus.WithFields(logrus.Fields{"database": database, "key": key})
+
+	size, err := s.dump(database, format, key)
+	if err != nil {
+		logger.WithError(err).Error("Tenant backup failed")
+		s.count("tenant_backups_total", metrics.Labels{"result": "failure"})
+		return Archive{}, fmt.Errorf("failed to back up %s: %w", database, err)
+	}
+
+	s.count("tenant_backups_total", metrics.Labels{"result": "success"})
+	if s.metrics != nil {
+		s.metrics.Observe("tenant_backup_duration_seconds", nil, time.Since(started).Seconds())
+	}
+
+	archive := Archive{
+		Database:  database,
+		Key:       key,
+		Location:  s.storage.Location(key),
+		Format:    format,
+		Size:      size,
+		CreatedAt: createdAt,
+	}
+	logger.WithField("size", size).Info("Tenant backup stored")
+	return archive, nil
+}
+
+// Export streams a zip dump of a database with its filestore to key, outside
+// the backups of the database, so retention never prunes it
+func (s *Scheduler) Export(database, key string) (Archive, error) {
+	createdAt := time.Now().UTC()
+	size, err := s.dump(database, odoo.DumpZip, key)
+	if err != nil {
+		return Archive{}, fmt.Errorf("failed to export %s: %w", database, err)
+	}
+
+	return Archive{
+		Database:  database,
+		Key:       key,
+		Location:  s.storage.Location(key),
+		Format:    odoo.DumpZip,
+		Size:      size,
+		CreatedAt: createdAt,
+	}, nil
+}
+
+// dump streams a dump of a database to key in the storage backend
+func (s *Scheduler) dump(database, format, key string) (int64, error) {
+	client, err := s.backends.ClientFor(database)
+	if err != nil {
+		return 0, err
+	}
+
+	// The dump is decoded straight into the upload
+	reader, writer := io.Pipe()
+	go func() {
+		rpcID := int(time.Now().UnixNano() % 1000000)
+		writer.CloseWithError(client.DumpDatabase(database, format, writer, rpcID))
+	}()
+
+	size, err := s.storage.Put(key, reader)
+	reader.CloseWithError(io.ErrClosedPipe)
+	return size, err
+}
+
+// List returns the backups of a database, newest first
+func (s *Scheduler) List(database string) ([]Archive, error) {
+	objects, err := s.storage.List(database + "/")
+	if err != nil {
+		return nil, err
+	}
+
+	archives := make([]Archive, 0, len(objects))
+	for _, object := range objects {
+		archive, ok := parseArchive(database, object)
+		if !ok {
+			continue
+		}
+		archive.Location = s.storage.Location(object.Key)
+		archives = append(archives, archive)
+	}
+
+	sort.Slice(archives, func(i, j int) bool { return archives[i].CreatedAt.After(archives[j].CreatedAt) })
+	return archives, nil
+}
+
+// Purge deletes every object stored under a database name, its backups and
+// anything kept with them, and returns how many were deleted
+func (s *Scheduler) Purge(database string) (int, error) {
+	objects, err := s.storage.List(database + "/")
+	if err != nil {
+		return 0, err
+	}
+
+	for n, object := range objects {
+		if err := s.storage.Delete(object.Key); err != nil {
+			return n, err
+		}
+	}
+	return len(objects), nil
+}
+
+// HasBackups reports whether backups are stored under a database name
+func (s *Scheduler) HasBackups(database string) (bool, error) {
+	archives, err := s.List(database)
+	if err != nil {
+		return false, err
+	}
+	return len(archives) > 0, nil
+}
+
+// MoveBackups copies the backups of a database to the keys of a new name,
+// skipping those copied before, and with remove deletes the originals. A
+// renamed tenant keeps its backups this way.
+func (s *Scheduler) MoveBackups(from, to string, remove bool) error {
+	archives, err := s.List(from)
+	if err != nil {
+		return err
+	}
+
+	for _, archive := range archives {
+		key := archiveKey(to, archive.CreatedAt, archive.Format)
+		if _, err := s.storage.Stat(key); errors.Is(err, storage.ErrNotFound) {
+			if err := s.copy(archive.Key, key); err != nil {
+				return fmt.Errorf("failed to copy backup %s to %s: %w", archive.Key, key, err)
+			}
+		} else if err != nil {
+			return err
+		}
+
+		if remove {
+			if err := s.storage.Delete(archive.Key); err != nil {
+				return fmt.Errorf("failed to delete backup %s: %w", archive.Key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// copy streams a stored object to another key
+func (s *Scheduler) copy(from, to string) error {
+	reader, err := s.storage.Get(from)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	_, err = s.storage.Put(to, reader)
+	return err
+}
+
+// Prune deletes the backups of a database the retention policy does not keep
+func (s *Scheduler) Prune(database string) ([]Archive, error) {
+	if !s.retention.Enabled() {
+		return nil, nil
+	}
+
+	archives, err := s.List(database)
+	if err != nil {
+		return nil, err
+	}
+
+	_, prune := s.retention.Apply(archives)
+	pruned := make([]Archive, 0, len(prune))
+	for _, archive := range prune {
+		if err := s.storage.Delete(archive.Key); err != nil {
+			return pruned, err
+		}
+		logrus.WithFields(logrus.Fields{"database": database, "key": archive.Key}).Info("Backup pruned")
+		s.count("tenant_backups_pruned_total", nil)
+		pruned = append(pruned, archive)
+	}
+	return pruned, nil
+}
+
+// count increments a counter when metrics are enabled
+func (s *Scheduler) count(name string, labels metrics.Labels) {
+	if s.metrics != nil {
+		s.metrics.Inc(name, labels)
+	}
+}
+
+// archiveKey names a backup:
+// <database>/<database>_<timestamp>.<zip|dump>
+func archiveKey(database string, createdAt time.Time, format string) string {
+	return fmt.Sprintf("%s/%s_%s.%s", database, database, createdAt.Format(timestampLayout), format)
+}
+
+// parseArchive reads the database, time and format from a key made by archiveKey
+func parseArchive(database string, object storage.Object) (Archive, bool) {
+	name := path.Base(object.Key)
+	ext := path.Ext(name)
+	stamp, ok := strings.CutPrefix(strings.TrimSuffix(name, ext), database+"_")
+	if !ok || path.Dir(object.Key) != database {
+		return Archive{}, false
+	}
+
+	createdAt, err := time.Parse(timestampLayout, stamp)
+	if err != nil {
+		return Archive{}, false
+	}
+
+	return Archive{
+		Database:  database,
+		Key:       object.Key,
+		Format:    strings.TrimPrefix(ext, "."),
+		Size:      object.Size,
+		CreatedAt: createdAt,
+	}, true
+}
@@ -0,0 +1,76 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRejectsInvalidExpressions(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"1-x * * * *",
+		"@fortnightly",
+	} {
+		if _, err := Parse(expr, nil); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	utc := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	}
+	plus2 := time.FixedZone("UTC+2", 2*60*60)
+
+	tests := []struct {
+		expr string
+		loc  *time.Location
+		from time.Time
+		want time.Time
+	}{
+		{"*/15 * * * *", nil, utc(2024, 1, 1, 10, 7), utc(2024, 1, 1, 10, 15)},
+		{"*/15 * * * *", nil, utc(2024, 1, 1, 10, 45), utc(2024, 1, 1, 11, 0)},
+		// Strictly after the given time, seconds included
+		{"0 2 * * *", nil, utc(2024, 1, 1, 2, 0), utc(2024, 1, 2, 2, 0)},
+		{"0 2 * * *", nil, utc(2024, 1, 1, 1, 59).Add(30 * time.Second), utc(2024, 1, 1, 2, 0)},
+		{"@daily", nil, utc(2024, 12, 31, 23, 59), utc(2025, 1, 1, 0, 0)},
+		{"@monthly", nil, utc(2024, 1, 31, 12, 0), utc(2024, 2, 1, 0, 0)},
+		{"@yearly", nil, utc(2024, 6, 1, 0, 0), utc(2025, 1, 1, 0, 0)},
+		// 2024-01-03 is a Wednesday
+		{"@weekly", nil, utc(2024, 1, 3, 0, 0), utc(2024, 1, 7, 0, 0)},
+		{"0 0 * * 7", nil, utc(2024, 1, 3, 0, 0), utc(2024, 1, 7, 0, 0)},
+		{"0 9 * * 1-5", nil, utc(2024, 1, 5, 10, 0), utc(2024, 1, 8, 9, 0)},
+		{"0 9-17/4 * * *", nil, utc(2024, 1, 1, 13, 30), utc(2024, 1, 1, 17, 0)},
+		{"0 0 1,15 * *", nil, utc(2024, 1, 2, 0, 0), utc(2024, 1, 15, 0, 0)},
+		// A restricted day of month and day of week match when either does
+		{"0 0 13 * 5", nil, utc(2024, 1, 1, 0, 0), utc(2024, 1, 5, 0, 0)},
+		{"0 0 13 * 5", nil, utc(2024, 1, 12, 0, 0), utc(2024, 1, 13, 0, 0)},
+		{"0 0 31 * *", nil, utc(2024, 4, 1, 0, 0), utc(2024, 5, 31, 0, 0)},
+		{"0 0 29 2 *", nil, utc(2024, 3, 1, 0, 0), utc(2028, 2, 29, 0, 0)},
+		{"0 0 30 2 *", nil, utc(2024, 1, 1, 0, 0), time.Time{}},
+		// Evaluated in the schedule's location
+		{"30 1 * * *", plus2, utc(2024, 1, 1, 0, 0), utc(2024, 1, 1, 23, 30)},
+	}
+
+	for _, tt := range tests {
+		schedule, err := Parse(tt.expr, tt.loc)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.expr, err)
+		}
+		got := schedule.Next(tt.from)
+		if !got.Equal(tt.want) {
+			t.Errorf("%q after %s = %s, want %s", tt.expr, tt.from.Format(time.RFC3339), got.UTC().Format(time.RFC3339), tt.want.Format(time.RFC3339))
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Package dns creates the DNS records of tenant hosts through a provider, for
+// setups where the service domain has no wildcard record.
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Record types a provider can write
+const (
+	TypeA     = "A"
+	TypeAAAA  = "AAAA"
+	TypeCNAME = "CNAME"
+)
+
+// Provider writes DNS records. Upsert replaces the records of the same name
+// and type; Delete succeeds when the record does not exist.
+type Provider interface {
+	Upsert(ctx context.Context, record Record) error
+	Delete(ctx context.Context, record Record) error
+}
+
+// Record is a DNS record of a tenant host
+type Record struct {
+	Name  string `json:"name"` // Fully qualified, without the trailing dot
+	Type  string `json:"type"`
+	Value string `json:"value"` // Address, or target host of a CNAME
+	TTL   int    `json:"ttl"`
+}
+
+// Template gives the type, value and TTL of the record of every tenant host
+type Template struct {
+	Type  string
+	Value string
+	TTL   int
+}
+
+// Validate checks that the template describes a record a provider can write
+func (t Template) Validate() error {
+	switch t.Type {
+	case TypeA:
+		if ip := net.ParseIP(t.Value); ip == nil || ip.To4() == nil {
+			return fmt.Errorf("%q is not an IPv4 address", t.Value)
+		}
+	case TypeAAAA:
+		if ip := net.ParseIP(t.Value); ip == nil || ip.To4() != nil {
+			return fmt.Errorf("%q is not an IPv6 address", t.Value)
+		}
+	case TypeCNAME:
+		if t.Value == "" {
+			return errors.New("a CNAME record needs a target host")
+		}
+	default:
+		return fmt.Errorf("unsupported record type %q, use A, AAAA or CNAME", t.Type)
+	}
+	if t.TTL <= 0 {
+		return errors.New("the TTL must be positive")
+	}
+	return nil
+}
+
+// For returns the record of a host
+func (t Template) For(host string) Record {
+	return Record{
+		Name:  strings.TrimSuffix(strings.ToLower(host), "."),
+		Type:  t.Type,
+		Value: strings.TrimSuffix(t.Value, "."),
+		TTL:   t.TTL,
+	}
+}
+
+// Resolver looks up the answers a record produces; *net.Resolver implements it
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+	LookupCNAME(ctx context.Context, host string) (string, error)
+}
+
+// NewResolver returns the system resolver, or a resolver querying server
+// (host:port) when set. Querying the authoritative server avoids waiting for
+// stale and negative answers to expire from a cache.
+func NewResolver(server string) *net.Resolver {
+	if server == "" {
+		return net.DefaultResolver
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// Propagated reports whether the resolver answers with the value of a record.
+// A name that does not resolve yet is not an error.
+func Propagated(ctx context.Context, resolver Resolver, record Record) (bool, error) {
+	if record.Type == TypeCNAME {
+		target, err := resolver.LookupCNAME(ctx, record.Name)
+		if err != nil {
+			return false, notFound(err)
+		}
+		return strings.EqualFold(strings.TrimSuffix(target, "."), record.Value), nil
+	}
+
+	addrs, err := resolver.LookupIPAddr(ctx, record.Name)
+	if err != nil {
+		return false, notFound(err)
+	}
+	want := net.ParseIP(record.Value)
+	for _, addr := range addrs {
+		if addr.IP.Equal(want) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// notFound hides the errors of names that do not resolve yet
+func notFound(err error) error {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,101 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// httpTimeout bounds a request when the context has no deadline
+const httpTimeout = 30 * time.Second
+
+// HTTPConfig configures a DNS HTTP API
+type HTTPConfig struct {
+	URL   string // Base URL; records live at <URL>/records/<name>/<type>
+	Token string // Sent as a bearer token when set
+}
+
+// HTTP writes records through a minimal REST API, for providers reached
+// through a small adapter or an in-house DNS service. Upsert sends
+// PUT <URL>/records/<name>/<type> with the record as JSON; Delete sends
+// DELETE to the same URL, and a 404 counts as deleted.
+type HTTP struct {
+	config HTTPConfig
+	client *http.Client
+}
+
+// NewHTTP creates an HTTP API provider
+func NewHTTP(config HTTPConfig) (*HTTP, error) {
+	if config.URL == "" {
+		return nil, errors.New("the DNS HTTP API needs a URL")
+	}
+	u, err := url.Parse(config.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid DNS HTTP API URL %q", config.URL)
+	}
+	config.URL = strings.TrimRight(config.URL, "/")
+
+	return &HTTP{config: config, client: &http.Client{}}, nil
+}
+
+// Upsert creates or replaces the record
+func (h *HTTP) Upsert(ctx context.Context, record Record) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := h.do(ctx, http.MethodPut, record, body); err != nil {
+		return fmt.Errorf("failed to update %s %s: %w", record.Name, record.Type, err)
+	}
+	return nil
+}
+
+// Delete removes the record
+func (h *HTTP) Delete(ctx context.Context, record Record) error {
+	status, err := h.do(ctx, http.MethodDelete, record, nil)
+	if err != nil && status != http.StatusNotFound {
+		return fmt.Errorf("failed to delete %s %s: %w", record.Name, record.Type, err)
+	}
+	return nil
+}
+
+// do sends a request for a record and returns the status, with an error
+// for responses other than 2xx
+func (h *HTTP) do(ctx context.Context, method string, record Record, body []byte) (int, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, httpTimeout)
+		defer cancel()
+	}
+
+	endpoint := h.config.URL + "/records/" + url.PathEscape(record.Name) + "/" + url.PathEscape(record.Type)
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if h.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.config.Token)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.StatusCode, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,406 @@
+package dns
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// DNS wire format constants used by dynamic updates
+const (
+	opcodeUpdate = 5
+	classIN      = 1
+	classANY     = 255
+	typeSOA      = 6
+	typeTSIG     = 250
+	tsigFudge    = 300
+	rcodeNotAuth = 9
+)
+
+// rfc2136Timeout bounds an update when the context has no deadline
+const rfc2136Timeout = 10 * time.Second
+
+// wireTypes are the type codes of the record types
+var wireTypes = map[string]uint16{TypeA: 1, TypeCNAME: 5, TypeAAAA: 28}
+
+// tsigAlgorithms are the supported TSIG algorithms
+var tsigAlgorithms = map[string]func() hash.Hash{
+	"hmac-sha1":   sha1.New,
+	"hmac-sha256": sha256.New,
+	"hmac-sha512": sha512.New,
+}
+
+// rcodeNames name the response codes of failed updates
+var rcodeNames = map[int]string{
+	1: "FORMERR", 2: "SERVFAIL", 3: "NXDOMAIN", 4: "NOTIMP", 5: "REFUSED",
+	6: "YXDOMAIN", 7: "YXRRSET", 8: "NXRRSET", 9: "NOTAUTH", 10: "NOTZONE",
+	16: "BADSIG", 17: "BADKEY", 18: "BADTIME",
+}
+
+// RFC2136Config configures dynamic updates of a zone
+type RFC2136Config struct {
+	Server        string // host:port of the primary server; port 53 when omitted
+	Zone          string // Zone holding the records, e.g. the service domain
+	TSIGKey       string // Name of the TSIG key; updates are unsigned when empty
+	TSIGSecret    string // Base64 secret of the key
+	TSIGAlgorithm string // hmac-sha1, hmac-sha256 or hmac-sha512
+}
+
+// RFC2136 writes records with DNS UPDATE messages (RFC 2136) sent over TCP
+// and signed with TSIG (RFC 8945), as accepted by BIND, Knot, PowerDNS and
+// most primary servers
+type RFC2136 struct {
+	config RFC2136Config
+	secret []byte
+	newMAC func() hash.Hash
+}
+
+// NewRFC2136 creates an RFC 2136 provider
+func NewRFC2136(config RFC2136Config) (*RFC2136, error) {
+	if config.Server == "" || config.Zone == "" {
+		return nil, errors.New("RFC 2136 updates need a server and a zone")
+	}
+	if _, _, err := net.SplitHostPort(config.Server); err != nil {
+		config.Server = net.JoinHostPort(config.Server, "53")
+	}
+	config.Zone = strings.TrimSuffix(strings.ToLower(config.Zone), ".")
+
+	p := &RFC2136{config: config}
+	if config.TSIGKey != "" {
+		if config.TSIGAlgorithm == "" {
+			config.TSIGAlgorithm = "hmac-sha256"
+		}
+		config.TSIGAlgorithm = strings.TrimSuffix(strings.ToLower(config.TSIGAlgorithm), ".")
+		newMAC, ok := tsigAlgorithms[config.TSIGAlgorithm]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TSIG algorithm %q", config.TSIGAlgorithm)
+		}
+		secret, err := base64.StdEncoding.DecodeString(config.TSIGSecret)
+		if err != nil || len(secret) == 0 {
+			return nil, errors.New("the TSIG secret must be base64")
+		}
+		config.TSIGKey = strings.TrimSuffix(strings.ToLower(config.TSIGKey), ".")
+		p.config, p.secret, p.newMAC = config, secret, newMAC
+	}
+	return p, nil
+}
+
+// Upsert replaces the records of the name and type with the record
+func (p *RFC2136) Upsert(ctx context.Context, record Record) error {
+	rdata, err := encodeRData(record)
+	if err != nil {
+		return err
+	}
+
+	var updates []byte
+	updates = appendRR(updates, record.Name, wireTypes[record.Type], classANY, 0, nil)
+	updates = appendRR(updates, record.Name, wireTypes[record.Type], classIN, uint32(record.TTL), rdata)
+	return p.update(ctx, record, updates, 2)
+}
+
+// Delete removes the records of the name and type
+func (p *RFC2136) Delete(ctx context.Context, record Record) error {
+	if _, ok := wireTypes[record.Type]; !ok {
+		return fmt.Errorf("unsupported record type %q", record.Type)
+	}
+	updates := appendRR(nil, record.Name, wireTypes[record.Type], classANY, 0, nil)
+	return p.update(ctx, record, updates, 1)
+}
+
+// update sends an UPDATE message for the zone and checks the response
+func (p *RFC2136) update(ctx context.Context, record Record, updates []byte, count int) error {
+	if record.Name != p.config.Zone && !strings.HasSuffix(record.Name, "."+p.config.Zone) {
+		return fmt.Errorf("%s is outside the zone %s", record.Name, p.config.Zone)
+	}
+
+	var idBytes [2]byte
+	if _, err := rand.Read(idBytes[:]); err != nil {
+		return err
+	}
+	id := binary.BigEndian.Uint16(idBytes[:])
+
+	msg := make([]byte, 12)
+	binary.BigEndian.PutUint16(msg[0:], id)
+	binary.BigEndian.PutUint16(msg[2:], opcodeUpdate<<11)
+	binary.BigEndian.PutUint16(msg[4:], 1) // Zone section
+	binary.BigEndian.PutUint16(msg[8:], uint16(count))
+	msg = appendName(msg, p.config.Zone)
+	msg = binary.BigEndian.AppendUint16(msg, typeSOA)
+	msg = binary.BigEndian.AppendUint16(msg, classIN)
+	msg = append(msg, updates...)
+
+	var requestMAC []byte
+	if p.secret != nil {
+		msg, requestMAC = p.sign(msg, id, time.Now())
+	}
+
+	response, err := p.exchange(ctx, msg)
+	if err != nil {
+		return fmt.Errorf("failed to update %s %s: %w", record.Name, record.Type, err)
+	}
+	return p.check(response, id, requestMAC)
+}
+
+// sign appends a TSIG record to a message and returns it with its MAC
+func (p *RFC2136) sign(msg []byte, id uint16, now time.Time) ([]byte, []byte) {
+	signed := uint64(now.Unix())
+
+	mac := hmac.New(p.newMAC, p.secret)
+	mac.Write(msg)
+	mac.Write(p.tsigVariables(signed, 0))
+	sum := mac.Sum(nil)
+
+	var rdata []byte
+	rdata = appendName(rdata, p.config.TSIGAlgorithm)
+	rdata = appendTime(rdata, signed)
+	rdata = binary.BigEndian.AppendUint16(rdata, tsigFudge)
+	rdata = binary.BigEndian.AppendUint16(rdata, uint16(len(sum)))
+	rdata = append(rdata, sum...)
+	rdata = binary.BigEndian.AppendUint16(rdata, id)
+	rdata = binary.BigEndian.AppendUint16(rdata, 0) // Error
+	rdata = binary.BigEndian.AppendUint16(rdata, 0) // Other length
+
+	signedMsg := appendRR(append([]byte(nil), msg...), p.config.TSIGKey, typeTSIG, classANY, 0, rdata)
+	binary.BigEndian.PutUint16(signedMsg[10:], binary.BigEndian.Uint16(msg[10:])+1)
+	return signedMsg, sum
+}
+
+// tsigVariables returns the TSIG fields covered by the MAC
+func (p *RFC2136) tsigVariables(signed uint64, tsigError uint16) []byte {
+	var b []byte
+	b = appendName(b, p.config.TSIGKey)
+	b = binary.BigEndian.AppendUint16(b, classANY)
+	b = binary.BigEndian.AppendUint32(b, 0) // TTL
+	b = appendName(b, p.config.TSIGAlgorithm)
+	b = appendTime(b, signed)
+	b = binary.BigEndian.AppendUint16(b, tsigFudge)
+	b = binary.BigEndian.AppendUint16(b, tsigError)
+	b = binary.BigEndian.AppendUint16(b, 0) // Other length
+	return b
+}
+
+// exchange sends a message over TCP and reads the response
+func (p *RFC2136) exchange(ctx context.Context, msg []byte) ([]byte, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, rfc2136Timeout)
+		defer cancel()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", p.config.Server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	framed := binary.BigEndian.AppendUint16(nil, uint16(len(msg)))
+	if _, err := conn.Write(append(framed, msg...)); err != nil {
+		return nil, err
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	response := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// check verifies the response to an update, and its TSIG when the request was signed
+func (p *RFC2136) check(response []byte, id uint16, requestMAC []byte) error {
+	if len(response) < 12 || binary.BigEndian.Uint16(response) != id || response[2]&0x80 == 0 {
+		return errors.New("malformed response to the update")
+	}
+	rcode := int(binary.BigEndian.Uint16(response[2:]) & 0x0f)
+
+	if requestMAC != nil {
+		tsigError, err := p.verify(response, requestMAC)
+		if tsigError != 0 {
+			rcode = int(tsigError)
+		} else if err != nil && rcode == 0 {
+			return err
+		}
+	}
+
+	if rcode != 0 {
+		name := rcodeNames[rcode]
+		if name == "" {
+			name = fmt.Sprintf("RCODE %d", rcode)
+		}
+		if rcode == rcodeNotAuth {
+			name += " (check the TSIG key and the update policy of the zone)"
+		}
+		return fmt.Errorf("update refused: %s", name)
+	}
+	return nil
+}
+
+// verify checks the TSIG of a response. It returns the TSIG error the server
+// reported, if any.
+func (p *RFC2136) verify(response, requestMAC []byte) (uint16, error) {
+	start, end, err := lastRecord(response)
+	if err != nil {
+		return 0, err
+	}
+	if start < 0 {
+		return 0, errors.New("the response is not signed")
+	}
+
+	// Name, type, class, TTL and RDLENGTH precede the TSIG RDATA
+	offset, err := skipName(response, start)
+	if err != nil || offset+10 > end || binary.BigEndian.Uint16(response[offset:]) != typeTSIG {
+		return 0, errors.New("the response is not signed")
+	}
+	rdata := response[offset+10 : end]
+
+	algEnd, err := skipName(rdata, 0)
+	if err != nil || algEnd+10 > len(rdata) {
+		return 0, errors.New("malformed TSIG in the response")
+	}
+	signed := uint64(binary.BigEndian.Uint16(rdata[algEnd:]))<<32 | uint64(binary.BigEndian.Uint32(rdata[algEnd+2:]))
+	macSize := int(binary.BigEndian.Uint16(rdata[algEnd+8:]))
+	if algEnd+10+macSize+6 > len(rdata) {
+		return 0, errors.New("malformed TSIG in the response")
+	}
+	mac := rdata[algEnd+10 : algEnd+10+macSize]
+	tsigError := binary.BigEndian.Uint16(rdata[algEnd+10+macSize+2:])
+	if tsigError != 0 {
+		return tsigError, nil
+	}
+
+	// The MAC covers the request MAC, the response without its TSIG and the TSIG variables
+	unsigned := append([]byte(nil), response[:start]...)
+	binary.BigEndian.PutUint16(unsigned[10:], binary.BigEndian.Uint16(unsigned[10:])-1)
+
+	expected := hmac.New(p.newMAC, p.secret)
+	expected.Write(binary.BigEndian.AppendUint16(nil, uint16(len(requestMAC))))
+	expected.Write(requestMAC)
+	expected.Write(unsigned)
+	expected.Write(p.tsigVariables(signed, 0))
+	if !hmac.Equal(mac, expected.Sum(nil)) {
+		return 0, errors.New("the TSIG of the response does not match")
+	}
+	return 0, nil
+}
+
+// lastRecord returns the bounds of the last additional record of a message,
+// or -1 when it has none
+func lastRecord(msg []byte) (int, int, error) {
+	counts := [4]int{}
+	for i := range counts {
+		counts[i] = int(binary.BigEndian.Uint16(msg[4+2*i:]))
+	}
+	if counts[3] == 0 {
+		return -1, -1, nil
+	}
+
+	offset := 12
+	var err error
+	for i := 0; i < counts[0]; i++ {
+		if offset, err = skipName(msg, offset); err != nil {
+			return 0, 0, err
+		}
+		offset += 4
+	}
+
+	start := -1
+	for i := 0; i < counts[1]+counts[2]+counts[3]; i++ {
+		start = offset
+		if offset, err = skipName(msg, offset); err != nil {
+			return 0, 0, err
+		}
+		if offset+10 > len(msg) {
+			return 0, 0, errors.New("truncated record")
+		}
+		offset += 10 + int(binary.BigEndian.Uint16(msg[offset+8:]))
+		if offset > len(msg) {
+			return 0, 0, errors.New("truncated record")
+		}
+	}
+	return start, offset, nil
+}
+
+// skipName returns the offset after the name at offset, following no pointers
+func skipName(msg []byte, offset int) (int, error) {
+	for {
+		if offset >= len(msg) {
+			return 0, errors.New("truncated name")
+		}
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			return offset + 1, nil
+		case length&0xc0 == 0xc0:
+			return offset + 2, nil
+		}
+		offset += 1 + length
+	}
+}
+
+// encodeRData returns the wire form of the value of a record
+func encodeRData(record Record) ([]byte, error) {
+	switch record.Type {
+	case TypeA:
+		if ip := net.ParseIP(record.Value).To4(); ip != nil {
+			return ip, nil
+		}
+	case TypeAAAA:
+		if ip := net.ParseIP(record.Value); ip != nil && ip.To4() == nil {
+			return ip.To16(), nil
+		}
+	case TypeCNAME:
+		if record.Value != "" {
+			return appendName(nil, strings.ToLower(record.Value)), nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported record type %q", record.Type)
+	}
+	return nil, fmt.Errorf("invalid %s value %q", record.Type, record.Value)
+}
+
+// appendRR appends a resource record
+func appendRR(b []byte, name string, rtype, class uint16, ttl uint32, rdata []byte) []byte {
+	b = appendName(b, name)
+	b = binary.BigEndian.AppendUint16(b, rtype)
+	b = binary.BigEndian.AppendUint16(b, class)
+	b = binary.BigEndian.AppendUint32(b, ttl)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(rdata)))
+	return append(b, rdata...)
+}
+
+// appendName appends a domain name as uncompressed labels
+func appendName(b []byte, name string) []byte {
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" {
+			continue
+		}
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0)
+}
+
+// appendTime appends a 48-bit TSIG time
+func appendTime(b []byte, t uint64) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(t>>32))
+	return binary.BigEndian.AppendUint32(b, uint32(t))
+}
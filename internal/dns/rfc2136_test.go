@@ -0,0 +1,365 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testKey    = "update-key.example.com"
+	testSecret = "dHNpZy1zZWNyZXQtZm9yLXRlc3Rz" // tsig-secret-for-tests
+)
+
+// wireRR is a decoded resource record
+type wireRR struct {
+	name  string
+	rtype uint16
+	class uint16
+	ttl   uint32
+	rdata []byte
+	start int // Offset of the record in the message
+}
+
+// readName decodes a possibly compressed name at offset and returns it with
+// the offset after it
+func readName(msg []byte, offset int) (string, int, error) {
+	var labels []string
+	next := -1
+	for jumps := 0; ; {
+		if offset >= len(msg) {
+			return "", 0, errors.New("truncated name")
+		}
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			if next < 0 {
+				next = offset + 1
+			}
+			return strings.Join(labels, "."), next, nil
+		case length&0xc0 == 0xc0:
+			if offset+2 > len(msg) || jumps > 10 {
+				return "", 0, errors.New("bad pointer")
+			}
+			if next < 0 {
+				next = offset + 2
+			}
+			offset = int(binary.BigEndian.Uint16(msg[offset:]) & 0x3fff)
+			jumps++
+		default:
+			if offset+1+length > len(msg) {
+				return "", 0, errors.New("truncated label")
+			}
+			labels = append(labels, string(msg[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+}
+
+// decodeMessage decodes the header, the zone and the records of a message
+func decodeMessage(msg []byte) (header [6]uint16, zone string, records []wireRR, err error) {
+	if len(msg) < 12 {
+		return header, "", nil, errors.New("short message")
+	}
+	for i := range header {
+		header[i] = binary.BigEndian.Uint16(msg[2*i:])
+	}
+	offset := 12
+	if header[2] != 1 {
+		return header, "", nil, errors.New("expected one zone")
+	}
+	if zone, offset, err = readName(msg, offset); err != nil {
+		return header, "", nil, err
+	}
+	if offset+4 > len(msg) || binary.BigEndian.Uint16(msg[offset:]) != typeSOA {
+		return header, "", nil, errors.New("zone is not of type SOA")
+	}
+	offset += 4
+
+	for i := 0; i < int(header[3]+header[4]+header[5]); i++ {
+		rr := wireRR{start: offset}
+		if rr.name, offset, err = readName(msg, offset); err != nil {
+			return header, "", nil, err
+		}
+		if offset+10 > len(msg) {
+			return header, "", nil, errors.New("truncated record")
+		}
+		rr.rtype = binary.BigEndian.Uint16(msg[offset:])
+		rr.class = binary.BigEndian.Uint16(msg[offset+2:])
+		rr.ttl = binary.BigEndian.Uint32(msg[offset+4:])
+		length := int(binary.BigEndian.Uint16(msg[offset+8:]))
+		offset += 10
+		if offset+length > len(msg) {
+			return header, "", nil, errors.New("truncated rdata")
+		}
+		rr.rdata = msg[offset : offset+length]
+		offset += length
+		records = append(records, rr)
+	}
+	if offset != len(msg) {
+		return header, "", nil, errors.New("trailing bytes")
+	}
+	return header, zone, records, nil
+}
+
+// testTSIG builds the MAC of a message without its TSIG, written out from
+// RFC 8945 independently of the provider
+func testTSIG(t *testing.T, prefix, msg []byte, signed uint64) []byte {
+	t.Helper()
+	var vars []byte
+	vars = appendName(vars, testKey)
+	vars = append(vars, 0x00, 0xff, 0, 0, 0, 0)
+	vars = appendName(vars, "hmac-sha256")
+	vars = append(vars, byte(signed>>40), byte(signed>>32), byte(signed>>24), byte(signed>>16), byte(signed>>8), byte(signed))
+	vars = append(vars, 0x01, 0x2c, 0, 0, 0, 0)
+
+	mac := hmac.New(sha256.New, []byte("tsig-secret-for-tests"))
+	mac.Write(prefix)
+	mac.Write(msg)
+	mac.Write(vars)
+	return mac.Sum(nil)
+}
+
+// serveOnce answers one framed DNS message over TCP with the response of reply
+func serveOnce(t *testing.T, reply func(request []byte) []byte) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var length [2]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return
+		}
+		request := make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(conn, request); err != nil {
+			return
+		}
+		response := reply(request)
+		conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(response))), response...))
+	}()
+	return listener.Addr().String()
+}
+
+// signedResponse answers a request with rcode, signed with the test key. The
+// owner name of the TSIG is compressed against the zone.
+func signedResponse(t *testing.T, request []byte, rcode uint16, corrupt bool) []byte {
+	_, _, records, err := decodeMessage(request)
+	if err != nil || len(records) == 0 {
+		t.Errorf("undecodable request: %v", err)
+		return nil
+	}
+	tsig := records[len(records)-1].rdata
+	_, algEnd, _ := readName(tsig, 0)
+	requestMAC := tsig[algEnd+10 : algEnd+10+int(binary.BigEndian.Uint16(tsig[algEnd+8:]))]
+
+	msg := append([]byte(nil), request[:2]...)
+	msg = binary.BigEndian.AppendUint16(msg, 0xa800|rcode)
+	msg = append(msg, 0, 1, 0, 0, 0, 0, 0, 0)
+	msg = appendName(msg, "example.com")
+	msg = append(msg, 0, 6, 0, 1)
+
+	signed := uint64(time.Now().Unix())
+	prefix := binary.BigEndian.AppendUint16(nil, uint16(len(requestMAC)))
+	mac := testTSIG(t, append(prefix, requestMAC...), msg, signed)
+	if corrupt {
+		mac[0] ^= 0xff
+	}
+
+	var rdata []byte
+	rdata = appendName(rdata, "hmac-sha256")
+	rdata = appendTime(rdata, signed)
+	rdata = append(rdata, 0x01, 0x2c, 0, byte(len(mac)))
+	rdata = append(rdata, mac...)
+	rdata = append(rdata, request[:2]...)
+	rdata = append(rdata, 0, 0, 0, 0)
+
+	binary.BigEndian.PutUint16(msg[10:], 1)
+	msg = append(msg, byte(len("update-key")))
+	msg = append(msg, "update-key"...)
+	msg = append(msg, 0xc0, 12)
+	msg = append(msg, 0, 250, 0, 255, 0, 0, 0, 0)
+	msg = binary.BigEndian.AppendUint16(msg, uint16(len(rdata)))
+	return append(msg, rdata...)
+}
+
+func TestSignKnownVector(t *testing.T) {
+	p, err := NewRFC2136(RFC2136Config{
+		Server:     "127.0.0.1",
+		Zone:       "example.com",
+		TSIGKey:    "update-key",
+		TSIGSecret: testSecret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, _ := hex.DecodeString("123428000001000000000000" + "076578616d706c6503636f6d00" + "00060001")
+	signed, mac := p.sign(msg, 0x1234, time.Unix(1700000000, 0))
+
+	// HMAC-SHA256 of the message and the TSIG variables, computed separately
+	want := "0250dba26f49b47be9d1b778bf0fbd2cfadd5b14692d51aa5b6bf64e472672da"
+	if got := hex.EncodeToString(mac); got != want {
+		t.Fatalf("MAC = %s, want %s", got, want)
+	}
+
+	header, zone, records, err := decodeMessage(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header[5] != 1 || zone != "example.com" || len(records) != 1 {
+		t.Fatalf("header %v, zone %q, %d records", header, zone, len(records))
+	}
+	tsig := records[0]
+	if tsig.name != "update-key" || tsig.rtype != typeTSIG || tsig.class != classANY || tsig.ttl != 0 {
+		t.Fatalf("TSIG record %+v", tsig)
+	}
+	wantRData, _ := hex.DecodeString("0b686d61632d73686132353600" + "00006553f100" + "012c" + "0020" + want + "1234" + "0000" + "0000")
+	if !bytes.Equal(tsig.rdata, wantRData) {
+		t.Fatalf("TSIG rdata = %x, want %x", tsig.rdata, wantRData)
+	}
+	if !bytes.Equal(signed[:10], msg[:10]) || !bytes.Equal(signed[12:len(msg)], msg[12:]) {
+		t.Fatal("signing changed the message")
+	}
+}
+
+func TestUpdateRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		delete  bool
+		record  Record
+		records []wireRR
+	}{
+		{
+			name:   "upsert A",
+			record: Record{Name: "acme.example.com", Type: TypeA, Value: "192.0.2.10", TTL: 300},
+			records: []wireRR{
+				{name: "acme.example.com", rtype: 1, class: classANY},
+				{name: "acme.example.com", rtype: 1, class: classIN, ttl: 300, rdata: []byte{192, 0, 2, 10}},
+			},
+		},
+		{
+			name:   "upsert AAAA",
+			record: Record{Name: "acme.example.com", Type: TypeAAAA, Value: "2001:db8::1", TTL: 60},
+			records: []wireRR{
+				{name: "acme.example.com", rtype: 28, class: classANY},
+				{name: "acme.example.com", rtype: 28, class: classIN, ttl: 60, rdata: net.ParseIP("2001:db8::1")},
+			},
+		},
+		{
+			name:   "upsert CNAME",
+			record: Record{Name: "acme.example.com", Type: TypeCNAME, Value: "Front.Example.net", TTL: 3600},
+			records: []wireRR{
+				{name: "acme.example.com", rtype: 5, class: classANY},
+				{name: "acme.example.com", rtype: 5, class: classIN, ttl: 3600, rdata: appendName(nil, "front.example.net")},
+			},
+		},
+		{
+			name:   "delete",
+			delete: true,
+			record: Record{Name: "acme.example.com", Type: TypeA},
+			records: []wireRR{
+				{name: "acme.example.com", rtype: 1, class: classANY},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var request []byte
+			server := serveOnce(t, func(r []byte) []byte {
+				request = r
+				return signedResponse(t, r, 0, false)
+			})
+			p, err := NewRFC2136(RFC2136Config{Server: server, Zone: "Example.com.", TSIGKey: testKey + ".", TSIGSecret: testSecret})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.delete {
+				err = p.Delete(context.Background(), tt.record)
+			} else {
+				err = p.Upsert(context.Background(), tt.record)
+			}
+			if err != nil {
+				t.Fatalf("update failed: %v", err)
+			}
+
+			header, zone, records, err := decodeMessage(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if opcode := header[1] >> 11 & 0xf; opcode != opcodeUpdate || zone != "example.com" {
+				t.Fatalf("opcode %d, zone %q", opcode, zone)
+			}
+			if int(header[4]) != len(tt.records) || header[5] != 1 {
+				t.Fatalf("%d updates and %d additional records, want %d and 1", header[4], header[5], len(tt.records))
+			}
+			for i, want := range tt.records {
+				got := records[i]
+				if got.name != want.name || got.rtype != want.rtype || got.class != want.class || got.ttl != want.ttl || !bytes.Equal(got.rdata, want.rdata) {
+					t.Errorf("update %d = %+v, want %+v", i, got, want)
+				}
+			}
+
+			tsig := records[len(records)-1]
+			if tsig.name != testKey || tsig.rtype != typeTSIG {
+				t.Fatalf("last record %+v is not the TSIG", tsig)
+			}
+			algorithm, algEnd, _ := readName(tsig.rdata, 0)
+			signed := uint64(binary.BigEndian.Uint16(tsig.rdata[algEnd:]))<<32 | uint64(binary.BigEndian.Uint32(tsig.rdata[algEnd+2:]))
+			mac := tsig.rdata[algEnd+10 : algEnd+10+int(binary.BigEndian.Uint16(tsig.rdata[algEnd+8:]))]
+			unsigned := append([]byte(nil), request[:tsig.start]...)
+			binary.BigEndian.PutUint16(unsigned[10:], 0)
+			if algorithm != "hmac-sha256" || !hmac.Equal(mac, testTSIG(t, nil, unsigned, signed)) {
+				t.Errorf("request TSIG does not verify")
+			}
+		})
+	}
+}
+
+func TestUpdateChecksResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		rcode   uint16
+		corrupt bool
+		err     string
+	}{
+		{name: "refused", rcode: 5, err: "update refused: REFUSED"},
+		{name: "not authoritative", rcode: rcodeNotAuth, err: "NOTAUTH (check the TSIG key"},
+		{name: "bad MAC", corrupt: true, err: "the TSIG of the response does not match"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := serveOnce(t, func(r []byte) []byte {
+				return signedResponse(t, r, tt.rcode, tt.corrupt)
+			})
+			p, err := NewRFC2136(RFC2136Config{Server: server, Zone: "example.com", TSIGKey: testKey, TSIGSecret: testSecret})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = p.Upsert(context.Background(), Record{Name: "acme.example.com", Type: TypeA, Value: "192.0.2.10", TTL: 300})
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
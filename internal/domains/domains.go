@@ -0,0 +1,315 @@
+// Package domains attaches custom domains to tenants. A domain is routed to
+// its tenant once the owner proved control of it with a DNS TXT record.
+package domains
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"odoo-signup/internal/events"
+	"odoo-signup/internal/models"
+	"odoo-signup/internal/routing"
+	"odoo-signup/internal/tenants"
+	"odoo-signup/internal/util"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ChallengePrefix is prepended to a domain to name its TXT challenge record
+const ChallengePrefix = "_odoo-signup-challenge."
+
+// lookupTimeout bounds one DNS lookup
+const lookupTimeout = 10 * time.Second
+
+// Errors returned by domain operations
+var (
+	ErrInvalidDomain   = errors.New("invalid domain name")
+	ErrReservedDomain  = errors.New("subdomains of the service domain are assigned by tenant name")
+	ErrDomainTaken     = errors.New("domain is already attached to a tenant")
+	ErrDomainNotFound  = errors.New("domain is not attached to the tenant")
+	ErrNotVerified     = errors.New("domain is not verified")
+	ErrChallengeFailed = errors.New("TXT challenge record not found")
+)
+
+// hostnamePattern matches a lowercase DNS name with at least two labels
+var hostnamePattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+// Resolver looks up TXT records; *net.Resolver implements it
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// Challenge is the TXT record proving control of a domain
+type Challenge struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// ChallengeFor returns the record the owner of a domain must publish
+func ChallengeFor(domain tenants.Domain) Challenge {
+	return Challenge{Name: ChallengePrefix + domain.Name, Value: "odoo-signup-verification=" + domain.Challenge}
+}
+
+// Service manages the custom domains in the tenant registry
+type Service struct {
+	registry tenants.Store
+	manager  *tenants.Manager
+	resolver Resolver
+	bus      *events.Bus
+	domain   string // Service domain tenants get a subdomain of
+}
+
+// NewService creates a domain service; base URL changes are written to the
+// tenant through the manager
+func NewService(config *models.Config, registry tenants.Store, manager *tenants.Manager, resolver Resolver, bus *events.Bus) *Service {
+	return &Service{
+		registry: registry,
+		manager:  manager,
+		resolver: resolver,
+		bus:      bus,
+		domain:   strings.ToLower(config.Domain),
+	}
+}
+
+// Add attaches an unverified domain to a tenant and returns the challenge to
+// publish. Adding a domain again returns its existing challenge.
+func (s *Service) Add(database, name string) (tenants.Domain, Challenge, error) {
+	name, err := s.normalize(name)
+	if err != nil {
+		return tenants.Domain{}, Challenge{}, err
+	}
+
+	if _, err := s.tenant(database); err != nil {
+		return tenants.Domain{}, Challenge{}, err
+	}
+	if owner, ok := s.Owner(name); ok && owner != database {
+		return tenants.Domain{}, Challenge{}, ErrDomainTaken
+	}
+
+	var domain tenants.Domain
+	added := false
+	_, err = s.update(database, func(tenant *tenants.Tenant) error {
+		if existing, err := find(*tenant, name); err == nil {
+			domain = *existing
+			return nil
+		}
+		domain = tenants.Domain{Name: name, Challenge: util.NewID(), CreatedAt: time.Now().UTC()}
+		tenant.Domains = append(tenant.Domains, domain)
+		added = true
+		return nil
+	})
+	if err != nil {
+		return tenants.Domain{}, Challenge{}, err
+	}
+
+	if added {
+		logrus.WithFields(logrus.Fields{"database": database, "domain": name}).Info("Custom domain added")
+	}
+	return domain, ChallengeFor(domain), nil
+}
+
+// Verify looks up the challenge of a domain and routes the domain to the
+// tenant when the record is found. primary also makes it the tenant's base URL.
+func (s *Service) Verify(database, name, actor string, primary bool) (tenants.Domain, error) {
+	name = strings.ToLower(name)
+	tenant, err := s.tenant(database)
+	if err != nil {
+		return tenants.Domain{}, err
+	}
+	domain, err := find(tenant, name)
+	if err != nil {
+		return tenants.Domain{}, err
+	}
+
+	if !domain.Verified() {
+		// The lookup runs outside the registry transaction, which only records its outcome
+		if err := s.check(*domain); err != nil {
+			return *domain, err
+		}
+		now := time.Now().UTC()
+		verified := false
+		tenant, err = s.update(database, func(tenant *tenants.Tenant) error {
+			current, err := find(*tenant, name)
+			if err != nil {
+				return err
+			}
+			if !current.Verified() {
+				current.VerifiedAt = &now
+				verified = true
+			}
+			return nil
+		})
+		if err != nil {
+			return tenants.Domain{}, err
+		}
+		if domain, err = find(tenant, name); err != nil {
+			return tenants.Domain{}, err
+		}
+
+		if verified {
+			s.bus.Publish(events.DomainVerified{Tenant: tenants.EventTenant(tenant), Domain: domain.Name, Actor: actor, At: now})
+			logrus.WithFields(logrus.Fields{"database": tenant.Database, "domain": domain.Name, "actor": actor}).Info("Custom domain verified")
+		}
+	}
+
+	if primary && !domain.Primary {
+		return s.SetPrimary(database, domain.Name)
+	}
+	return *domain, nil
+}
+
+// SetPrimary makes a verified domain the base URL of the tenant; an empty
+// name goes back to the instance URL
+func (s *Service) SetPrimary(database, name string) (tenants.Domain, error) {
+	name = strings.ToLower(name)
+	var primary tenants.Domain
+	tenant, err := s.update(database, func(tenant *tenants.Tenant) error {
+		if name != "" {
+			domain, err := find(*tenant, name)
+			if err != nil {
+				return err
+			}
+			if !domain.Verified() {
+				return ErrNotVerified
+			}
+		}
+		for i := range tenant.Domains {
+			tenant.Domains[i].Primary = tenant.Domains[i].Name == name
+			if tenant.Domains[i].Primary {
+				primary = tenant.Domains[i]
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return tenants.Domain{}, err
+	}
+
+	if err := s.manager.SetBaseURL(tenant); err != nil {
+		return primary, err
+	}
+	return primary, nil
+}
+
+// Remove detaches a domain from a tenant. A removed primary domain gives the
+// base URL back to the instance URL.
+func (s *Service) Remove(database, name, actor string) error {
+	name = strings.ToLower(name)
+	var removed tenants.Domain
+	tenant, err := s.update(database, func(tenant *tenants.Tenant) error {
+		domain, err := find(*tenant, name)
+		if err != nil {
+			return err
+		}
+		removed = *domain
+
+		var kept []tenants.Domain
+		for _, d := range tenant.Domains {
+			if d.Name != removed.Name {
+				kept = append(kept, d)
+			}
+		}
+		tenant.Domains = kept
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logger := logrus.WithFields(logrus.Fields{"database": tenant.Database, "domain": removed.Name, "actor": actor})
+	if removed.Primary {
+		if err := s.manager.SetBaseURL(tenant); err != nil {
+			logger.WithError(err).Warn("Failed to reset web.base.url after removing the primary domain")
+		}
+	}
+	if removed.Verified() {
+		s.bus.Publish(events.DomainRemoved{Tenant: tenants.EventTenant(tenant), Domain: removed.Name, Actor: actor, At: time.Now().UTC()})
+	}
+	logger.Info("Custom domain removed")
+	return nil
+}
+
+// Owner returns the tenant a domain is attached to
+func (s *Service) Owner(name string) (string, bool) {
+	for _, tenant := range s.registry.List() {
+		if _, err := find(tenant, name); err == nil {
+			return tenant.Database, true
+		}
+	}
+	return "", false
+}
+
+// Routes returns every host of active and suspended tenants, sorted by host
+func (s *Service) Routes() []routing.Route {
+	return routing.Table(s.registry)
+}
+
+// check looks for the challenge record of a domain
+func (s *Service) check(domain tenants.Domain) error {
+	challenge := ChallengeFor(domain)
+
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+	records, err := s.resolver.LookupTXT(ctx, challenge.Name)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return fmt.Errorf("%w: %s", ErrChallengeFailed, challenge.Name)
+		}
+		return fmt.Errorf("failed to look up %s: %w", challenge.Name, err)
+	}
+
+	for _, record := range records {
+		if strings.TrimSpace(record) == challenge.Value {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s has no record %q", ErrChallengeFailed, challenge.Name, challenge.Value)
+}
+
+// normalize checks a domain name and returns it in lowercase without a trailing dot
+func (s *Service) normalize(name string) (string, error) {
+	name = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+	if len(name) > 253 || !hostnamePattern.MatchString(name) {
+		return "", ErrInvalidDomain
+	}
+	if name == s.domain || strings.HasSuffix(name, "."+s.domain) {
+		return "", ErrReservedDomain
+	}
+	return name, nil
+}
+
+// update changes the domains of a registered tenant in one registry transaction
+func (s *Service) update(database string, fn func(tenant *tenants.Tenant) error) (tenants.Tenant, error) {
+	tenant, err := s.registry.Update(database, fn)
+	if errors.Is(err, tenants.ErrNotRegistered) {
+		return tenant, tenants.ErrNotFound
+	}
+	return tenant, err
+}
+
+// tenant returns a registered tenant with its own copy of the domains, so
+// changes reach the registry only through update
+func (s *Service) tenant(database string) (tenants.Tenant, error) {
+	tenant, ok := s.registry.Get(database)
+	if !ok {
+		return tenants.Tenant{}, tenants.ErrNotFound
+	}
+	tenant.Domains = append([]tenants.Domain(nil), tenant.Domains...)
+	return tenant, nil
+}
+
+// find returns the domain of a tenant by name, pointing into tenant.Domains
+func find(tenant tenants.Tenant, name string) (*tenants.Domain, error) {
+	for i := range tenant.Domains {
+		if tenant.Domains[i].Name == name {
+			return &tenant.Domains[i], nil
+		}
+	}
+	return nil, ErrDomainNotFound
+}
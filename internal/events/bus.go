@@ -0,0 +1,108 @@
+package events
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// queueSize is the buffer of each shard; publishers block when it is full
+const queueSize = 1024
+
+// Handler processes an event. Errors and panics are logged and never reach the publisher.
+type Handler func(Event) error
+
+type subscriber struct {
+	name    string
+	handler Handler
+}
+
+// Bus delivers events to subscribers asynchronously. Events are sharded by
+// tenant so each tenant's events are handled in order by a single goroutine.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers []subscriber
+	shards      []chan Event
+	wg          sync.WaitGroup
+	closeOnce   sync.Once
+}
+
+// NewBus starts a bus with the given number of delivery goroutines
+func NewBus(shards int) *Bus {
+	if shards < 1 {
+		shards = 1
+	}
+
+	b := &Bus{shards: make([]chan Event, shards)}
+	for i := range b.shards {
+		b.shards[i] = make(chan Event, queueSize)
+		b.wg.Add(1)
+		go b.run(b.shards[i])
+	}
+
+	return b
+}
+
+// Subscribe registers a handler for all events
+func (b *Bus) Subscribe(name string, handler Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers = append(b.subscribers, subscriber{name: name, handler: handler})
+}
+
+// Publish queues an event for delivery. It is safe to call on a nil bus.
+func (b *Bus) Publish(event Event) {
+	if b == nil {
+		return
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(event.TenantKey()))
+	b.shards[h.Sum32()%uint32(len(b.shards))] <- event
+}
+
+// Close stops accepting events and waits for queued ones to be delivered
+func (b *Bus) Close() {
+	b.closeOnce.Do(func() {
+		for _, shard := range b.shards {
+			close(shard)
+		}
+		b.wg.Wait()
+	})
+}
+
+// run delivers the events of one shard in order
+func (b *Bus) run(shard chan Event) {
+	defer b.wg.Done()
+
+	for event := range shard {
+		b.mu.RLock()
+		subscribers := b.subscribers
+		b.mu.RUnlock()
+
+		for _, s := range subscribers {
+			b.deliver(s, event)
+		}
+	}
+}
+
+// deliver calls one subscriber, isolating the bus from its errors and panics
+func (b *Bus) deliver(s subscriber, event Event) {
+	logger := logrus.WithFields(logrus.Fields{
+		"subscriber": s.name,
+		"event":      event.Name(),
+		"tenant":     event.TenantKey(),
+	})
+
+	defer func() {
+		if r := recover(); r != nil {
+			logger.WithError(fmt.Errorf("panic: %v", r)).Error("Event subscriber panicked")
+		}
+	}()
+
+	if err := s.handler(event); err != nil {
+		logger.WithError(err).Error("Event subscriber failed")
+	}
+}
@@ -0,0 +1,211 @@
+package events
+
+import (
+	"time"
+
+	"odoo-signup/internal/models"
+)
+
+// Event is a domain event published on the bus. Events for the same tenant
+// are delivered to subscribers in publication order.
+type Event interface {
+	Name() string
+	TenantKey() string
+}
+
+// Tenant describes the tenant an event is about. It never carries credentials.
+type Tenant struct {
+	Database    string     `json:"database"`
+	InstanceURL string     `json:"instanceUrl"`
+	DbMode      string     `json:"dbMode,omitempty"`
+	Template    string     `json:"template,omitempty"`
+	Backend     string     `json:"backend,omitempty"` // Odoo server hosting the database
+	SourceIP    string     `json:"sourceIp,omitempty"`
+	Email       string     `json:"email,omitempty"`
+	FirstName   string     `json:"firstName,omitempty"`
+	LastName    string     `json:"lastName,omitempty"`
+	Phone       string     `json:"phone,omitempty"`
+	CompanyName string     `json:"companyName,omitempty"`
+	CountryCode string     `json:"countryCode,omitempty"`
+	Industry    string     `json:"industry,omitempty"`
+	CompanySize string     `json:"companySize,omitempty"`
+	Plan        string     `json:"plan,omitempty"`
+	UTM         models.UTM `json:"utm"`
+	JobID       string     `json:"jobId,omitempty"` // Job recording the provisioning run
+	TrialEndsAt *time.Time `json:"trialEndsAt,omitempty"`
+}
+
+// TenantKey returns the key used to order events per tenant
+func (t Tenant) TenantKey() string {
+	return t.Database
+}
+
+// NewTenant builds the event view of a signup request
+func NewTenant(req *models.SignupRequest, dbName, instanceURL, dbMode, template, sourceIP string) Tenant {
+	return Tenant{
+		Database:    dbName,
+		InstanceURL: instanceURL,
+		DbMode:      dbMode,
+		Template:    template,
+		SourceIP:    sourceIP,
+		Email:       req.Email,
+		FirstName:   req.FirstName,
+		LastName:    req.LastName,
+		Phone:       req.Phone,
+		CompanyName: req.CompanyName,
+		CountryCode: req.Country.Code,
+		Industry:    req.Industry,
+		CompanySize: req.CompanySize,
+		Plan:        req.Plan,
+		UTM:         req.UTM,
+	}
+}
+
+// SignupRequested is published when a valid signup starts provisioning
+type SignupRequested struct {
+	Tenant
+	At time.Time
+}
+
+// StepStarted is published when a provisioning step begins
+type StepStarted struct {
+	Tenant
+	Step string
+	At   time.Time
+}
+
+// StepCompleted is published when a provisioning step succeeds
+type StepCompleted struct {
+	Tenant
+	Step     string
+	Duration time.Duration
+}
+
+// StepFailed is published when a provisioning step fails
+type StepFailed struct {
+	Tenant
+	Step     string
+	Error    string
+	Duration time.Duration
+}
+
+// DNSRecordUpdated is published when provisioning creates, checks or
+// removes the DNS record of a tenant host. Status is created, propagated,
+// pending (not answered yet when the wait ended) or deleted.
+type DNSRecordUpdated struct {
+	Tenant
+	Record string // e.g. "acme.example.com A 203.0.113.10"
+	Status string
+}
+
+// TenantProvisioned is published when a tenant is ready for use
+type TenantProvisioned struct {
+	Tenant
+	Duration time.Duration
+}
+
+// ProvisioningFailed is published when provisioning stops with an error
+type ProvisioningFailed struct {
+	Tenant
+	Step     string
+	Error    string
+	Duration time.Duration
+}
+
+// TenantDeleted is published when a tenant database is dropped
+type TenantDeleted struct {
+	Tenant
+	At time.Time
+}
+
+// TenantErased is published when a tenant is dropped on its owner's request.
+// Its Tenant only holds the database and instance URL.
+type TenantErased struct {
+	Tenant
+	At time.Time
+}
+
+// TenantRenamed is published when a tenant database gets a new name and
+// instance URL. Tenant describes it under the new name.
+type TenantRenamed struct {
+	Tenant
+	From  string // Previous database name
+	Actor string
+	At    time.Time
+}
+
+// TenantMigrated is published when a tenant moved to another backend.
+// Tenant describes it on the new backend.
+type TenantMigrated struct {
+	Tenant
+	From  string // Backend the tenant moved from
+	Actor string
+	At    time.Time
+}
+
+// DomainVerified is published when a custom domain of a tenant passed its
+// DNS challenge and is routed to the tenant
+type DomainVerified struct {
+	Tenant
+	Domain string
+	Actor  string
+	At     time.Time
+}
+
+// DomainRemoved is published when a custom domain is detached from a tenant
+type DomainRemoved struct {
+	Tenant
+	Domain string
+	Actor  string
+	At     time.Time
+}
+
+// TenantSuspended is published when a tenant's users are locked out
+type TenantSuspended struct {
+	Tenant
+	Actor     string
+	Reason    string
+	Migration bool // Only locked out while a migration copies the tenant
+	At        time.Time
+}
+
+// TenantResumed is published when a suspended tenant's users are reactivated
+type TenantResumed struct {
+	Tenant
+	Actor     string
+	Migration bool // Ends the lockout of a migration
+	At        time.Time
+}
+
+// TrialEnding is published when a reminder that a trial ends is due
+type TrialEnding struct {
+	Tenant
+	EndsAt   time.Time
+	DaysLeft int
+}
+
+// TrialExpired is published when a tenant is suspended at the end of its
+// trial. DeleteAt is nil when expired trials are kept.
+type TrialExpired struct {
+	Tenant
+	EndsAt   time.Time
+	DeleteAt *time.Time
+}
+
+func (SignupRequested) Name() string    { return "signup.requested" }
+func (StepStarted) Name() string        { return "provisioning.step_started" }
+func (StepCompleted) Name() string      { return "provisioning.step_completed" }
+func (StepFailed) Name() string         { return "provisioning.step_failed" }
+func (DNSRecordUpdated) Name() string   { return "provisioning.dns_record" }
+func (TenantProvisioned) Name() string  { return "tenant.provisioned" }
+func (ProvisioningFailed) Name() string { return "tenant.provisioning_failed" }
+func (TenantDeleted) Name() string      { return "tenant.deleted" }
+func (TenantErased) Name() string       { return "tenant.erased" }
+func (TenantRenamed) Name() string      { return "tenant.renamed" }
+func (TenantMigrated) Name() string     { return "tenant.migrated" }
+func (DomainVerified) Name() string     { return "tenant.domain_verified" }
+func (DomainRemoved) Name() string      { return "tenant.domain_removed" }
+func (TenantSuspended) Name() string    { return "tenant.suspended" }
+func (TenantResumed) Name() string      { return "tenant.resumed" }
+func (TrialEnding) Name() string        { return "tenant.trial_ending" }
+func (TrialExpired) Name() string       { return "tenant.trial_expired" }
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"odoo-signup/internal/privacy"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// AccountRequest asks for an export or deletion of a tenant; the link to
+// confirm it is emailed to the tenant owner
+type AccountRequest struct {
+	Database string `json:"database" form:"database" binding:"required"`
+	Email    string `json:"email" form:"email" binding:"required,email"`
+	Kind     string `json:"kind" form:"kind" binding:"required,oneof=export delete"`
+}
+
+// accountRequestSent is the answer to every valid request, whether or not
+// the email owns the tenant
+const accountRequestSent = "If the email owns this instance, a confirmation link is on its way."
+
+// HandleCreateAccountRequest opens an export or deletion request from the API
+func (h *Handler) HandleCreateAccountRequest(c *gin.Context) {
+	var req AccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "A database, an email and a kind of export or delete are required",
+		})
+		return
+	}
+
+	if err := h.privacy.Open(req.Kind, strings.ToLower(req.Database), req.Email); err != nil {
+		logrus.WithError(err).WithField("database", req.Database).Error("Failed to open account request")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to process the request",
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"message": accountRequestSent,
+	})
+}
+
+// HandleAccountPage shows the export and deletion request form
+func (h *Handler) HandleAccountPage(c *gin.Context) {
+	h.renderAccount(c, http.StatusOK, gin.H{"Title": "Export or delete your data", "Mode": "form"})
+}
+
+// HandleAccountForm opens a request from the account page
+func (h *Handler) HandleAccountForm(c *gin.Context) {
+	var req AccountRequest
+	if err := c.ShouldBind(&req); err != nil {
+		h.renderAccount(c, http.StatusBadRequest, gin.H{
+			"Title": "Export or delete your data",
+			"Mode":  "form",
+			"Error": "Enter your subdomain and the email you signed up with.",
+		})
+		return
+	}
+
+	if err := h.privacy.Open(req.Kind, strings.ToLower(req.Database), req.Email); err != nil {
+		logrus.WithError(err).WithField("database", req.Database).Error("Failed to open account request")
+		h.renderAccount(c, http.StatusInternalServerError, gin.H{"Title": "Request failed", "Error": "Please try again later."})
+		return
+	}
+
+	h.renderAccount(c, http.StatusAccepted, gin.H{"Title": "Check your email", "Message": accountRequestSent})
+}
+
+// HandleAccountConfirmPage shows what the emailed link of a request does
+func (h *Handler) HandleAccountConfirmPage(c *gin.Context) {
+	token := c.Query("token")
+	req, err := h.privacy.Lookup(token)
+	if err != nil {
+		h.accountError(c, err)
+		return
+	}
+
+	switch {
+	case req.Status == privacy.StatusPending:
+		h.renderAccount(c, http.StatusOK, gin.H{
+			"Title":      "Confirm your request",
+			"Mode":       "confirm",
+			"Request":    req,
+			"Token":      token,
+			"CoolingOff": fmt.Sprintf("%d days", h.config.DeletionCoolingOffDays),
+		})
+	case req.Status == privacy.StatusScheduled:
+		h.renderAccount(c, http.StatusOK, gin.H{
+			"Title":   "Deletion scheduled",
+			"Mode":    "scheduled",
+			"Request": req,
+			"Token":   token,
+		})
+	default:
+		h.accountError(c, privacy.ErrNotPending)
+	}
+}
+
+// HandleAccountConfirm confirms a request, or cancels a scheduled deletion.
+// Confirmation takes a POST so that mail scanners following the link do not
+// trigger it.
+func (h *Handler) HandleAccountConfirm(c *gin.Context) {
+	token := c.PostForm("token")
+
+	if c.PostForm("action") == "cancel" {
+		if _, err := h.privacy.Cancel(token); err != nil {
+			h.accountError(c, err)
+			return
+		}
+		h.renderAccount(c, http.StatusOK, gin.H{"Title": "Deletion cancelled", "Message": "Your instance and its data are kept."})
+		return
+	}
+
+	req, err := h.privacy.Confirm(token)
+	if err != nil {
+		h.accountError(c, err)
+		return
+	}
+
+	if req.Kind == privacy.KindExport {
+		h.renderAccount(c, http.StatusOK, gin.H{"Title": "Export started", "Message": "We email you a download link once your data is ready."})
+		return
+	}
+	h.renderAccount(c, http.StatusOK, gin.H{
+		"Title":   "Deletion scheduled",
+		"Message": fmt.Sprintf("Your instance is deleted on %s. The email we sent you has a link to cancel until then.", req.DeleteAt.Format("January 2, 2006")),
+	})
+}
+
+// HandleAccountExport streams an export to its owner
+func (h *Handler) HandleAccountExport(c *gin.Context) {
+	req, reader, size, err := h.privacy.Download(c.Query("token"))
+	if err != nil {
+		h.accountError(c, err)
+		return
+	}
+	defer reader.Close()
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, req.Database))
+	c.DataFromReader(http.StatusOK, size, "application/zip", reader, nil)
+}
+
+// HandleListAccountRequests lists owners' export and deletion requests
+func (h *Handler) HandleListAccountRequests(c *gin.Context) {
+	requests, err := h.privacy.Store().List()
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list account requests")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to list account requests",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    requests,
+	})
+}
+
+// HandleErasures lists the proofs of erasure and whether their chain is intact
+func (h *Handler) HandleErasures(c *gin.Context) {
+	erasures := h.privacy.Erasures()
+	proofs, err := erasures.List()
+	var broken int
+	if err == nil {
+		broken, err = erasures.Verify()
+	}
+	if err != nil {
+		logrus.WithError(err).Error("Failed to read the erasure log")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to read the erasure log",
+		})
+		return
+	}
+
+	response := gin.H{
+		"success": true,
+		"data":    proofs,
+		"intact":  broken < 0,
+	}
+	if broken >= 0 {
+		response["brokenAt"] = broken
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// accountError renders the account page for a link that cannot be used
+func (h *Handler) accountError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, privacy.ErrNotFound), errors.Is(err, privacy.ErrNoExport):
+		h.renderAccount(c, http.StatusNotFound, gin.H{"Title": "Link not valid", "Error": "This link is not valid or has expired."})
+	case errors.Is(err, privacy.ErrExpired):
+		h.renderAccount(c, http.StatusGone, gin.H{"Title": "Link expired", "Error": "This link has expired. Request a new one."})
+	case errors.Is(err, privacy.ErrNotPending):
+		h.renderAccount(c, http.StatusConflict, gin.H{"Title": "Link already used", "Error": "This request was already confirmed or is finished."})
+	default:
+		logrus.WithError(err).Error("Account request failed")
+		h.renderAccount(c, http.StatusInternalServerError, gin.H{"Title": "Request failed", "Error": "Please try again later."})
+	}
+}
+
+// renderAccount renders the account page with the company details
+func (h *Handler) renderAccount(c *gin.Context, status int, data gin.H) {
+	data["OdooCompany"] = h.config.OdooCompany
+	data["Domain"] = h.config.Domain
+	if _, ok := data["Mode"]; !ok {
+		data["Mode"] = ""
+	}
+	c.HTML(status, "account.html", data)
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"odoo-signup/internal/backends"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// HandleListBackends lists the Odoo servers with their tenant counts
+func (h *Handler) HandleListBackends(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    h.backends.List(),
+	})
+}
+
+// HandleDrainBackend stops placing new tenants on a backend
+func (h *Handler) HandleDrainBackend(c *gin.Context) {
+	backend, err := h.backends.Drain(c.Param("name"), actorName(c))
+	if err != nil {
+		h.backendError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    backend,
+	})
+}
+
+// HandleUndrainBackend places new tenants on a drained backend again
+func (h *Handler) HandleUndrainBackend(c *gin.Context) {
+	backend, err := h.backends.Undrain(c.Param("name"))
+	if err != nil {
+		h.backendError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    backend,
+	})
+}
+
+// backendError maps pool errors to HTTP responses
+func (h *Handler) backendError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, backends.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, backends.ErrDraining), errors.Is(err, backends.ErrNotDraining),
+		errors.Is(err, backends.ErrLastBackend):
+		status = http.StatusConflict
+	}
+
+	if status == http.StatusInternalServerError {
+		logrus.WithError(err).WithField("backend", c.Param("name")).Error("Backend operation failed")
+	}
+
+	c.JSON(status, gin.H{
+		"success": false,
+		"message": err.Error(),
+	})
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"odoo-signup/internal/backups"
+	"odoo-signup/internal/tenants"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// RestoreTenantRequest selects the backup a tenant is restored from
+type RestoreTenantRequest struct {
+	Key        string `json:"key" binding:"required"` // As listed by GET /tenants/:name/backups
+	Replace    bool   `json:"replace"`
+	Copy       bool   `json:"copy"`
+	Neutralize bool   `json:"neutralize"`
+}
+
+// HandleListTenantBackups returns the stored backups of a tenant, newest
+// first. Backups of dropped tenants are listed too, so they can be restored.
+func (h *Handler) HandleListTenantBackups(c *gin.Context) {
+	database := c.Param("name")
+	if database == h.config.TemplateDatabase {
+		h.tenantError(c, tenants.ErrProtected)
+		return
+	}
+
+	archives, err := h.backups.List(database)
+	if err != nil {
+		logrus.WithError(err).WithField("tenant", database).Error("Failed to list backups")
+		c.JSON(http.StatusBadGateway, gin.H{
+			"success": false,
+			"message": "Failed to list backups",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    archives,
+	})
+}
+
+// HandleRestoreTenant queues a job restoring a stored backup into the tenant
+// database, or into a new database named by the path
+func (h *Handler) HandleRestoreTenant(c *gin.Context) {
+	var req RestoreTenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "A backup key is required",
+		})
+		return
+	}
+
+	actor := actorName(c)
+
+	job, _, err := h.restorer.Submit(backups.RestoreRequest{
+		Database:   c.Param("name"),
+		Key:        req.Key,
+		Replace:    req.Replace,
+		Copy:       req.Copy,
+		Neutralize: req.Neutralize,
+	}, actor)
+	if errors.Is(err, backups.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		h.tenantError(c, err)
+		return
+	}
+
+	job.Secret = ""
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"data":    job,
+	})
+}
+
+// HandleRunBackups starts a backup of every tenant outside the schedule
+func (h *Handler) HandleRunBackups(c *gin.Context) {
+	go h.backups.RunAll()
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"message": "Backup of all tenants started",
+	})
+}
@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"odoo-signup/internal/backups"
+	"odoo-signup/internal/domains"
+	"odoo-signup/internal/jobs"
+	"odoo-signup/internal/middleware"
+	"odoo-signup/internal/tenants"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ConsoleTemplates lists the admin console templates, loaded next to index.html
+var ConsoleTemplates = []string{
+	"./templates/admin/layout.html",
+	"./templates/admin/tenants.html",
+	"./templates/admin/tenant.html",
+	"./templates/admin/jobs.html",
+	"./templates/admin/job.html",
+	"./templates/admin/error.html",
+}
+
+// consoleJobLimit is the number of jobs shown on the job history pages
+const consoleJobLimit = 200
+
+// TemplateFuncs returns the helpers used by the admin console templates
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"datetime": func(v interface{}) string {
+			switch t := v.(type) {
+			case time.Time:
+				if !t.IsZero() {
+					return t.UTC().Format("2006-01-02 15:04:05 UTC")
+				}
+			case *time.Time:
+				if t != nil && !t.IsZero() {
+					return t.UTC().Format("2006-01-02 15:04:05 UTC")
+				}
+			}
+			return "-"
+		},
+		"duration": func(d time.Duration) string {
+			if d <= 0 {
+				return "-"
+			}
+			if d < time.Second {
+				return d.Round(time.Millisecond).String()
+			}
+			return d.Round(100 * time.Millisecond).String()
+		},
+		"ms": func(ms int64) string {
+			return (time.Duration(ms) * time.Millisecond).String()
+		},
+		"bytes": func(n int64) string {
+			const unit = 1024
+			if n < unit {
+				return fmt.Sprintf("%d B", n)
+			}
+			div, exp := int64(unit), 0
+			for m := n / unit; m >= unit; m /= unit {
+				div *= unit
+				exp++
+			}
+			return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+		},
+	}
+}
+
+// HandleConsoleTenants renders the tenant list with search and status filter
+func (h *Handler) HandleConsoleTenants(c *gin.Context) {
+	query := strings.ToLower(strings.TrimSpace(c.Query("q")))
+	status := c.Query("status")
+
+	data := h.consoleData(c, "Tenants", "tenants")
+	data["Notice"] = c.Query("notice")
+	data["Query"] = c.Query("q")
+	data["Status"] = status
+	data["Statuses"] = []string{
+		tenants.StatusProvisioning, tenants.StatusActive, tenants.StatusSuspended,
+		tenants.StatusFailed, tenants.StatusUnregistered,
+	}
+
+	list, err := h.tenants.List()
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list tenants for the console")
+		data["Error"] = err.Error()
+	}
+
+	matches := make([]tenants.Tenant, 0, len(list))
+	for _, tenant := range list {
+		if status != "" && tenant.Status != status {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(tenant.Database+" "+tenant.OwnerEmail+" "+tenant.CompanyName), query) {
+			continue
+		}
+		matches = append(matches, tenant)
+	}
+	data["Tenants"] = matches
+	data["Total"] = len(list)
+
+	c.HTML(http.StatusOK, "tenants.html", data)
+}
+
+// HandleConsoleTenant renders a tenant with its metadata, jobs and lifecycle actions
+func (h *Handler) HandleConsoleTenant(c *gin.Context) {
+	h.renderTenant(c, http.StatusOK, c.Query("notice"), "")
+}
+
+// HandleConsoleTenantAction runs a lifecycle action submitted from the tenant page
+func (h *Handler) HandleConsoleTenantAction(c *gin.Context) {
+	name := c.Param("name")
+	logger := logrus.WithFields(logrus.Fields{"tenant": name, "action": c.Param("action")})
+	if principal, ok := middleware.CurrentPrincipal(c); ok {
+		logger = logger.WithField("operator", principal.Name)
+	}
+
+	var notice string
+	var err error
+	switch c.Param("action") {
+	case "suspend":
+		_, err = h.tenants.Suspend(name, actorName(c), c.PostForm("reason"))
+		notice = "Tenant suspended"
+	case "resume":
+		_, err = h.tenants.Resume(name, actorName(c))
+		notice = "Tenant resumed"
+	case "extend-trial":
+		days, convErr := strconv.Atoi(c.PostForm("days"))
+		if convErr != nil || days <= 0 {
+			h.renderTenant(c, http.StatusBadRequest, "", "Enter the number of days to extend the trial by")
+			return
+		}
+		end := time.Now().AddDate(0, 0, days)
+		_, err = h.tenants.SetTrial(name, actorName(c), &end)
+		notice = "Trial extended until " + end.UTC().Format("2006-01-02")
+	case "end-trial":
+		_, err = h.tenants.SetTrial(name, actorName(c), nil)
+		notice = "Trial ended, the tenant is kept"
+	case "backup":
+		if _, err = h.tenants.Lookup(name); err == nil {
+			var archive backups.Archive
+			if archive, _, err = h.backups.Run(name); err != nil && archive.Key != "" {
+				logger.WithError(err).Warn("Failed to prune backups")
+				err = nil
+			}
+			if err == nil {
+				notice = "Backup stored at " + archive.Location
+			}
+		}
+	case "reset-password":
+		var newPassword string
+		if newPassword, err = h.tenants.ResetOwnerPassword(name, ""); err == nil {
+			// The generated password is shown once and never put in a URL
+			logger.Info("Tenant owner password reset from the console")
+			h.renderTenant(c, http.StatusOK, "Owner password reset. New password: "+newPassword, "")
+			return
+		}
+	case "rename":
+		var renamed tenants.Tenant
+		if renamed, err = h.tenants.Rename(name, c.PostForm("to"), actorName(c)); err == nil {
+			logger.WithField("to", renamed.Database).Info("Tenant renamed from the console")
+			c.Redirect(http.StatusSeeOther, "/admin/tenants/"+url.PathEscape(renamed.Database)+"?notice="+url.QueryEscape("Tenant renamed from "+name))
+			return
+		}
+	case "add-domain":
+		var domain tenants.Domain
+		if domain, _, err = h.domains.Add(name, c.PostForm("domain")); err == nil {
+			notice = "Domain " + domain.Name + " added; publish its TXT record, then verify it"
+		}
+	case "verify-domain":
+		if _, err = h.domains.Verify(name, c.PostForm("domain"), actorName(c), false); err == nil {
+			notice = "Domain " + c.PostForm("domain") + " verified"
+		}
+	case "primary-domain":
+		if _, err = h.domains.SetPrimary(name, c.PostForm("domain")); err == nil {
+			notice = "Base URL updated"
+		}
+	case "remove-domain":
+		if err = h.domains.Remove(name, c.PostForm("domain"), actorName(c)); err == nil {
+			notice = "Domain " + c.PostForm("domain") + " removed"
+		}
+	case "delete":
+		if c.PostForm("confirm") != name {
+			h.renderTenant(c, http.StatusBadRequest, "", "Type the database name to confirm the deletion")
+			return
+		}
+		if err = h.tenants.Delete(name); err == nil {
+			logger.Info("Tenant deleted from the console")
+			c.Redirect(http.StatusSeeOther, "/admin/?notice="+url.QueryEscape("Tenant "+name+" deleted"))
+			return
+		}
+	default:
+		h.ConsoleDeny(c, http.StatusNotFound, "Unknown action")
+		return
+	}
+
+	if err != nil {
+		logger.WithError(err).Warn("Console action failed")
+		h.renderTenant(c, consoleStatus(err), "", err.Error())
+		return
+	}
+
+	logger.Info("Console action completed")
+	c.Redirect(http.StatusSeeOther, "/admin/tenants/"+url.PathEscape(name)+"?notice="+url.QueryEscape(notice))
+}
+
+// HandleConsoleJobs renders the job history
+func (h *Handler) HandleConsoleJobs(c *gin.Context) {
+	filter := jobs.Filter{
+		Tenant: c.Query("tenant"),
+		Status: c.Query("status"),
+		Limit:  consoleJobLimit,
+	}
+
+	data := h.consoleData(c, "Jobs", "jobs")
+	data["Filter"] = filter
+	data["Statuses"] = []string{jobs.StatusQueued, jobs.StatusRunning, jobs.StatusSucceeded, jobs.StatusFailed}
+
+	list, err := h.jobs.Store().List(filter)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list jobs for the console")
+		data["Error"] = err.Error()
+	}
+	data["Jobs"] = list
+
+	c.HTML(http.StatusOK, "jobs.html", data)
+}
+
+// HandleConsoleJob renders a job with the timings of its steps
+func (h *Handler) HandleConsoleJob(c *gin.Context) {
+	job, err := h.jobs.Store().Get(c.Param("id"))
+	if err != nil {
+		h.ConsoleDeny(c, consoleStatus(err), err.Error())
+		return
+	}
+
+	data := h.consoleData(c, "Job "+job.ID, "jobs")
+	data["Job"] = job
+	data["Notice"] = c.Query("notice")
+	c.HTML(http.StatusOK, "job.html", data)
+}
+
+// HandleConsoleRetryJob queues a failed job for another attempt
+func (h *Handler) HandleConsoleRetryJob(c *gin.Context) {
+	job, _, err := h.jobs.Retry(c.Param("id"))
+	if err != nil {
+		h.ConsoleDeny(c, consoleStatus(err), err.Error())
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{"job_id": job.ID, "tenant": job.Tenant}).Info("Job retry queued from the console")
+	c.Redirect(http.StatusSeeOther, "/admin/jobs/"+url.PathEscape(job.ID)+"?notice="+url.QueryEscape("Retry queued"))
+}
+
+// HandleConsoleLogout ends the operator session and leaves the console
+func (h *Handler) HandleConsoleLogout(c *gin.Context) {
+	if session, ok := middleware.CurrentSession(c); ok {
+		h.sso.Sessions.Delete(session.ID)
+	}
+
+	h.setSessionCookie(c, "", -1)
+	c.Redirect(http.StatusSeeOther, "/")
+}
+
+// ConsoleDeny renders an error page for a rejected console request
+func (h *Handler) ConsoleDeny(c *gin.Context, status int, message string) {
+	data := h.consoleData(c, http.StatusText(status), "")
+	data["Message"] = message
+	c.HTML(status, "error.html", data)
+	c.Abort()
+}
+
+// renderTenant renders the tenant page with an optional notice or error
+func (h *Handler) renderTenant(c *gin.Context, status int, notice, failure string) {
+	name := c.Param("name")
+
+	tenant, err := h.tenants.Lookup(name)
+	if err != nil {
+		h.ConsoleDeny(c, consoleStatus(err), err.Error())
+		return
+	}
+
+	data := h.consoleData(c, tenant.Database, "tenants")
+	data["Tenant"] = tenant
+	data["Notice"] = notice
+	data["Error"] = failure
+
+	challenges := make(map[string]domains.Challenge, len(tenant.Domains))
+	for _, domain := range tenant.Domains {
+		challenges[domain.Name] = domains.ChallengeFor(domain)
+	}
+	data["Challenges"] = challenges
+
+	// Metadata needs a working database; failed and provisioning tenants may have none
+	if tenant.Status != tenants.StatusFailed && tenant.Status != tenants.StatusProvisioning {
+		if details, err := h.tenants.Get(name); err == nil {
+			data["Details"] = details
+		} else {
+			data["DetailsError"] = err.Error()
+		}
+	}
+
+	if list, err := h.jobs.Store().List(jobs.Filter{Tenant: name, Limit: consoleJobLimit}); err == nil {
+		data["Jobs"] = list
+	}
+
+	c.HTML(status, "tenant.html", data)
+}
+
+// consoleData returns the values every console page uses
+func (h *Handler) consoleData(c *gin.Context, title, section string) gin.H {
+	data := gin.H{
+		"Title":       title,
+		"Section":     section,
+		"OdooCompany": h.config.OdooCompany,
+	}
+	if principal, ok := middleware.CurrentPrincipal(c); ok {
+		data["Principal"] = principal
+	}
+	if session, ok := middleware.CurrentSession(c); ok {
+		data["CSRFToken"] = session.CSRFToken
+	}
+	return data
+}
+
+// consoleStatus maps errors of console actions to HTTP statuses
+func consoleStatus(err error) int {
+	switch {
+	case errors.Is(err, tenants.ErrNotFound), errors.Is(err, jobs.ErrNotFound),
+		errors.Is(err, domains.ErrDomainNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, tenants.ErrProtected):
+		return http.StatusForbidden
+	case errors.Is(err, tenants.ErrReasonRequired), errors.Is(err, tenants.ErrInvalidName),
+		errors.Is(err, domains.ErrInvalidDomain), errors.Is(err, domains.ErrReservedDomain):
+		return http.StatusBadRequest
+	case errors.Is(err, tenants.ErrSuspended), errors.Is(err, tenants.ErrNotSuspended),
+		errors.Is(err, tenants.ErrOwnerUnknown), errors.Is(err, tenants.ErrOwnerNotFound),
+		errors.Is(err, tenants.ErrExists), errors.Is(err, tenants.ErrNotRegistered),
+		errors.Is(err, tenants.ErrJobActive), errors.Is(err, tenants.ErrClaimed), errors.Is(err, tenants.ErrBackupsExist),
+		errors.Is(err, jobs.ErrNotRetryable), errors.Is(err, jobs.ErrTenantBusy), errors.Is(err, domains.ErrDomainTaken),
+		errors.Is(err, domains.ErrNotVerified), errors.Is(err, domains.ErrChallengeFailed):
+		return http.StatusConflict
+	case errors.Is(err, jobs.ErrBusy), errors.Is(err, jobs.ErrStopped):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadGateway
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"odoo-signup/internal/integration/odoo"
+	"odoo-signup/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// countryCache holds the country list read from Odoo for a limited time.
+// The lock only guards the fields; Odoo is read outside of it.
+type countryCache struct {
+	mu         sync.Mutex
+	countries  []models.CountryInfo
+	expiresAt  time.Time
+	refreshing chan struct{} // Closed when the running refresh finishes
+	err        error         // Error of the last refresh
+}
+
+// HandleCountries returns the countries available for signup
+func (h *Handler) HandleCountries(c *gin.Context) {
+	countries, err := h.getCountries()
+	if err != nil {
+		logrus.WithError(err).Error("Failed to load countries")
+		c.JSON(http.StatusBadGateway, gin.H{
+			"success": false,
+			"message": "Failed to load countries",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    countries,
+	})
+}
+
+// getCountries returns the cached country list, refreshing it when expired.
+// One request refreshes it at a time: the others get the stale list, or wait
+// for the refresh when nothing was cached yet.
+func (h *Handler) getCountries() ([]models.CountryInfo, error) {
+	cache := &h.countries
+	cache.mu.Lock()
+	if cache.countries != nil && time.Now().Before(cache.expiresAt) {
+		countries := cache.countries
+		cache.mu.Unlock()
+		return countries, nil
+	}
+	if wait := cache.refreshing; wait != nil {
+		countries := cache.countries
+		cache.mu.Unlock()
+		if countries != nil {
+			return countries, nil
+		}
+		<-wait
+		cache.mu.Lock()
+		defer cache.mu.Unlock()
+		if cache.countries == nil {
+			return nil, cache.err
+		}
+		return cache.countries, nil
+	}
+	done := make(chan struct{})
+	cache.refreshing = done
+	cache.mu.Unlock()
+
+	countries, err := h.fetchCountries()
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	defer close(done)
+	cache.refreshing = nil
+	cache.err = err
+	if err != nil {
+		// Serve stale data rather than failing when Odoo is briefly unavailable
+		if cache.countries != nil {
+			logrus.WithError(err).Warn("Failed to refresh countries, serving cached list")
+			return cache.countries, nil
+		}
+		return nil, err
+	}
+
+	cache.countries = countries
+	cache.expiresAt = time.Now().Add(time.Duration(h.config.CountryCacheTTLSeconds) * time.Second)
+	return countries, nil
+}
+
+// fetchCountries reads res.country from the template database, falling back to db.list_countries
+func (h *Handler) fetchCountries() ([]models.CountryInfo, error) {
+	rpcID := int(time.Now().UnixNano() % 1000000)
+	logger := logrus.WithField("database", h.config.TemplateDatabase)
+
+	countries, err := h.readTemplateCountries(rpcID)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to read countries from template database, using db.list_countries")
+
+		pairs, listErr := h.backends.Default().ListCountries(rpcID)
+		if listErr != nil {
+			return nil, fmt.Errorf("failed to list countries: %w", listErr)
+		}
+
+		countries = make([]models.CountryInfo, 0, len(pairs))
+		for _, pair := range pairs {
+			countries = append(countries, models.CountryInfo{
+				Code: strings.ToUpper(pair[0]),
+				Name: pair[1],
+			})
+		}
+	}
+
+	sort.Slice(countries, func(i, j int) bool {
+		return countries[i].Name < countries[j].Name
+	})
+
+	logger.WithField("count", len(countries)).Info("Country list refreshed")
+	return countries, nil
+}
+
+// readTemplateCountries reads code, name and phone code of all countries in the template database
+func (h *Handler) readTemplateCountries(rpcID int) ([]models.CountryInfo, error) {
+	client := h.backends.Default()
+	uid, err := client.Login(h.config.TemplateDatabase, h.config.AdminUser, h.config.AdminPassword, rpcID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := client.ExecuteKw(h.config.TemplateDatabase, uid, h.config.AdminPassword, "res.country", "search_read", []interface{}{[]interface{}{}, []interface{}{"code", "name", "phone_code"}}, rpcID)
+	if err != nil {
+		return nil, err
+	}
+
+	records, ok := result.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected res.country result: %v", result)
+	}
+
+	countries := make([]models.CountryInfo, 0, len(records))
+	for _, r := range records {
+		record, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		code, _ := record["code"].(string)
+		name, _ := record["name"].(string)
+		phoneCode, _ := odoo.ToInt(record["phone_code"])
+		countries = append(countries, models.CountryInfo{
+			Code:      strings.ToUpper(code),
+			Name:      name,
+			PhoneCode: phoneCode,
+		})
+	}
+
+	return countries, nil
+}
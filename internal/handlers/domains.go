@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"net/http"
+
+	"odoo-signup/internal/domains"
+	"odoo-signup/internal/tenants"
+
+	"github.com/gin-gonic/gin"
+)
+
+// domainRequest names a custom domain
+type domainRequest struct {
+	Domain string `json:"domain" binding:"required"`
+}
+
+// primaryDomainRequest names the domain to use as base URL; empty goes back
+// to the instance URL
+type primaryDomainRequest struct {
+	Domain string `json:"domain"`
+}
+
+// domainView is a custom domain with the challenge proving control of it
+type domainView struct {
+	tenants.Domain
+	Challenge domains.Challenge `json:"challenge"`
+}
+
+// HandleListTenantDomains lists the custom domains of a tenant
+func (h *Handler) HandleListTenantDomains(c *gin.Context) {
+	tenant, err := h.tenants.Lookup(c.Param("name"))
+	if err != nil {
+		h.tenantError(c, err)
+		return
+	}
+
+	views := make([]domainView, 0, len(tenant.Domains))
+	for _, domain := range tenant.Domains {
+		views = append(views, domainView{Domain: domain, Challenge: domains.ChallengeFor(domain)})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    views,
+		"baseUrl": tenant.BaseURL(),
+	})
+}
+
+// HandleAddTenantDomain attaches a domain to a tenant and returns the TXT
+// record to publish before verifying it
+func (h *Handler) HandleAddTenantDomain(c *gin.Context) {
+	var req domainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "A domain is required",
+		})
+		return
+	}
+
+	domain, challenge, err := h.domains.Add(c.Param("name"), req.Domain)
+	if err != nil {
+		h.tenantError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    domainView{Domain: domain, Challenge: challenge},
+	})
+}
+
+// HandleVerifyTenantDomain checks the challenge of a domain; primary=true
+// also makes it the base URL
+func (h *Handler) HandleVerifyTenantDomain(c *gin.Context) {
+	domain, err := h.domains.Verify(c.Param("name"), c.Param("domain"), actorName(c), c.Query("primary") == "true")
+	if err != nil {
+		h.tenantError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    domain,
+	})
+}
+
+// HandleSetPrimaryDomain sets the domain written to web.base.url
+func (h *Handler) HandleSetPrimaryDomain(c *gin.Context) {
+	var req primaryDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request format",
+		})
+		return
+	}
+
+	domain, err := h.domains.SetPrimary(c.Param("name"), req.Domain)
+	if err != nil {
+		h.tenantError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    domain,
+	})
+}
+
+// HandleRemoveTenantDomain detaches a domain from a tenant
+func (h *Handler) HandleRemoveTenantDomain(c *gin.Context) {
+	if err := h.domains.Remove(c.Param("name"), c.Param("domain"), actorName(c)); err != nil {
+		h.tenantError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Domain removed",
+	})
+}
+
+// HandleListDomains returns the host to database mapping the proxy routes by
+func (h *Handler) HandleListDomains(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    h.domains.Routes(),
+	})
+}
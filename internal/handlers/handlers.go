@@ -1,41 +1,80 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
-	"strings"
 	"time"
 
-	"odoo-signup/internal/integration/odoo"
+	"odoo-signup/internal/audit"
+	"odoo-signup/internal/auth"
+	"odoo-signup/internal/backends"
+	"odoo-signup/internal/backups"
+	"odoo-signup/internal/domains"
+	"odoo-signup/internal/imports"
+	"odoo-signup/internal/jobs"
+	"odoo-signup/internal/middleware"
+	"odoo-signup/internal/migrations"
 	"odoo-signup/internal/models"
+	"odoo-signup/internal/privacy"
+	"odoo-signup/internal/provisioning"
+	"odoo-signup/internal/tenants"
+	"odoo-signup/internal/webhooks"
 
 	"github.com/gin-gonic/gin"
-	"github.com/go-playground/validator/v10"
 	"github.com/sirupsen/logrus"
 )
 
+// maxFormBytes bounds the body of the public signup and password endpoints
+const maxFormBytes = 64 << 10
+
 // Handler holds dependencies for HTTP handlers
 type Handler struct {
-	config     *models.Config
-	odooClient *odoo.Client
-	validate   *validator.Validate
+	config      *models.Config
+	backends    *backends.Pool
+	provisioner *provisioning.Provisioner
+	webhooks    *webhooks.Dispatcher
+	tenants     *tenants.Manager
+	jobs        *jobs.Runner
+	imports     *imports.Importer
+	backups     *backups.Scheduler
+	restorer    *backups.Restorer
+	migrator    *migrations.Migrator
+	privacy     *privacy.Service
+	domains     *domains.Service
+	audit       *audit.Log
+	sso         *auth.SSO
+	countries   countryCache
 }
 
 // NewHandler creates a new handler instance
-func NewHandler(config *models.Config, odooClient *odoo.Client) *Handler {
+func NewHandler(config *models.Config, pool *backends.Pool, provisioner *provisioning.Provisioner, dispatcher *webhooks.Dispatcher, tenantManager *tenants.Manager, jobRunner *jobs.Runner, importer *imports.Importer, backupScheduler *backups.Scheduler, restorer *backups.Restorer, migrator *migrations.Migrator, accounts *privacy.Service, domainService *domains.Service, auditLog *audit.Log, sso *auth.SSO) *Handler {
 	return &Handler{
-		config:     config,
-		odooClient: odooClient,
-		validate:   validator.New(),
+		config:      config,
+		backends:    pool,
+		provisioner: provisioner,
+		webhooks:    dispatcher,
+		tenants:     tenantManager,
+		jobs:        jobRunner,
+		imports:     importer,
+		backups:     backupScheduler,
+		restorer:    restorer,
+		migrator:    migrator,
+		privacy:     accounts,
+		domains:     domainService,
+		audit:       auditLog,
+		sso:         sso,
 	}
 }
 
 // HandleSignup handles user signup requests
 func (h *Handler) HandleSignup(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxFormBytes)
+
 	var req models.SignupRequest
 
 	// Determine database mode from query param or config
-	dbMode := c.DefaultQuery("db_mode", h.config.DefaultDBMode)
+	dbMode := provisioning.NormalizeMode(c.DefaultQuery("db_mode", h.config.DefaultDBMode))
 
 	// Bind JSON
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -47,262 +86,63 @@ func (h *Handler) HandleSignup(c *gin.Context) {
 		return
 	}
 
-	// Validate request
-	if err := h.validate.Struct(req); err != nil {
+	// Validate and normalize request
+	if err := h.provisioner.Validate(&req); err != nil {
 		logrus.WithError(err).Warn("Validation failed for signup request")
-		c.JSON(http.StatusBadRequest, models.SignupResponse{
-			Success: false,
-			Message: "Validation failed: " + err.Error(),
-		})
-		return
-	}
-
-	// Sanitize and validate username
-	req.Username = strings.ToLower(strings.TrimSpace(req.Username))
-	if req.Username == "admin" || req.Username == "www" {
-		c.JSON(http.StatusBadRequest, models.SignupResponse{
+		response := models.SignupResponse{
 			Success: false,
-			Message: "Username not allowed",
-		})
+			Message: err.Error(),
+		}
+		var validationErr *provisioning.ValidationError
+		if errors.As(err, &validationErr) {
+			response.Errors = validationErr.Fields
+		}
+		c.JSON(http.StatusBadRequest, response)
 		return
 	}
 
-	// Generate database name (use just the username)
-	dbName := req.Username
-
-	logger := logrus.WithFields(logrus.Fields{
-		"username": req.Username,
-		"email":    req.Email,
-		"database": dbName,
+	// Provisioning runs as a job so it is recorded and can be retried from the console
+	payload := provisioning.NewJobPayload(&req, provisioning.Options{
+		DbMode:   dbMode,
+		SourceIP: c.ClientIP(),
 	})
-	logger.Info("Processing signup request")
-
-	// Generate unique RPC ID for this signup request
-	rpcID := int(time.Now().UnixNano() % 1000000)
-
-	// Check if database already exists by attempting authentication
-	exists, err := h.odooClient.DatabaseExists(dbName)
-	if err != nil {
-		logger.WithError(err).Error("Failed to check database existence")
-		c.JSON(http.StatusInternalServerError, models.SignupResponse{
-			Success: false,
-			Message: "Failed to validate database name",
-		})
-		return
-	}
-
-	if exists {
-		c.JSON(http.StatusConflict, models.SignupResponse{
-			Success: false,
-			Message: "Username already taken",
-		})
-		return
+	_, done, err := h.jobs.Submit(provisioning.JobKind, req.Username, "signup", payload, req.Password)
+	if err == nil {
+		err = <-done
 	}
-
-	var uid int
-
-	if dbMode == "create" {
-		logger.Info("Creating new database")
-
-		// Create new database
-		if err := h.odooClient.CreateNewDatabase(dbName, req.Password, req.Email, req.Country.Code, rpcID); err != nil {
-			logger.WithError(err).WithField("database", dbName).Error("Failed to create database")
-			c.JSON(http.StatusInternalServerError, models.SignupResponse{
-				Success: false,
-				Message: "Failed to create database",
-			})
-			return
-		}
-
-		// Poll for database readiness with new user credentials
-		maxPollingTime := time.Duration(h.config.TimeoutSeconds) * time.Second
-		pollInterval := 3 * time.Second
-		startTime := time.Now()
-
-		for {
-			elapsed := time.Since(startTime)
-			if elapsed > maxPollingTime {
-				logger.WithField("elapsed_seconds", elapsed.Seconds()).Error("Database polling timeout exceeded")
-				c.JSON(http.StatusInternalServerError, models.SignupResponse{
-					Success: false,
-					Message: "Database creation timeout",
-				})
-				return
-			}
-
-			logger.WithField("elapsed_seconds", elapsed.Seconds()).Debug("Checking if database is ready...")
-			tempUID, authErr := h.odooClient.Login(dbName, req.Email, req.Password, rpcID)
-			if authErr != nil {
-				logger.WithError(authErr).WithField("elapsed_seconds", elapsed.Seconds()).Debug("Database not ready yet, retrying...")
-			} else {
-				logger.WithField("elapsed_seconds", elapsed.Seconds()).Info("Database is now ready and accessible")
-				uid = int(tempUID)
-				break
-			}
-
-			time.Sleep(pollInterval)
-		}
-
-		// Update company details with new user credentials
-		companyData := map[string]interface{}{
-			"name":  req.CompanyName,
-			"email": req.Email,
-		}
-
-		if req.Phone != "" {
-			companyData["phone"] = req.Phone
-		}
-
-		_, err = h.odooClient.ExecuteKw(dbName, uid, req.Password, "res.company", "write", []interface{}{[]interface{}{1}, companyData}, rpcID)
-		if err != nil {
-			logger.WithError(err).Error("Failed to update company details")
-			c.JSON(http.StatusInternalServerError, models.SignupResponse{
-				Success: false,
-				Message: "Database created but company update failed",
-			})
-			return
-		}
-
-		logger.Info("Company details updated successfully")
-
-	} else { // clone mode
-		logger.Info("Cloning database from template")
-
-		// Clone database
-		if err := h.odooClient.CloneDatabase(h.config.TemplateDatabase, dbName, rpcID); err != nil {
-			logger.WithError(err).WithField("database", dbName).Error("Failed to clone database")
-			c.JSON(http.StatusInternalServerError, models.SignupResponse{
-				Success: false,
-				Message: "Failed to clone database",
-			})
-			return
-		}
-
-		// Poll for database readiness with admin credentials
-		maxPollingTime := time.Duration(h.config.TimeoutSeconds) * time.Second
-		pollInterval := 3 * time.Second
-		startTime := time.Now()
-
-		adminReady := false
-		for {
-			elapsed := time.Since(startTime)
-			if elapsed > maxPollingTime {
-				logger.WithField("elapsed_seconds", elapsed.Seconds()).Error("Database polling timeout exceeded")
-				c.JSON(http.StatusInternalServerError, models.SignupResponse{
-					Success: false,
-					Message: "Database cloning timeout",
-				})
-				return
-			}
-
-			logger.WithField("elapsed_seconds", elapsed.Seconds()).Debug("Checking if cloned database is ready...")
-			tempUID, authErr := h.odooClient.Login(dbName, h.config.AdminUser, h.config.AdminPassword, rpcID)
-			if authErr != nil {
-				logger.WithError(authErr).WithField("elapsed_seconds", elapsed.Seconds()).Debug("Database not ready yet, retrying...")
-			} else {
-				logger.WithField("elapsed_seconds", elapsed.Seconds()).Info("Cloned database is now ready")
-				uid = int(tempUID)
-				adminReady = true
-				break
-			}
-
-			time.Sleep(pollInterval)
-		}
-
-		if !adminReady {
-			c.JSON(http.StatusInternalServerError, models.SignupResponse{
+	if err != nil {
+		if errors.Is(err, provisioning.ErrUsernameTaken) {
+			c.JSON(http.StatusConflict, models.SignupResponse{
 				Success: false,
-				Message: "Database cloned but admin authentication failed",
+				Message: "Username already taken",
 			})
 			return
 		}
-
-		// Create new user
-		userData := map[string]interface{}{
-			"name":       fmt.Sprintf("%s %s", req.FirstName, req.LastName),
-			"login":      req.Email,
-			"password":   req.Password,
-			"email":      req.Email,
-			"active":     true,
-			"company_id": 1,
-			"groups_id": []interface{}{
-				[]interface{}{6, 0, []interface{}{1, 2, 4}}, // Admin group
-			},
-		}
-
-		_, err = h.odooClient.ExecuteKw(dbName, uid, h.config.AdminPassword, "res.users", "create", []interface{}{userData}, rpcID)
-		if err != nil {
-			logger.WithError(err).Error("Failed to create new user")
-			c.JSON(http.StatusInternalServerError, models.SignupResponse{
+		if errors.Is(err, jobs.ErrBusy) || errors.Is(err, jobs.ErrStopped) {
+			c.JSON(http.StatusServiceUnavailable, models.SignupResponse{
 				Success: false,
-				Message: "Database cloned but user creation failed",
+				Message: "Signups are busy, please try again in a few minutes",
 			})
 			return
 		}
 
-		logger.Info("New user created successfully")
-
-		// Update company details
-		companyData := map[string]interface{}{
-			"name":  req.CompanyName,
-			"email": req.Email,
+		message := "Signup failed"
+		var stepErr *provisioning.StepError
+		if errors.As(err, &stepErr) {
+			message = stepErr.Message
 		}
-
-		if req.Phone != "" {
-			companyData["phone"] = req.Phone
-		}
-
-		if req.Country.ID > 0 {
-			companyData["country_id"] = req.Country.ID
-		}
-
-		// First search for country ID
-		// countryIDResult, err := h.odooClient.ExecuteKw(dbName, uid, h.config.AdminPassword, "res.country", "search", []interface{}{[]interface{}{"name", "=", req.Country}}, rpcID)
-		//if err != nil {
-		//		logger.WithError(err).Warn("Failed to find country ID, skipping country update")
-		//} else {
-		// if countryIDs, ok := countryIDResult.([]interface{}); ok && len(countryIDs) > 0 {
-		//	if id, ok := countryIDs[0].(float64); ok {
-		//		companyData["country_id"] = int(id)
-		//	}
-		//}
-		// }
-
-		_, err = h.odooClient.ExecuteKw(dbName, uid, h.config.AdminPassword, "res.company", "write", []interface{}{[]interface{}{1}, companyData}, rpcID)
-		if err != nil {
-			logger.WithError(err).Error("Failed to update company details")
-			c.JSON(http.StatusInternalServerError, models.SignupResponse{
-				Success: false,
-				Message: "Database cloned and user created but company update failed",
-			})
-			return
-		}
-
-		logger.Info("Company details updated successfully")
-
+		c.JSON(http.StatusInternalServerError, models.SignupResponse{
+			Success: false,
+			Message: message,
+		})
+		return
 	}
 
-	// Success response
-	instanceURL := fmt.Sprintf("%s.%s", req.Username, h.config.Domain)
-
-	response := models.SignupResponse{
+	c.JSON(http.StatusOK, models.SignupResponse{
 		Success: true,
 		Message: fmt.Sprintf("Signup successful using %s mode! Your Odoo instance is ready.", dbMode),
-		Data: &models.SignupData{
-			InstanceURL: instanceURL,
-			Email:       req.Email,
-			Database:    dbName,
-		},
-	}
-
-	logger.WithFields(logrus.Fields{
-		"username":    req.Username,
-		"email":       req.Email,
-		"db_mode":     dbMode,
-		"instanceURL": instanceURL,
-	}).Info("Signup completed successfully")
-
-	c.JSON(http.StatusOK, response)
+		Data:    h.provisioner.Result(&req),
+	})
 }
 
 // HandleHealthCheck handles health check requests
@@ -312,3 +152,22 @@ func (h *Handler) HandleHealthCheck(c *gin.Context) {
 		"timestamp": time.Now().UTC(),
 	})
 }
+
+// HandleMaintenance renders the page the proxy shows on the hosts of
+// suspended tenants
+func (h *Handler) HandleMaintenance(c *gin.Context) {
+	c.Header("Retry-After", "3600")
+	c.Header("Cache-Control", "no-store")
+	c.HTML(http.StatusServiceUnavailable, "maintenance.html", gin.H{
+		"OdooCompany": h.config.OdooCompany,
+		"Host":        c.Request.Host,
+	})
+}
+
+// actorName identifies the caller in job histories and tenant records
+func actorName(c *gin.Context) string {
+	if principal, ok := middleware.CurrentPrincipal(c); ok {
+		return principal.Name
+	}
+	return "admin"
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"odoo-signup/internal/imports"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// maxImportBytes bounds the size of an uploaded import
+const maxImportBytes = 32 << 20
+
+// HandleCreateImport validates uploaded CSV or JSONL rows and provisions them
+// in the background. The rows come as a multipart "file" field or as the raw
+// request body named by ?source=; ?format= overrides the format detected
+// from the file name or content type. Responds 202 with the report, or 422 with the invalid rows.
+func (h *Handler) HandleCreateImport(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+
+	var body io.Reader = c.Request.Body
+	source := c.Query("source")
+	detectFrom := c.ContentType()
+	if source != "" {
+		detectFrom = source
+	}
+	if c.ContentType() == "multipart/form-data" {
+		header, err := c.FormFile("file")
+		if err != nil {
+			h.importError(c, http.StatusBadRequest, err)
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			h.importError(c, http.StatusBadRequest, err)
+			return
+		}
+		defer file.Close()
+		body, source, detectFrom = file, header.Filename, header.Filename
+	}
+
+	format := c.Query("format")
+	if format == "" {
+		var err error
+		if format, err = imports.DetectFormat(detectFrom); err != nil {
+			h.importError(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	rows, err := imports.Parse(body, format)
+	if err != nil {
+		h.importError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	actor := actorName(c)
+	concurrency, _ := strconv.Atoi(c.Query("concurrency"))
+
+	report, err := h.imports.Prepare(rows, imports.Options{
+		Source:      source,
+		Format:      format,
+		Actor:       actor,
+		DbMode:      c.DefaultQuery("db_mode", h.config.DefaultDBMode),
+		Concurrency: concurrency,
+		SkipInvalid: c.Query("skip_invalid") == "true",
+		Resume:      c.Query("resume"),
+	})
+	if errors.Is(err, imports.ErrInvalidRows) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"data":    report,
+		})
+		return
+	}
+	if err != nil {
+		h.importError(c, importStatus(err), err)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"import_id": report.ID,
+		"actor":     actor,
+		"rows":      len(rows),
+	}).Info("Import accepted")
+
+	// The report is copied before the run starts changing it
+	accepted := *report
+	accepted.Rows = append([]imports.RowResult(nil), report.Rows...)
+	go h.imports.Run(report, rows)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"data":    accepted,
+	})
+}
+
+// HandleListImports returns the imports, newest first, without their rows
+func (h *Handler) HandleListImports(c *gin.Context) {
+	list, err := h.imports.Store().List()
+	if err != nil {
+		h.importError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	for i := range list {
+		list[i].Rows = nil
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    list,
+	})
+}
+
+// HandleGetImport returns an import with the result of every row
+func (h *Handler) HandleGetImport(c *gin.Context) {
+	report, err := h.imports.Store().Get(c.Param("id"))
+	if err != nil {
+		h.importError(c, importStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    report,
+	})
+}
+
+// importError writes an import error response
+func (h *Handler) importError(c *gin.Context, status int, err error) {
+	c.JSON(status, gin.H{
+		"success": false,
+		"message": err.Error(),
+	})
+}
+
+// importStatus maps import errors to HTTP statuses
+func importStatus(err error) int {
+	switch {
+	case errors.Is(err, imports.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, imports.ErrEmpty):
+		return http.StatusBadRequest
+	case errors.Is(err, imports.ErrRunning):
+		return http.StatusConflict
+	}
+	return http.StatusBadGateway
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"odoo-signup/internal/jobs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleListJobs returns the job history, optionally filtered by tenant, kind and status
+func (h *Handler) HandleListJobs(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+
+	list, err := h.jobs.Store().List(jobs.Filter{
+		Tenant: c.Query("tenant"),
+		Kind:   c.Query("kind"),
+		Status: c.Query("status"),
+		Limit:  limit,
+	})
+	if err != nil {
+		h.jobError(c, err)
+		return
+	}
+
+	for i := range list {
+		list[i].Secret = ""
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    list,
+	})
+}
+
+// HandleGetJob returns a job with its step timings
+func (h *Handler) HandleGetJob(c *gin.Context) {
+	job, err := h.jobs.Store().Get(c.Param("id"))
+	if err != nil {
+		h.jobError(c, err)
+		return
+	}
+
+	job.Secret = ""
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    job,
+	})
+}
+
+// HandleRetryJob queues a failed job for another attempt
+func (h *Handler) HandleRetryJob(c *gin.Context) {
+	job, _, err := h.jobs.Retry(c.Param("id"))
+	if err != nil {
+		h.jobError(c, err)
+		return
+	}
+
+	job.Secret = ""
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"data":    job,
+	})
+}
+
+// jobError maps job errors to responses
+func (h *Handler) jobError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, jobs.ErrNotRetryable), errors.Is(err, jobs.ErrTenantBusy):
+		status = http.StatusConflict
+	case errors.Is(err, jobs.ErrBusy), errors.Is(err, jobs.ErrStopped):
+		status = http.StatusServiceUnavailable
+	}
+
+	c.JSON(status, gin.H{
+		"success": false,
+		"message": err.Error(),
+	})
+}
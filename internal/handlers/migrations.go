@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"odoo-signup/internal/backends"
+	"odoo-signup/internal/migrations"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MigrateTenantRequest names the backend a tenant moves to
+type MigrateTenantRequest struct {
+	Backend string `json:"backend" binding:"required"`
+	DryRun  bool   `json:"dryRun"`
+}
+
+// HandleMigrateTenant queues a job moving the tenant to another backend
+func (h *Handler) HandleMigrateTenant(c *gin.Context) {
+	var req MigrateTenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "A target backend is required",
+		})
+		return
+	}
+
+	job, _, err := h.migrator.Submit(migrations.Request{
+		Database: c.Param("name"),
+		Target:   req.Backend,
+		DryRun:   req.DryRun,
+	}, actorName(c))
+	switch {
+	case errors.Is(err, backends.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	case errors.Is(err, migrations.ErrSameBackend), errors.Is(err, migrations.ErrTargetDraining),
+		errors.Is(err, migrations.ErrNotMovable), errors.Is(err, backends.ErrNoCapacity):
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	case err != nil:
+		h.tenantError(c, err)
+		return
+	}
+
+	job.Secret = ""
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"data":    job,
+	})
+}
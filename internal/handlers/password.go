@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+
+	"odoo-signup/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandlePasswordStrength scores a password for live feedback on the signup form
+func (h *Handler) HandlePasswordStrength(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxFormBytes)
+
+	var req models.PasswordStrengthRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request format",
+		})
+		return
+	}
+	if err := h.provisioner.ValidateStrength(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	result := h.provisioner.PasswordPolicy().Evaluate(req.Password, req.Username, req.Email, req.CompanyName, req.FirstName, req.LastName)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    result,
+	})
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"odoo-signup/internal/auth"
+	"odoo-signup/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// HandleLogin redirects the operator to the identity provider
+func (h *Handler) HandleLogin(c *gin.Context) {
+	authURL, err := h.sso.Start(c.Query("return_to"))
+	if err != nil {
+		logrus.WithError(err).Error("Failed to start single sign-on")
+		c.JSON(http.StatusBadGateway, gin.H{
+			"success": false,
+			"message": "Identity provider unavailable",
+		})
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// HandleLoginCallback completes the login and sets the session cookie
+func (h *Handler) HandleLoginCallback(c *gin.Context) {
+	if providerErr := c.Query("error"); providerErr != "" {
+		logrus.WithField("error", providerErr).Warn("Identity provider rejected the login")
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "Sign-in was cancelled or denied: " + c.Query("error_description"),
+		})
+		return
+	}
+
+	session, returnTo, err := h.sso.Complete(c.Query("state"), c.Query("code"))
+	if err != nil {
+		status := http.StatusUnauthorized
+		if errors.Is(err, auth.ErrNoRole) {
+			status = http.StatusForbidden
+		}
+		logrus.WithError(err).Warn("Single sign-on failed")
+		c.JSON(status, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"operator": session.Principal.Name,
+		"roles":    session.Principal.Roles,
+	}).Info("Operator signed in")
+
+	h.setSessionCookie(c, session.ID, int(h.sso.Sessions.TTL().Seconds()))
+	c.Redirect(http.StatusFound, returnTo)
+}
+
+// HandleLogout ends the operator session
+func (h *Handler) HandleLogout(c *gin.Context) {
+	if session, ok := middleware.CurrentSession(c); ok {
+		h.sso.Sessions.Delete(session.ID)
+	}
+
+	h.setSessionCookie(c, "", -1)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Signed out",
+	})
+}
+
+// HandleSession describes the authenticated principal. Session callers also
+// receive the CSRF token to send with state-changing requests.
+func (h *Handler) HandleSession(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+
+	data := gin.H{"principal": principal}
+	if session, ok := middleware.CurrentSession(c); ok {
+		data["csrfToken"] = session.CSRFToken
+		data["expiresAt"] = session.ExpiresAt.UTC()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// setSessionCookie writes or clears the session cookie
+func (h *Handler) setSessionCookie(c *gin.Context, value string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     h.sso.CookieName(),
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   h.sso.CookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"odoo-signup/internal/domains"
+	"odoo-signup/internal/jobs"
+	"odoo-signup/internal/tenants"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// resetPasswordRequest optionally sets the new owner password
+type resetPasswordRequest struct {
+	Password string `json:"password"`
+}
+
+// suspendRequest gives the reason of a suspension, such as non-payment or abuse
+type suspendRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// renameRequest gives the new name of a tenant database
+type renameRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// trialRequest moves the end of a trial; exactly one field is set
+type trialRequest struct {
+	EndsAt    *time.Time `json:"endsAt"`
+	Days      int        `json:"days"`      // Trial ends this many days from now
+	Permanent bool       `json:"permanent"` // End the trial and keep the tenant
+}
+
+// HandleListTenants lists registered tenants and unregistered Odoo databases
+func (h *Handler) HandleListTenants(c *gin.Context) {
+	list, err := h.tenants.List()
+	if err != nil {
+		h.tenantError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    list,
+	})
+}
+
+// HandleReconcileTenants compares the tenant registry with Odoo's database list
+func (h *Handler) HandleReconcileTenants(c *gin.Context) {
+	report, err := h.tenants.Reconcile()
+	if err != nil {
+		h.tenantError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    report,
+	})
+}
+
+// HandleGetTenant returns a tenant with metadata read from its database
+func (h *Handler) HandleGetTenant(c *gin.Context) {
+	details, err := h.tenants.Get(c.Param("name"))
+	if err != nil {
+		h.tenantError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    details,
+	})
+}
+
+// HandleSuspendTenant archives the tenant's users, recording the caller and reason
+func (h *Handler) HandleSuspendTenant(c *gin.Context) {
+	var req suspendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "A reason is required",
+		})
+		return
+	}
+
+	tenant, err := h.tenants.Suspend(c.Param("name"), actorName(c), req.Reason)
+	if err != nil {
+		h.tenantError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    tenant,
+	})
+}
+
+// HandleResumeTenant reactivates the users archived by a suspension
+func (h *Handler) HandleResumeTenant(c *gin.Context) {
+	tenant, err := h.tenants.Resume(c.Param("name"), actorName(c))
+	if err != nil {
+		h.tenantError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    tenant,
+	})
+}
+
+// HandleRenameTenant renames the tenant database and moves it to the
+// matching instance URL
+func (h *Handler) HandleRenameTenant(c *gin.Context) {
+	var req renameRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "A new name is required",
+		})
+		return
+	}
+
+	tenant, err := h.tenants.Rename(c.Param("name"), req.Name, actorName(c))
+	if err != nil {
+		h.tenantError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    tenant,
+	})
+}
+
+// HandleDeleteTenant drops the tenant database
+func (h *Handler) HandleDeleteTenant(c *gin.Context) {
+	if err := h.tenants.Delete(c.Param("name")); err != nil {
+		h.tenantError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Tenant deleted",
+	})
+}
+
+// HandleResetTenantPassword sets a new password for the tenant owner
+func (h *Handler) HandleResetTenantPassword(c *gin.Context) {
+	var req resetPasswordRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Invalid request format",
+			})
+			return
+		}
+	}
+
+	newPassword, err := h.tenants.ResetOwnerPassword(c.Param("name"), req.Password)
+	if err != nil {
+		h.tenantError(c, err)
+		return
+	}
+
+	data := gin.H{}
+	if req.Password == "" {
+		data["password"] = newPassword
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Owner password reset",
+		"data":    data,
+	})
+}
+
+// HandleSetTenantTrial extends a trial or makes the tenant permanent
+func (h *Handler) HandleSetTenantTrial(c *gin.Context) {
+	var req trialRequest
+	set := 0
+	if err := c.ShouldBindJSON(&req); err == nil {
+		for _, given := range []bool{req.EndsAt != nil, req.Days > 0, req.Permanent} {
+			if given {
+				set++
+			}
+		}
+	}
+	if set != 1 || req.Days < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Set one of endsAt, days or permanent",
+		})
+		return
+	}
+
+	endsAt := req.EndsAt
+	if req.Days > 0 {
+		end := time.Now().AddDate(0, 0, req.Days)
+		endsAt = &end
+	}
+
+	tenant, err := h.tenants.SetTrial(c.Param("name"), actorName(c), endsAt)
+	if err != nil {
+		h.tenantError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    tenant,
+	})
+}
+
+// HandleBackupTenant backs up the tenant database to the backup storage and
+// prunes its backups like a scheduled run
+func (h *Handler) HandleBackupTenant(c *gin.Context) {
+	database := c.Param("name")
+	if _, err := h.tenants.Lookup(database); err != nil {
+		h.tenantError(c, err)
+		return
+	}
+
+	archive, pruned, err := h.backups.Run(database)
+	if err != nil && archive.Key == "" {
+		h.tenantError(c, err)
+		return
+	}
+	if err != nil {
+		logrus.WithError(err).WithField("tenant", database).Warn("Failed to prune backups")
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    archive,
+		"pruned":  pruned,
+	})
+}
+
+// tenantError maps tenant lifecycle errors to responses
+func (h *Handler) tenantError(c *gin.Context, err error) {
+	status := http.StatusBadGateway
+	switch {
+	case errors.Is(err, tenants.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, tenants.ErrProtected):
+		status = http.StatusForbidden
+	case errors.Is(err, tenants.ErrSuspended), errors.Is(err, tenants.ErrNotSuspended),
+		errors.Is(err, tenants.ErrOwnerUnknown), errors.Is(err, tenants.ErrOwnerNotFound),
+		errors.Is(err, tenants.ErrExists), errors.Is(err, tenants.ErrNotRegistered),
+		errors.Is(err, tenants.ErrJobActive), errors.Is(err, tenants.ErrClaimed), errors.Is(err, tenants.ErrBackupsExist),
+		errors.Is(err, domains.ErrDomainTaken), errors.Is(err, domains.ErrNotVerified),
+		errors.Is(err, domains.ErrChallengeFailed):
+		status = http.StatusConflict
+	case errors.Is(err, tenants.ErrWeakPassword), errors.Is(err, tenants.ErrReasonRequired),
+		errors.Is(err, tenants.ErrInvalidName), errors.Is(err, domains.ErrInvalidDomain),
+		errors.Is(err, domains.ErrReservedDomain):
+		status = http.StatusBadRequest
+	case errors.Is(err, domains.ErrDomainNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, jobs.ErrBusy), errors.Is(err, jobs.ErrStopped):
+		status = http.StatusServiceUnavailable
+	}
+
+	if status == http.StatusBadGateway {
+		logrus.WithError(err).WithField("tenant", c.Param("name")).Error("Tenant operation failed")
+	}
+
+	c.JSON(status, gin.H{
+		"success": false,
+		"message": err.Error(),
+	})
+}
@@ -0,0 +1,329 @@
+package imports
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"odoo-signup/internal/backends"
+	"odoo-signup/internal/jobs"
+	"odoo-signup/internal/provisioning"
+	"odoo-signup/internal/tenants"
+	"odoo-signup/internal/util"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultConcurrency is the number of rows provisioned at the same time
+// unless the import asks for another limit
+const DefaultConcurrency = 2
+
+// Errors returned by the importer
+var (
+	ErrInvalidRows = errors.New("the import has invalid rows")
+	ErrEmpty       = errors.New("the import has no rows")
+	ErrRunning     = errors.New("the import is still running")
+)
+
+// Options describe one import run
+type Options struct {
+	Source      string // File name the rows came from
+	Format      string
+	Actor       string
+	DbMode      string
+	Concurrency int
+	SkipInvalid bool   // Provision the valid rows even when others are invalid
+	Resume      string // ID of an earlier import of the same rows to continue
+}
+
+// Importer provisions tenants in bulk through the job runner
+type Importer struct {
+	provisioner *provisioning.Provisioner
+	runner      *jobs.Runner
+	backends    *backends.Pool
+	registry    tenants.Store
+	store       *Store
+
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+// NewImporter creates an importer
+func NewImporter(provisioner *provisioning.Provisioner, runner *jobs.Runner, pool *backends.Pool, registry tenants.Store, store *Store) *Importer {
+	return &Importer{
+		provisioner: provisioner,
+		runner:      runner,
+		backends:    pool,
+		registry:    registry,
+		store:       store,
+		running:     make(map[string]bool),
+	}
+}
+
+// Store returns the import reports
+func (i *Importer) Store() *Store {
+	return i.store
+}
+
+// Prepare validates every row before anything is provisioned and returns the
+// report to pass to Run. Rows are normalized in place. Rows that were created
+// or skipped by the import being resumed keep their outcome; rows whose
+// database already exists are skipped. When rows are invalid and
+// opts.SkipInvalid is false, the rejected report is returned with
+// ErrInvalidRows and nothing is stored.
+func (i *Importer) Prepare(rows []Row, opts Options) (*Report, error) {
+	if len(rows) == 0 {
+		return nil, ErrEmpty
+	}
+	if opts.Concurrency < 1 {
+		opts.Concurrency = DefaultConcurrency
+	}
+
+	now := time.Now().UTC()
+	report := &Report{
+		ID:          util.NewID()[:16],
+		Source:      opts.Source,
+		Format:      opts.Format,
+		Actor:       opts.Actor,
+		DbMode:      provisioning.NormalizeMode(opts.DbMode),
+		Concurrency: opts.Concurrency,
+		Status:      StatusRunning,
+		Rows:        make([]RowResult, len(rows)),
+		CreatedAt:   now,
+	}
+
+	done := make(map[string]RowResult)
+	attempted := make(map[string]string) // Last job of the rows to provision again
+	if opts.Resume != "" {
+		previous, err := i.store.Get(opts.Resume)
+		if err != nil {
+			return nil, err
+		}
+		if i.isRunning(previous.ID) {
+			return nil, ErrRunning
+		}
+		report.ID = previous.ID
+		report.CreatedAt = previous.CreatedAt
+		for _, row := range previous.Rows {
+			if row.Status == RowCreated || row.Status == RowSkipped {
+				done[row.Username] = row
+			} else if row.JobID != "" {
+				attempted[row.Username] = row.JobID
+			}
+		}
+	}
+
+	seen := make(map[string]int)
+	for n := range rows {
+		row := &rows[n]
+		// The operator accepts the terms on the customers' behalf
+		row.Request.Terms = true
+
+		result := RowResult{
+			Line:     row.Line,
+			Username: strings.ToLower(strings.TrimSpace(row.Request.Username)),
+			Email:    row.Request.Email,
+			Status:   RowPending,
+		}
+
+		switch {
+		case row.Error != "":
+			result.Status, result.Reason = RowInvalid, row.Error
+		default:
+			if err := i.provisioner.Validate(&row.Request); err != nil {
+				result.Status, result.Reason = RowInvalid, validationReason(err)
+			} else if line, dup := seen[row.Request.Username]; dup {
+				result.Status, result.Reason = RowInvalid, fmt.Sprintf("duplicate of line %d", line)
+			} else {
+				seen[row.Request.Username] = row.Line
+				result.Username = row.Request.Username
+				if previous, ok := done[result.Username]; ok {
+					result.Status, result.Reason, result.JobID = previous.Status, previous.Reason, previous.JobID
+				} else {
+					result.JobID = attempted[result.Username]
+				}
+			}
+		}
+		report.Rows[n] = result
+	}
+
+	report.summarize()
+	if report.Summary.Invalid > 0 && !opts.SkipInvalid {
+		report.Status = StatusRejected
+		return report, ErrInvalidRows
+	}
+
+	if err := i.skipExisting(report); err != nil {
+		return nil, err
+	}
+
+	i.mu.Lock()
+	if i.running[report.ID] {
+		i.mu.Unlock()
+		return nil, ErrRunning
+	}
+	i.running[report.ID] = true
+	i.mu.Unlock()
+
+	report.FinishedAt = nil
+	if err := i.store.Save(report); err != nil {
+		i.release(report.ID)
+		return nil, err
+	}
+	return report, nil
+}
+
+// Run provisions the pending rows of a prepared report, at most
+// report.Concurrency at a time, and saves the report after every row
+func (i *Importer) Run(report *Report, rows []Row) {
+	defer i.release(report.ID)
+
+	logger := logrus.WithFields(logrus.Fields{"import_id": report.ID, "source": report.Source})
+	logger.WithField("rows", len(rows)).Info("Import started")
+
+	var queued []int
+	for n, row := range report.Rows {
+		if row.Status == RowPending {
+			queued = append(queued, n)
+		}
+	}
+
+	pending := make(chan int)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for w := 0; w < report.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range pending {
+				// The job is recorded once submitted, so a resumed import can replace its failed attempt
+				result := i.provision(report, &rows[n], report.Rows[n].JobID, func(jobID string) {
+					mu.Lock()
+					defer mu.Unlock()
+					report.Rows[n].JobID = jobID
+					if err := i.store.Save(report); err != nil {
+						logger.WithError(err).Error("Failed to save import report")
+					}
+				})
+
+				mu.Lock()
+				report.Rows[n] = result
+				if err := i.store.Save(report); err != nil {
+					logger.WithError(err).Error("Failed to save import report")
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, n := range queued {
+		pending <- n
+	}
+	close(pending)
+	wg.Wait()
+
+	now := time.Now().UTC()
+	report.Status = StatusCompleted
+	report.FinishedAt = &now
+	if err := i.store.Save(report); err != nil {
+		logger.WithError(err).Error("Failed to save import report")
+	}
+
+	logger.WithFields(logrus.Fields{
+		"created": report.Summary.Created,
+		"skipped": report.Summary.Skipped,
+		"failed":  report.Summary.Failed,
+		"invalid": report.Summary.Invalid,
+	}).Info("Import completed")
+}
+
+// provision submits one row as a provisioning job and waits for it. The job
+// may replace the failed attempt of previous, the row's job in the import
+// being resumed; submitted is called with the new job before it runs.
+func (i *Importer) provision(report *Report, row *Row, previous string, submitted func(jobID string)) RowResult {
+	req := row.Request
+	result := RowResult{Line: row.Line, Username: req.Username, Email: req.Email}
+
+	payload := provisioning.NewJobPayload(&req, provisioning.Options{
+		DbMode:   report.DbMode,
+		Replaces: previous,
+	})
+
+	job, done, err := i.runner.Submit(provisioning.JobKind, req.Username, report.Actor, payload, req.Password)
+	if err == nil {
+		result.JobID = job.ID
+		submitted(job.ID)
+		err = <-done
+	}
+
+	switch {
+	case err == nil:
+		result.Status = RowCreated
+	case errors.Is(err, provisioning.ErrUsernameTaken):
+		result.Status, result.Reason = RowSkipped, "database already exists"
+	default:
+		result.Status, result.Reason = RowFailed, err.Error()
+	}
+	return result
+}
+
+// skipExisting marks the pending rows whose database exists on any backend.
+// Databases a failed attempt of the row left behind stay pending; the row's
+// new job replaces them.
+func (i *Importer) skipExisting(report *Report) error {
+	databases, err := i.backends.Databases()
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[string]bool)
+	for _, names := range databases {
+		for _, name := range names {
+			existing[name] = true
+		}
+	}
+
+	for n, row := range report.Rows {
+		if row.Status != RowPending || !existing[row.Username] {
+			continue
+		}
+		if tenant, ok := i.registry.Get(row.Username); ok && tenant.Status == tenants.StatusFailed && row.JobID != "" && tenant.JobID == row.JobID {
+			continue
+		}
+		report.Rows[n].Status, report.Rows[n].Reason = RowSkipped, "database already exists"
+	}
+	return nil
+}
+
+// isRunning reports whether this process is running an import
+func (i *Importer) isRunning(id string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.running[id]
+}
+
+// release marks an import as no longer running
+func (i *Importer) release(id string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	delete(i.running, id)
+}
+
+// validationReason flattens a validation error with its field errors
+func validationReason(err error) string {
+	var validationErr *provisioning.ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Fields) == 0 {
+		return strings.ReplaceAll(err.Error(), "\n", "; ")
+	}
+
+	fields := make([]string, 0, len(validationErr.Fields))
+	for field, message := range validationErr.Fields {
+		fields = append(fields, field+": "+message)
+	}
+	sort.Strings(fields)
+	return validationErr.Message + " (" + strings.Join(fields, "; ") + ")"
+}
@@ -0,0 +1,167 @@
+package imports
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"odoo-signup/internal/models"
+)
+
+// Input formats
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// maxLineBytes bounds one JSONL row
+const maxLineBytes = 1 << 20
+
+// Row is one signup request read from the input
+type Row struct {
+	Line    int                  // Line in the input, for reports
+	Request models.SignupRequest // Parsed request, not yet validated
+	Error   string               // Set when the row could not be parsed
+}
+
+// DetectFormat returns the format named by a file name or content type
+func DetectFormat(name string) (string, error) {
+	switch strings.ToLower(strings.TrimPrefix(filepath.Ext(name), ".")) {
+	case "csv":
+		return FormatCSV, nil
+	case "jsonl", "ndjson", "json":
+		return FormatJSONL, nil
+	}
+
+	switch {
+	case strings.Contains(name, "csv"):
+		return FormatCSV, nil
+	case strings.Contains(name, "ndjson"), strings.Contains(name, "jsonl"), strings.Contains(name, "json"):
+		return FormatJSONL, nil
+	}
+	return "", fmt.Errorf("cannot tell the format of %q, use %s or %s", name, FormatCSV, FormatJSONL)
+}
+
+// Parse reads signup requests from CSV or JSONL. Rows that cannot be parsed
+// are returned with an error so they show up in the report.
+func Parse(r io.Reader, format string) ([]Row, error) {
+	switch format {
+	case FormatCSV:
+		return parseCSV(r)
+	case FormatJSONL:
+		return parseJSONL(r)
+	}
+	return nil, fmt.Errorf("unknown import format %q", format)
+}
+
+// parseJSONL reads one request per line, in the format of POST /api/signup
+func parseJSONL(r io.Reader) ([]Row, error) {
+	var rows []Row
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineBytes)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		row := Row{Line: line}
+		if err := json.Unmarshal(text, &row.Request); err != nil {
+			row.Error = "invalid JSON: " + err.Error()
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read input: %w", err)
+	}
+
+	return rows, nil
+}
+
+// parseCSV reads requests from a CSV file whose header names the request
+// fields: username, email, password, firstName, lastName, companyName,
+// country, and optionally phone, industry, companySize, timezone, currency,
+// plan, dbMode and utm.source, utm.medium, utm.campaign, utm.term, utm.content
+func parseCSV(r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff"))
+	}
+
+	var rows []Row
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			rows = append(rows, Row{Line: line, Error: err.Error()})
+			continue
+		}
+
+		row := Row{Line: line}
+		if err := decodeRecord(header, record, &row.Request); err != nil {
+			row.Error = err.Error()
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// decodeRecord maps a CSV record onto a request through its JSON field names
+func decodeRecord(header, record []string, req *models.SignupRequest) error {
+	fields := make(map[string]interface{})
+	utm := make(map[string]interface{})
+
+	for i, name := range header {
+		if i >= len(record) || name == "" {
+			continue
+		}
+		value := strings.TrimSpace(record[i])
+		if value == "" {
+			continue
+		}
+
+		switch {
+		case name == "country":
+			fields["country"] = map[string]string{"code": strings.ToUpper(value)}
+		case strings.HasPrefix(name, "utm."):
+			utm[strings.TrimPrefix(name, "utm.")] = value
+		default:
+			fields[name] = value
+		}
+	}
+	if len(utm) > 0 {
+		fields["utm"] = utm
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, req); err != nil {
+		return fmt.Errorf("invalid row: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package imports
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"odoo-signup/internal/store"
+)
+
+// Import statuses
+const (
+	StatusRejected  = "rejected" // Invalid rows stopped the import before anything was provisioned
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+)
+
+// Row statuses
+const (
+	RowPending = "pending"
+	RowCreated = "created"
+	RowSkipped = "skipped"
+	RowFailed  = "failed"
+	RowInvalid = "invalid"
+)
+
+// ErrNotFound is returned for unknown import IDs
+var ErrNotFound = errors.New("import not found")
+
+// RowResult is the outcome of one input row. Passwords are never recorded.
+type RowResult struct {
+	Line     int    `json:"line"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Status   string `json:"status"`
+	Reason   string `json:"reason,omitempty"`
+	JobID    string `json:"jobId,omitempty"`
+}
+
+// Summary counts the rows of an import by status
+type Summary struct {
+	Total   int `json:"total"`
+	Pending int `json:"pending"`
+	Created int `json:"created"`
+	Skipped int `json:"skipped"`
+	Failed  int `json:"failed"`
+	Invalid int `json:"invalid"`
+}
+
+// Report records a bulk import row by row so it can be inspected and resumed
+type Report struct {
+	ID          string      `json:"id"`
+	Source      string      `json:"source"` // File name the rows came from
+	Format      string      `json:"format"`
+	Actor       string      `json:"actor"`
+	DbMode      string      `json:"dbMode"`
+	Concurrency int         `json:"concurrency"`
+	Status      string      `json:"status"`
+	Summary     Summary     `json:"summary"`
+	Rows        []RowResult `json:"rows"`
+	CreatedAt   time.Time   `json:"createdAt"`
+	UpdatedAt   time.Time   `json:"updatedAt"`
+	FinishedAt  *time.Time  `json:"finishedAt,omitempty"`
+}
+
+// summarize recounts the rows
+func (r *Report) summarize() {
+	s := Summary{Total: len(r.Rows)}
+	for _, row := range r.Rows {
+		switch row.Status {
+		case RowPending:
+			s.Pending++
+		case RowCreated:
+			s.Created++
+		case RowSkipped:
+			s.Skipped++
+		case RowFailed:
+			s.Failed++
+		case RowInvalid:
+			s.Invalid++
+		}
+	}
+	r.Summary = s
+}
+
+// Store keeps one JSON report per import in a directory
+type Store struct {
+	dir string
+}
+
+// NewStore creates a report store in dir
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Save writes a report, replacing the previous version
+func (s *Store) Save(report *Report) error {
+	report.UpdatedAt = time.Now().UTC()
+	report.summarize()
+	return store.NewFile(s.path(report.ID)).Save(report)
+}
+
+// Get loads a report by ID
+func (s *Store) Get(id string) (*Report, error) {
+	if !validID(id) {
+		return nil, ErrNotFound
+	}
+	if _, err := os.Stat(s.path(id)); errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+
+	var report Report
+	if err := store.NewFile(s.path(id)).Load(&report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// List returns all reports, newest first
+func (s *Store) List() ([]Report, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Report{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", s.dir, err)
+	}
+
+	reports := make([]Report, 0, len(entries))
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || !validID(id) {
+			continue
+		}
+		report, err := s.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, *report)
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].CreatedAt.After(reports[j].CreatedAt)
+	})
+	return reports, nil
+}
+
+// path returns the file of a report
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// validID keeps IDs from naming files outside the store
+func validID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, c := range id {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,269 @@
+package odoo
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// call invokes a JSON-RPC service method and returns its raw result
+func (c *Client) call(service, method string, args []interface{}, rpcID int) (interface{}, error) {
+	payload := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  "call",
+		"params": map[string]interface{}{
+			"service": service,
+			"method":  method,
+			"args":    args,
+		},
+		"id": rpcID,
+	}
+
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s.%s payload: %w", service, method, err)
+	}
+
+	req, err := http.NewRequest("POST", c.baseURL+"/jsonrpc", bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s.%s request: %w", service, method, err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s.%s request failed: %w", service, method, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s.%s response: %w", service, method, err)
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse %s.%s response: %w", service, method, err)
+	}
+
+	if result, ok := response["result"]; ok {
+		return result, nil
+	}
+
+	return nil, fmt.Errorf("%s.%s failed: %v", service, method, response["error"])
+}
+
+// ListCountries returns the [code, name] pairs known to the server using the db service
+func (c *Client) ListCountries(rpcID int) ([][2]string, error) {
+	logrus.Debug("Listing countries using db.list_countries")
+
+	result, err := c.call("db", "list_countries", []interface{}{c.masterPass}, rpcID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, ok := result.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected list_countries result: %v", result)
+	}
+
+	countries := make([][2]string, 0, len(rows))
+	for _, row := range rows {
+		pair, ok := row.([]interface{})
+		if !ok || len(pair) < 2 {
+			continue
+		}
+		code, _ := pair[0].(string)
+		name, _ := pair[1].(string)
+		countries = append(countries, [2]string{code, name})
+	}
+
+	return countries, nil
+}
+
+// Dump formats accepted by DumpDatabase
+const (
+	DumpZip = "zip"  // SQL dump with the filestore
+	DumpSQL = "dump" // pg_dump custom format without the filestore
+)
+
+// ListDatabases returns the databases visible to the server using db.list
+func (c *Client) ListDatabases(rpcID int) ([]string, error) {
+	result, err := c.call("db", "list", []interface{}{}, rpcID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, ok := result.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected db.list result: %v", result)
+	}
+
+	names := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if name, ok := row.(string); ok {
+			names = append(names, name)
+		}
+	}
+
+	return names, nil
+}
+
+// DropDatabase deletes a database and its filestore using db.drop
+func (c *Client) DropDatabase(dbName string, rpcID int) error {
+	logrus.WithField("database", dbName).Info("Dropping Odoo database using JSON-RPC")
+
+	result, err := c.call("db", "drop", []interface{}{c.masterPass, dbName}, rpcID)
+	if err != nil {
+		return err
+	}
+	if result != true {
+		return fmt.Errorf("database drop failed: %v", result)
+	}
+
+	return nil
+}
+
+// RenameDatabase renames a database and its filestore using db.rename
+func (c *Client) RenameDatabase(oldName, newName string, rpcID int) error {
+	logrus.WithFields(logrus.Fields{"database": oldName, "new_database": newName}).Info("Renaming Odoo database using JSON-RPC")
+
+	result, err := c.call("db", "rename", []interface{}{c.masterPass, oldName, newName}, rpcID)
+	if err != nil {
+		return err
+	}
+	if result != true {
+		return fmt.Errorf("database rename failed: %v", result)
+	}
+
+	return nil
+}
+
+// DumpDatabase writes a backup of the database to w using db.dump.
+// The dump is returned base64-encoded in a single response.
+func (c *Client) DumpDatabase(dbName, format string, w io.Writer, rpcID int) error {
+	logrus.WithFields(logrus.Fields{
+		"database": dbName,
+		"format":   format,
+	}).Info("Dumping Odoo database using JSON-RPC")
+
+	result, err := c.call("db", "dump", []interface{}{c.masterPass, dbName, format}, rpcID)
+	if err != nil {
+		return err
+	}
+
+	encoded, ok := result.(string)
+	if !ok {
+		return fmt.Errorf("unexpected db.dump result type %T", result)
+	}
+
+	if _, err := io.Copy(w, base64.NewDecoder(base64.StdEncoding, strings.NewReader(encoded))); err != nil {
+		return fmt.Errorf("failed to write dump of %s: %w", dbName, err)
+	}
+
+	return nil
+}
+
+// RestoreDatabase creates a database from a backup read from r. The backup
+// is streamed to the database manager's upload form rather than sent
+// base64-encoded through db.restore, so its size is not bounded by memory.
+// copy gives the restored database a new UUID, as Odoo does for duplicates,
+// so it can run next to the original; neutralize disables its mail servers,
+// crons and payment providers (Odoo 16+).
+func (c *Client) RestoreDatabase(dbName string, r io.Reader, copy, neutralize bool) error {
+	logrus.WithFields(logrus.Fields{
+		"database":   dbName,
+		"copy":       copy,
+		"neutralize": neutralize,
+	}).Info("Restoring Odoo database through the database manager")
+
+	body, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		fields := map[string]string{"master_pwd": c.masterPass, "name": dbName}
+		if copy {
+			fields["copy"] = "true"
+		}
+		if neutralize {
+			fields["neutralize_database"] = "true"
+		}
+		for name, value := range fields {
+			if err := form.WriteField(name, value); err != nil {
+				writer.CloseWithError(err)
+				return
+			}
+		}
+
+		part, err := form.CreateFormFile("backup_file", dbName+".zip")
+		if err == nil {
+			_, err = io.Copy(part, r)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+
+	req, err := http.NewRequest("POST", c.baseURL+"/web/database/restore", body)
+	if err != nil {
+		body.Close()
+		return fmt.Errorf("failed to create restore request: %w", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	// Restores take as long as the backup is large; the success redirect is the answer
+	client := *c.httpClient
+	client.Timeout = 0
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	resp, err := client.Do(req)
+	body.CloseWithError(io.ErrClosedPipe)
+	if err != nil {
+		return fmt.Errorf("restore request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusSeeOther || resp.StatusCode == http.StatusFound {
+		return nil
+	}
+
+	page, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if match := restoreErrorPattern.FindSubmatch(page); match != nil {
+		message := strings.Join(strings.Fields(htmlTagPattern.ReplaceAllString(string(match[1]), " ")), " ")
+		return fmt.Errorf("database restore failed: %s", html.UnescapeString(message))
+	}
+	return fmt.Errorf("database restore failed with status %d", resp.StatusCode)
+}
+
+// restoreErrorPattern finds the error the database manager renders after a failed restore
+var restoreErrorPattern = regexp.MustCompile(`(?s)alert-danger[^>]*>(.*?)</div>`)
+
+// htmlTagPattern matches HTML tags
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// ServerVersion returns the Odoo server version using db.server_version
+func (c *Client) ServerVersion(rpcID int) (string, error) {
+	result, err := c.call("db", "server_version", []interface{}{}, rpcID)
+	if err != nil {
+		return "", err
+	}
+
+	version, ok := result.(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected server_version result: %v", result)
+	}
+
+	return version, nil
+}
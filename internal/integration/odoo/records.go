@@ -0,0 +1,54 @@
+package odoo
+
+import "fmt"
+
+// SearchID returns the first record matching the domain, or 0 when there is none
+func (c *Client) SearchID(dbName string, uid int, password, model string, domain []interface{}, rpcID int) (int, error) {
+	result, err := c.ExecuteKw(dbName, uid, password, model, "search", []interface{}{domain}, rpcID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to search %s: %w", model, err)
+	}
+
+	ids, ok := result.([]interface{})
+	if !ok || len(ids) == 0 {
+		return 0, nil
+	}
+
+	id, ok := ToInt(ids[0])
+	if !ok {
+		return 0, fmt.Errorf("invalid %s id: %v", model, ids[0])
+	}
+
+	return id, nil
+}
+
+// FindOrCreate returns the first record matching the domain, creating it from values when missing
+func (c *Client) FindOrCreate(dbName string, uid int, password, model string, domain []interface{}, values map[string]interface{}, rpcID int) (int, error) {
+	id, err := c.SearchID(dbName, uid, password, model, domain, rpcID)
+	if err != nil || id > 0 {
+		return id, err
+	}
+
+	result, err := c.ExecuteKw(dbName, uid, password, model, "create", []interface{}{values}, rpcID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create %s: %w", model, err)
+	}
+
+	created, ok := ToInt(result)
+	if !ok {
+		return 0, fmt.Errorf("invalid %s id: %v", model, result)
+	}
+
+	return created, nil
+}
+
+// ToInt converts a JSON-RPC number, decoded as float64, to int
+func ToInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case float64:
+		return int(n), true
+	case int:
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,285 @@
+package operator
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"odoo-signup/internal/integration/odoo"
+	"odoo-signup/internal/models"
+	"odoo-signup/internal/profile"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SyncKind is the outbox message kind for operator CRM sync
+const SyncKind = "operator.sync"
+
+// Provisioning statuses recorded on the lead
+const (
+	StatusProvisioned = "provisioned"
+	StatusFailed      = "failed"
+)
+
+// Lead describes a signup recorded in the operator database
+type Lead struct {
+	ContactName string     `json:"contactName"`
+	Email       string     `json:"email"`
+	Phone       string     `json:"phone,omitempty"`
+	CompanyName string     `json:"companyName"`
+	CountryCode string     `json:"countryCode,omitempty"`
+	Industry    string     `json:"industry,omitempty"`
+	CompanySize string     `json:"companySize,omitempty"`
+	Plan        string     `json:"plan,omitempty"`
+	UTM         models.UTM `json:"utm"`
+	InstanceURL string     `json:"instanceUrl"`
+	Database    string     `json:"database"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// Client writes signup records into the central operator Odoo database
+type Client struct {
+	odooClient *odoo.Client
+	database   string
+	user       string
+	password   string
+}
+
+// NewClient creates an operator client, or returns nil when no operator database is configured
+func NewClient(config *models.Config) *Client {
+	if config.OperatorURL == "" || config.OperatorDatabase == "" {
+		return nil
+	}
+
+	return &Client{
+		odooClient: odoo.NewClient(config.OperatorURL, "", config.OperatorUser, config.OperatorPassword, config.TimeoutSeconds),
+		database:   config.OperatorDatabase,
+		user:       config.OperatorUser,
+		password:   config.OperatorPassword,
+	}
+}
+
+// HandleMessage is the outbox handler for SyncKind messages
+func (c *Client) HandleMessage(payload json.RawMessage) error {
+	var lead Lead
+	if err := json.Unmarshal(payload, &lead); err != nil {
+		return fmt.Errorf("failed to decode operator sync message: %w", err)
+	}
+	return c.Sync(lead)
+}
+
+// Sync creates or updates the company partner and crm.lead for a signup
+func (c *Client) Sync(lead Lead) error {
+	logger := logrus.WithFields(logrus.Fields{
+		"operator_database": c.database,
+		"tenant_database":   lead.Database,
+		"status":            lead.Status,
+	})
+
+	rpcID := int(time.Now().UnixNano() % 1000000)
+
+	uid, err := c.odooClient.Login(c.database, c.user, c.password, rpcID)
+	if err != nil {
+		return fmt.Errorf("failed to log in to operator database: %w", err)
+	}
+
+	countryID := 0
+	if lead.CountryCode != "" {
+		countryID, err = c.odooClient.SearchID(c.database, uid, c.password, "res.country", []interface{}{[]interface{}{"code", "=", strings.ToUpper(lead.CountryCode)}}, rpcID)
+		if err != nil {
+			logger.WithError(err).Warn("Failed to find country in operator database, skipping")
+		}
+	}
+
+	partnerID, err := c.upsertPartner(uid, lead, countryID, rpcID)
+	if err != nil {
+		return err
+	}
+
+	leadData := map[string]interface{}{
+		"name":         fmt.Sprintf("Signup: %s (%s)", lead.CompanyName, lead.Database),
+		"type":         "lead",
+		"partner_id":   partnerID,
+		"contact_name": lead.ContactName,
+		"partner_name": lead.CompanyName,
+		"email_from":   lead.Email,
+		"website":      lead.InstanceURL,
+		"description":  describe(lead),
+	}
+
+	if lead.Phone != "" {
+		leadData["phone"] = lead.Phone
+	}
+
+	if countryID > 0 {
+		leadData["country_id"] = countryID
+	}
+
+	for field, spec := range map[string][2]string{
+		"source_id":   {"utm.source", lead.UTM.Source},
+		"medium_id":   {"utm.medium", lead.UTM.Medium},
+		"campaign_id": {"utm.campaign", lead.UTM.Campaign},
+	} {
+		if spec[1] == "" {
+			continue
+		}
+		id, err := c.odooClient.FindOrCreate(c.database, uid, c.password, spec[0],
+			[]interface{}{[]interface{}{"name", "=", spec[1]}},
+			map[string]interface{}{"name": spec[1]}, rpcID)
+		if err != nil {
+			logger.WithError(err).WithField("model", spec[0]).Warn("Failed to resolve UTM record, skipping")
+			continue
+		}
+		leadData[field] = id
+	}
+
+	if tags := c.resolveTags(uid, lead, rpcID, logger); len(tags) > 0 {
+		leadData["tag_ids"] = tags
+	}
+
+	// The instance URL identifies the tenant, so a retried or later sync updates the same lead
+	leadDomain := []interface{}{
+		[]interface{}{"website", "=", lead.InstanceURL},
+		[]interface{}{"active", "in", []interface{}{true, false}},
+	}
+	leadID, err := c.odooClient.SearchID(c.database, uid, c.password, "crm.lead", leadDomain, rpcID)
+	if err != nil {
+		return err
+	}
+
+	if leadID > 0 {
+		if _, err := c.odooClient.ExecuteKw(c.database, uid, c.password, "crm.lead", "write", []interface{}{[]interface{}{leadID}, leadData}, rpcID); err != nil {
+			return fmt.Errorf("failed to update lead: %w", err)
+		}
+		logger.WithField("lead_id", leadID).Info("Operator lead updated")
+		return nil
+	}
+
+	result, err := c.odooClient.ExecuteKw(c.database, uid, c.password, "crm.lead", "create", []interface{}{leadData}, rpcID)
+	if err != nil {
+		return fmt.Errorf("failed to create lead: %w", err)
+	}
+
+	logger.WithField("lead_id", result).Info("Operator lead created")
+	return nil
+}
+
+// upsertPartner creates or updates the company partner identified by email
+func (c *Client) upsertPartner(uid int, lead Lead, countryID, rpcID int) (int, error) {
+	partnerData := map[string]interface{}{
+		"name":       lead.CompanyName,
+		"is_company": true,
+		"email":      lead.Email,
+		"website":    lead.InstanceURL,
+	}
+
+	if lead.Phone != "" {
+		partnerData["phone"] = lead.Phone
+	}
+
+	if countryID > 0 {
+		partnerData["country_id"] = countryID
+	}
+
+	if lead.Industry != "" {
+		name := profile.IndustryName(lead.Industry)
+		industryID, err := c.odooClient.FindOrCreate(c.database, uid, c.password, "res.partner.industry",
+			[]interface{}{[]interface{}{"name", "=ilike", name}},
+			map[string]interface{}{"name": name, "full_name": name}, rpcID)
+		if err == nil {
+			partnerData["industry_id"] = industryID
+		}
+	}
+
+	domain := []interface{}{
+		[]interface{}{"email", "=ilike", lead.Email},
+		[]interface{}{"is_company", "=", true},
+	}
+	partnerID, err := c.odooClient.SearchID(c.database, uid, c.password, "res.partner", domain, rpcID)
+	if err != nil {
+		return 0, err
+	}
+
+	if partnerID > 0 {
+		if _, err := c.odooClient.ExecuteKw(c.database, uid, c.password, "res.partner", "write", []interface{}{[]interface{}{partnerID}, partnerData}, rpcID); err != nil {
+			return 0, fmt.Errorf("failed to update partner: %w", err)
+		}
+		return partnerID, nil
+	}
+
+	result, err := c.odooClient.ExecuteKw(c.database, uid, c.password, "res.partner", "create", []interface{}{partnerData}, rpcID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create partner: %w", err)
+	}
+
+	id, ok := result.(float64)
+	if !ok {
+		return 0, fmt.Errorf("invalid partner id: %v", result)
+	}
+
+	return int(id), nil
+}
+
+// resolveTags returns tag commands for industry, size, plan and provisioning status
+func (c *Client) resolveTags(uid int, lead Lead, rpcID int, logger *logrus.Entry) []interface{} {
+	var names []string
+	if lead.Industry != "" {
+		names = append(names, profile.IndustryName(lead.Industry))
+	}
+	if lead.CompanySize != "" {
+		names = append(names, profile.SizeLabel(lead.CompanySize))
+	}
+	if lead.Plan != "" {
+		names = append(names, "Plan: "+lead.Plan)
+	}
+	if lead.Status == StatusFailed {
+		names = append(names, "Provisioning failed")
+	}
+
+	// Replace the tag set so a status change from failed to provisioned drops the old tag
+	ids := []interface{}{}
+	for _, name := range names {
+		tagID, err := c.odooClient.FindOrCreate(c.database, uid, c.password, "crm.tag",
+			[]interface{}{[]interface{}{"name", "=", name}},
+			map[string]interface{}{"name": name}, rpcID)
+		if err != nil {
+			logger.WithError(err).Warn("Failed to resolve lead tag, skipping")
+			continue
+		}
+		ids = append(ids, tagID)
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+	return []interface{}{[]interface{}{6, 0, ids}}
+}
+
+// describe renders the signup details for the lead's internal notes
+func describe(lead Lead) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Instance: %s\n", lead.InstanceURL)
+	fmt.Fprintf(&b, "Database: %s\n", lead.Database)
+	fmt.Fprintf(&b, "Provisioning status: %s\n", lead.Status)
+	if lead.Error != "" {
+		fmt.Fprintf(&b, "Provisioning error: %s\n", lead.Error)
+	}
+	if lead.Plan != "" {
+		fmt.Fprintf(&b, "Plan: %s\n", lead.Plan)
+	}
+	if lead.Industry != "" {
+		fmt.Fprintf(&b, "Industry: %s\n", profile.IndustryName(lead.Industry))
+	}
+	if lead.CompanySize != "" {
+		fmt.Fprintf(&b, "Company size: %s\n", lead.CompanySize)
+	}
+	if lead.UTM.Term != "" {
+		fmt.Fprintf(&b, "UTM term: %s\n", lead.UTM.Term)
+	}
+	if lead.UTM.Content != "" {
+		fmt.Fprintf(&b, "UTM content: %s\n", lead.UTM.Content)
+	}
+	return b.String()
+}
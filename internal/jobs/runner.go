@@ -0,0 +1,418 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"odoo-signup/internal/util"
+
+	"github.com/sirupsen/logrus"
+)
+
+// queueSize bounds the jobs waiting for a worker before Submit and Retry
+// refuse new ones with ErrBusy
+const queueSize = 256
+
+// Owners refresh the heartbeat of their unfinished jobs every
+// heartbeatInterval; a job whose heartbeat is older than staleAfter belongs to
+// a process that is gone.
+const (
+	heartbeatInterval = 30 * time.Second
+	staleAfter        = 4 * heartbeatInterval
+)
+
+// Handler runs one attempt of a job. secret is the credential submitted with
+// the job, or empty when it is no longer available.
+type Handler func(job Job, secret string) error
+
+// Runner executes jobs on a fixed pool of workers, which bounds how many
+// tenants are provisioned or restored at the same time
+type Runner struct {
+	store   *Store
+	sealer  *Sealer
+	workers int
+	owner   string // Identifies this process on the jobs it queues and runs
+
+	mu       sync.Mutex
+	handlers map[string]Handler
+	secrets  map[string]string // Credentials of jobs submitted by this process, until their attempt ends
+	waiters  map[string]chan error
+	queue    []string // Jobs waiting for a worker, oldest first
+	wake     *sync.Cond
+	stopped  bool
+	adopt    bool // Take over the jobs of processes that are gone, see Resume
+
+	stop       chan struct{}
+	wg         sync.WaitGroup
+	heartbeats sync.WaitGroup
+}
+
+// NewRunner creates a runner; sealer may be nil
+func NewRunner(store *Store, sealer *Sealer, workers int) *Runner {
+	if workers < 1 {
+		workers = 1
+	}
+	host, _ := os.Hostname()
+	r := &Runner{
+		store:    store,
+		sealer:   sealer,
+		workers:  workers,
+		owner:    fmt.Sprintf("%s:%d:%s", host, os.Getpid(), util.NewID()[:8]),
+		handlers: make(map[string]Handler),
+		secrets:  make(map[string]string),
+		waiters:  make(map[string]chan error),
+		stop:     make(chan struct{}),
+	}
+	r.wake = sync.NewCond(&r.mu)
+	return r
+}
+
+// Store returns the job history the runner records to
+func (r *Runner) Store() *Store {
+	return r.store
+}
+
+// Register sets the handler for a job kind
+func (r *Runner) Register(kind string, handler Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[kind] = handler
+}
+
+// Submit stores a job and queues it. The returned channel receives the
+// result of the attempt once it finishes. It fails with ErrBusy when the
+// queue is full and with ErrStopped once Stop was called.
+func (r *Runner) Submit(kind, tenant, actor string, payload interface{}, secret string) (Job, <-chan error, error) {
+	if err := r.accepting(); err != nil {
+		return Job{}, nil, err
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Job{}, nil, fmt.Errorf("failed to encode %s job: %w", kind, err)
+	}
+
+	sealed, err := r.sealer.Seal(secret)
+	if err != nil {
+		return Job{}, nil, err
+	}
+
+	now := time.Now().UTC()
+	job, err := r.store.Create(Job{
+		Kind:      kind,
+		Tenant:    tenant,
+		Actor:     actor,
+		Payload:   data,
+		Secret:    sealed,
+		Owner:     r.owner,
+		Heartbeat: &now,
+	})
+	if err != nil {
+		return Job{}, nil, err
+	}
+
+	done := make(chan error, 1)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopped {
+		// The job stays queued for the next process that resumes jobs
+		return job, nil, ErrStopped
+	}
+	if secret != "" {
+		r.secrets[job.ID] = secret
+	}
+	r.waiters[job.ID] = done
+	r.push(job.ID)
+	return job, done, nil
+}
+
+// Retry queues a failed job for another attempt. The returned channel
+// receives the result of the attempt once it finishes.
+func (r *Runner) Retry(id string) (Job, <-chan error, error) {
+	if err := r.accepting(); err != nil {
+		return Job{}, nil, err
+	}
+
+	job, err := r.store.Requeue(id, r.owner)
+	if err != nil {
+		return job, nil, err
+	}
+
+	done := make(chan error, 1)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopped {
+		return job, nil, ErrStopped
+	}
+	r.waiters[id] = done
+	r.push(id)
+	return job, done, nil
+}
+
+// Resume takes over the jobs of processes that are gone: it queues the jobs
+// they left queued and fails the ones they were running. Jobs of a live
+// process, such as the CLI running jobs next to the server, are left to it.
+// Only the server calls it; the check repeats with every heartbeat until Stop.
+func (r *Runner) Resume() error {
+	r.mu.Lock()
+	r.adopt = true
+	r.mu.Unlock()
+	return r.reap()
+}
+
+// reap takes over the unfinished jobs whose owner is gone
+func (r *Runner) reap() error {
+	unfinished, err := r.store.List(Filter{})
+	if err != nil {
+		return err
+	}
+
+	// Oldest first so resumed jobs keep their submission order
+	for i := len(unfinished) - 1; i >= 0; i-- {
+		if !r.orphaned(unfinished[i]) {
+			continue
+		}
+
+		adopted := false
+		job, err := r.store.Update(unfinished[i].ID, func(job *Job) {
+			// Checked again as the owner may have moved on since the listing
+			if !r.orphaned(*job) {
+				return
+			}
+			adopted = true
+			now := time.Now().UTC()
+			if job.Status == StatusRunning {
+				// The attempt died with its process; its outcome is unknown
+				job.Status = StatusFailed
+				job.Error = "interrupted by a restart"
+				job.FinishedAt = &now
+				job.Owner = ""
+				job.Heartbeat = nil
+				return
+			}
+			job.Owner = r.owner
+			job.Heartbeat = &now
+		})
+		if err != nil {
+			return err
+		}
+		if !adopted || job.Status != StatusQueued {
+			continue
+		}
+
+		r.mu.Lock()
+		if !r.stopped {
+			r.push(job.ID)
+		}
+		r.mu.Unlock()
+	}
+	return nil
+}
+
+// orphaned reports whether a job is unfinished and its owner is gone
+func (r *Runner) orphaned(job Job) bool {
+	if job.Status != StatusQueued && job.Status != StatusRunning {
+		return false
+	}
+	if job.Owner == r.owner {
+		return false
+	}
+	return job.Owner == "" || job.Heartbeat == nil || time.Since(*job.Heartbeat) > staleAfter
+}
+
+// Start starts the workers and the heartbeat of the runner's jobs
+func (r *Runner) Start() {
+	for i := 0; i < r.workers; i++ {
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			for {
+				id, ok := r.next()
+				if !ok {
+					return
+				}
+				r.run(id)
+			}
+		}()
+	}
+
+	r.heartbeats.Add(1)
+	go func() {
+		defer r.heartbeats.Done()
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				r.heartbeat()
+			}
+		}
+	}()
+}
+
+// Stop waits for running jobs to finish. Jobs still queued are released and
+// resume in the next process that resumes jobs; their submitters receive
+// ErrStopped.
+func (r *Runner) Stop() {
+	r.mu.Lock()
+	if r.stopped {
+		r.mu.Unlock()
+		return
+	}
+	r.stopped = true
+	r.wake.Broadcast()
+	r.mu.Unlock()
+
+	r.wg.Wait()
+	close(r.stop)
+	r.heartbeats.Wait()
+
+	if err := r.store.Release(r.owner); err != nil {
+		logrus.WithError(err).Error("Failed to release queued jobs")
+	}
+
+	r.mu.Lock()
+	queued := r.queue
+	r.queue = nil
+	r.mu.Unlock()
+	for _, id := range queued {
+		r.finish(id, ErrStopped)
+	}
+}
+
+// accepting returns why new jobs cannot be queued, if they cannot
+func (r *Runner) accepting() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case r.stopped:
+		return ErrStopped
+	case len(r.queue) >= queueSize:
+		return ErrBusy
+	}
+	return nil
+}
+
+// push queues a job for the workers; the caller holds r.mu
+func (r *Runner) push(id string) {
+	r.queue = append(r.queue, id)
+	r.wake.Signal()
+}
+
+// next waits for a queued job and reports false once the runner stops
+func (r *Runner) next() (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for len(r.queue) == 0 && !r.stopped {
+		r.wake.Wait()
+	}
+	if r.stopped {
+		return "", false
+	}
+	id := r.queue[0]
+	r.queue = r.queue[1:]
+	return id, true
+}
+
+// heartbeat shows other processes that this one still owns its jobs, and
+// takes over the jobs of processes that are gone once Resume was called
+func (r *Runner) heartbeat() {
+	if err := r.store.Heartbeat(r.owner, time.Now().UTC()); err != nil {
+		logrus.WithError(err).Warn("Failed to record job heartbeat")
+	}
+
+	r.mu.Lock()
+	adopt := r.adopt
+	r.mu.Unlock()
+	if adopt {
+		if err := r.reap(); err != nil {
+			logrus.WithError(err).Error("Failed to resume jobs of stopped processes")
+		}
+	}
+}
+
+// run executes one attempt of a job and records the outcome
+func (r *Runner) run(id string) {
+	logger := logrus.WithField("job_id", id)
+
+	job, err := r.store.Begin(id, r.owner)
+	if errors.Is(err, ErrNotOwned) {
+		// Failed or adopted by another process's reap since it was queued here
+		logger.Warn("Job was taken over by another process, not running it")
+		r.finish(id, err)
+		return
+	}
+	if err != nil {
+		logger.WithError(err).Error("Failed to start job")
+		r.finish(id, err)
+		return
+	}
+
+	logger = logger.WithFields(logrus.Fields{
+		"kind":    job.Kind,
+		"tenant":  job.Tenant,
+		"attempt": job.Attempts,
+	})
+
+	r.mu.Lock()
+	handler, ok := r.handlers[job.Kind]
+	secret, known := r.secrets[id]
+	r.mu.Unlock()
+
+	if !known && job.Secret != "" {
+		if secret, err = r.sealer.Open(job.Secret); err != nil {
+			logger.WithError(err).Warn("Job credential is unavailable")
+		}
+	}
+
+	if ok {
+		logger.Info("Job started")
+		err = handler(job, secret)
+	} else {
+		err = fmt.Errorf("%w: %s", ErrUnknownKind, job.Kind)
+	}
+
+	if _, updateErr := r.store.Update(id, func(job *Job) {
+		now := time.Now().UTC()
+		job.FinishedAt = &now
+		job.Progress = nil
+		job.Status = StatusSucceeded
+		job.Error = ""
+		if err != nil {
+			job.Status = StatusFailed
+			job.Error = err.Error()
+		} else {
+			job.Secret = ""
+		}
+	}); updateErr != nil {
+		logger.WithError(updateErr).Error("Failed to record job outcome")
+	}
+
+	if err != nil {
+		logger.WithError(err).Warn("Job failed")
+	} else {
+		logger.Info("Job succeeded")
+	}
+
+	r.finish(id, err)
+}
+
+// finish forgets a job's credential and notifies the submitter. A retry
+// opens the sealed copy kept with a failed job.
+func (r *Runner) finish(id string, err error) {
+	r.mu.Lock()
+	delete(r.secrets, id)
+	done := r.waiters[id]
+	delete(r.waiters, id)
+	r.mu.Unlock()
+
+	if done != nil {
+		done <- err
+	}
+}
@@ -0,0 +1,71 @@
+package jobs
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// Sealer encrypts the credentials a job needs to be retried, so they are
+// never written to disk in clear text
+type Sealer struct {
+	aead cipher.AEAD
+}
+
+// NewSealer derives an AES-256-GCM key from secret. It returns nil when
+// secret is empty, in which case credentials are only kept in memory.
+func NewSealer(secret string) (*Sealer, error) {
+	if secret == "" {
+		return nil, nil
+	}
+
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job cipher: %w", err)
+	}
+
+	return &Sealer{aead: aead}, nil
+}
+
+// Seal encrypts plaintext; a nil sealer returns an empty string
+func (s *Sealer) Seal(plaintext string) (string, error) {
+	if s == nil || plaintext == "" {
+		return "", nil
+	}
+
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := s.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value produced by Seal
+func (s *Sealer) Open(sealed string) (string, error) {
+	if s == nil {
+		return "", errors.New("JOB_SECRET is not configured")
+	}
+
+	data, err := base64.RawStdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < s.aead.NonceSize() {
+		return "", errors.New("sealed value is malformed")
+	}
+
+	nonce, ciphertext := data[:s.aead.NonceSize()], data[s.aead.NonceSize():]
+	plaintext, err := s.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", errors.New("sealed value cannot be decrypted, was JOB_SECRET changed?")
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,523 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"odoo-signup/internal/store"
+	"odoo-signup/internal/util"
+)
+
+// Job statuses
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// maxFinished caps the finished jobs kept in the history
+const maxFinished = 1000
+
+// Errors returned by the job store and runner
+var (
+	ErrNotFound     = errors.New("job not found")
+	ErrNotRetryable = errors.New("only failed jobs can be retried")
+	ErrUnknownKind  = errors.New("no handler registered for job kind")
+	ErrBusy         = errors.New("too many jobs are queued, try again later")
+	ErrStopped      = errors.New("the job runner is shutting down")
+	ErrTenantBusy   = errors.New("another job of the tenant is queued or running")
+	ErrNotOwned     = errors.New("the job is no longer queued for this process")
+)
+
+// Step records the timing of one step of a job attempt
+type Step struct {
+	Name       string    `json:"name"`
+	Attempt    int       `json:"attempt"`
+	Status     string    `json:"status"` // running, succeeded or failed
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"startedAt"`
+	DurationMs int64     `json:"durationMs"`
+}
+
+// Progress counts the bytes a running step has transferred
+type Progress struct {
+	Step  string `json:"step"`
+	Done  int64  `json:"done"`
+	Total int64  `json:"total"` // Zero when the size is unknown
+}
+
+// Percent returns how much of the transfer is done, or -1 when the size is unknown
+func (p Progress) Percent() int {
+	if p.Total <= 0 {
+		return -1
+	}
+	return int(p.Done * 100 / p.Total)
+}
+
+// Job is a tracked background operation on a tenant
+type Job struct {
+	ID         string            `json:"id"`
+	Kind       string            `json:"kind"`
+	Tenant     string            `json:"tenant"`
+	Actor      string            `json:"actor"` // Who submitted the job
+	Status     string            `json:"status"`
+	Error      string            `json:"error,omitempty"`
+	Payload    json.RawMessage   `json:"payload"`
+	Secret     string            `json:"secret,omitempty"` // Sealed credential needed to retry, cleared on success
+	Steps      []Step            `json:"steps"`
+	Progress   *Progress         `json:"progress,omitempty"` // Transfer of the running step
+	Result     map[string]string `json:"result,omitempty"`   // Outputs of the job, e.g. the key of a safety snapshot
+	Attempts   int               `json:"attempts"`
+	Owner      string            `json:"owner,omitempty"`       // Process that queued or runs the job
+	Heartbeat  *time.Time        `json:"heartbeatAt,omitempty"` // Last time the owner reported it was alive
+	CreatedAt  time.Time         `json:"createdAt"`
+	UpdatedAt  time.Time         `json:"updatedAt"`
+	StartedAt  *time.Time        `json:"startedAt,omitempty"`
+	FinishedAt *time.Time        `json:"finishedAt,omitempty"`
+}
+
+// Retryable reports whether the job can be run again
+func (j Job) Retryable() bool {
+	return j.Status == StatusFailed
+}
+
+// Duration returns how long the last attempt ran, or has been running
+func (j Job) Duration() time.Duration {
+	if j.StartedAt == nil {
+		return 0
+	}
+	if j.FinishedAt == nil {
+		return time.Since(*j.StartedAt)
+	}
+	return j.FinishedAt.Sub(*j.StartedAt)
+}
+
+// Filter selects jobs from the history. Empty fields match everything.
+type Filter struct {
+	Tenant string
+	Kind   string
+	Status string
+	Limit  int
+}
+
+// Store keeps the job history in a JSON file. The CLI may run jobs against
+// the same file, so it is reloaded whenever it changes, and every change
+// reads and writes it under the file's lock shared with the other processes.
+type Store struct {
+	mu      sync.Mutex
+	file    *store.File
+	jobs    map[string]*Job
+	version store.Version
+}
+
+// NewStore loads the jobs persisted at path
+func NewStore(path string) (*Store, error) {
+	s := &Store{
+		file: store.NewFile(path),
+		jobs: make(map[string]*Job),
+	}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Create stores a new queued job and returns it with its ID
+func (s *Store) Create(job Job) (Job, error) {
+	now := time.Now().UTC()
+	job.ID = util.NewID()[:16]
+	job.Status = StatusQueued
+	job.Steps = []Step{}
+	job.CreatedAt = now
+	job.UpdatedAt = now
+
+	err := s.change(func() (bool, error) {
+		stored := job
+		s.jobs[job.ID] = &stored
+		s.prune()
+		return true, nil
+	})
+	if err != nil {
+		return Job{}, err
+	}
+	return job, nil
+}
+
+// Get returns a job by ID
+func (s *Store) Get(id string) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return Job{}, err
+	}
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	return copyJob(job), nil
+}
+
+// List returns the jobs matching a filter, newest first
+func (s *Store) List(filter Filter) ([]Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+
+	list := make([]Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		if (filter.Tenant == "" || job.Tenant == filter.Tenant) &&
+			(filter.Kind == "" || job.Kind == filter.Kind) &&
+			(filter.Status == "" || job.Status == filter.Status) {
+			list = append(list, copyJob(job))
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+
+	if filter.Limit > 0 && len(list) > filter.Limit {
+		list = list[:filter.Limit]
+	}
+	return list, nil
+}
+
+// Unfinished returns the queued and running jobs of a tenant, newest first
+func (s *Store) Unfinished(tenant string) ([]Job, error) {
+	list, err := s.List(Filter{Tenant: tenant})
+	if err != nil {
+		return nil, err
+	}
+
+	unfinished := list[:0]
+	for _, job := range list {
+		if job.Status == StatusQueued || job.Status == StatusRunning {
+			unfinished = append(unfinished, job)
+		}
+	}
+	return unfinished, nil
+}
+
+// Running returns the running jobs of a tenant other than the given job
+func (s *Store) Running(tenant, except string) ([]Job, error) {
+	list, err := s.List(Filter{Tenant: tenant, Status: StatusRunning})
+	if err != nil {
+		return nil, err
+	}
+
+	running := list[:0]
+	for _, job := range list {
+		if job.ID != except {
+			running = append(running, job)
+		}
+	}
+	return running, nil
+}
+
+// Update applies fn to a stored job and persists the result
+func (s *Store) Update(id string, fn func(job *Job)) (Job, error) {
+	var updated Job
+	err := s.change(func() (bool, error) {
+		job, ok := s.jobs[id]
+		if !ok {
+			return false, ErrNotFound
+		}
+
+		fn(job)
+		job.UpdatedAt = time.Now().UTC()
+		updated = copyJob(job)
+		return true, nil
+	})
+	if err != nil {
+		return Job{}, err
+	}
+	return updated, nil
+}
+
+// Begin marks a job owner queued as running and starts a new attempt. It
+// returns ErrNotOwned when the job finished or another process took it over
+// since it was queued.
+func (s *Store) Begin(id, owner string) (Job, error) {
+	var started Job
+	err := s.change(func() (bool, error) {
+		job, ok := s.jobs[id]
+		if !ok {
+			return false, ErrNotFound
+		}
+		if job.Status != StatusQueued || job.Owner != owner {
+			return false, ErrNotOwned
+		}
+
+		now := time.Now().UTC()
+		job.Status = StatusRunning
+		job.Attempts++
+		job.StartedAt = &now
+		job.FinishedAt = nil
+		job.Heartbeat = &now
+		job.UpdatedAt = now
+		started = copyJob(job)
+		return true, nil
+	})
+	if err != nil {
+		return Job{}, err
+	}
+	return started, nil
+}
+
+// Requeue queues a failed job again for owner. It returns ErrNotRetryable
+// when the job did not fail, and ErrTenantBusy while another job of its
+// tenant is queued or running.
+func (s *Store) Requeue(id, owner string) (Job, error) {
+	var requeued Job
+	err := s.change(func() (bool, error) {
+		job, ok := s.jobs[id]
+		if !ok {
+			return false, ErrNotFound
+		}
+		if !job.Retryable() {
+			return false, ErrNotRetryable
+		}
+		for _, other := range s.jobs {
+			if other.ID != id && job.Tenant != "" && other.Tenant == job.Tenant &&
+				(other.Status == StatusQueued || other.Status == StatusRunning) {
+				return false, fmt.Errorf("%w: %s job %s", ErrTenantBusy, other.Kind, other.ID)
+			}
+		}
+
+		now := time.Now().UTC()
+		job.Status = StatusQueued
+		job.Error = ""
+		job.FinishedAt = nil
+		job.Owner = owner
+		job.Heartbeat = &now
+		job.UpdatedAt = now
+		requeued = copyJob(job)
+		return true, nil
+	})
+	if err != nil {
+		return Job{}, err
+	}
+	return requeued, nil
+}
+
+// StartStep records that a step of the current attempt began
+func (s *Store) StartStep(id, name string, at time.Time) error {
+	_, err := s.Update(id, func(job *Job) {
+		job.Steps = append(job.Steps, Step{
+			Name:      name,
+			Attempt:   job.Attempts,
+			Status:    StatusRunning,
+			StartedAt: at,
+		})
+	})
+	return err
+}
+
+// FinishStep records the outcome of a running step; failure is empty on success
+func (s *Store) FinishStep(id, name, failure string, duration time.Duration) error {
+	_, err := s.Update(id, func(job *Job) {
+		for i := len(job.Steps) - 1; i >= 0; i-- {
+			step := &job.Steps[i]
+			if step.Name != name || step.Status != StatusRunning {
+				continue
+			}
+			step.Status = StatusSucceeded
+			if failure != "" {
+				step.Status = StatusFailed
+				step.Error = failure
+			}
+			step.DurationMs = duration.Milliseconds()
+			return
+		}
+	})
+	return err
+}
+
+// SetProgress records the transfer of a running step; a nil progress clears it
+func (s *Store) SetProgress(id string, progress *Progress) error {
+	_, err := s.Update(id, func(job *Job) {
+		job.Progress = progress
+	})
+	return err
+}
+
+// SetResult records an output of a job
+func (s *Store) SetResult(id, key, value string) error {
+	_, err := s.Update(id, func(job *Job) {
+		if job.Result == nil {
+			job.Result = make(map[string]string)
+		}
+		job.Result[key] = value
+	})
+	return err
+}
+
+// Heartbeat records that owner is alive on the unfinished jobs it owns
+func (s *Store) Heartbeat(owner string, at time.Time) error {
+	return s.updateOwned(owner, func(job *Job) bool {
+		job.Heartbeat = &at
+		return true
+	})
+}
+
+// Release gives up the queued jobs of owner, so the next process to resume
+// jobs runs them without waiting for the owner's heartbeat to go stale
+func (s *Store) Release(owner string) error {
+	return s.updateOwned(owner, func(job *Job) bool {
+		if job.Status != StatusQueued {
+			return false
+		}
+		job.Owner = ""
+		job.Heartbeat = nil
+		return true
+	})
+}
+
+// updateOwned applies fn to the unfinished jobs of owner and saves them when
+// fn changed any
+func (s *Store) updateOwned(owner string, fn func(job *Job) bool) error {
+	return s.change(func() (bool, error) {
+		changed := false
+		for _, job := range s.jobs {
+			if job.Owner == owner && (job.Status == StatusQueued || job.Status == StatusRunning) && fn(job) {
+				changed = true
+			}
+		}
+		return changed, nil
+	})
+}
+
+// DeleteTenant removes the finished jobs of a tenant, whose payloads hold
+// the signup data, and returns how many were removed. Queued and running
+// jobs are kept.
+func (s *Store) DeleteTenant(tenant string) (int, error) {
+	removed := 0
+	err := s.change(func() (bool, error) {
+		for id, job := range s.jobs {
+			if job.Tenant == tenant && (job.Status == StatusSucceeded || job.Status == StatusFailed) {
+				delete(s.jobs, id)
+				removed++
+			}
+		}
+		return removed > 0, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// prune drops the oldest finished jobs beyond maxFinished
+func (s *Store) prune() {
+	var finished []*Job
+	for _, job := range s.jobs {
+		if job.Status == StatusSucceeded || job.Status == StatusFailed {
+			finished = append(finished, job)
+		}
+	}
+	if len(finished) <= maxFinished {
+		return
+	}
+
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].CreatedAt.Before(finished[j].CreatedAt)
+	})
+	for _, job := range finished[:len(finished)-maxFinished] {
+		delete(s.jobs, job.ID)
+	}
+}
+
+// change applies fn to the jobs read under the file's lock, and saves them
+// before the lock is released when fn reports a change
+func (s *Store) change(fn func() (bool, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := s.file.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+	changed, err := fn()
+	if err != nil || !changed {
+		return err
+	}
+	return s.save()
+}
+
+// reload reads the file again when it changed since the last load
+func (s *Store) reload() error {
+	version, err := s.file.Version()
+	if err != nil {
+		return err
+	}
+	if version.Same(s.version) {
+		return nil
+	}
+	return s.load()
+}
+
+// load reads the file and remembers its version
+func (s *Store) load() error {
+	version, err := s.file.Version()
+	if err != nil {
+		return err
+	}
+
+	jobs := make(map[string]*Job)
+	if err := s.file.Load(&jobs); err != nil {
+		return err
+	}
+
+	s.jobs = jobs
+	s.version = version
+	return nil
+}
+
+// save writes the jobs and remembers the new version
+func (s *Store) save() error {
+	if err := s.file.Save(s.jobs); err != nil {
+		return err
+	}
+
+	version, err := s.file.Version()
+	if err != nil {
+		return err
+	}
+	s.version = version
+	return nil
+}
+
+// copyJob returns a job whose steps do not alias the stored slice
+func copyJob(job *Job) Job {
+	c := *job
+	c.Steps = append([]Step{}, job.Steps...)
+	if job.Heartbeat != nil {
+		heartbeat := *job.Heartbeat
+		c.Heartbeat = &heartbeat
+	}
+	if job.Progress != nil {
+		progress := *job.Progress
+		c.Progress = &progress
+	}
+	if job.Result != nil {
+		c.Result = make(map[string]string, len(job.Result))
+		for k, v := range job.Result {
+			c.Result[k] = v
+		}
+	}
+	return c
+}
@@ -0,0 +1,83 @@
+package mailer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"odoo-signup/internal/models"
+)
+
+// SendKind is the outbox message kind for outgoing email
+const SendKind = "email.send"
+
+// Message is a plain text email
+type Message struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// Mailer sends email through an SMTP relay
+type Mailer struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+}
+
+// New creates a mailer, or returns nil when no SMTP host is configured
+func New(config *models.Config) *Mailer {
+	if config.SMTPHost == "" {
+		return nil
+	}
+
+	return &Mailer{
+		host:     config.SMTPHost,
+		port:     config.SMTPPort,
+		username: config.SMTPUsername,
+		password: config.SMTPPassword,
+		from:     config.SMTPFrom,
+	}
+}
+
+// HandleMessage is the outbox handler for SendKind messages
+func (m *Mailer) HandleMessage(payload json.RawMessage) error {
+	var msg Message
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return fmt.Errorf("failed to decode email message: %w", err)
+	}
+	return m.Send(msg)
+}
+
+// Send delivers a message. STARTTLS is used when the server offers it.
+func (m *Mailer) Send(msg Message) error {
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+
+	if err := smtp.SendMail(net.JoinHostPort(m.host, m.port), auth, m.from, []string{msg.To}, m.render(msg)); err != nil {
+		return fmt.Errorf("failed to send email to %s: %w", msg.To, err)
+	}
+
+	return nil
+}
+
+// render builds the RFC 5322 message
+func (m *Mailer) render(msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Labels are the label values of a series
+type Labels map[string]string
+
+// series is one counter or summary with a fixed label set
+type series struct {
+	name   string
+	labels string
+	value  float64 // counter value, or sum for summaries
+	count  uint64  // observations, summaries only
+}
+
+// Registry holds counters and summaries and renders them in the Prometheus text format
+type Registry struct {
+	mu        sync.Mutex
+	counters  map[string]*series
+	summaries map[string]*series
+	help      map[string]string
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		counters:  make(map[string]*series),
+		summaries: make(map[string]*series),
+		help:      make(map[string]string),
+	}
+}
+
+// Describe sets the help text of a metric
+func (r *Registry) Describe(name, help string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.help[name] = help
+}
+
+// Inc increments a counter
+func (r *Registry) Inc(name string, labels Labels) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.get(r.counters, name, labels).value++
+}
+
+// Observe records a value in a summary
+func (r *Registry) Observe(name string, labels Labels, value float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.get(r.summaries, name, labels)
+	s.value += value
+	s.count++
+}
+
+// ServeHTTP writes all metrics in the Prometheus text exposition format
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	r.write(w, r.counters, "counter", func(s *series) {
+		fmt.Fprintf(w, "%s%s %g\n", s.name, s.labels, s.value)
+	})
+	r.write(w, r.summaries, "summary", func(s *series) {
+		fmt.Fprintf(w, "%s_sum%s %g\n", s.name, s.labels, s.value)
+		fmt.Fprintf(w, "%s_count%s %d\n", s.name, s.labels, s.count)
+	})
+}
+
+// write renders one metric type grouped by name in a stable order
+func (r *Registry) write(w http.ResponseWriter, all map[string]*series, kind string, line func(*series)) {
+	sorted := make([]*series, 0, len(all))
+	for _, s := range all {
+		sorted = append(sorted, s)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].name != sorted[j].name {
+			return sorted[i].name < sorted[j].name
+		}
+		return sorted[i].labels < sorted[j].labels
+	})
+
+	last := ""
+	for _, s := range sorted {
+		if s.name != last {
+			if help, ok := r.help[s.name]; ok {
+				fmt.Fprintf(w, "# HELP %s %s\n", s.name, help)
+			}
+			fmt.Fprintf(w, "# TYPE %s %s\n", s.name, kind)
+			last = s.name
+		}
+		line(s)
+	}
+}
+
+// get returns the series for a name and label set, creating it when missing
+func (r *Registry) get(all map[string]*series, name string, labels Labels) *series {
+	rendered := renderLabels(labels)
+	key := name + rendered
+	s, ok := all[key]
+	if !ok {
+		s = &series{name: name, labels: rendered}
+		all[key] = s
+	}
+	return s
+}
+
+// renderLabels formats labels as {a="1",b="2"} with sorted keys
+func renderLabels(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(labels[key])
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, key, value))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
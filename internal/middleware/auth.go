@@ -0,0 +1,217 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"odoo-signup/internal/apikeys"
+	"odoo-signup/internal/audit"
+	"odoo-signup/internal/auth"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Context keys set by AdminAuthMiddleware
+const (
+	principalContextKey = "principal"
+	sessionContextKey   = "session"
+)
+
+// CSRFHeader carries the session's CSRF token on state-changing requests
+const CSRFHeader = "X-CSRF-Token"
+
+// CSRFField carries the session's CSRF token in HTML form posts
+const CSRFField = "csrf_token"
+
+// Denier responds to a request the auth middleware rejects
+type Denier func(c *gin.Context, status int, message string)
+
+// DenyJSON rejects a request with the JSON error body used by the API
+func DenyJSON(c *gin.Context, status int, message string) {
+	c.JSON(status, gin.H{
+		"success": false,
+		"message": message,
+	})
+	c.Abort()
+}
+
+// AdminAuthMiddleware authenticates requests with a bearer API key or an
+// operator session cookie, and records every request, including rejected
+// ones, in the audit trail. sso is nil when single sign-on is disabled.
+func AdminAuthMiddleware(keys *apikeys.Store, sso *auth.SSO, auditLog *audit.Log) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		entry := newEntry(c)
+		defer record(c, auditLog, &entry)
+
+		if header := c.GetHeader("Authorization"); header != "" {
+			key, err := keys.Authenticate(strings.TrimPrefix(header, "Bearer "))
+			if err != nil {
+				entry.Error = err.Error()
+				DenyJSON(c, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+			authenticated(c, &entry, auth.FromKey(key))
+			c.Next()
+			return
+		}
+
+		session, err := sessionFromCookie(c, sso)
+		if err != nil {
+			entry.Error = err.Error()
+			DenyJSON(c, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		if !checkCSRF(c, session, &entry, DenyJSON) {
+			return
+		}
+
+		c.Set(sessionContextKey, session)
+		authenticated(c, &entry, session.Principal)
+		c.Next()
+	}
+}
+
+// ConsoleAuthMiddleware authenticates browser requests to the admin console
+// with the operator session cookie. Requests without a valid session are
+// redirected to the sign-in page and come back once signed in.
+func ConsoleAuthMiddleware(sso *auth.SSO, auditLog *audit.Log, deny Denier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		entry := newEntry(c)
+		defer record(c, auditLog, &entry)
+
+		session, err := sessionFromCookie(c, sso)
+		if err != nil {
+			entry.Error = err.Error()
+			returnTo := c.Request.URL.RequestURI()
+			if !isSafeMethod(c.Request.Method) {
+				returnTo = c.Request.URL.Path
+			}
+			c.Redirect(http.StatusFound, "/admin/login?return_to="+url.QueryEscape(returnTo))
+			c.Abort()
+			return
+		}
+		if !checkCSRF(c, session, &entry, deny) {
+			return
+		}
+
+		c.Set(sessionContextKey, session)
+		authenticated(c, &entry, session.Principal)
+		c.Next()
+	}
+}
+
+// RequireScope rejects API requests whose principal is not allowed the scope
+func RequireScope(scope string) gin.HandlerFunc {
+	return RequireScopeWith(scope, DenyJSON)
+}
+
+// RequireScopeWith rejects requests whose principal is not allowed the scope using deny
+func RequireScopeWith(scope string, deny Denier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := CurrentPrincipal(c)
+		if !ok || !principal.HasScope(scope) {
+			deny(c, http.StatusForbidden, "Missing the "+scope+" scope")
+			return
+		}
+		c.Next()
+	}
+}
+
+// CurrentPrincipal returns the principal authenticated by AdminAuthMiddleware
+func CurrentPrincipal(c *gin.Context) (auth.Principal, bool) {
+	value, ok := c.Get(principalContextKey)
+	if !ok {
+		return auth.Principal{}, false
+	}
+	principal, ok := value.(auth.Principal)
+	return principal, ok
+}
+
+// CurrentSession returns the operator session of a cookie-authenticated request
+func CurrentSession(c *gin.Context) (*auth.Session, bool) {
+	value, ok := c.Get(sessionContextKey)
+	if !ok {
+		return nil, false
+	}
+	session, ok := value.(*auth.Session)
+	return session, ok
+}
+
+// sessionFromCookie returns the session named by the request's session cookie
+func sessionFromCookie(c *gin.Context, sso *auth.SSO) (*auth.Session, error) {
+	if sso == nil {
+		return nil, errMissingCredentials
+	}
+
+	id, err := c.Cookie(sso.CookieName())
+	if err != nil || id == "" {
+		return nil, errMissingCredentials
+	}
+
+	session, ok := sso.Sessions.Get(id)
+	if !ok {
+		return nil, errSessionExpired
+	}
+	return session, nil
+}
+
+// newEntry starts the audit entry of a request
+func newEntry(c *gin.Context) audit.Entry {
+	return audit.Entry{
+		Time:     time.Now().UTC(),
+		Method:   c.Request.Method,
+		Path:     c.Request.URL.Path,
+		ClientIP: c.ClientIP(),
+	}
+}
+
+// record completes the audit entry once the request was handled
+func record(c *gin.Context, auditLog *audit.Log, entry *audit.Entry) {
+	entry.Status = c.Writer.Status()
+	if err := auditLog.Record(*entry); err != nil {
+		logrus.WithError(err).Error("Failed to write audit entry")
+	}
+}
+
+// authenticated stores the principal for the handlers and the audit entry
+func authenticated(c *gin.Context, entry *audit.Entry, principal auth.Principal) {
+	entry.Actor = principal.ID
+	entry.Name = principal.Name
+	c.Set(principalContextKey, principal)
+}
+
+// checkCSRF verifies the CSRF token of unsafe requests. Cookies are sent by the
+// browser automatically, so these requests must prove same-origin intent.
+func checkCSRF(c *gin.Context, session *auth.Session, entry *audit.Entry, deny Denier) bool {
+	if isSafeMethod(c.Request.Method) {
+		return true
+	}
+
+	token := c.GetHeader(CSRFHeader)
+	if token == "" {
+		token = c.PostForm(CSRFField)
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(session.CSRFToken)) == 1 {
+		return true
+	}
+
+	entry.Actor, entry.Name = session.Principal.ID, session.Principal.Name
+	entry.Error = "invalid CSRF token"
+	deny(c, http.StatusForbidden, "Invalid CSRF token")
+	return false
+}
+
+// isSafeMethod reports whether a method does not change state
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+var (
+	errMissingCredentials = errors.New("missing credentials")
+	errSessionExpired     = errors.New("session expired")
+)
@@ -20,4 +20,4 @@ func RateLimitMiddleware(limiter *rate.Limiter) gin.HandlerFunc {
 		}
 		c.Next()
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,460 @@
+// Package migrations moves tenants between Odoo backends as tracked jobs
+package migrations
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"odoo-signup/internal/backends"
+	"odoo-signup/internal/backups"
+	"odoo-signup/internal/events"
+	"odoo-signup/internal/integration/odoo"
+	"odoo-signup/internal/jobs"
+	"odoo-signup/internal/models"
+	"odoo-signup/internal/tenants"
+
+	"github.com/sirupsen/logrus"
+)
+
+// JobKind identifies migration jobs
+const JobKind = "migration"
+
+// ReleaseInterval is how often sources whose hold period ended are dropped
+const ReleaseInterval = 15 * time.Minute
+
+// Migration steps recorded in the job
+const (
+	StepCheck    = "check"
+	StepSuspend  = "suspend"
+	StepDump     = "dump"
+	StepRestore  = "restore"
+	StepVerify   = "verify"
+	StepSwitch   = "switch"
+	StepResume   = "resume"
+	StepRollback = "rollback"
+)
+
+// Errors returned by the migrator
+var (
+	ErrTargetRequired = errors.New("a target backend is required")
+	ErrSameBackend    = errors.New("the tenant is already on this backend")
+	ErrTargetDraining = errors.New("the target backend is draining")
+	ErrNotMovable     = errors.New("only active and suspended tenants can be migrated")
+	ErrVerification   = errors.New("the copy does not match its source")
+)
+
+// Request describes a migration
+type Request struct {
+	Database string `json:"database"`
+	Target   string `json:"target"`           // Backend the tenant moves to
+	DryRun   bool   `json:"dryRun,omitempty"` // Run the checks only, without suspending or copying
+}
+
+// Migrator moves a tenant to another backend: it suspends the tenant, dumps
+// it to the backup storage, restores the dump on the target, verifies the
+// copy, then switches the registry and routing to it. The source is kept for
+// the hold period, so the move can be undone. A migration is refused while
+// another job of the tenant is queued or running, and the job claims the
+// tenant in the registry, which keeps other processes from renaming, dropping
+// or restoring it meanwhile.
+type Migrator struct {
+	config   *models.Config
+	backends *backends.Pool
+	registry tenants.Store
+	manager  *tenants.Manager
+	backups  *backups.Scheduler
+	runner   *jobs.Runner
+	bus      *events.Bus
+	hold     time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewMigrator creates a migrator and registers its job handler with the runner
+func NewMigrator(config *models.Config, pool *backends.Pool, registry tenants.Store, manager *tenants.Manager, backupScheduler *backups.Scheduler, runner *jobs.Runner, bus *events.Bus) *Migrator {
+	m := &Migrator{
+		config:   config,
+		backends: pool,
+		registry: registry,
+		manager:  manager,
+		backups:  backupScheduler,
+		runner:   runner,
+		bus:      bus,
+		hold:     time.Duration(config.MigrationHoldHours) * time.Hour,
+	}
+	runner.Register(JobKind, m.RunJob)
+	return m
+}
+
+// Submit checks a migration and queues it as a job. The returned channel
+// receives the result once the job finishes.
+func (m *Migrator) Submit(req Request, actor string) (jobs.Job, <-chan error, error) {
+	if _, _, _, err := m.check(req); err != nil {
+		return jobs.Job{}, nil, err
+	}
+	active, err := m.runner.Store().Unfinished(req.Database)
+	if err != nil {
+		return jobs.Job{}, nil, err
+	}
+	if err := tenants.ActiveJob(req.Database, active); err != nil {
+		return jobs.Job{}, nil, err
+	}
+	return m.runner.Submit(JobKind, req.Database, actor, req, "")
+}
+
+// RunJob is the job handler for migration jobs
+func (m *Migrator) RunJob(job jobs.Job, _ string) error {
+	var req Request
+	if err := json.Unmarshal(job.Payload, &req); err != nil {
+		return fmt.Errorf("invalid migration job payload: %w", err)
+	}
+
+	store := m.runner.Store()
+	logger := logrus.WithFields(logrus.Fields{"job_id": job.ID, "database": req.Database, "target": req.Target})
+
+	var tenant tenants.Tenant
+	var source, target *odoo.Client
+	var sourceName string
+	var claim *tenants.Claim
+	err := m.step(job.ID, StepCheck, func() error {
+		// The claim keeps renames, drops, restores and other migrations of the
+		// tenant off it until the job ends, in this process or another
+		if !req.DryRun {
+			_, claimed, err := m.manager.Claim(req.Database, tenants.OperationMigration, job.ID)
+			if err != nil {
+				return err
+			}
+			claim = &claimed
+		}
+		running, err := store.Running(req.Database, job.ID)
+		if err != nil {
+			return err
+		}
+		if err := tenants.ActiveJob(req.Database, running); err != nil {
+			return err
+		}
+
+		var from backends.Backend
+		if tenant, from, target, err = m.check(req); err != nil {
+			return err
+		}
+		sourceName = from.Name
+		if source, err = m.backends.Client(from.Name); err != nil {
+			return err
+		}
+		// The source must answer before the tenant is suspended
+		if _, err := m.fingerprint(source, req.Database); err != nil {
+			return err
+		}
+		if err := store.SetResult(job.ID, "source", sourceName); err != nil {
+			return err
+		}
+		return store.SetResult(job.ID, "target", req.Target)
+	})
+	if claim != nil {
+		defer m.manager.Unclaim(req.Database, *claim)
+	}
+	if err != nil {
+		return err
+	}
+	logger = logger.WithField("source", sourceName)
+
+	if req.DryRun {
+		if err := store.SetResult(job.ID, "dryRun", "true"); err != nil {
+			return err
+		}
+		logger.Info("Migration dry run passed")
+		return nil
+	}
+
+	// Users are locked out so nothing is written to the source while it is copied
+	suspended := false
+	if tenant.Status == tenants.StatusActive {
+		err := m.step(job.ID, StepSuspend, func() error {
+			_, err := m.manager.SuspendForMigration(req.Database, job.Actor, "migration to "+req.Target)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		suspended = true
+	}
+
+	var archive backups.Archive
+	err = m.step(job.ID, StepDump, func() error {
+		var err error
+		if archive, err = m.backups.Snapshot(req.Database); err != nil {
+			return err
+		}
+		return store.SetResult(job.ID, "snapshot", archive.Key)
+	})
+	if err == nil {
+		err = m.step(job.ID, StepRestore, func() error {
+			return m.restore(target, req.Database, archive)
+		})
+	}
+	if err == nil {
+		err = m.step(job.ID, StepVerify, func() error {
+			return m.verify(source, target, req.Database)
+		})
+	}
+	if err == nil {
+		err = m.step(job.ID, StepSwitch, func() error {
+			return m.switchBackend(job, req.Database, sourceName, req.Target)
+		})
+	}
+	if err != nil {
+		m.rollback(job, req.Database, target, suspended, logger)
+		return err
+	}
+
+	// The tenant runs on the target from here on; a failed resume fails the
+	// job, so the tenant is not left suspended unnoticed
+	if suspended {
+		err := m.step(job.ID, StepResume, func() error {
+			_, err := m.manager.ResumeAfterMigration(req.Database, job.Actor)
+			return err
+		})
+		if err != nil {
+			if resultErr := store.SetResult(job.ID, "suspended", "true"); resultErr != nil {
+				logger.WithError(resultErr).Warn("Failed to record the suspension")
+			}
+			logger.WithError(err).Error("Tenant migrated but still suspended")
+			return fmt.Errorf("tenant migrated to %s but still suspended, resume it: %w", req.Target, err)
+		}
+	}
+
+	// Without a hold period the source goes at once
+	if m.hold == 0 {
+		if _, err := m.manager.ReleaseHeld(time.Now()); err != nil {
+			logger.WithError(err).Warn("Failed to drop the migration source")
+		}
+	}
+
+	logger.WithField("hold_hours", m.config.MigrationHoldHours).Info("Tenant migrated")
+	return nil
+}
+
+// Start releases the sources whose hold period ended now and then every interval
+func (m *Migrator) Start(interval time.Duration) {
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := m.manager.ReleaseHeld(time.Now()); err != nil {
+				logrus.WithError(err).Error("Failed to release migration sources")
+			}
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops the releases and waits for a running one to finish
+func (m *Migrator) Stop() {
+	if m.stop == nil {
+		return
+	}
+	close(m.stop)
+	<-m.done
+}
+
+// check validates a migration and returns the tenant with its current
+// backend and the client of the target
+func (m *Migrator) check(req Request) (tenants.Tenant, backends.Backend, *odoo.Client, error) {
+	tenant, ok := m.registry.Get(req.Database)
+	if !ok {
+		return tenant, backends.Backend{}, nil, tenants.ErrNotRegistered
+	}
+	if tenant.Status != tenants.StatusActive && tenant.Status != tenants.StatusSuspended {
+		return tenant, backends.Backend{}, nil, ErrNotMovable
+	}
+	if req.Target == "" {
+		return tenant, backends.Backend{}, nil, ErrTargetRequired
+	}
+
+	source, err := m.backends.Get(tenant.Backend)
+	if err != nil {
+		return tenant, source, nil, err
+	}
+	destination, err := m.backends.Get(req.Target)
+	if err != nil {
+		return tenant, source, nil, err
+	}
+	if source.Name == destination.Name {
+		return tenant, source, nil, ErrSameBackend
+	}
+	if destination.Draining {
+		return tenant, source, nil, ErrTargetDraining
+	}
+	for _, status := range m.backends.List() {
+		if status.Name == destination.Name && status.Capacity > 0 && status.Tenants >= status.Capacity {
+			return tenant, source, nil, backends.ErrNoCapacity
+		}
+	}
+
+	target, err := m.backends.Client(destination.Name)
+	if err != nil {
+		return tenant, source, nil, err
+	}
+	exists, err := databaseExists(target, req.Database)
+	if err != nil {
+		return tenant, source, nil, err
+	}
+	if exists {
+		return tenant, source, nil, fmt.Errorf("%w on %s", tenants.ErrExists, destination.Name)
+	}
+	return tenant, source, target, nil
+}
+
+// restore streams the dump into the target, under the same name and UUID
+func (m *Migrator) restore(target *odoo.Client, database string, archive backups.Archive) error {
+	dump, err := m.backups.Storage().Get(archive.Key)
+	if err != nil {
+		return fmt.Errorf("failed to open the dump: %w", err)
+	}
+	defer dump.Close()
+	return target.RestoreDatabase(database, dump, false, false)
+}
+
+// verify signs in to the copy with the service account and checks that it
+// holds the source database: the same UUID and the same number of users
+func (m *Migrator) verify(source, target *odoo.Client, database string) error {
+	want, err := m.fingerprint(source, database)
+	if err != nil {
+		return err
+	}
+	got, err := m.fingerprint(target, database)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrVerification, err)
+	}
+	if got != want {
+		return fmt.Errorf("%w: %s on the source, %s on the target", ErrVerification, want, got)
+	}
+	return nil
+}
+
+// fingerprint signs in to a database and describes it by its UUID and user count
+func (m *Migrator) fingerprint(client *odoo.Client, database string) (string, error) {
+	rpcID := newRPCID()
+	uid, err := client.Login(database, m.config.AdminUser, m.config.AdminPassword, rpcID)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign in to %s: %w", database, err)
+	}
+
+	result, err := client.ExecuteKw(database, uid, m.config.AdminPassword, "ir.config_parameter", "get_param",
+		[]interface{}{"database.uuid"}, rpcID)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the database UUID: %w", err)
+	}
+	uuid, _ := result.(string)
+
+	// Archived users count too: the tenant is suspended during the copy
+	result, err = client.ExecuteKw(database, uid, m.config.AdminPassword, "res.users", "search_count",
+		[]interface{}{[]interface{}{"|", []interface{}{"active", "=", true}, []interface{}{"active", "=", false}}}, rpcID)
+	if err != nil {
+		return "", fmt.Errorf("failed to count users: %w", err)
+	}
+	users, _ := result.(float64)
+
+	return fmt.Sprintf("UUID %s with %d users", uuid, int(users)), nil
+}
+
+// switchBackend moves the tenant to the target in the registry, which routes
+// it there, and holds the source until the hold period ends
+func (m *Migrator) switchBackend(job jobs.Job, database, source, target string) error {
+	now := time.Now().UTC()
+	until := now.Add(m.hold)
+	tenant, err := m.registry.Update(database, func(tenant *tenants.Tenant) error {
+		tenant.Backend = target
+		tenant.Held = append(tenant.Held, tenants.HeldCopy{Backend: source, Database: database, JobID: job.ID, Until: until})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := m.runner.Store().SetResult(job.ID, "holdUntil", until.Format(time.RFC3339)); err != nil {
+		logrus.WithError(err).WithField("job_id", job.ID).Warn("Failed to record the hold period")
+	}
+
+	m.bus.Publish(events.TenantMigrated{Tenant: tenants.EventTenant(tenant), From: source, Actor: job.Actor, At: now})
+	return nil
+}
+
+// rollback drops what the migration created on the target and resumes the
+// tenant on its source when the migration suspended it
+func (m *Migrator) rollback(job jobs.Job, database string, target *odoo.Client, suspended bool, logger *logrus.Entry) {
+	err := m.step(job.ID, StepRollback, func() error {
+		exists, err := databaseExists(target, database)
+		if err != nil {
+			return err
+		}
+		if exists {
+			if err := target.DropDatabase(database, newRPCID()); err != nil {
+				return err
+			}
+		}
+		if suspended {
+			_, err := m.manager.ResumeAfterMigration(database, job.Actor)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		logger.WithError(err).Error("Rollback of the migration failed")
+		return
+	}
+	logger.Warn("Migration failed, tenant left on its source")
+}
+
+// step runs one step of a migration and records its timing in the job
+func (m *Migrator) step(jobID, name string, fn func() error) error {
+	store := m.runner.Store()
+	started := time.Now()
+	if err := store.StartStep(jobID, name, started.UTC()); err != nil {
+		return err
+	}
+
+	err := fn()
+	failure := ""
+	if err != nil {
+		failure = err.Error()
+	}
+	if recordErr := store.FinishStep(jobID, name, failure, time.Since(started)); recordErr != nil {
+		logrus.WithError(recordErr).WithField("job_id", jobID).Warn("Failed to record migration step")
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// databaseExists reports whether a backend has the database
+func databaseExists(client *odoo.Client, database string) (bool, error) {
+	names, err := client.ListDatabases(newRPCID())
+	if err != nil {
+		return false, fmt.Errorf("failed to list databases: %w", err)
+	}
+	for _, name := range names {
+		if name == database {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// newRPCID returns an RPC ID for a single operation
+func newRPCID() int {
+	return int(time.Now().UnixNano() % 1000000)
+}
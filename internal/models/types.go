@@ -1,52 +1,174 @@
 package models
 
-import "golang.org/x/time/rate"
+import (
+	"time"
+
+	"golang.org/x/time/rate"
+)
 
 // Config holds application configuration
 type Config struct {
-	Port             string
-	OdooURL          string
-	OdooMasterPass   string
-	OdooCompany      string
-	Environment      string
-	Domain           string
-	TemplateDatabase string // Name of the template database to clone
-	AdminUser        string // Admin username for template database
-	AdminPassword    string // Password for the admin user in template database
-	DefaultDBMode    string // Default database mode: "create" or "clone"
-	RateLimit        rate.Limit
-	BurstLimit       int
-	LogLevel         string
-	TimeoutSeconds   int // HTTP client timeout in seconds
+	Port                     string
+	OdooURL                  string
+	OdooMasterPass           string
+	BackendsPath             string // JSON file listing the Odoo servers; only ODOO_URL is used when missing
+	PlacementStrategy        string // Backend of new tenants: least, weighted, region or plan
+	PlacementRegions         string // Countries of each backend region, e.g. "eu=DE,FR;us=US,CA"
+	MigrationHoldHours       int    // Hours the source of a migration is kept before it is dropped
+	OdooCompany              string
+	Environment              string
+	Domain                   string
+	TemplateDatabase         string // Name of the template database to clone
+	AdminUser                string // Admin username for template database
+	AdminPassword            string // Password for the admin user in template database
+	DefaultDBMode            string // Default database mode: "create" or "clone"
+	InstallLocalization      bool   // Install the country's fiscal localization module
+	RateLimit                rate.Limit
+	BurstLimit               int
+	LogLevel                 string
+	TimeoutSeconds           int    // HTTP client timeout in seconds
+	CountryCacheTTLSeconds   int    // How long the country list is cached
+	PasswordMinLength        int    // Minimum password length, also applied to Odoo's auth_password_policy
+	PasswordMinScore         int    // Minimum password strength score (0-4)
+	CompanySizeField         string // res.partner field for company size; a partner tag is used when empty
+	OperatorURL              string // URL of the central operator Odoo server; lead sync is disabled when empty
+	OperatorDatabase         string
+	OperatorUser             string
+	OperatorPassword         string
+	OutboxPath               string // File holding deliveries awaiting retry
+	OutboxIntervalSeconds    int    // How often queued deliveries are retried
+	WebhookEndpoints         string // Comma-separated URLs receiving signup lifecycle events
+	WebhookSecret            string // HMAC-SHA256 key for signing webhook deliveries
+	WebhookLogPath           string // File holding the webhook delivery log
+	APIKeysPath              string // File holding hashed admin API keys
+	AuditLogPath             string // Append-only audit trail of admin API calls
+	OIDCIssuer               string // OpenID Connect issuer for operator sign-on; disabled when empty
+	OIDCClientID             string
+	OIDCClientSecret         string // Empty for public clients
+	OIDCRedirectURL          string // Must point to /admin/callback
+	OIDCScopes               string // Space-separated scopes requested from the provider
+	OIDCGroupsClaim          string // Claim listing the operator's groups
+	OIDCRoleMapping          string // Comma-separated group=role pairs (roles: admin, operator, viewer)
+	SessionTTLMinutes        int    // Lifetime of operator sessions
+	SessionCookieSecure      bool   // Mark the session cookie Secure; disable only for local HTTP testing
+	SMTPHost                 string // SMTP relay for welcome emails; email is disabled when empty
+	SMTPPort                 string
+	SMTPUsername             string
+	SMTPPassword             string
+	SMTPFrom                 string
+	EventBusWorkers          int    // Goroutines delivering domain events to subscribers
+	TenantRegistryPath       string // SQLite database holding the registry of provisioned tenants
+	MaintenanceMapPath       string // nginx map of suspended tenant hosts, for a maintenance page; disabled when empty
+	DomainMapPath            string // nginx map of tenant hosts to databases, custom domains included; disabled when empty
+	DNSResolver              string // host:port of the DNS server checking domain challenges and record propagation; the system resolver when empty
+	DNSProvider              string // Creates a record per tenant host: rfc2136 or http; disabled when empty, e.g. with wildcard DNS
+	DNSRecordType            string // A, AAAA or CNAME
+	DNSRecordValue           string // Address of the proxy, or the host a CNAME points to
+	DNSRecordTTL             int
+	DNSPropagationTimeout    int    // Seconds provisioning waits for the record to resolve; 0 skips the check
+	DNSUpdateServer          string // host:port of the primary server accepting dynamic updates
+	DNSZone                  string // Zone holding tenant records; DOMAIN when empty
+	DNSTSIGKey               string // TSIG key name; updates are unsigned when empty
+	DNSTSIGSecret            string // Base64 TSIG secret
+	DNSTSIGAlgorithm         string // hmac-sha256, hmac-sha512 or hmac-sha1
+	DNSHTTPURL               string // Base URL of the DNS HTTP API
+	DNSHTTPToken             string // Bearer token of the DNS HTTP API
+	ProxyConfigFormat        string // Reverse proxy configuration written from the registry: nginx, caddy or traefik; disabled when empty
+	ProxyConfigPath          string // File receiving the proxy configuration
+	ProxyOdooUpstream        string // URL the proxy reaches Odoo at
+	ProxyMaintenanceUpstream string // URL the proxy reaches this service at, for the maintenance page of suspended tenants
+	ProxyCertResolver        string // Traefik certificate resolver of tenant routers; no TLS section when empty
+	ProxyReloadCommand       string // Shell command run after a routing file changed, e.g. "nginx -s reload"
+	BackupDir                string // Directory of the local backup storage
+	BackupSchedule           string // Cron expression (UTC) of automatic backups; empty disables them
+	BackupFormat             string // "zip" (with filestore) or "dump" (pg_dump custom format)
+	BackupStorage            string // "local" (BACKUP_DIR) or "s3"
+	BackupS3Endpoint         string
+	BackupS3Region           string
+	BackupS3Bucket           string
+	BackupS3AccessKey        string
+	BackupS3SecretKey        string
+	BackupS3Prefix           string
+	BackupKeepDaily          int    // Retention: newest backup of this many days
+	BackupKeepWeekly         int    // Retention: newest backup of this many ISO weeks
+	BackupKeepMonthly        int    // Retention: newest backup of this many months
+	JobsPath                 string // File holding the history of provisioning jobs
+	JobWorkers               int    // Jobs run concurrently; signups beyond this wait in the queue
+	JobSecret                string // Key sealing signup passwords so failed jobs can be retried after a restart
+	ImportsDir               string // Directory holding the reports of bulk tenant imports
+	TrialDays                int    // Trial length of plans not listed in TrialPlans; 0 provisions them permanently
+	TrialPlans               string // Comma-separated plan=days trial lengths; 0 days makes a plan permanent
+	TrialReminderDays        string // Comma-separated days before a trial ends that a reminder is sent
+	TrialGraceDays           int    // Days an expired trial stays suspended before it is backed up and dropped; 0 keeps it
+	TrialCheckMinutes        int    // How often trials are checked for reminders, expiry and deletion
+	PublicURL                string // Base URL of this service in emailed links; self-service account requests are disabled when empty
+	AccountRequestsPath      string // File holding owners' export and deletion requests
+	ErasureLogPath           string // Append-only proofs of erasure of deleted tenants
+	AccountLinkHours         int    // How long an emailed confirmation link stays valid
+	DeletionCoolingOffDays   int    // Days from a confirmed deletion request to the erasure, during which it can be cancelled
+	ExportRetentionHours     int    // How long an export can be downloaded before it is deleted
+	AccountCheckMinutes      int    // How often due erasures and expired requests and exports are processed
 }
 
+// Country identifies the selected country by ISO code. The matching
+// res.country record is looked up in the target database.
 type Country struct {
-	ID   int    `json:"id" validate:"required"`
 	Code string `json:"code" validate:"required,len=2"`
 	Name string `json:"name"`
 }
 
+// CountryInfo represents a country offered on the signup form
+type CountryInfo struct {
+	Code      string `json:"code"`
+	Name      string `json:"name"`
+	PhoneCode int    `json:"phoneCode,omitempty"`
+}
+
 // SignupRequest represents the signup form data
 type SignupRequest struct {
 	Username    string  `json:"username" validate:"required,min=3,max=20,alphanum"`
 	Email       string  `json:"email" validate:"required,email"`
-	Password    string  `json:"password" validate:"required,min=8"`
+	Password    string  `json:"password" validate:"required,min=8,max=128"`
 	FirstName   string  `json:"firstName" validate:"required,min=2"`
 	LastName    string  `json:"lastName" validate:"required,min=2"`
 	Phone       string  `json:"phone"`
 	CompanyName string  `json:"companyName" validate:"required,min=2"`
-	Industry    string  `json:"industry"`
-	CompanySize string  `json:"companySize"`
-	Country     Country `json:"country" validate:"required,dive"`
+	Industry    string  `json:"industry" validate:"omitempty,max=64"`
+	CompanySize string  `json:"companySize" validate:"omitempty,oneof=1-10 11-50 51-200 201-1000 1000+"`
+	Country     Country `json:"country" validate:"required"`
+	Timezone    string  `json:"timezone,omitempty" validate:"omitempty,timezone"`
+	Currency    string  `json:"currency,omitempty" validate:"omitempty,len=3,alpha"`
+	Plan        string  `json:"plan,omitempty" validate:"omitempty,max=32"`
+	UTM         UTM     `json:"utm,omitempty"`
 	DbMode      string  `json:"dbMode,omitempty" validate:"omitempty,oneof=create clone"`
 	Terms       bool    `json:"terms" validate:"required"`
 }
 
+// UTM holds the marketing campaign parameters the visitor arrived with
+type UTM struct {
+	Source   string `json:"source,omitempty" validate:"omitempty,max=128"`
+	Medium   string `json:"medium,omitempty" validate:"omitempty,max=128"`
+	Campaign string `json:"campaign,omitempty" validate:"omitempty,max=128"`
+	Term     string `json:"term,omitempty" validate:"omitempty,max=128"`
+	Content  string `json:"content,omitempty" validate:"omitempty,max=128"`
+}
+
+// PasswordStrengthRequest carries a password and the personal data it must not contain
+type PasswordStrengthRequest struct {
+	Password    string `json:"password" validate:"max=128"`
+	Username    string `json:"username" validate:"max=128"`
+	Email       string `json:"email" validate:"max=254"`
+	CompanyName string `json:"companyName" validate:"max=128"`
+	FirstName   string `json:"firstName" validate:"max=128"`
+	LastName    string `json:"lastName" validate:"max=128"`
+}
+
 // SignupResponse represents the API response for signup
 type SignupResponse struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message,omitempty"`
-	Data    *SignupData `json:"data,omitempty"`
+	Success bool              `json:"success"`
+	Message string            `json:"message,omitempty"`
+	Errors  map[string]string `json:"errors,omitempty"` // Field errors keyed by form field name
+	Data    *SignupData       `json:"data,omitempty"`
 }
 
 // SignupData contains the signup result data
@@ -56,6 +178,23 @@ type SignupData struct {
 	Database    string `json:"database"`
 }
 
+// TenantEventData is the payload of signup lifecycle webhook events
+type TenantEventData struct {
+	Database         string     `json:"database"`
+	InstanceURL      string     `json:"instanceUrl"`
+	Email            string     `json:"email,omitempty"`
+	CompanyName      string     `json:"companyName,omitempty"`
+	CountryCode      string     `json:"countryCode,omitempty"`
+	Plan             string     `json:"plan,omitempty"`
+	DbMode           string     `json:"dbMode,omitempty"`
+	Error            string     `json:"error,omitempty"`
+	Reason           string     `json:"reason,omitempty"` // Why a tenant was suspended
+	TrialEndsAt      *time.Time `json:"trialEndsAt,omitempty"`
+	DeleteAt         *time.Time `json:"deleteAt,omitempty"`         // When an expired trial is dropped
+	PreviousDatabase string     `json:"previousDatabase,omitempty"` // Name before a rename
+	Domain           string     `json:"domain,omitempty"`           // Custom domain verified or removed
+}
+
 // DatabaseInfo represents database information
 type DatabaseInfo struct {
 	Name string `json:"name"`
@@ -0,0 +1,268 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testIssuer is an identity provider serving discovery, JWKS, token and
+// userinfo endpoints. The token endpoint checks the PKCE verifier against the
+// challenge of the last authorization URL and returns an ID token with claims.
+type testIssuer struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	challenge string
+	claims    map[string]interface{}
+	userinfo  map[string]interface{}
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &testIssuer{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 issuer.server.URL,
+			"authorization_endpoint": issuer.server.URL + "/authorize",
+			"token_endpoint":         issuer.server.URL + "/token",
+			"userinfo_endpoint":      issuer.server.URL + "/userinfo",
+			"jwks_uri":               issuer.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []jsonWebKey{{
+			Kty: "RSA",
+			Kid: "test-key",
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "test-code" || Challenge(r.FormValue("code_verifier")) != issuer.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "test-access-token",
+			"id_token":     issuer.sign(t, issuer.claims),
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-access-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(issuer.userinfo)
+	})
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+// sign returns an RS256 JWT of the claims
+func (i *testIssuer) sign(t *testing.T, claims map[string]interface{}) string {
+	header, _ := json.Marshal(jwtHeader{Alg: "RS256", Kid: "test-key"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Error(err)
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, i.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Error(err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// login starts a login with the provider and redeems the code
+func (i *testIssuer) login(t *testing.T, p *Provider, nonce string) (*Identity, error) {
+	t.Helper()
+	verifier, err := NewVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := p.AuthURL("test-state", nonce, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("nonce") != nonce || query.Get("state") != "test-state" {
+		t.Fatalf("unexpected authorization URL %s", authURL)
+	}
+	i.challenge = query.Get("code_challenge")
+	return p.Exchange("test-code", verifier, nonce)
+}
+
+func (i *testIssuer) provider() *Provider {
+	return NewProvider(Config{
+		Issuer:      i.server.URL + "/",
+		ClientID:    "odoo-signup",
+		RedirectURL: "https://signup.example.com/auth/callback",
+		Scopes:      []string{"openid", "email"},
+		GroupsClaim: "groups",
+	}, i.server.Client())
+}
+
+func (i *testIssuer) validClaims(nonce string) map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{
+		"iss":    i.server.URL,
+		"aud":    []string{"odoo-signup", "other-client"},
+		"sub":    "user-1",
+		"email":  "admin@example.com",
+		"name":   "Admin",
+		"groups": []string{"operators"},
+		"iat":    now.Unix(),
+		"exp":    now.Add(time.Hour).Unix(),
+		"nonce":  nonce,
+	}
+}
+
+func TestExchangeAcceptsValidToken(t *testing.T) {
+	issuer := newTestIssuer(t)
+	issuer.claims = issuer.validClaims("test-nonce")
+
+	identity, err := issuer.login(t, issuer.provider(), "test-nonce")
+	if err != nil {
+		t.Fatalf("valid token rejected: %v", err)
+	}
+	if identity.Subject != "user-1" || identity.Email != "admin@example.com" || identity.Name != "Admin" ||
+		len(identity.Groups) != 1 || identity.Groups[0] != "operators" {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+}
+
+func TestExchangeReadsGroupsFromUserinfo(t *testing.T) {
+	issuer := newTestIssuer(t)
+	issuer.claims = issuer.validClaims("test-nonce")
+	delete(issuer.claims, "groups")
+	issuer.userinfo = map[string]interface{}{"sub": "user-1", "groups": []string{"admins", "operators"}}
+
+	identity, err := issuer.login(t, issuer.provider(), "test-nonce")
+	if err != nil {
+		t.Fatalf("valid token rejected: %v", err)
+	}
+	if strings.Join(identity.Groups, ",") != "admins,operators" {
+		t.Fatalf("groups = %v, want the userinfo groups", identity.Groups)
+	}
+}
+
+func TestExchangeRejectsInvalidToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(claims map[string]interface{})
+		err    string
+	}{
+		{
+			name:   "wrong audience",
+			change: func(claims map[string]interface{}) { claims["aud"] = "other-client" },
+			err:    "not issued for this client",
+		},
+		{
+			name: "expired",
+			change: func(claims map[string]interface{}) {
+				claims["iat"] = time.Now().Add(-2 * time.Hour).Unix()
+				claims["exp"] = time.Now().Add(-time.Hour).Unix()
+			},
+			err: "expired",
+		},
+		{
+			name:   "wrong nonce",
+			change: func(claims map[string]interface{}) { claims["nonce"] = "other-nonce" },
+			err:    "nonce mismatch",
+		},
+		{
+			name:   "wrong issuer",
+			change: func(claims map[string]interface{}) { claims["iss"] = "https://attacker.example.com" },
+			err:    "issuer",
+		},
+		{
+			name:   "issued in the future",
+			change: func(claims map[string]interface{}) { claims["iat"] = time.Now().Add(time.Hour).Unix() },
+			err:    "issued in the future",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := newTestIssuer(t)
+			issuer.claims = issuer.validClaims("test-nonce")
+			tt.change(issuer.claims)
+
+			_, err := issuer.login(t, issuer.provider(), "test-nonce")
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestExchangeRejectsForgedSignature(t *testing.T) {
+	issuer := newTestIssuer(t)
+	issuer.claims = issuer.validClaims("test-nonce")
+	// Sign with another key published under the same key ID
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer.key = other
+
+	_, err = issuer.login(t, issuer.provider(), "test-nonce")
+	if err == nil || !strings.Contains(err.Error(), "signature verification failed") {
+		t.Fatalf("error = %v, want a signature failure", err)
+	}
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	issuer := newTestIssuer(t)
+	issuer.claims = issuer.validClaims("test-nonce")
+	p := issuer.provider()
+
+	verifier, _ := NewVerifier()
+	if _, err := p.AuthURL("test-state", "test-nonce", verifier); err != nil {
+		t.Fatal(err)
+	}
+	issuer.challenge = Challenge(verifier)
+
+	other, _ := NewVerifier()
+	if _, err := p.Exchange("test-code", other, "test-nonce"); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("error = %v, want invalid_grant", err)
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	// RFC 7636, appendix B
+	if got := Challenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"); got != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Fatalf("Challenge = %s", got)
+	}
+	verifier, err := NewVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(verifier) != 43 {
+		t.Fatalf("verifier has %d characters, want 43", len(verifier))
+	}
+}
@@ -0,0 +1,320 @@
+package outbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"odoo-signup/internal/store"
+	"odoo-signup/internal/util"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Retry schedule for failed messages
+const (
+	baseDelay   = 30 * time.Second
+	maxDelay    = time.Hour
+	maxAttempts = 20
+)
+
+// Message is a pending delivery to an external system
+type Message struct {
+	ID          string          `json:"id"`
+	Kind        string          `json:"kind"`
+	Key         string          `json:"key,omitempty"` // Tenant or record the message belongs to
+	Payload     json.RawMessage `json:"payload"`
+	Attempts    int             `json:"attempts"`
+	NextAttempt time.Time       `json:"nextAttempt"`
+	LastError   string          `json:"lastError,omitempty"`
+	Dead        bool            `json:"dead,omitempty"` // Gave up after maxAttempts
+	CreatedAt   time.Time       `json:"createdAt"`
+}
+
+// Handler delivers a message payload; returning an error schedules a retry
+type Handler func(payload json.RawMessage) error
+
+// Outbox stores messages on disk until their handler succeeds. The CLI
+// enqueues into the same file the server delivers from, so the file is
+// reloaded whenever it changed before messages are read, and changed under
+// the file's lock shared with the other processes.
+type Outbox struct {
+	mu       sync.Mutex
+	file     *store.File
+	version  store.Version
+	messages []*Message
+	handlers map[string]Handler
+	interval time.Duration
+	wake     chan struct{}
+	stop     chan struct{}
+}
+
+// New loads the outbox persisted at path
+func New(path string, interval time.Duration) (*Outbox, error) {
+	o := &Outbox{
+		file:     store.NewFile(path),
+		handlers: make(map[string]Handler),
+		interval: interval,
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+	}
+
+	if err := o.reload(); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// Register sets the handler for a message kind
+func (o *Outbox) Register(kind string, handler Handler) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.handlers[kind] = handler
+}
+
+// Enqueue stores a message and triggers an immediate delivery attempt
+func (o *Outbox) Enqueue(kind, key string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s message: %w", kind, err)
+	}
+
+	now := time.Now().UTC()
+	err = o.change(func() (bool, error) {
+		o.messages = append(o.messages, &Message{
+			ID:          util.NewID(),
+			Kind:        kind,
+			Key:         key,
+			Payload:     data,
+			NextAttempt: now,
+			CreatedAt:   now,
+		})
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Messages returns a copy of all stored messages
+func (o *Outbox) Messages() []Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err := o.reload(); err != nil {
+		logrus.WithError(err).Error("Failed to reload outbox")
+	}
+
+	result := make([]Message, 0, len(o.messages))
+	for _, m := range o.messages {
+		result = append(result, *m)
+	}
+	return result
+}
+
+// Purge removes the undelivered messages of a key, such as the emails and
+// events of an erased tenant, and returns how many were removed
+func (o *Outbox) Purge(key string) (int, error) {
+	removed := 0
+	err := o.change(func() (bool, error) {
+		kept := o.messages[:0]
+		for _, m := range o.messages {
+			if m.Key != key {
+				kept = append(kept, m)
+			}
+		}
+		removed = len(o.messages) - len(kept)
+		o.messages = kept
+		return removed > 0, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// Start delivers due messages in the background until Stop is called
+func (o *Outbox) Start() {
+	go func() {
+		ticker := time.NewTicker(o.interval)
+		defer ticker.Stop()
+
+		o.deliverDue()
+		for {
+			select {
+			case <-ticker.C:
+			case <-o.wake:
+			case <-o.stop:
+				return
+			}
+			o.deliverDue()
+		}
+	}()
+}
+
+// Stop ends background delivery
+func (o *Outbox) Stop() {
+	close(o.stop)
+}
+
+// deliverDue attempts every message whose retry time has come
+func (o *Outbox) deliverDue() {
+	now := time.Now().UTC()
+
+	o.mu.Lock()
+	if err := o.reload(); err != nil {
+		logrus.WithError(err).Error("Failed to reload outbox")
+	}
+	var due []Message
+	for _, m := range o.messages {
+		if !m.Dead && !m.NextAttempt.After(now) {
+			due = append(due, *m)
+		}
+	}
+	o.mu.Unlock()
+
+	for _, m := range due {
+		o.deliver(m)
+	}
+}
+
+// deliver runs the handler for one message and records the outcome
+func (o *Outbox) deliver(m Message) {
+	logger := logrus.WithFields(logrus.Fields{
+		"outbox_id": m.ID,
+		"kind":      m.Kind,
+		"key":       m.Key,
+	})
+
+	o.mu.Lock()
+	handler, ok := o.handlers[m.Kind]
+	o.mu.Unlock()
+	if !ok {
+		logger.Warn("No outbox handler registered, leaving message queued")
+		return
+	}
+
+	err := handler(m.Payload)
+
+	// The outcome is recorded on the messages as stored now, which may hold
+	// messages the CLI enqueued during the delivery
+	recordErr := o.change(func() (bool, error) {
+		index := -1
+		for i, existing := range o.messages {
+			if existing.ID == m.ID {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return false, nil
+		}
+
+		if err == nil {
+			o.messages = append(o.messages[:index], o.messages[index+1:]...)
+			logger.Info("Outbox message delivered")
+			return true, nil
+		}
+
+		m := o.messages[index]
+		m.Attempts++
+		m.LastError = err.Error()
+		if m.Attempts >= maxAttempts {
+			m.Dead = true
+			logger.WithError(err).Error("Outbox message failed permanently")
+		} else {
+			m.NextAttempt = time.Now().UTC().Add(backoff(m.Attempts))
+			logger.WithError(err).WithField("next_attempt", m.NextAttempt).Warn("Outbox delivery failed, will retry")
+		}
+		return true, nil
+	})
+	if recordErr != nil {
+		logger.WithError(recordErr).Error("Failed to persist outbox")
+	}
+}
+
+// change applies fn to the messages read under the file's lock, and saves
+// them before the lock is released when fn reports a change
+func (o *Outbox) change(fn func() (bool, error)) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	unlock, err := o.file.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := o.load(); err != nil {
+		return err
+	}
+	changed, err := fn()
+	if err != nil || !changed {
+		return err
+	}
+	return o.save()
+}
+
+// reload reads the file again when it changed since the last load
+func (o *Outbox) reload() error {
+	version, err := o.file.Version()
+	if err != nil {
+		return err
+	}
+	if version.Same(o.version) {
+		return nil
+	}
+	return o.load()
+}
+
+// load reads the file and remembers its version
+func (o *Outbox) load() error {
+	version, err := o.file.Version()
+	if err != nil {
+		return err
+	}
+
+	var messages []*Message
+	if err := o.file.Load(&messages); err != nil {
+		return err
+	}
+
+	o.messages = messages
+	o.version = version
+	return nil
+}
+
+// save writes the messages and remembers the new version
+func (o *Outbox) save() error {
+	if err := o.file.Save(o.messages); err != nil {
+		return err
+	}
+
+	version, err := o.file.Version()
+	if err != nil {
+		return err
+	}
+	o.version = version
+	return nil
+}
+
+// backoff returns the exponential delay before the next attempt
+func backoff(attempts int) time.Duration {
+	delay := baseDelay
+	for i := 1; i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay
+}
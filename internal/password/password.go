@@ -0,0 +1,284 @@
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+//go:embed common-passwords.txt
+var commonPasswordList string
+
+// commonPasswords holds the bundled list of frequently used passwords
+var commonPasswords = func() map[string]bool {
+	set := make(map[string]bool)
+	scanner := bufio.NewScanner(strings.NewReader(commonPasswordList))
+	for scanner.Scan() {
+		if word := strings.TrimSpace(scanner.Text()); word != "" {
+			set[strings.ToLower(word)] = true
+		}
+	}
+	return set
+}()
+
+// commonWords lists the common passwords usable as substrings, longest first,
+// so the longest match wins when patterns overlap
+var commonWords = func() []string {
+	var words []string
+	for word := range commonPasswords {
+		if len(word) >= 4 {
+			words = append(words, word)
+		}
+	}
+	sort.Slice(words, func(i, j int) bool {
+		if len(words[i]) != len(words[j]) {
+			return len(words[i]) > len(words[j])
+		}
+		return words[i] < words[j]
+	})
+	return words
+}()
+
+// Entropy thresholds in bits for scores 1 to 4
+var scoreThresholds = [4]float64{28, 36, 50, 65}
+
+// Keyboard rows used to detect patterns such as "qwerty" or "asdf"
+var keyboardRows = []string{"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./"}
+
+// leetSubstitutions maps common character substitutions back to letters
+var leetSubstitutions = strings.NewReplacer("0", "o", "1", "l", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s", "!", "i")
+
+// Policy describes the minimum requirements for a password
+type Policy struct {
+	MinLength int // Minimum number of characters
+	MinScore  int // Minimum strength score from 0 (very weak) to 4 (very strong)
+}
+
+// Result describes the strength of a password
+type Result struct {
+	Score       int      `json:"score"`
+	Entropy     float64  `json:"entropy"`
+	Acceptable  bool     `json:"acceptable"`
+	MinScore    int      `json:"minScore"`
+	Warnings    []string `json:"warnings,omitempty"`
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+// Evaluate scores a password against the policy. userInputs holds personal
+// data such as the username, email or company name that must not be reused.
+func (p Policy) Evaluate(password string, userInputs ...string) Result {
+	result := Result{MinScore: p.MinScore}
+
+	length := len([]rune(password))
+	lower := strings.ToLower(password)
+	unleet := leetSubstitutions.Replace(lower)
+
+	if commonPasswords[lower] || commonPasswords[unleet] {
+		result.Warnings = append(result.Warnings, "This is a commonly used password")
+		result.Suggestions = append(result.Suggestions, "Avoid well-known passwords and simple variations of them")
+		result.Acceptable = false
+		return result
+	}
+
+	// Patterns are located by character, so multibyte characters count once.
+	// Leet substitutions replace one character by one, so both forms align.
+	chars := []rune(unleet)
+
+	// Mark characters belonging to predictable patterns; those add little entropy
+	covered := make([]bool, len(chars))
+	patternBits := 0.0
+
+	for _, input := range tokens(userInputs) {
+		if idx := indexRunes(chars, []rune(input)); idx >= 0 {
+			patternBits += mark(covered, idx, len([]rune(input)), 2)
+			if !containsString(result.Warnings, "Avoid using your name, username, email or company name") {
+				result.Warnings = append(result.Warnings, "Avoid using your name, username, email or company name")
+			}
+		}
+	}
+
+	for _, word := range commonWords {
+		if idx := indexRunes(chars, []rune(word)); idx >= 0 {
+			patternBits += mark(covered, idx, len([]rune(word)), math.Log2(float64(len(commonPasswords))))
+			if !containsString(result.Warnings, "Contains a common word or password") {
+				result.Warnings = append(result.Warnings, "Contains a common word or password")
+			}
+		}
+	}
+
+	for _, run := range predictableRuns([]rune(lower)) {
+		patternBits += mark(covered, run[0], run[1], math.Log2(float64(run[1]))+4)
+		if !containsString(result.Warnings, "Avoid sequences, repeated characters and keyboard patterns") {
+			result.Warnings = append(result.Warnings, "Avoid sequences, repeated characters and keyboard patterns")
+		}
+	}
+
+	// Remaining characters are treated as brute-forceable over the character set in use
+	charsetBits := math.Log2(float64(charsetSize(password)))
+	for i := range covered {
+		if !covered[i] {
+			patternBits += charsetBits
+		}
+	}
+
+	result.Entropy = math.Round(patternBits*10) / 10
+	for _, threshold := range scoreThresholds {
+		if result.Entropy >= threshold {
+			result.Score++
+		}
+	}
+
+	if length < p.MinLength {
+		result.Warnings = append(result.Warnings, "Password is too short")
+	}
+	if result.Score < p.MinScore {
+		result.Suggestions = append(result.Suggestions, "Use a longer password or a passphrase of several unrelated words")
+		if charsetSize(password) < 62 {
+			result.Suggestions = append(result.Suggestions, "Mix upper and lower case letters, numbers and symbols")
+		}
+	}
+
+	result.Acceptable = length >= p.MinLength && result.Score >= p.MinScore
+	return result
+}
+
+// tokens splits personal data into lowercase fragments worth checking
+func tokens(inputs []string) []string {
+	var result []string
+	for _, input := range inputs {
+		input = strings.ToLower(input)
+		if at := strings.Index(input, "@"); at > 0 {
+			result = append(result, input[:at])
+			input = input[at+1:]
+		}
+		for _, field := range strings.FieldsFunc(input, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			if len([]rune(field)) >= 3 {
+				result = append(result, field)
+			}
+		}
+	}
+	return result
+}
+
+// predictableRuns finds repeated characters, alphabetical or numeric sequences
+// and keyboard patterns of at least three characters. Each run is [start, length]
+// in characters.
+func predictableRuns(s []rune) [][2]int {
+	var runs [][2]int
+	i := 0
+	for i < len(s)-2 {
+		n := 1
+		for i+n < len(s) && isPredictableStep(s, i+n-1, i+n) {
+			n++
+		}
+		if n >= 3 {
+			runs = append(runs, [2]int{i, n})
+			i += n
+			continue
+		}
+		i++
+	}
+	return runs
+}
+
+// isPredictableStep reports whether the character at b follows the one at a
+// in a repeat, a sequence or along a keyboard row
+func isPredictableStep(s []rune, a, b int) bool {
+	diff := s[b] - s[a]
+	if diff == 0 || diff == 1 || diff == -1 {
+		return true
+	}
+	for _, row := range keyboardRows {
+		if ia := strings.IndexRune(row, s[a]); ia >= 0 {
+			if ib := strings.IndexRune(row, s[b]); ib >= 0 && (ib-ia == 1 || ia-ib == 1) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// indexRunes returns the character index of the first match of sub in s, or -1
+func indexRunes(s, sub []rune) int {
+	for i := 0; i+len(sub) <= len(s); i++ {
+		match := true
+		for j := range sub {
+			if s[i+j] != sub[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
+
+// mark flags a pattern as covered and returns the bits it contributes,
+// or zero if it overlaps a pattern already found
+func mark(covered []bool, start, length int, bits float64) float64 {
+	for i := start; i < start+length && i < len(covered); i++ {
+		if covered[i] {
+			return 0
+		}
+	}
+	for i := start; i < start+length && i < len(covered); i++ {
+		covered[i] = true
+	}
+	return bits
+}
+
+// charsetSize estimates the size of the character set used by a password
+func charsetSize(password string) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	size := 0
+	if lower {
+		size += 26
+	}
+	if upper {
+		size += 26
+	}
+	if digit {
+		size += 10
+	}
+	if symbol {
+		size += 33
+	}
+	if other {
+		size += 100
+	}
+	if size == 0 {
+		size = 1
+	}
+	return size
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package phone
+
+// callingCodes maps ISO country codes to their ITU country calling code
+var callingCodes = map[string]string{
+	"AD": "376", "AE": "971", "AF": "93", "AG": "1", "AI": "1", "AL": "355", "AM": "374",
+	"AO": "244", "AR": "54", "AS": "1", "AT": "43", "AU": "61", "AW": "297", "AX": "358",
+	"AZ": "994", "BA": "387", "BB": "1", "BD": "880", "BE": "32", "BF": "226", "BG": "359",
+	"BH": "973", "BI": "257", "BJ": "229", "BL": "590", "BM": "1", "BN": "673", "BO": "591",
+	"BQ": "599", "BR": "55", "BS": "1", "BT": "975", "BW": "267", "BY": "375", "BZ": "501",
+	"CA": "1", "CC": "61", "CD": "243", "CF": "236", "CG": "242", "CH": "41", "CI": "225",
+	"CK": "682", "CL": "56", "CM": "237", "CN": "86", "CO": "57", "CR": "506", "CU": "53",
+	"CV": "238", "CW": "599", "CX": "61", "CY": "357", "CZ": "420", "DE": "49", "DJ": "253",
+	"DK": "45", "DM": "1", "DO": "1", "DZ": "213", "EC": "593", "EE": "372", "EG": "20",
+	"EH": "212", "ER": "291", "ES": "34", "ET": "251", "FI": "358", "FJ": "679", "FK": "500",
+	"FM": "691", "FO": "298", "FR": "33", "GA": "241", "GB": "44", "GD": "1", "GE": "995",
+	"GF": "594", "GG": "44", "GH": "233", "GI": "350", "GL": "299", "GM": "220", "GN": "224",
+	"GP": "590", "GQ": "240", "GR": "30", "GT": "502", "GU": "1", "GW": "245", "GY": "592",
+	"HK": "852", "HN": "504", "HR": "385", "HT": "509", "HU": "36", "ID": "62", "IE": "353",
+	"IL": "972", "IM": "44", "IN": "91", "IO": "246", "IQ": "964", "IR": "98", "IS": "354",
+	"IT": "39", "JE": "44", "JM": "1", "JO": "962", "JP": "81", "KE": "254", "KG": "996",
+	"KH": "855", "KI": "686", "KM": "269", "KN": "1", "KP": "850", "KR": "82", "KW": "965",
+	"KY": "1", "KZ": "7", "LA": "856", "LB": "961", "LC": "1", "LI": "423", "LK": "94",
+	"LR": "231", "LS": "266", "LT": "370", "LU": "352", "LV": "371", "LY": "218", "MA": "212",
+	"MC": "377", "MD": "373", "ME": "382", "MF": "590", "MG": "261", "MH": "692", "MK": "389",
+	"ML": "223", "MM": "95", "MN": "976", "MO": "853", "MP": "1", "MQ": "596", "MR": "222",
+	"MS": "1", "MT": "356", "MU": "230", "MV": "960", "MW": "265", "MX": "52", "MY": "60",
+	"MZ": "258", "NA": "264", "NC": "687", "NE": "227", "NF": "672", "NG": "234", "NI": "505",
+	"NL": "31", "NO": "47", "NP": "977", "NR": "674", "NU": "683", "NZ": "64", "OM": "968",
+	"PA": "507", "PE": "51", "PF": "689", "PG": "675", "PH": "63", "PK": "92", "PL": "48",
+	"PM": "508", "PR": "1", "PS": "970", "PT": "351", "PW": "680", "PY": "595", "QA": "974",
+	"RE": "262", "RO": "40", "RS": "381", "RU": "7", "RW": "250", "SA": "966", "SB": "677",
+	"SC": "248", "SD": "249", "SE": "46", "SG": "65", "SH": "290", "SI": "386", "SJ": "47",
+	"SK": "421", "SL": "232", "SM": "378", "SN": "221", "SO": "252", "SR": "597", "SS": "211",
+	"ST": "239", "SV": "503", "SX": "1", "SY": "963", "SZ": "268", "TC": "1", "TD": "235",
+	"TG": "228", "TH": "66", "TJ": "992", "TK": "690", "TL": "670", "TM": "993", "TN": "216",
+	"TO": "676", "TR": "90", "TT": "1", "TV": "688", "TW": "886", "TZ": "255", "UA": "380",
+	"UG": "256", "US": "1", "UY": "598", "UZ": "998", "VA": "39", "VC": "1", "VE": "58",
+	"VG": "1", "VI": "1", "VN": "84", "VU": "678", "WF": "681", "WS": "685", "XK": "383",
+	"YE": "967", "YT": "262", "ZA": "27", "ZM": "260", "ZW": "263",
+}
+
+// primaryCountries picks the country used for shared calling codes when the
+// selected country does not use that code
+var primaryCountries = map[string]string{
+	"1":   "US",
+	"7":   "RU",
+	"39":  "IT",
+	"44":  "GB",
+	"47":  "NO",
+	"61":  "AU",
+	"212": "MA",
+	"262": "RE",
+	"358": "FI",
+	"590": "GP",
+	"599": "CW",
+}
+
+// trunkPrefixes lists national dialling prefixes that differ from "0".
+// An empty prefix means national numbers are dialled as-is.
+var trunkPrefixes = map[string]string{
+	// North American Numbering Plan
+	"US": "1", "CA": "1", "AG": "1", "AI": "1", "AS": "1", "BB": "1", "BM": "1", "BS": "1",
+	"DM": "1", "DO": "1", "GD": "1", "GU": "1", "JM": "1", "KN": "1", "KY": "1", "LC": "1",
+	"MP": "1", "MS": "1", "PR": "1", "SX": "1", "TC": "1", "TT": "1", "VC": "1", "VG": "1",
+	"VI": "1",
+	// Former Soviet trunk prefix
+	"RU": "8", "KZ": "8", "BY": "8", "UZ": "8", "TM": "8",
+	// No trunk prefix; a leading zero is part of the number
+	"IT": "", "VA": "", "SM": "", "ES": "", "PT": "", "GR": "", "DK": "", "NO": "",
+	"SJ": "", "IS": "", "LU": "", "MT": "", "MC": "", "AD": "", "CY": "", "EE": "",
+	"LV": "", "SG": "", "HK": "", "MO": "", "QA": "", "BH": "", "KW": "", "OM": "",
+	"CR": "", "GT": "", "HN": "", "SV": "", "NI": "", "PA": "", "BZ": "", "UY": "",
+	"CL": "", "BO": "",
+}
+
+// nationalLengths holds the minimum and maximum length of the national
+// significant number for countries with well-known numbering plans
+var nationalLengths = map[string][2]int{
+	"AE": {8, 9}, "AR": {10, 10}, "AT": {4, 13}, "AU": {9, 9}, "BD": {8, 10},
+	"BE": {8, 9}, "BR": {10, 11}, "CA": {10, 10}, "CH": {9, 9}, "CL": {9, 9},
+	"CN": {9, 12}, "CO": {8, 10}, "CZ": {9, 9}, "DE": {6, 13}, "DK": {8, 8},
+	"EG": {8, 10}, "ES": {9, 9}, "FI": {5, 12}, "FR": {9, 9}, "GB": {9, 10},
+	"GR": {10, 10}, "HK": {8, 8}, "HU": {8, 9}, "ID": {8, 12}, "IE": {7, 9},
+	"IL": {8, 9}, "IN": {10, 10}, "IT": {6, 11}, "JP": {9, 10}, "KE": {9, 9},
+	"KR": {8, 10}, "KZ": {10, 10}, "MX": {10, 10}, "MY": {8, 10}, "NG": {8, 10},
+	"NL": {9, 9}, "NO": {8, 8}, "NZ": {8, 10}, "PE": {8, 9}, "PH": {8, 10},
+	"PK": {9, 10}, "PL": {9, 9}, "PT": {9, 9}, "RO": {9, 9}, "RU": {10, 10},
+	"SA": {8, 9}, "SE": {7, 13}, "SG": {8, 8}, "TH": {8, 9}, "TR": {10, 10},
+	"TW": {8, 9}, "UA": {9, 9}, "US": {10, 10}, "VN": {9, 10}, "ZA": {9, 9},
+}
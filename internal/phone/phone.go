@@ -0,0 +1,165 @@
+package phone
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidNumber is returned when a number cannot be a valid phone number
+var ErrInvalidNumber = errors.New("invalid phone number")
+
+// maxE164Digits is the maximum number of digits in an E.164 number
+const maxE164Digits = 15
+
+// Generic national number bounds used for countries without specific rules
+const (
+	defaultMinLength = 4
+	defaultMaxLength = 14
+)
+
+// Normalize parses a phone number entered for the given ISO country code and
+// returns it in E.164 format. Numbers starting with "+" or "00" are treated as
+// international, anything else as a national number of the selected country.
+func Normalize(raw, countryCode string) (string, error) {
+	countryCode = strings.ToUpper(strings.TrimSpace(countryCode))
+
+	digits, international, err := clean(raw)
+	if err != nil {
+		return "", err
+	}
+
+	var callingCode, national, country string
+	if international {
+		callingCode, national, country = splitInternational(digits, countryCode)
+		if callingCode == "" {
+			return "", fmt.Errorf("%w: unknown country calling code", ErrInvalidNumber)
+		}
+	} else {
+		code, ok := callingCodes[countryCode]
+		if !ok {
+			return "", fmt.Errorf("%w: unknown country %q", ErrInvalidNumber, countryCode)
+		}
+		callingCode = code
+		country = countryCode
+		national = stripTrunkPrefix(digits, countryCode)
+	}
+
+	minLen, maxLen := defaultMinLength, defaultMaxLength
+	if bounds, ok := nationalLengths[country]; ok {
+		minLen, maxLen = bounds[0], bounds[1]
+	}
+
+	switch {
+	case len(national) < minLen:
+		return "", fmt.Errorf("%w: too short for %s", ErrInvalidNumber, country)
+	case len(national) > maxLen:
+		return "", fmt.Errorf("%w: too long for %s", ErrInvalidNumber, country)
+	case len(callingCode)+len(national) > maxE164Digits:
+		return "", fmt.Errorf("%w: exceeds %d digits", ErrInvalidNumber, maxE164Digits)
+	}
+
+	return "+" + callingCode + national, nil
+}
+
+// clean strips formatting characters and reports whether the number carries an
+// international prefix
+func clean(raw string) (string, bool, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", false, fmt.Errorf("%w: empty", ErrInvalidNumber)
+	}
+
+	international := strings.HasPrefix(raw, "+")
+	if international {
+		raw = raw[1:]
+	}
+
+	var b strings.Builder
+	for _, r := range raw {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')' || r == '/':
+			// formatting
+		default:
+			return "", false, fmt.Errorf("%w: unexpected character %q", ErrInvalidNumber, r)
+		}
+	}
+
+	digits := b.String()
+	if !international && strings.HasPrefix(digits, "00") {
+		international = true
+		digits = digits[2:]
+	}
+
+	if digits == "" {
+		return "", false, fmt.Errorf("%w: no digits", ErrInvalidNumber)
+	}
+
+	return digits, international, nil
+}
+
+// splitInternational separates the calling code from the national number.
+// When several countries share the calling code the selected country is preferred.
+func splitInternational(digits, preferred string) (string, string, string) {
+	// Calling codes are prefix-free, so the first match is the only match
+	for n := 1; n <= 3 && n < len(digits); n++ {
+		prefix := digits[:n]
+		countries, ok := countriesByCallingCode[prefix]
+		if !ok {
+			continue
+		}
+
+		country := countries[0]
+		for _, c := range countries {
+			if c == preferred {
+				country = c
+				break
+			}
+		}
+
+		return prefix, digits[n:], country
+	}
+
+	return "", "", ""
+}
+
+// stripTrunkPrefix removes the national dialling prefix from a national number
+func stripTrunkPrefix(digits, country string) string {
+	prefix := "0"
+	if p, ok := trunkPrefixes[country]; ok {
+		prefix = p
+	}
+
+	if prefix != "" && strings.HasPrefix(digits, prefix) {
+		return digits[len(prefix):]
+	}
+
+	// Tolerate national numbers typed with the calling code but without "+"
+	if code := callingCodes[country]; code != "" && strings.HasPrefix(digits, code) {
+		if bounds, ok := nationalLengths[country]; ok && len(digits)-len(code) >= bounds[0] && len(digits) > bounds[1] {
+			return digits[len(code):]
+		}
+	}
+
+	return digits
+}
+
+// countriesByCallingCode is the reverse index of callingCodes
+var countriesByCallingCode = func() map[string][]string {
+	index := make(map[string][]string)
+	for country, code := range callingCodes {
+		index[code] = append(index[code], country)
+	}
+	// Make the primary country of shared codes deterministic
+	for code, primary := range primaryCountries {
+		countries := index[code]
+		for i, c := range countries {
+			if c == primary {
+				countries[0], countries[i] = countries[i], countries[0]
+			}
+		}
+	}
+	return index
+}()
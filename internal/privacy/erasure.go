@@ -0,0 +1,144 @@
+package privacy
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Proof records that a tenant was erased, without keeping its personal data.
+// Each proof carries the hash of the previous one, so removing or editing an
+// entry breaks the chain.
+type Proof struct {
+	RequestID   string    `json:"requestId"`
+	Database    string    `json:"database"`
+	EmailHash   string    `json:"emailHash"` // HashEmail of the owner who confirmed the request
+	RequestedAt time.Time `json:"requestedAt"`
+	ConfirmedAt time.Time `json:"confirmedAt"`
+	ErasedAt    time.Time `json:"erasedAt"`
+	Purged      Purged    `json:"purged"`
+	Previous    string    `json:"previous"` // Hash of the previous proof, empty for the first
+	Hash        string    `json:"hash"`
+}
+
+// Purged counts the records deleted with a tenant
+type Purged struct {
+	Database       bool `json:"database"`
+	Registry       bool `json:"registry"`
+	Backups        int  `json:"backups"`
+	Exports        int  `json:"exports"`
+	Jobs           int  `json:"jobs"`
+	OutboxMessages int  `json:"outboxMessages"`
+}
+
+// ErasureLog is the append-only chain of proofs, stored as JSON lines
+type ErasureLog struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewErasureLog opens the proofs at path, creating its directory when needed
+func NewErasureLog(path string) (*ErasureLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	return &ErasureLog{path: path}, nil
+}
+
+// Record chains a proof to the last one and appends it
+func (l *ErasureLog) Record(proof Proof) (Proof, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	proofs, err := l.read()
+	if err != nil {
+		return Proof{}, err
+	}
+	proof.Previous = ""
+	if len(proofs) > 0 {
+		proof.Previous = proofs[len(proofs)-1].Hash
+	}
+	proof.Hash = proof.digest()
+
+	data, err := json.Marshal(proof)
+	if err != nil {
+		return Proof{}, fmt.Errorf("failed to encode proof of erasure: %w", err)
+	}
+
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return Proof{}, fmt.Errorf("failed to open %s: %w", l.path, err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return Proof{}, fmt.Errorf("failed to write %s: %w", l.path, err)
+	}
+	return proof, nil
+}
+
+// List returns all proofs, oldest first
+func (l *ErasureLog) List() ([]Proof, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.read()
+}
+
+// Verify checks the chain and returns the index of the first proof that does
+// not match its hash or predecessor, or -1 when the chain is intact
+func (l *ErasureLog) Verify() (int, error) {
+	proofs, err := l.List()
+	if err != nil {
+		return 0, err
+	}
+
+	previous := ""
+	for i, proof := range proofs {
+		if proof.Previous != previous || proof.Hash != proof.digest() {
+			return i, nil
+		}
+		previous = proof.Hash
+	}
+	return -1, nil
+}
+
+// read decodes every proof; the caller holds the lock
+func (l *ErasureLog) read() ([]Proof, error) {
+	file, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Proof{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", l.path, err)
+	}
+	defer file.Close()
+
+	proofs := []Proof{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var proof Proof
+		if err := json.Unmarshal(scanner.Bytes(), &proof); err != nil {
+			return nil, fmt.Errorf("failed to parse %s line %d: %w", l.path, len(proofs)+1, err)
+		}
+		proofs = append(proofs, proof)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", l.path, err)
+	}
+	return proofs, nil
+}
+
+// digest hashes a proof without its own hash
+func (p Proof) digest() string {
+	p.Hash = ""
+	data, _ := json.Marshal(p)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
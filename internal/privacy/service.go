@@ -0,0 +1,621 @@
+package privacy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"odoo-signup/internal/backups"
+	"odoo-signup/internal/events"
+	"odoo-signup/internal/jobs"
+	"odoo-signup/internal/mailer"
+	"odoo-signup/internal/models"
+	"odoo-signup/internal/outbox"
+	"odoo-signup/internal/tenants"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ExportJobKind identifies the jobs dumping a tenant for its owner
+const ExportJobKind = "export"
+
+// Actor is recorded as the submitter of export jobs
+const Actor = "account"
+
+// exportPrefix keeps exports apart from backups in the backup storage. Odoo
+// database names start with a letter or digit, so no tenant uses it.
+const exportPrefix = "_exports/"
+
+// Errors returned for emailed links
+var (
+	ErrInvalidKind = errors.New("kind must be export or delete")
+	ErrExpired     = errors.New("the link has expired, request a new one")
+	ErrNotPending  = errors.New("the request was already confirmed or is finished")
+	ErrNoExport    = errors.New("the export is not available")
+)
+
+// RunResult lists what a pass over the requests did, by request ID
+type RunResult struct {
+	CheckedAt time.Time         `json:"checkedAt"`
+	Expired   []string          `json:"expired"`
+	Removed   []string          `json:"removed"` // Exports deleted after their retention
+	Erased    []Proof           `json:"erased"`
+	Failures  map[string]string `json:"failures,omitempty"` // Error by request ID
+}
+
+// Service runs owners' export and deletion requests
+type Service struct {
+	store      *Store
+	registry   tenants.Store
+	manager    *tenants.Manager
+	backups    *backups.Scheduler
+	runner     *jobs.Runner
+	outbox     *outbox.Outbox
+	erasures   *ErasureLog
+	bus        *events.Bus
+	backupDir  string // Manual backups of earlier versions were written here whatever the storage
+	publicURL  string
+	company    string
+	linkTTL    time.Duration
+	coolingOff time.Duration
+	exportTTL  time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewService creates the service and registers the export job handler with
+// the runner. Emails carry the links' tokens, so they are queued in the
+// outbox directly instead of through events, which webhooks receive.
+func NewService(config *models.Config, requests *Store, registry tenants.Store, manager *tenants.Manager, backupScheduler *backups.Scheduler, runner *jobs.Runner, messageOutbox *outbox.Outbox, erasures *ErasureLog, bus *events.Bus) *Service {
+	s := &Service{
+		store:      requests,
+		registry:   registry,
+		manager:    manager,
+		backups:    backupScheduler,
+		runner:     runner,
+		outbox:     messageOutbox,
+		erasures:   erasures,
+		bus:        bus,
+		backupDir:  config.BackupDir,
+		publicURL:  config.PublicURL,
+		company:    config.OdooCompany,
+		linkTTL:    time.Duration(config.AccountLinkHours) * time.Hour,
+		coolingOff: time.Duration(config.DeletionCoolingOffDays) * 24 * time.Hour,
+		exportTTL:  time.Duration(config.ExportRetentionHours) * time.Hour,
+	}
+	runner.Register(ExportJobKind, s.RunJob)
+	return s
+}
+
+// Store returns the request store
+func (s *Service) Store() *Store {
+	return s.store
+}
+
+// Erasures returns the proofs of erasure
+func (s *Service) Erasures() *ErasureLog {
+	return s.erasures
+}
+
+// Open emails a confirmation link to the owner of a tenant. Requests whose
+// email does not own the tenant are ignored without an error, so callers
+// cannot probe which addresses own which tenants.
+func (s *Service) Open(kind, database, email string) error {
+	if kind != KindExport && kind != KindDelete {
+		return ErrInvalidKind
+	}
+	logger := logrus.WithFields(logrus.Fields{"database": database, "kind": kind})
+
+	tenant, ok := s.registry.Get(database)
+	if !ok || tenant.OwnerEmail == "" || HashEmail(tenant.OwnerEmail) != HashEmail(email) ||
+		(tenant.Status != tenants.StatusActive && tenant.Status != tenants.StatusSuspended) {
+		logger.Info("Account request ignored, the email does not own the tenant")
+		return nil
+	}
+
+	// Only the newest link of a kind is valid; a scheduled deletion is only cancelled
+	requests, err := s.store.List()
+	if err != nil {
+		return err
+	}
+	for _, req := range requests {
+		if req.Database != database || req.Kind != kind {
+			continue
+		}
+		if req.Status == StatusScheduled || req.Status == StatusRunning {
+			logger.Info("Account request ignored, one is already in progress")
+			return nil
+		}
+		if req.Status == StatusPending {
+			if _, err := s.store.Update(req.ID, func(r *Request) { r.Status = StatusExpired }); err != nil {
+				return err
+			}
+		}
+	}
+
+	req, token, err := s.store.Create(kind, database, tenant.OwnerEmail, s.linkTTL)
+	if err != nil {
+		return err
+	}
+
+	action := "export all data of"
+	if kind == KindDelete {
+		action = "permanently delete"
+	}
+	err = s.email(req, fmt.Sprintf("Confirm your %s request", kind), fmt.Sprintf("Hello,\n\n"+
+		"We received a request to %s your Odoo instance at https://%s.\n\n"+
+		"Confirm it within %s at:\n\n"+
+		"    %s\n\n"+
+		"If you did not ask for this, ignore this email and nothing happens.\n\n"+
+		"The %s team\n",
+		action, tenant.InstanceURL, hours(s.linkTTL), s.link("confirm", token), s.company))
+	if err != nil {
+		return err
+	}
+
+	logger.WithField("request_id", req.ID).Info("Account request opened")
+	return nil
+}
+
+// Confirm runs the request of an emailed link: an export is queued as a job
+// and a deletion is scheduled after the cooling-off period
+func (s *Service) Confirm(token string) (Request, error) {
+	req, err := s.pending(token)
+	if err != nil {
+		return Request{}, err
+	}
+
+	now := time.Now().UTC()
+	if req.Kind == KindExport {
+		req, err = s.store.Update(req.ID, func(r *Request) {
+			r.Status = StatusRunning
+			r.ConfirmedAt = &now
+		})
+		if err != nil {
+			return Request{}, err
+		}
+
+		job, _, err := s.runner.Submit(ExportJobKind, req.Database, Actor, exportPayload{RequestID: req.ID}, "")
+		if err != nil {
+			s.fail(req.ID, err)
+			return Request{}, err
+		}
+		return s.store.Update(req.ID, func(r *Request) { r.JobID = job.ID })
+	}
+
+	deleteAt := now.Add(s.coolingOff)
+	req, err = s.store.Update(req.ID, func(r *Request) {
+		r.Status = StatusScheduled
+		r.ConfirmedAt = &now
+		r.DeleteAt = &deleteAt
+	})
+	if err != nil {
+		return Request{}, err
+	}
+
+	err = s.email(req, "Your instance is scheduled for deletion", fmt.Sprintf("Hello,\n\n"+
+		"Your Odoo instance %s and all its data will be deleted on %s, including backups.\n\n"+
+		"Until then you can cancel the deletion at:\n\n"+
+		"    %s\n\n"+
+		"The %s team\n",
+		req.Database, deleteAt.Format("January 2, 2006"), s.link("confirm", token), s.company))
+	if err != nil {
+		logrus.WithError(err).WithField("request_id", req.ID).Error("Failed to queue the deletion notice")
+	}
+
+	logrus.WithFields(logrus.Fields{"request_id": req.ID, "database": req.Database, "delete_at": deleteAt}).Info("Tenant deletion scheduled")
+	return req, nil
+}
+
+// Cancel stops a scheduled deletion during its cooling-off period
+func (s *Service) Cancel(token string) (Request, error) {
+	req, err := s.store.Find(token)
+	if err != nil {
+		return Request{}, err
+	}
+	if req.Kind != KindDelete || req.Status != StatusScheduled {
+		return Request{}, ErrNotPending
+	}
+
+	now := time.Now().UTC()
+	req, err = s.store.Update(req.ID, func(r *Request) {
+		r.Status = StatusCancelled
+		r.CompletedAt = &now
+	})
+	if err != nil {
+		return Request{}, err
+	}
+
+	logrus.WithFields(logrus.Fields{"request_id": req.ID, "database": req.Database}).Info("Tenant deletion cancelled")
+	return req, nil
+}
+
+// Lookup returns the request of an emailed link
+func (s *Service) Lookup(token string) (Request, error) {
+	return s.store.Find(token)
+}
+
+// Download opens the export of an emailed link. The caller closes the reader.
+func (s *Service) Download(token string) (Request, io.ReadCloser, int64, error) {
+	req, err := s.store.Find(token)
+	if err != nil {
+		return Request{}, nil, 0, err
+	}
+	if req.Kind != KindExport || req.ExportKey == "" || req.ExportExpiresAt == nil || !req.ExportExpiresAt.After(time.Now()) {
+		return Request{}, nil, 0, ErrNoExport
+	}
+
+	object, err := s.backups.Storage().Stat(req.ExportKey)
+	if err != nil {
+		return Request{}, nil, 0, ErrNoExport
+	}
+	reader, err := s.backups.Storage().Get(req.ExportKey)
+	if err != nil {
+		return Request{}, nil, 0, err
+	}
+
+	logrus.WithFields(logrus.Fields{"request_id": req.ID, "database": req.Database}).Info("Export downloaded")
+	return req, reader, object.Size, nil
+}
+
+// exportPayload is the payload of export jobs
+type exportPayload struct {
+	RequestID string `json:"requestId"`
+}
+
+// RunJob is the job handler for export jobs: it dumps the tenant with its
+// filestore and emails the download link. A failed export fails its request;
+// the owner asks again rather than retrying the job.
+func (s *Service) RunJob(job jobs.Job, _ string) error {
+	var payload exportPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid export job payload: %w", err)
+	}
+
+	req, err := s.store.Get(payload.RequestID)
+	if err != nil {
+		return err
+	}
+	if req.Status != StatusRunning {
+		return fmt.Errorf("export request %s is %s", req.ID, req.Status)
+	}
+
+	if err := s.export(job, req); err != nil {
+		s.fail(req.ID, err)
+		return err
+	}
+	return nil
+}
+
+// export runs the steps of an export job
+func (s *Service) export(job jobs.Job, req Request) error {
+	store := s.runner.Store()
+	key := exportPrefix + req.ID + ".zip"
+
+	started := time.Now()
+	if err := store.StartStep(job.ID, "dump_database", started.UTC()); err != nil {
+		return err
+	}
+	archive, err := s.backups.Export(req.Database, key)
+	failure := ""
+	if err != nil {
+		failure = err.Error()
+	}
+	if recordErr := store.FinishStep(job.ID, "dump_database", failure, time.Since(started)); recordErr != nil {
+		logrus.WithError(recordErr).WithField("job_id", job.ID).Warn("Failed to record export step")
+	}
+	if err != nil {
+		return err
+	}
+	if err := store.SetResult(job.ID, "size", fmt.Sprint(archive.Size)); err != nil {
+		return err
+	}
+
+	// The link is queued while the request still holds the email address
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+	if _, err := s.store.Update(req.ID, func(r *Request) { r.TokenHash = hashToken(token) }); err != nil {
+		return err
+	}
+	err = s.email(req, "Your data export is ready", fmt.Sprintf("Hello,\n\n"+
+		"The export of your Odoo instance %s is ready. It holds the database and its files.\n\n"+
+		"Download it within %s at:\n\n"+
+		"    %s\n\n"+
+		"The %s team\n",
+		req.Database, hours(s.exportTTL), s.link("export", token), s.company))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	expiresAt := now.Add(s.exportTTL)
+	if _, err := s.store.Update(req.ID, func(r *Request) {
+		r.Status = StatusCompleted
+		r.ExportKey = key
+		r.ExportExpiresAt = &expiresAt
+		r.CompletedAt = &now
+	}); err != nil {
+		return err
+	}
+
+	logrus.WithFields(logrus.Fields{"request_id": req.ID, "database": req.Database, "size": archive.Size}).Info("Tenant exported")
+	return nil
+}
+
+// Start processes the requests now and then every interval
+func (s *Service) Start(interval time.Duration) {
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			s.Run(time.Now())
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops processing and waits for a running pass to finish
+func (s *Service) Stop() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+}
+
+// Run expires unconfirmed requests, deletes expired exports and erases the
+// tenants whose cooling-off period ended
+func (s *Service) Run(now time.Time) RunResult {
+	now = now.UTC()
+	result := RunResult{
+		CheckedAt: now,
+		Expired:   []string{},
+		Removed:   []string{},
+		Erased:    []Proof{},
+		Failures:  make(map[string]string),
+	}
+
+	requests, err := s.store.List()
+	if err != nil {
+		logrus.WithError(err).Error("Failed to load account requests")
+		result.Failures[""] = err.Error()
+		return result
+	}
+
+	for _, req := range requests {
+		var err error
+		switch {
+		case req.Status == StatusPending && !req.ExpiresAt.After(now):
+			if _, err = s.store.Update(req.ID, func(r *Request) { r.Status = StatusExpired }); err == nil {
+				result.Expired = append(result.Expired, req.ID)
+			}
+		case req.ExportKey != "" && req.ExportExpiresAt != nil && !req.ExportExpiresAt.After(now):
+			if err = s.removeExport(req); err == nil {
+				result.Removed = append(result.Removed, req.ID)
+			}
+		case req.Status == StatusScheduled && req.DeleteAt != nil && !req.DeleteAt.After(now):
+			var proof Proof
+			if proof, err = s.erase(req, requests); err == nil {
+				result.Erased = append(result.Erased, proof)
+			}
+		}
+
+		if err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{"request_id": req.ID, "database": req.Database}).Error("Account request processing failed")
+			result.Failures[req.ID] = err.Error()
+		}
+	}
+
+	if len(result.Expired)+len(result.Removed)+len(result.Erased)+len(result.Failures) > 0 {
+		logrus.WithFields(logrus.Fields{
+			"expired":  len(result.Expired),
+			"removed":  len(result.Removed),
+			"erased":   len(result.Erased),
+			"failures": len(result.Failures),
+		}).Info("Account requests processed")
+	}
+	return result
+}
+
+// removeExport deletes an export once it can no longer be downloaded
+func (s *Service) removeExport(req Request) error {
+	if err := s.backups.Storage().Delete(req.ExportKey); err != nil {
+		return err
+	}
+	_, err := s.store.Update(req.ID, func(r *Request) {
+		r.ExportKey = ""
+	})
+	return err
+}
+
+// erase drops a tenant and everything kept about it, then records a proof of
+// erasure. Each part tolerates having run before, so a failed erasure is
+// simply tried again on the next pass.
+func (s *Service) erase(req Request, requests []Request) (Proof, error) {
+	active, err := s.runner.Store().Unfinished(req.Database)
+	if err != nil {
+		return Proof{}, err
+	}
+	if len(active) > 0 {
+		return Proof{}, fmt.Errorf("job %s of the tenant is still %s", active[0].ID, active[0].Status)
+	}
+
+	// The former names are kept with the request, so a retried erasure still
+	// finds backups left under them once the registry record is gone
+	tenant, registered := s.registry.Get(req.Database)
+	if registered && len(tenant.FormerNames) > 0 {
+		if req, err = s.store.Update(req.ID, func(r *Request) {
+			r.FormerNames = tenant.FormerNames
+		}); err != nil {
+			return Proof{}, err
+		}
+	}
+
+	var purged Purged
+	if purged.Database, purged.Registry, err = s.manager.Erase(req.Database); err != nil {
+		return Proof{}, err
+	}
+
+	for _, name := range append([]string{req.Database}, req.FormerNames...) {
+		if other, ok := s.registry.Get(name); ok && name != req.Database {
+			// The name was taken again; its backups are another tenant's
+			logrus.WithFields(logrus.Fields{"request_id": req.ID, "name": name, "tenant": other.Database}).Warn("Former name belongs to another tenant, its backups are kept")
+			continue
+		}
+		deleted, err := s.backups.Purge(name)
+		purged.Backups += deleted
+		if err != nil {
+			return Proof{}, err
+		}
+		if deleted, err = s.purgeBackupDir(name); err != nil {
+			return Proof{}, err
+		}
+		purged.Backups += deleted
+	}
+
+	for _, other := range requests {
+		if other.Database == req.Database && other.ExportKey != "" {
+			if err := s.removeExport(other); err != nil {
+				return Proof{}, err
+			}
+			purged.Exports++
+		}
+	}
+
+	if purged.Jobs, err = s.runner.Store().DeleteTenant(req.Database); err != nil {
+		return Proof{}, err
+	}
+	if purged.OutboxMessages, err = s.outbox.Purge(req.Database); err != nil {
+		return Proof{}, err
+	}
+
+	now := time.Now().UTC()
+	proof, err := s.erasures.Record(Proof{
+		RequestID:   req.ID,
+		Database:    req.Database,
+		EmailHash:   req.EmailHash,
+		RequestedAt: req.CreatedAt,
+		ConfirmedAt: *req.ConfirmedAt,
+		ErasedAt:    now,
+		Purged:      purged,
+	})
+	if err != nil {
+		return Proof{}, err
+	}
+
+	if _, err := s.store.Update(req.ID, func(r *Request) {
+		r.Status = StatusCompleted
+		r.CompletedAt = &now
+	}); err != nil {
+		return proof, err
+	}
+
+	if s.bus != nil {
+		s.bus.Publish(events.TenantErased{Tenant: events.Tenant{Database: req.Database, InstanceURL: tenant.InstanceURL}, At: now})
+	}
+	logrus.WithFields(logrus.Fields{"request_id": req.ID, "database": req.Database, "proof": proof.Hash}).Info("Tenant erased")
+	return proof, nil
+}
+
+// purgeBackupDir deletes the directory of a database in BACKUP_DIR, where
+// earlier versions wrote manual backups even with another backup storage,
+// and returns how many files it held
+func (s *Service) purgeBackupDir(database string) (int, error) {
+	if s.backupDir == "" || database == "" || filepath.Base(database) != database || strings.HasPrefix(database, ".") {
+		return 0, nil
+	}
+
+	dir := filepath.Join(s.backupDir, database)
+	files := 0
+	err := filepath.WalkDir(dir, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			files++
+		}
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return 0, fmt.Errorf("failed to delete %s: %w", dir, err)
+	}
+	return files, nil
+}
+
+// pending returns the unexpired, unconfirmed request of a token
+func (s *Service) pending(token string) (Request, error) {
+	req, err := s.store.Find(token)
+	if err != nil {
+		return Request{}, err
+	}
+	if req.Status != StatusPending {
+		return Request{}, ErrNotPending
+	}
+	if !req.ExpiresAt.After(time.Now()) {
+		if _, err := s.store.Update(req.ID, func(r *Request) { r.Status = StatusExpired }); err != nil {
+			return Request{}, err
+		}
+		return Request{}, ErrExpired
+	}
+	return req, nil
+}
+
+// fail records why a request could not be completed
+func (s *Service) fail(id string, cause error) {
+	now := time.Now().UTC()
+	if _, err := s.store.Update(id, func(r *Request) {
+		r.Status = StatusFailed
+		r.Error = cause.Error()
+		r.CompletedAt = &now
+	}); err != nil {
+		logrus.WithError(err).WithField("request_id", id).Error("Failed to record the request failure")
+	}
+}
+
+// email queues a message to the owner of a request
+func (s *Service) email(req Request, subject, body string) error {
+	return s.outbox.Enqueue(mailer.SendKind, req.Database, mailer.Message{
+		To:      req.Email,
+		Subject: fmt.Sprintf("%s: %s", s.company, subject),
+		Body:    body,
+	})
+}
+
+// link builds an emailed link to an account page
+func (s *Service) link(page, token string) string {
+	return fmt.Sprintf("%s/account/%s?token=%s", s.publicURL, page, url.QueryEscape(token))
+}
+
+// hours formats a link lifetime
+func hours(d time.Duration) string {
+	if h := int(d.Hours()); h%24 == 0 && h >= 48 {
+		return fmt.Sprintf("%d days", h/24)
+	}
+	return fmt.Sprintf("%d hours", int(d.Hours()))
+}
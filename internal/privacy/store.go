@@ -0,0 +1,255 @@
+// Package privacy lets tenant owners export or erase their data: requests
+// are confirmed through an emailed link, exports are full database dumps and
+// deletions drop everything kept about a tenant after a cooling-off period
+package privacy
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"odoo-signup/internal/store"
+	"odoo-signup/internal/util"
+)
+
+// Request kinds
+const (
+	KindExport = "export"
+	KindDelete = "delete"
+)
+
+// Request statuses
+const (
+	StatusPending   = "pending"   // Waiting for the owner to confirm the emailed link
+	StatusRunning   = "running"   // Export confirmed, the dump job is running
+	StatusScheduled = "scheduled" // Deletion confirmed, waiting for the cooling-off period
+	StatusCompleted = "completed"
+	StatusCancelled = "cancelled"
+	StatusExpired   = "expired" // Never confirmed
+	StatusFailed    = "failed"
+)
+
+// ErrNotFound is returned for unknown or expired links
+var ErrNotFound = errors.New("request not found")
+
+// Request is an owner's request to export or delete a tenant. Only a hash of
+// the emailed token is stored; the email address is dropped once the request
+// is finished.
+type Request struct {
+	ID              string     `json:"id"`
+	Kind            string     `json:"kind"`
+	Database        string     `json:"database"`
+	Email           string     `json:"email,omitempty"`
+	EmailHash       string     `json:"emailHash"`
+	TokenHash       string     `json:"tokenHash"`
+	Status          string     `json:"status"`
+	Error           string     `json:"error,omitempty"`
+	JobID           string     `json:"jobId,omitempty"`           // Export job
+	ExportKey       string     `json:"exportKey,omitempty"`       // Dump in the backup storage, until it expires
+	ExportExpiresAt *time.Time `json:"exportExpiresAt,omitempty"` // Download link expiry
+	FormerNames     []string   `json:"formerNames,omitempty"`     // Names the tenant had before renames, erased with it
+	CreatedAt       time.Time  `json:"createdAt"`
+	ExpiresAt       time.Time  `json:"expiresAt"` // Confirmation link expiry
+	ConfirmedAt     *time.Time `json:"confirmedAt,omitempty"`
+	DeleteAt        *time.Time `json:"deleteAt,omitempty"` // End of the cooling-off period
+	CompletedAt     *time.Time `json:"completedAt,omitempty"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+}
+
+// Finished reports whether nothing more happens to a request
+func (r Request) Finished() bool {
+	switch r.Status {
+	case StatusCompleted, StatusCancelled, StatusExpired, StatusFailed:
+		return true
+	}
+	return false
+}
+
+// Store keeps account requests in a JSON file. The CLI processes requests
+// from the same file, so it is reloaded whenever it changes.
+type Store struct {
+	mu       sync.Mutex
+	file     *store.File
+	requests map[string]*Request
+	modTime  time.Time
+}
+
+// NewStore loads the requests persisted at path
+func NewStore(path string) (*Store, error) {
+	s := &Store{
+		file:     store.NewFile(path),
+		requests: make(map[string]*Request),
+	}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Create stores a new pending request and returns it with the token to email
+func (s *Store) Create(kind, database, email string, ttl time.Duration) (Request, string, error) {
+	token, err := newToken()
+	if err != nil {
+		return Request{}, "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return Request{}, "", err
+	}
+
+	now := time.Now().UTC()
+	req := &Request{
+		ID:        util.NewID()[:16],
+		Kind:      kind,
+		Database:  database,
+		Email:     email,
+		EmailHash: HashEmail(email),
+		TokenHash: hashToken(token),
+		Status:    StatusPending,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+		UpdatedAt: now,
+	}
+	s.requests[req.ID] = req
+	return *req, token, s.save()
+}
+
+// Get returns a request by ID
+func (s *Store) Get(id string) (Request, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return Request{}, err
+	}
+
+	req, ok := s.requests[id]
+	if !ok {
+		return Request{}, ErrNotFound
+	}
+	return *req, nil
+}
+
+// Find returns the request an emailed token belongs to
+func (s *Store) Find(token string) (Request, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return Request{}, err
+	}
+
+	hash := hashToken(token)
+	for _, req := range s.requests {
+		if req.TokenHash == hash {
+			return *req, nil
+		}
+	}
+	return Request{}, ErrNotFound
+}
+
+// List returns all requests, newest first
+func (s *Store) List() ([]Request, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+
+	list := make([]Request, 0, len(s.requests))
+	for _, req := range s.requests {
+		list = append(list, *req)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list, nil
+}
+
+// Update applies fn to a stored request and persists the result. A finished
+// request no longer needs the owner's email address, so it is dropped.
+func (s *Store) Update(id string, fn func(req *Request)) (Request, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return Request{}, err
+	}
+
+	req, ok := s.requests[id]
+	if !ok {
+		return Request{}, ErrNotFound
+	}
+
+	fn(req)
+	if req.Finished() {
+		req.Email = ""
+	}
+	req.UpdatedAt = time.Now().UTC()
+	return *req, s.save()
+}
+
+// reload reads the file again when it changed since the last load
+func (s *Store) reload() error {
+	modTime, err := s.file.ModTime()
+	if err != nil {
+		return err
+	}
+	if !modTime.IsZero() && modTime.Equal(s.modTime) {
+		return nil
+	}
+
+	requests := make(map[string]*Request)
+	if err := s.file.Load(&requests); err != nil {
+		return err
+	}
+
+	s.requests = requests
+	s.modTime = modTime
+	return nil
+}
+
+// save writes the requests and remembers the new modification time
+func (s *Store) save() error {
+	if err := s.file.Save(s.requests); err != nil {
+		return err
+	}
+
+	modTime, err := s.file.ModTime()
+	if err != nil {
+		return err
+	}
+	s.modTime = modTime
+	return nil
+}
+
+// HashEmail identifies an email address in records that must outlive it,
+// such as proofs of erasure
+func HashEmail(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(sum[:])
+}
+
+// newToken returns 256 random bits for an emailed link
+func newToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashToken hashes a token for storage. Tokens are random, so a fast hash is sufficient.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,32 @@
+package profile
+
+import "strings"
+
+// industryNames maps signup form industries to the res.partner.industry
+// records shipped with Odoo's base module
+var industryNames = map[string]string{
+	"technology":    "IT/Communication",
+	"manufacturing": "Manufacturing",
+	"retail":        "Wholesale/Retail",
+	"healthcare":    "Health/Social",
+	"finance":       "Finance/Insurance",
+	"education":     "Education",
+	"construction":  "Construction",
+	"consulting":    "Scientific",
+	"other":         "Other Services",
+}
+
+// IndustryName returns the Odoo industry name for a signup form industry.
+// Unknown values are passed through so they can be created in Odoo.
+func IndustryName(industry string) string {
+	industry = strings.TrimSpace(industry)
+	if name, ok := industryNames[strings.ToLower(industry)]; ok {
+		return name
+	}
+	return industry
+}
+
+// SizeLabel returns the label used for a company size tag
+func SizeLabel(companySize string) string {
+	return "Size: " + strings.TrimSpace(companySize)
+}
@@ -0,0 +1,327 @@
+package provisioning
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"odoo-signup/internal/integration/odoo"
+	"odoo-signup/internal/profile"
+
+	"github.com/sirupsen/logrus"
+)
+
+// execute calls a model method in the tenant database with the run's credentials
+func (p *Provisioner) execute(r *run, model, method string, args ...interface{}) (interface{}, error) {
+	return r.client.ExecuteKw(r.tenant.Database, r.uid, r.password, model, method, args, r.rpcID)
+}
+
+// createUser adds the signup user as an administrator of a cloned database
+func (p *Provisioner) createUser(r *run) error {
+	req := r.req
+
+	userData := map[string]interface{}{
+		"name":       fmt.Sprintf("%s %s", req.FirstName, req.LastName),
+		"login":      req.Email,
+		"password":   req.Password,
+		"email":      req.Email,
+		"active":     true,
+		"company_id": 1,
+		"groups_id": []interface{}{
+			[]interface{}{6, 0, []interface{}{1, 2, 4}}, // Admin group
+		},
+	}
+
+	if req.Phone != "" {
+		userData["phone"] = req.Phone
+		userData["mobile"] = req.Phone
+	}
+
+	result, err := p.execute(r, "res.users", "create", userData)
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to create new user")
+		return &StepError{Step: StepCreateUser, Message: "Database cloned but user creation failed", Err: err}
+	}
+
+	r.userID, _ = odoo.ToInt(result)
+	r.logger.WithField("user_id", r.userID).Info("New user created successfully")
+	return nil
+}
+
+// createServiceAccount adds the admin account used for tenant management to a
+// created database. Cloned databases inherit it from the template. Suspension
+// and the other management actions sign in with it, so a tenant without it
+// fails.
+func (p *Provisioner) createServiceAccount(r *run) error {
+	if strings.EqualFold(p.config.AdminUser, r.req.Email) {
+		return &StepError{Step: StepServiceAccount, Message: "The email address is reserved for the service account"}
+	}
+
+	serviceData := map[string]interface{}{
+		"name":       "Signup Service",
+		"login":      p.config.AdminUser,
+		"password":   p.config.AdminPassword,
+		"active":     true,
+		"company_id": 1,
+		"groups_id": []interface{}{
+			[]interface{}{6, 0, []interface{}{1, 2, 4}}, // Admin group
+		},
+	}
+
+	if _, err := p.execute(r, "res.users", "create", serviceData); err != nil {
+		r.logger.WithError(err).Error("Failed to create service account")
+		return &StepError{Step: StepServiceAccount, Message: "Database created but service account creation failed", Err: err}
+	}
+
+	r.logger.Info("Service account created")
+	return nil
+}
+
+// configureCompany writes the company name, contact details and country
+func (p *Provisioner) configureCompany(r *run, failureMessage string) error {
+	req := r.req
+
+	companyData := map[string]interface{}{
+		"name":  req.CompanyName,
+		"email": req.Email,
+	}
+
+	if req.Phone != "" {
+		companyData["phone"] = req.Phone
+	}
+
+	// Look up the country in the new database instead of trusting a client-supplied ID
+	if countryID, err := p.lookupCountryID(r, req.Country.Code); err != nil {
+		r.logger.WithError(err).Warn("Failed to find country ID, skipping country update")
+	} else {
+		companyData["country_id"] = countryID
+	}
+
+	if _, err := p.execute(r, "res.company", "write", []interface{}{1}, companyData); err != nil {
+		r.logger.WithError(err).Error("Failed to update company details")
+		return &StepError{Step: StepConfigureCompany, Message: failureMessage, Err: err}
+	}
+
+	r.logger.Info("Company details updated successfully")
+	return nil
+}
+
+// setUserPhone stores the phone number on the signup user's partner
+func (p *Provisioner) setUserPhone(r *run) {
+	if r.req.Phone == "" {
+		return
+	}
+
+	userPhone := map[string]interface{}{"phone": r.req.Phone, "mobile": r.req.Phone}
+	if _, err := p.execute(r, "res.users", "write", []interface{}{r.userID}, userPhone); err != nil {
+		r.logger.WithError(err).Warn("Failed to update user phone, skipping")
+	}
+}
+
+// lookupCountryID finds the res.country record for an ISO code in the tenant database
+func (p *Provisioner) lookupCountryID(r *run, code string) (int, error) {
+	domain := []interface{}{[]interface{}{"code", "=", strings.ToUpper(code)}}
+	id, err := r.client.SearchID(r.tenant.Database, r.uid, r.password, "res.country", domain, r.rpcID)
+	if err != nil {
+		return 0, err
+	}
+	if id == 0 {
+		return 0, fmt.Errorf("country %s not found", code)
+	}
+	return id, nil
+}
+
+// applyLocaleSettings sets the user timezone, company currency and fiscal
+// localization of a freshly provisioned database
+func (p *Provisioner) applyLocaleSettings(r *run) {
+	settings := r.locale
+	logger := r.logger.WithFields(logrus.Fields{
+		"timezone":     settings.Timezone,
+		"currency":     settings.Currency,
+		"localization": settings.Localization,
+	})
+
+	if settings.Timezone != "" {
+		if _, err := p.execute(r, "res.users", "write", []interface{}{r.userID}, map[string]interface{}{"tz": settings.Timezone}); err != nil {
+			logger.WithError(err).Warn("Failed to set user timezone, skipping")
+		}
+	}
+
+	if settings.Currency != "" {
+		if err := p.setCompanyCurrency(r, settings.Currency); err != nil {
+			logger.WithError(err).Warn("Failed to set company currency, skipping")
+		}
+	}
+
+	if settings.Localization != "" && p.config.InstallLocalization {
+		if err := p.installLocalization(r, settings.Localization); err != nil {
+			logger.WithError(err).Warn("Failed to install fiscal localization, skipping")
+		}
+	}
+
+	logger.Info("Locale settings applied")
+}
+
+// setCompanyCurrency activates the currency if needed and assigns it to the main company
+func (p *Provisioner) setCompanyCurrency(r *run, code string) error {
+	// Including "active" in the domain disables Odoo's implicit active_test filter
+	domain := []interface{}{
+		[]interface{}{"name", "=", code},
+		[]interface{}{"active", "in", []interface{}{true, false}},
+	}
+	result, err := p.execute(r, "res.currency", "search_read", domain, []interface{}{"id", "active"})
+	if err != nil {
+		return fmt.Errorf("failed to search currency: %w", err)
+	}
+
+	records, ok := result.([]interface{})
+	if !ok || len(records) == 0 {
+		return fmt.Errorf("currency %s not found", code)
+	}
+
+	record, _ := records[0].(map[string]interface{})
+	currencyID, ok := odoo.ToInt(record["id"])
+	if !ok {
+		return fmt.Errorf("invalid currency record for %s", code)
+	}
+
+	if active, _ := record["active"].(bool); !active {
+		if _, err := p.execute(r, "res.currency", "write", []interface{}{currencyID}, map[string]interface{}{"active": true}); err != nil {
+			return fmt.Errorf("failed to activate currency: %w", err)
+		}
+	}
+
+	if _, err := p.execute(r, "res.company", "write", []interface{}{1}, map[string]interface{}{"currency_id": currencyID}); err != nil {
+		return fmt.Errorf("failed to update company currency: %w", err)
+	}
+
+	return nil
+}
+
+// installLocalization installs the fiscal localization module when it is available
+func (p *Provisioner) installLocalization(r *run, module string) error {
+	domain := []interface{}{[]interface{}{"name", "=", module}}
+	result, err := p.execute(r, "ir.module.module", "search_read", domain, []interface{}{"id", "state"})
+	if err != nil {
+		return fmt.Errorf("failed to search module: %w", err)
+	}
+
+	records, ok := result.([]interface{})
+	if !ok || len(records) == 0 {
+		r.logger.WithField("module", module).Debug("No fiscal localization available for country")
+		return nil
+	}
+
+	record, _ := records[0].(map[string]interface{})
+	if state, _ := record["state"].(string); state == "installed" {
+		return nil
+	}
+
+	moduleID, ok := odoo.ToInt(record["id"])
+	if !ok {
+		return fmt.Errorf("invalid module record for %s", module)
+	}
+
+	if _, err := p.execute(r, "ir.module.module", "button_immediate_install", []interface{}{moduleID}); err != nil {
+		return fmt.Errorf("failed to install module: %w", err)
+	}
+
+	return nil
+}
+
+// applyPasswordPolicy aligns Odoo's auth_password_policy with the signup policy
+func (p *Provisioner) applyPasswordPolicy(r *run) {
+	minLength := strconv.Itoa(p.passwordPolicy.MinLength)
+
+	if _, err := p.execute(r, "ir.config_parameter", "set_param", "auth_password_policy.minlength", minLength); err != nil {
+		r.logger.WithError(err).Warn("Failed to set password policy, skipping")
+		return
+	}
+
+	r.logger.WithField("minlength", minLength).Info("Password policy applied")
+}
+
+// applyCompanyProfile stores the industry and company size on the company partner
+func (p *Provisioner) applyCompanyProfile(r *run) {
+	industry, companySize := r.req.Industry, r.req.CompanySize
+	if industry == "" && companySize == "" {
+		return
+	}
+
+	partnerID, err := p.companyPartnerID(r)
+	if err != nil {
+		r.logger.WithError(err).Warn("Failed to find company partner, skipping company profile")
+		return
+	}
+
+	partnerData := map[string]interface{}{}
+
+	if industry != "" {
+		name := profile.IndustryName(industry)
+		industryID, err := r.client.FindOrCreate(r.tenant.Database, r.uid, r.password, "res.partner.industry",
+			[]interface{}{[]interface{}{"name", "=ilike", name}},
+			map[string]interface{}{"name": name, "full_name": name}, r.rpcID)
+		if err != nil {
+			r.logger.WithError(err).Warn("Failed to resolve industry, skipping")
+		} else {
+			partnerData["industry_id"] = industryID
+		}
+	}
+
+	if companySize != "" {
+		if p.config.CompanySizeField != "" {
+			partnerData[p.config.CompanySizeField] = companySize
+		} else {
+			label := profile.SizeLabel(companySize)
+			tagID, err := r.client.FindOrCreate(r.tenant.Database, r.uid, r.password, "res.partner.category",
+				[]interface{}{[]interface{}{"name", "=", label}},
+				map[string]interface{}{"name": label}, r.rpcID)
+			if err != nil {
+				r.logger.WithError(err).Warn("Failed to resolve company size tag, skipping")
+			} else {
+				partnerData["category_id"] = []interface{}{[]interface{}{4, tagID}}
+			}
+		}
+	}
+
+	if len(partnerData) == 0 {
+		return
+	}
+
+	if _, err := p.execute(r, "res.partner", "write", []interface{}{partnerID}, partnerData); err != nil {
+		r.logger.WithError(err).Warn("Failed to update company profile, skipping")
+		return
+	}
+
+	r.logger.WithFields(logrus.Fields{
+		"industry":     industry,
+		"company_size": companySize,
+	}).Info("Company profile updated")
+}
+
+// companyPartnerID returns the partner record of the main company
+func (p *Provisioner) companyPartnerID(r *run) (int, error) {
+	result, err := p.execute(r, "res.company", "read", []interface{}{1}, []interface{}{"partner_id"})
+	if err != nil {
+		return 0, err
+	}
+
+	records, ok := result.([]interface{})
+	if !ok || len(records) == 0 {
+		return 0, fmt.Errorf("company not found")
+	}
+
+	record, _ := records[0].(map[string]interface{})
+	// Many2one fields are returned as [id, display_name]
+	partner, ok := record["partner_id"].([]interface{})
+	if !ok || len(partner) == 0 {
+		return 0, fmt.Errorf("company has no partner")
+	}
+
+	id, ok := odoo.ToInt(partner[0])
+	if !ok {
+		return 0, fmt.Errorf("invalid partner id: %v", partner[0])
+	}
+
+	return id, nil
+}
@@ -0,0 +1,145 @@
+package provisioning
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"odoo-signup/internal/dns"
+	"odoo-signup/internal/events"
+	"odoo-signup/internal/models"
+)
+
+// DNS record statuses reported in events and job results
+const (
+	DNSCreated    = "created"
+	DNSPropagated = "propagated"
+	DNSPending    = "pending"
+	DNSDeleted    = "deleted"
+)
+
+// dnsPollInterval is how often propagation is checked
+const dnsPollInterval = 5 * time.Second
+
+// dnsTimeout bounds one call to the DNS provider
+const dnsTimeout = 30 * time.Second
+
+// NewDNSProvider returns the DNS provider selected by the configuration, or
+// nil when tenant records are not managed
+func NewDNSProvider(config *models.Config) (dns.Provider, error) {
+	if config.DNSProvider == "" {
+		return nil, nil
+	}
+	if err := recordTemplate(config).Validate(); err != nil {
+		return nil, fmt.Errorf("invalid DNS record: %w", err)
+	}
+
+	switch config.DNSProvider {
+	case "rfc2136":
+		provider, err := dns.NewRFC2136(dns.RFC2136Config{
+			Server:        config.DNSUpdateServer,
+			Zone:          config.DNSZone,
+			TSIGKey:       config.DNSTSIGKey,
+			TSIGSecret:    config.DNSTSIGSecret,
+			TSIGAlgorithm: config.DNSTSIGAlgorithm,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+	case "http":
+		provider, err := dns.NewHTTP(dns.HTTPConfig{URL: config.DNSHTTPURL, Token: config.DNSHTTPToken})
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+	}
+	return nil, fmt.Errorf("unknown DNS provider %q, use rfc2136 or http", config.DNSProvider)
+}
+
+// recordTemplate returns the record written for every tenant host
+func recordTemplate(config *models.Config) dns.Template {
+	return dns.Template{Type: config.DNSRecordType, Value: config.DNSRecordValue, TTL: config.DNSRecordTTL}
+}
+
+// RecordFor returns the DNS record of a tenant database
+func (p *Provisioner) RecordFor(dbName string) dns.Record {
+	return recordTemplate(p.config).For(p.InstanceURL(dbName))
+}
+
+// createDNSRecord writes the record of the tenant host before the database is
+// created, so it has time to propagate while Odoo works
+func (p *Provisioner) createDNSRecord(r *run) error {
+	record := p.RecordFor(r.tenant.Database)
+
+	return p.step(r, StepCreateDNSRecord, func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), dnsTimeout)
+		defer cancel()
+		if err := p.records.Upsert(ctx, record); err != nil {
+			r.logger.WithError(err).Error("Failed to create DNS record")
+			return &StepError{Step: StepCreateDNSRecord, Message: "Failed to create DNS record", Err: err}
+		}
+		r.record = &record
+		p.publishRecord(r, DNSCreated)
+		return nil
+	})
+}
+
+// waitDNSPropagation polls the resolver until the record answers. A record
+// still unanswered when the wait ends does not fail the signup; it is
+// reported as pending.
+func (p *Provisioner) waitDNSPropagation(r *run) error {
+	timeout := time.Duration(p.config.DNSPropagationTimeout) * time.Second
+	if r.record == nil || timeout == 0 {
+		return nil
+	}
+
+	return p.step(r, StepWaitDNS, func() error {
+		deadline := time.Now().Add(timeout)
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), dnsPollInterval)
+			ok, err := dns.Propagated(ctx, p.resolver, *r.record)
+			cancel()
+			if ok {
+				r.logger.Info("DNS record propagated")
+				p.publishRecord(r, DNSPropagated)
+				return nil
+			}
+			if err != nil {
+				r.logger.WithError(err).Debug("DNS lookup failed, retrying...")
+			}
+
+			if time.Now().Add(dnsPollInterval).After(deadline) {
+				r.logger.WithField("record", r.record.Name).Warn("DNS record not propagated yet, continuing")
+				p.publishRecord(r, DNSPending)
+				return nil
+			}
+			time.Sleep(dnsPollInterval)
+		}
+	})
+}
+
+// deleteDNSRecord removes the record of a failed run. A failure is logged and
+// reported as a step, without hiding the error that stopped the run.
+func (p *Provisioner) deleteDNSRecord(r *run) {
+	if r.record == nil {
+		return
+	}
+
+	p.step(r, StepDeleteDNSRecord, func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), dnsTimeout)
+		defer cancel()
+		if err := p.records.Delete(ctx, *r.record); err != nil {
+			r.logger.WithError(err).Error("Failed to delete the DNS record of the failed signup")
+			return err
+		}
+		p.publishRecord(r, DNSDeleted)
+		return nil
+	})
+}
+
+// publishRecord reports the status of the record of the run
+func (p *Provisioner) publishRecord(r *run, status string) {
+	record := fmt.Sprintf("%s %s %s", r.record.Name, r.record.Type, r.record.Value)
+	p.bus.Publish(events.DNSRecordUpdated{Tenant: r.tenant, Record: record, Status: status})
+}
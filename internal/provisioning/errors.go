@@ -0,0 +1,34 @@
+package provisioning
+
+import "errors"
+
+// ErrUsernameTaken is returned when the tenant database already exists
+var ErrUsernameTaken = errors.New("username already taken")
+
+// ValidationError reports a signup request that cannot be provisioned
+type ValidationError struct {
+	Message string
+	Fields  map[string]string // Field errors keyed by form field name
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// StepError reports a provisioning step that failed
+type StepError struct {
+	Step    string
+	Message string // Message safe to show to the user
+	Err     error
+}
+
+func (e *StepError) Error() string {
+	if e.Err != nil {
+		return e.Step + ": " + e.Message + ": " + e.Err.Error()
+	}
+	return e.Step + ": " + e.Message
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,105 @@
+package provisioning
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"odoo-signup/internal/jobs"
+	"odoo-signup/internal/models"
+	"odoo-signup/internal/tenants"
+)
+
+// JobKind identifies provisioning jobs
+const JobKind = "provision"
+
+// ErrPasswordUnavailable is returned when a retried job no longer has the signup password
+var ErrPasswordUnavailable = errors.New("the signup password is no longer available; set JOB_SECRET to allow retries across restarts")
+
+// JobPayload is the stored input of a provisioning job. The password is
+// submitted separately as the job secret and never stored in clear text.
+type JobPayload struct {
+	Request models.SignupRequest `json:"request"`
+	Options Options              `json:"options"`
+}
+
+// NewJobPayload returns the payload of a provisioning job for a validated request
+func NewJobPayload(req *models.SignupRequest, opts Options) JobPayload {
+	stored := *req
+	stored.Password = ""
+	return JobPayload{Request: stored, Options: opts}
+}
+
+// RunJob is the job handler for provisioning jobs
+func (p *Provisioner) RunJob(job jobs.Job, secret string) error {
+	var payload JobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid provisioning job payload: %w", err)
+	}
+	if secret == "" {
+		return ErrPasswordUnavailable
+	}
+
+	req := payload.Request
+	req.Password = secret
+	opts := payload.Options
+	opts.JobID = job.ID
+
+	if job.Attempts > 1 || opts.Replaces != "" {
+		if err := p.discardFailedAttempt(req.Username, job.ID, opts.Replaces); err != nil {
+			return err
+		}
+	}
+
+	_, err := p.Provision(&req, opts)
+	return err
+}
+
+// Result returns the signup data of a successful provisioning job
+func (p *Provisioner) Result(req *models.SignupRequest) *models.SignupData {
+	return &models.SignupData{
+		InstanceURL: p.InstanceURL(req.Username),
+		Email:       req.Email,
+		Database:    req.Username,
+	}
+}
+
+// discardFailedAttempt drops the database left behind by a failed attempt on
+// the backend it was placed on, so the retry starts from scratch. Only
+// databases the registry records as failed by this job, or by the job it
+// replaces, are dropped; a name another job holds makes the run fail with
+// ErrUsernameTaken when it claims the name.
+func (p *Provisioner) discardFailedAttempt(dbName string, jobIDs ...string) error {
+	tenant, ok := p.registry.Get(dbName)
+	if !ok || !ownedBy(tenant, jobIDs...) {
+		return nil
+	}
+	if tenant.Status != tenants.StatusFailed {
+		return fmt.Errorf("tenant %s is %s, refusing to replace it", dbName, tenant.Status)
+	}
+	if tenant.Backend == "" {
+		// The attempt failed before it was placed, so it created no database
+		return nil
+	}
+
+	client, err := p.backends.Client(tenant.Backend)
+	if err != nil {
+		return &StepError{Step: "check_database", Message: "Failed to find the backend of the failed attempt", Err: err}
+	}
+
+	rpcID := int(time.Now().UnixNano() % 1000000)
+	names, err := client.ListDatabases(rpcID)
+	if err != nil {
+		return &StepError{Step: "check_database", Message: "Failed to list databases", Err: err}
+	}
+	for _, name := range names {
+		if name == dbName {
+			if err := client.DropDatabase(dbName, rpcID); err != nil {
+				return &StepError{Step: "check_database", Message: "Failed to drop the database of the failed attempt", Err: err}
+			}
+			return nil
+		}
+	}
+	return nil
+}
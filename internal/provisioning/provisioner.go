@@ -0,0 +1,451 @@
+package provisioning
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"odoo-signup/internal/backends"
+	"odoo-signup/internal/dns"
+	"odoo-signup/internal/events"
+	"odoo-signup/internal/integration/odoo"
+	"odoo-signup/internal/locale"
+	"odoo-signup/internal/models"
+	"odoo-signup/internal/password"
+	"odoo-signup/internal/phone"
+	"odoo-signup/internal/tenants"
+	"odoo-signup/internal/trials"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// Database modes
+const (
+	ModeCreate = "create"
+	ModeClone  = "clone"
+)
+
+// Provisioning steps reported in events
+const (
+	StepPlaceTenant      = "place_tenant"
+	StepCreateDatabase   = "create_database"
+	StepCloneDatabase    = "clone_database"
+	StepWaitReady        = "wait_ready"
+	StepCreateUser       = "create_user"
+	StepConfigureCompany = "configure_company"
+	StepConfigureUser    = "configure_user"
+	StepServiceAccount   = "create_service_account"
+	StepApplyLocale      = "apply_locale"
+	StepPasswordPolicy   = "apply_password_policy"
+	StepCompanyProfile   = "apply_company_profile"
+	StepCreateDNSRecord  = "create_dns_record"
+	StepWaitDNS          = "wait_dns_propagation"
+	StepDeleteDNSRecord  = "delete_dns_record"
+)
+
+// Provisioner creates and configures tenant databases
+type Provisioner struct {
+	config         *models.Config
+	backends       *backends.Pool
+	bus            *events.Bus
+	registry       tenants.Store
+	trials         trials.Policy
+	validate       *validator.Validate
+	passwordPolicy password.Policy
+	records        dns.Provider // Nil when tenant hosts need no DNS record
+	resolver       dns.Resolver
+}
+
+// Options select how a tenant is provisioned
+type Options struct {
+	DbMode   string `json:"dbMode"`             // "create" or "clone"
+	SourceIP string `json:"sourceIp"`           // Address the signup came from, recorded in the registry
+	Replaces string `json:"replaces,omitempty"` // Earlier job whose failed attempt this run may drop and replace
+	JobID    string `json:"-"`                  // Job recording the run, set by RunJob
+}
+
+// run holds the state of one provisioning run
+type run struct {
+	req      *models.SignupRequest
+	tenant   events.Tenant
+	client   *odoo.Client // Client of the backend the tenant is placed on
+	logger   *logrus.Entry
+	rpcID    int
+	uid      int    // UID used for RPC calls
+	password string // Password used for RPC calls
+	userID   int    // res.users ID of the signup user
+	locale   locale.Settings
+	record   *dns.Record // DNS record created by the run, deleted if it fails
+}
+
+// New creates a provisioner placing tenants on the pool's backends and
+// publishing its progress on the bus. Each run records the tenant and its
+// status in the registry, which also tells a retry whether a leftover
+// database belongs to its failed attempt; the trial policy sets when
+// the trial of a new tenant ends. A DNS provider creates the record of each
+// tenant host; it is nil with wildcard DNS.
+func New(config *models.Config, pool *backends.Pool, bus *events.Bus, registry tenants.Store, trialPolicy trials.Policy, records dns.Provider) *Provisioner {
+	return &Provisioner{
+		config:   config,
+		backends: pool,
+		bus:      bus,
+		registry: registry,
+		trials:   trialPolicy,
+		validate: validator.New(),
+		records:  records,
+		resolver: dns.NewResolver(config.DNSResolver),
+		passwordPolicy: password.Policy{
+			MinLength: config.PasswordMinLength,
+			MinScore:  config.PasswordMinScore,
+		},
+	}
+}
+
+// PasswordPolicy returns the policy enforced on signup passwords
+func (p *Provisioner) PasswordPolicy() password.Policy {
+	return p.passwordPolicy
+}
+
+// NormalizeMode returns the database mode to use; anything but "create" clones the template
+func NormalizeMode(dbMode string) string {
+	if dbMode == ModeCreate {
+		return ModeCreate
+	}
+	return ModeClone
+}
+
+// InstanceURL returns the URL of a tenant
+func (p *Provisioner) InstanceURL(dbName string) string {
+	return fmt.Sprintf("%s.%s", dbName, p.config.Domain)
+}
+
+// Validate checks and normalizes a signup request in place
+func (p *Provisioner) Validate(req *models.SignupRequest) error {
+	if err := p.validate.Struct(req); err != nil {
+		return &ValidationError{Message: "Validation failed: " + err.Error()}
+	}
+
+	// Sanitize and validate username
+	req.Username = strings.ToLower(strings.TrimSpace(req.Username))
+	if tenants.ReservedNames[req.Username] {
+		return &ValidationError{Message: "Username not allowed"}
+	}
+
+	// The service account that manages every tenant has this login
+	if strings.EqualFold(strings.TrimSpace(req.Email), p.config.AdminUser) {
+		return &ValidationError{
+			Message: "Email address not allowed",
+			Fields:  map[string]string{"email": "This email address is reserved"},
+		}
+	}
+
+	// Enforce password strength policy
+	if strength := p.passwordPolicy.Evaluate(req.Password, req.Username, req.Email, req.CompanyName, req.FirstName, req.LastName); !strength.Acceptable {
+		message := "Password is too weak"
+		if len(strength.Warnings) > 0 {
+			message = strength.Warnings[0]
+		}
+		return &ValidationError{
+			Message: "Password does not meet the password policy",
+			Fields:  map[string]string{"password": message},
+		}
+	}
+
+	// Normalize phone number to E.164 for the selected country
+	if req.Phone != "" {
+		formatted, err := phone.Normalize(req.Phone, req.Country.Code)
+		if err != nil {
+			return &ValidationError{
+				Message: "Invalid phone number",
+				Fields:  map[string]string{"phone": "Please enter a valid phone number for the selected country"},
+			}
+		}
+		req.Phone = formatted
+	}
+
+	return nil
+}
+
+// ValidateStrength checks the bounds of a password strength request, which
+// keep scoring a password cheap
+func (p *Provisioner) ValidateStrength(req *models.PasswordStrengthRequest) error {
+	if err := p.validate.Struct(req); err != nil {
+		return &ValidationError{Message: "Validation failed: " + err.Error()}
+	}
+	return nil
+}
+
+// Provision creates the tenant database for a validated request and configures it
+func (p *Provisioner) Provision(req *models.SignupRequest, opts Options) (*models.SignupData, error) {
+	dbMode := NormalizeMode(opts.DbMode)
+	template := ""
+	if dbMode == ModeClone {
+		template = p.config.TemplateDatabase
+	}
+
+	// Generate database name (use just the username)
+	dbName := req.Username
+	instanceURL := p.InstanceURL(dbName)
+
+	logger := logrus.WithFields(logrus.Fields{
+		"username": req.Username,
+		"database": dbName,
+	})
+	logger.Info("Processing signup request")
+
+	// Check that no backend has the database yet
+	exists, err := p.backends.Exists(dbName)
+	if err != nil {
+		logger.WithError(err).Error("Failed to check database existence")
+		return nil, &StepError{Step: "check_database", Message: "Failed to validate database name", Err: err}
+	}
+
+	if exists {
+		return nil, ErrUsernameTaken
+	}
+
+	tenant := events.NewTenant(req, dbName, instanceURL, dbMode, template, opts.SourceIP)
+	tenant.JobID = opts.JobID
+
+	// The name is claimed before anything is created, so only one run proceeds
+	if err := p.claim(tenant, opts.Replaces); err != nil {
+		if !errors.Is(err, ErrUsernameTaken) {
+			logger.WithError(err).Error("Failed to register tenant")
+		}
+		return nil, err
+	}
+
+	backend, err := p.backends.Place(backends.Placement{Database: dbName, Plan: req.Plan, Country: req.Country.Code, Template: template})
+	var client *odoo.Client
+	if err == nil {
+		client, err = p.backends.Client(backend.Name)
+	}
+	if err == nil {
+		err = p.assign(tenant, backend.Name)
+	}
+	if err != nil {
+		logger.WithError(err).Error("Failed to place tenant")
+		p.unclaim(tenant, logger)
+		var stepErr *StepError
+		if errors.As(err, &stepErr) {
+			return nil, err
+		}
+		return nil, &StepError{Step: StepPlaceTenant, Message: "No server can take the tenant", Err: err}
+	}
+	logger = logger.WithField("backend", backend.Name)
+	tenant.Backend = backend.Name
+
+	r := &run{
+		req:    req,
+		tenant: tenant,
+		client: client,
+		logger: logger,
+		// Generate unique RPC ID for this signup request
+		rpcID: int(time.Now().UnixNano() % 1000000),
+		// Derive timezone and currency from the country unless given explicitly
+		locale: locale.Resolve(req.Country.Code, req.Timezone, req.Currency),
+	}
+
+	started := time.Now()
+	p.bus.Publish(events.SignupRequested{Tenant: r.tenant, At: started.UTC()})
+
+	if p.records != nil {
+		err = p.createDNSRecord(r)
+	}
+	if err == nil {
+		if dbMode == ModeCreate {
+			err = p.provisionNew(r)
+		} else {
+			err = p.provisionClone(r)
+		}
+	}
+	if err == nil {
+		err = p.waitDNSPropagation(r)
+	}
+	if err == nil {
+		// The trial starts once the tenant can be used
+		r.tenant.TrialEndsAt = p.trials.EndsAt(req.Plan, time.Now())
+		err = p.activate(r)
+	}
+
+	if err != nil {
+		p.deleteDNSRecord(r)
+
+		failure := events.ProvisioningFailed{Tenant: r.tenant, Error: err.Error(), Duration: time.Since(started)}
+		var stepErr *StepError
+		if errors.As(err, &stepErr) {
+			failure.Step = stepErr.Step
+			failure.Error = stepErr.Message
+		}
+		p.markFailed(r, failure.Error)
+		p.bus.Publish(failure)
+		return nil, err
+	}
+
+	p.bus.Publish(events.TenantProvisioned{Tenant: r.tenant, Duration: time.Since(started)})
+
+	return p.Result(req), nil
+}
+
+// provisionNew creates an empty database whose admin is the signup user
+func (p *Provisioner) provisionNew(r *run) error {
+	req := r.req
+	dbName := r.tenant.Database
+
+	err := p.step(r, StepCreateDatabase, func() error {
+		r.logger.Info("Creating new database")
+		if err := r.client.CreateNewDatabase(dbName, req.Password, req.Email, req.Country.Code, r.rpcID); err != nil {
+			r.logger.WithError(err).WithField("database", dbName).Error("Failed to create database")
+			return &StepError{Step: StepCreateDatabase, Message: "Failed to create database", Err: err}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Poll for database readiness with new user credentials
+	err = p.step(r, StepWaitReady, func() error {
+		uid, err := p.waitReady(r, req.Email, req.Password, "Database creation timeout")
+		r.uid, r.password, r.userID = uid, req.Password, uid
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = p.step(r, StepConfigureCompany, func() error {
+		return p.configureCompany(r, "Database created but company update failed")
+	})
+	if err != nil {
+		return err
+	}
+
+	err = p.step(r, StepConfigureUser, func() error {
+		p.setUserPhone(r)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = p.step(r, StepServiceAccount, func() error {
+		return p.createServiceAccount(r)
+	})
+	if err != nil {
+		return err
+	}
+
+	return p.configureTenant(r)
+}
+
+// provisionClone duplicates the template database and adds the signup user
+func (p *Provisioner) provisionClone(r *run) error {
+	dbName := r.tenant.Database
+
+	err := p.step(r, StepCloneDatabase, func() error {
+		r.logger.Info("Cloning database from template")
+		if err := r.client.CloneDatabase(p.config.TemplateDatabase, dbName, r.rpcID); err != nil {
+			r.logger.WithError(err).WithField("database", dbName).Error("Failed to clone database")
+			return &StepError{Step: StepCloneDatabase, Message: "Failed to clone database", Err: err}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Poll for database readiness with admin credentials
+	err = p.step(r, StepWaitReady, func() error {
+		uid, err := p.waitReady(r, p.config.AdminUser, p.config.AdminPassword, "Database cloning timeout")
+		r.uid, r.password = uid, p.config.AdminPassword
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = p.step(r, StepCreateUser, func() error {
+		return p.createUser(r)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = p.step(r, StepConfigureCompany, func() error {
+		return p.configureCompany(r, "Database cloned and user created but company update failed")
+	})
+	if err != nil {
+		return err
+	}
+
+	return p.configureTenant(r)
+}
+
+// configureTenant applies the optional settings shared by both modes
+func (p *Provisioner) configureTenant(r *run) error {
+	err := p.step(r, StepApplyLocale, func() error {
+		if r.userID > 0 {
+			p.applyLocaleSettings(r)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = p.step(r, StepPasswordPolicy, func() error {
+		p.applyPasswordPolicy(r)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return p.step(r, StepCompanyProfile, func() error {
+		p.applyCompanyProfile(r)
+		return nil
+	})
+}
+
+// step runs one provisioning step and publishes its progress
+func (p *Provisioner) step(r *run, name string, fn func() error) error {
+	started := time.Now()
+	p.bus.Publish(events.StepStarted{Tenant: r.tenant, Step: name, At: started.UTC()})
+
+	if err := fn(); err != nil {
+		p.bus.Publish(events.StepFailed{Tenant: r.tenant, Step: name, Error: err.Error(), Duration: time.Since(started)})
+		return err
+	}
+
+	p.bus.Publish(events.StepCompleted{Tenant: r.tenant, Step: name, Duration: time.Since(started)})
+	return nil
+}
+
+// waitReady polls the new database until the given credentials can log in
+func (p *Provisioner) waitReady(r *run, login, password, timeoutMessage string) (int, error) {
+	maxPollingTime := time.Duration(p.config.TimeoutSeconds) * time.Second
+	pollInterval := 3 * time.Second
+	startTime := time.Now()
+
+	for {
+		elapsed := time.Since(startTime)
+		if elapsed > maxPollingTime {
+			r.logger.WithField("elapsed_seconds", elapsed.Seconds()).Error("Database polling timeout exceeded")
+			return 0, &StepError{Step: StepWaitReady, Message: timeoutMessage}
+		}
+
+		r.logger.WithField("elapsed_seconds", elapsed.Seconds()).Debug("Checking if database is ready...")
+		uid, authErr := r.client.Login(r.tenant.Database, login, password, r.rpcID)
+		if authErr != nil {
+			r.logger.WithError(authErr).WithField("elapsed_seconds", elapsed.Seconds()).Debug("Database not ready yet, retrying...")
+		} else {
+			r.logger.WithField("elapsed_seconds", elapsed.Seconds()).Info("Database is now ready and accessible")
+			return uid, nil
+		}
+
+		time.Sleep(pollInterval)
+	}
+}
@@ -0,0 +1,126 @@
+package provisioning
+
+import (
+	"errors"
+
+	"odoo-signup/internal/events"
+	"odoo-signup/internal/tenants"
+
+	"github.com/sirupsen/logrus"
+)
+
+// StepRegisterTenant records the tenant and its status in the registry
+const StepRegisterTenant = "register_tenant"
+
+// errOtherJob stops a registry update of a record another job owns
+var errOtherJob = errors.New("the tenant is registered by another job")
+
+// claim registers a tenant whose provisioning starts, and fails with
+// ErrUsernameTaken when the name is registered. A run takes over the failed
+// record of its own job, or of the job it replaces; the database that attempt
+// left behind was dropped by discardFailedAttempt.
+func (p *Provisioner) claim(tenant events.Tenant, replaces string) error {
+	record := tenants.Tenant{
+		Database:    tenant.Database,
+		InstanceURL: tenant.InstanceURL,
+		OwnerEmail:  tenant.Email,
+		CompanyName: tenant.CompanyName,
+		CountryCode: tenant.CountryCode,
+		Plan:        tenant.Plan,
+		DbMode:      tenant.DbMode,
+		Template:    tenant.Template,
+		SourceIP:    tenant.SourceIP,
+		JobID:       tenant.JobID,
+		Status:      tenants.StatusProvisioning,
+	}
+
+	err := p.registry.Create(record)
+	if errors.Is(err, tenants.ErrExists) {
+		_, err = p.registry.Update(tenant.Database, func(existing *tenants.Tenant) error {
+			if existing.Status != tenants.StatusFailed || !ownedBy(*existing, tenant.JobID, replaces) {
+				return ErrUsernameTaken
+			}
+			record.CreatedAt = existing.CreatedAt
+			*existing = record
+			return nil
+		})
+	}
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrUsernameTaken), errors.Is(err, tenants.ErrNotRegistered):
+		// A record that vanished meanwhile was claimed and dropped by another run
+		return ErrUsernameTaken
+	default:
+		return &StepError{Step: StepRegisterTenant, Message: "Failed to record the tenant", Err: err}
+	}
+}
+
+// unclaim removes the record of a run that stopped before creating anything
+func (p *Provisioner) unclaim(tenant events.Tenant, logger *logrus.Entry) {
+	if current, ok := p.registry.Get(tenant.Database); !ok || current.JobID != tenant.JobID {
+		return
+	}
+	if err := p.registry.Delete(tenant.Database); err != nil {
+		logger.WithError(err).Error("Failed to release the tenant name")
+	}
+}
+
+// assign records the backend a claimed tenant is placed on
+func (p *Provisioner) assign(tenant events.Tenant, backend string) error {
+	_, err := p.update(tenant, func(record *tenants.Tenant) {
+		record.Backend = backend
+	})
+	if err != nil {
+		return &StepError{Step: StepRegisterTenant, Message: "Failed to record the tenant", Err: err}
+	}
+	return nil
+}
+
+// activate marks a provisioned tenant active and starts its trial
+func (p *Provisioner) activate(r *run) error {
+	_, err := p.update(r.tenant, func(record *tenants.Tenant) {
+		record.Status = tenants.StatusActive
+		record.Error = ""
+		record.TrialEndsAt = r.tenant.TrialEndsAt
+	})
+	if err != nil {
+		return &StepError{Step: StepRegisterTenant, Message: "Failed to record the tenant", Err: err}
+	}
+	return nil
+}
+
+// markFailed records why provisioning of a tenant failed
+func (p *Provisioner) markFailed(r *run, failure string) {
+	_, err := p.update(r.tenant, func(record *tenants.Tenant) {
+		record.Status = tenants.StatusFailed
+		record.Error = failure
+	})
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to record the failed provisioning in the registry")
+	}
+}
+
+// update changes the record of a tenant as long as this run owns it
+func (p *Provisioner) update(tenant events.Tenant, fn func(record *tenants.Tenant)) (tenants.Tenant, error) {
+	return p.registry.Update(tenant.Database, func(record *tenants.Tenant) error {
+		if record.JobID != tenant.JobID {
+			return errOtherJob
+		}
+		fn(record)
+		return nil
+	})
+}
+
+// ownedBy reports whether a record was created by one of the given jobs
+func ownedBy(record tenants.Tenant, jobIDs ...string) bool {
+	if record.JobID == "" {
+		return false
+	}
+	for _, id := range jobIDs {
+		if id != "" && record.JobID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package provisioning
+
+import (
+	"fmt"
+	"time"
+
+	"odoo-signup/internal/integration/odoo"
+)
+
+// Check is the outcome of one template health check
+type Check struct {
+	Backend  string `json:"backend"`
+	Template string `json:"template,omitempty"` // Empty for checks of the server itself
+	Name     string `json:"name"`
+	OK       bool   `json:"ok"`
+	Detail   string `json:"detail"`
+}
+
+// CheckTemplate verifies that every template database of every backend can
+// be cloned and configured: it exists, the admin service account signs in,
+// and no module operation is pending that a clone would inherit
+func (p *Provisioner) CheckTemplate() []Check {
+	rpcID := int(time.Now().UnixNano() % 1000000)
+	var checks []Check
+
+	for _, backend := range p.backends.List() {
+		client, err := p.backends.Client(backend.Name)
+		if err != nil {
+			checks = append(checks, Check{Backend: backend.Name, Name: "server", Detail: err.Error()})
+			continue
+		}
+
+		version, err := client.ServerVersion(rpcID)
+		if err != nil {
+			checks = append(checks, Check{Backend: backend.Name, Name: "server", Detail: err.Error()})
+			continue
+		}
+		checks = append(checks, Check{Backend: backend.Name, Name: "server", OK: true, Detail: "Odoo " + version})
+
+		names, err := client.ListDatabases(rpcID)
+		if err != nil {
+			checks = append(checks, Check{Backend: backend.Name, Name: "template_exists", Detail: err.Error()})
+			continue
+		}
+
+		templates := backend.Templates
+		if len(templates) == 0 {
+			templates = []string{p.config.TemplateDatabase}
+		}
+		for _, template := range templates {
+			checks = append(checks, p.checkTemplate(client, backend.Name, template, names, rpcID)...)
+		}
+	}
+
+	return checks
+}
+
+// checkTemplate runs the checks of one template database on a backend
+// listing the databases in names
+func (p *Provisioner) checkTemplate(client *odoo.Client, backend, template string, names []string, rpcID int) []Check {
+	var checks []Check
+	check := func(name string, ok bool, detail string) {
+		checks = append(checks, Check{Backend: backend, Template: template, Name: name, OK: ok, Detail: detail})
+	}
+
+	exists := false
+	for _, name := range names {
+		if name == template {
+			exists = true
+			break
+		}
+	}
+	if !exists {
+		check("template_exists", false, fmt.Sprintf("database %q not found", template))
+		return checks
+	}
+	check("template_exists", true, template)
+
+	uid, err := client.Login(template, p.config.AdminUser, p.config.AdminPassword, rpcID)
+	if err != nil {
+		check("admin_login", false, err.Error())
+		return checks
+	}
+	check("admin_login", true, fmt.Sprintf("%s (uid %d)", p.config.AdminUser, uid))
+
+	result, err := client.ExecuteKw(template, uid, p.config.AdminPassword, "ir.module.module", "search_count",
+		[]interface{}{[]interface{}{[]interface{}{"state", "in", []interface{}{"to install", "to upgrade", "to remove"}}}}, rpcID)
+	if err != nil {
+		check("pending_modules", false, err.Error())
+		return checks
+	}
+	if pending, _ := odoo.ToInt(result); pending > 0 {
+		check("pending_modules", false, fmt.Sprintf("%d modules have a pending install, upgrade or removal", pending))
+	} else {
+		check("pending_modules", true, "none")
+	}
+
+	return checks
+}
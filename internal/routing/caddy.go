@@ -0,0 +1,107 @@
+package routing
+
+import (
+	"encoding/json"
+	"net"
+	"net/url"
+)
+
+// tenantHosts groups the hosts of one tenant database
+type tenantHosts struct {
+	Database  string
+	Backend   string
+	Suspended bool
+	Hosts     []string
+}
+
+// byDatabase groups routes by database, in the order of their first host
+func byDatabase(routes []Route) []tenantHosts {
+	index := make(map[string]int)
+	var groups []tenantHosts
+	for _, route := range routes {
+		i, ok := index[route.Database]
+		if !ok {
+			i = len(groups)
+			index[route.Database] = i
+			groups = append(groups, tenantHosts{Database: route.Database, Backend: route.Backend, Suspended: route.Suspended()})
+		}
+		groups[i].Hosts = append(groups[i].Hosts, route.Host)
+	}
+	return groups
+}
+
+// dbfilter returns the dbfilter expression matching exactly one database
+func dbfilter(database string) string {
+	return "^" + database + "$"
+}
+
+// Caddy renders a complete Caddy JSON configuration, to load with
+// `caddy run --config <path> --watch` or the admin API. Caddy obtains the
+// certificates of every host, custom domains included. Each tenant is proxied
+// to the Odoo of its backend.
+func Caddy(routes []Route, upstreams Upstreams) ([]byte, error) {
+	maintenance := caddyProxy(upstreams.Maintenance)
+
+	caddyRoutes := []map[string]interface{}{}
+	for _, group := range byDatabase(routes) {
+		var handle []interface{}
+		if group.Suspended {
+			handle = []interface{}{
+				map[string]interface{}{"handler": "rewrite", "uri": MaintenancePath},
+				maintenance,
+			}
+		} else {
+			proxy := caddyProxy(upstreams.OdooFor(group.Backend))
+			proxy["headers"] = map[string]interface{}{
+				"request": map[string]interface{}{
+					"set": map[string][]string{DBFilterHeader: {dbfilter(group.Database)}},
+				},
+			}
+			handle = []interface{}{proxy}
+		}
+		caddyRoutes = append(caddyRoutes, map[string]interface{}{
+			"@id":      "tenant-" + group.Database,
+			"match":    []interface{}{map[string]interface{}{"host": group.Hosts}},
+			"handle":   handle,
+			"terminal": true,
+		})
+	}
+
+	config := map[string]interface{}{
+		"apps": map[string]interface{}{
+			"http": map[string]interface{}{
+				"servers": map[string]interface{}{
+					"tenants": map[string]interface{}{
+						"listen": []string{":443"},
+						"routes": caddyRoutes,
+					},
+				},
+			},
+		},
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// caddyProxy returns a reverse_proxy handler for an upstream URL
+func caddyProxy(upstream *url.URL) map[string]interface{} {
+	port := upstream.Port()
+	if port == "" {
+		port = "80"
+		if upstream.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	handler := map[string]interface{}{
+		"handler":   "reverse_proxy",
+		"upstreams": []interface{}{map[string]string{"dial": net.JoinHostPort(upstream.Hostname(), port)}},
+	}
+	if upstream.Scheme == "https" {
+		handler["transport"] = map[string]interface{}{"protocol": "http", "tls": map[string]interface{}{}}
+	}
+	return handler
+}
@@ -0,0 +1,69 @@
+package routing
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Nginx renders two map blocks for the http context: $tenant_database, the
+// database of each host, and $tenant_suspended, 1 for suspended hosts. The
+// server block uses them to set the dbfilter header or serve the maintenance
+// page. With several backends, a third map, $tenant_upstream, holds the Odoo
+// URL of each host for proxy_pass.
+func Nginx(routes []Route, upstreams Upstreams) ([]byte, error) {
+	var b strings.Builder
+	b.WriteString("# Tenant routing, generated by odoo-signup; do not edit\n")
+
+	b.WriteString("map $host $tenant_database {\n    default \"\";\n")
+	for _, route := range routes {
+		fmt.Fprintf(&b, "    %s %s;\n", route.Host, route.Database)
+	}
+	b.WriteString("}\n\n")
+
+	b.WriteString("map $host $tenant_suspended {\n    default 0;\n")
+	for _, route := range routes {
+		if route.Suspended() {
+			fmt.Fprintf(&b, "    %s 1;\n", route.Host)
+		}
+	}
+	b.WriteString("}\n")
+
+	if len(upstreams.Backends) > 0 {
+		fmt.Fprintf(&b, "\nmap $host $tenant_upstream {\n    default %s;\n", upstreams.Odoo)
+		for _, route := range routes {
+			if upstream, ok := upstreams.Backends[route.Backend]; ok {
+				fmt.Fprintf(&b, "    %s %s;\n", route.Host, upstream)
+			}
+		}
+		b.WriteString("}\n")
+	}
+	return []byte(b.String()), nil
+}
+
+// MaintenanceMap renders the hosts of suspended tenants as the body of an
+// nginx map block:
+//
+//	map $host $tenant_suspended { default 0; include <path>; }
+func MaintenanceMap(routes []Route, _ Upstreams) ([]byte, error) {
+	var b strings.Builder
+	b.WriteString("# Suspended tenants, generated by odoo-signup; do not edit\n")
+	for _, route := range routes {
+		if route.Suspended() {
+			fmt.Fprintf(&b, "%s 1;\n", route.Host)
+		}
+	}
+	return []byte(b.String()), nil
+}
+
+// DomainMap renders every host with the database it serves as the body of an
+// nginx map block:
+//
+//	map $host $tenant_database { default ""; include <path>; }
+func DomainMap(routes []Route, _ Upstreams) ([]byte, error) {
+	var b strings.Builder
+	b.WriteString("# Tenant hosts, generated by odoo-signup; do not edit\n")
+	for _, route := range routes {
+		fmt.Fprintf(&b, "%s %s;\n", route.Host, route.Database)
+	}
+	return []byte(b.String()), nil
+}
@@ -0,0 +1,263 @@
+// Package routing generates reverse proxy configuration from the tenant
+// registry, so new tenants and custom domains are reachable without editing
+// the proxy by hand and suspended tenants are routed to a maintenance page.
+package routing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"odoo-signup/internal/backends"
+	"odoo-signup/internal/models"
+	"odoo-signup/internal/store"
+	"odoo-signup/internal/tenants"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Proxy configuration formats
+const (
+	FormatNginx   = "nginx"
+	FormatCaddy   = "caddy"
+	FormatTraefik = "traefik"
+)
+
+// DBFilterHeader carries the database of a host to Odoo; a header-based
+// dbfilter module such as dbfilter_from_header reads it
+const DBFilterHeader = "X-Odoo-dbfilter"
+
+// MaintenancePath is the page of this service suspended tenants are routed to
+const MaintenancePath = "/maintenance"
+
+// reloadTimeout bounds the reload hook
+const reloadTimeout = 30 * time.Second
+
+// ErrReloadFailed is returned when the files were written but the reload hook failed
+var ErrReloadFailed = errors.New("proxy reload command failed")
+
+// Route maps a host to the database serving it
+type Route struct {
+	Host     string `json:"host"`
+	Database string `json:"database"`
+	Backend  string `json:"backend,omitempty"` // Odoo server hosting the database; the default backend when empty
+	Status   string `json:"status"`
+}
+
+// Suspended reports whether the host is routed to the maintenance page
+func (r Route) Suspended() bool {
+	return r.Status == tenants.StatusSuspended
+}
+
+// Table returns every host of active and suspended tenants, sorted by host
+func Table(registry tenants.Store) []Route {
+	routes := []Route{}
+	for _, tenant := range registry.List() {
+		if tenant.Status != tenants.StatusActive && tenant.Status != tenants.StatusSuspended {
+			continue
+		}
+		for _, host := range tenant.Hosts() {
+			routes = append(routes, Route{Host: host, Database: tenant.Database, Backend: tenant.Backend, Status: tenant.Status})
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Host < routes[j].Host })
+	return routes
+}
+
+// Upstreams are the backends the generated configuration proxies to
+type Upstreams struct {
+	Odoo         *url.URL            // Odoo serving the tenants of the default backend
+	Backends     map[string]*url.URL // Odoo of the other backends, by backend name
+	Maintenance  *url.URL            // This service, serving the maintenance page
+	CertResolver string              // Traefik certificate resolver of the routers; none when empty
+}
+
+// OdooFor returns the Odoo upstream of the tenants on a backend
+func (u Upstreams) OdooFor(backend string) *url.URL {
+	if upstream, ok := u.Backends[backend]; ok {
+		return upstream
+	}
+	return u.Odoo
+}
+
+// Renderer turns the routes into the content of a configuration file
+type Renderer func(routes []Route, upstreams Upstreams) ([]byte, error)
+
+// Renderers returns the renderer of each proxy format
+func Renderers() map[string]Renderer {
+	return map[string]Renderer{
+		FormatNginx:   Nginx,
+		FormatCaddy:   Caddy,
+		FormatTraefik: Traefik,
+	}
+}
+
+// output is a generated file
+type output struct {
+	path   string
+	render Renderer
+}
+
+// Writer writes the configured routing files and runs the reload hook after
+// one of them changed
+type Writer struct {
+	mu        sync.Mutex
+	registry  tenants.Store
+	backends  *backends.Pool
+	upstreams Upstreams
+	outputs   []output
+	reload    string
+	pending   bool // The last reload failed and runs again on the next write
+}
+
+// NewWriter returns a writer for the files set in the configuration, routing
+// each tenant to the Odoo upstream of its backend
+func NewWriter(config *models.Config, registry tenants.Store, pool *backends.Pool) (*Writer, error) {
+	w := &Writer{registry: registry, backends: pool, reload: config.ProxyReloadCommand}
+
+	if config.ProxyConfigFormat != "" {
+		render, ok := Renderers()[config.ProxyConfigFormat]
+		if !ok {
+			return nil, fmt.Errorf("unknown PROXY_CONFIG_FORMAT %q, use %s, %s or %s", config.ProxyConfigFormat, FormatNginx, FormatCaddy, FormatTraefik)
+		}
+		if config.ProxyConfigPath == "" {
+			return nil, errors.New("PROXY_CONFIG_PATH is required when PROXY_CONFIG_FORMAT is set")
+		}
+		w.outputs = append(w.outputs, output{path: config.ProxyConfigPath, render: render})
+	}
+	if config.MaintenanceMapPath != "" {
+		w.outputs = append(w.outputs, output{path: config.MaintenanceMapPath, render: MaintenanceMap})
+	}
+	if config.DomainMapPath != "" {
+		w.outputs = append(w.outputs, output{path: config.DomainMapPath, render: DomainMap})
+	}
+
+	var err error
+	if w.upstreams.Odoo, err = parseUpstream(config.ProxyOdooUpstream); err != nil {
+		return nil, fmt.Errorf("invalid PROXY_ODOO_UPSTREAM: %w", err)
+	}
+	if w.upstreams.Maintenance, err = parseUpstream(config.ProxyMaintenanceUpstream); err != nil {
+		return nil, fmt.Errorf("invalid PROXY_MAINTENANCE_UPSTREAM: %w", err)
+	}
+	w.upstreams.CertResolver = config.ProxyCertResolver
+	return w, nil
+}
+
+// Enabled reports whether any routing file is configured
+func (w *Writer) Enabled() bool {
+	return len(w.outputs) > 0
+}
+
+// Render returns the configuration of a format for the current registry
+func (w *Writer) Render(format string) ([]byte, error) {
+	render, ok := Renderers()[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	upstreams, err := w.current()
+	if err != nil {
+		return nil, err
+	}
+	return render(Table(w.registry), upstreams)
+}
+
+// Write renders every file and replaces those whose content changed, each
+// through a temporary file and rename. The reload hook runs once when a file
+// changed, or when it failed last time. It returns whether a file changed.
+func (w *Writer) Write() (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	upstreams, err := w.current()
+	if err != nil {
+		return false, err
+	}
+
+	routes := Table(w.registry)
+	changed := false
+	for _, out := range w.outputs {
+		data, err := out.render(routes, upstreams)
+		if err != nil {
+			return changed, fmt.Errorf("failed to render %s: %w", out.path, err)
+		}
+		if current, err := os.ReadFile(out.path); err == nil && bytes.Equal(current, data) {
+			continue
+		}
+		if err := store.NewFile(out.path).WriteRaw(data, 0o644); err != nil {
+			return changed, err
+		}
+		changed = true
+	}
+
+	if changed {
+		logrus.WithField("hosts", len(routes)).Info("Proxy routing updated")
+	} else if !w.pending {
+		return false, nil
+	}
+
+	err = w.runReload()
+	w.pending = err != nil
+	return changed, err
+}
+
+// current returns the upstreams with those of the backends as listed now.
+// The default backend is reached at PROXY_ODOO_UPSTREAM unless it sets its
+// own upstream; the others at their upstream or their URL.
+func (w *Writer) current() (Upstreams, error) {
+	upstreams := w.upstreams
+	upstreams.Backends = make(map[string]*url.URL)
+	for n, backend := range w.backends.List() {
+		raw := backend.Upstream
+		if raw == "" {
+			if n == 0 {
+				continue
+			}
+			raw = backend.URL
+		}
+		upstream, err := parseUpstream(raw)
+		if err != nil {
+			return upstreams, fmt.Errorf("invalid upstream of backend %s: %w", backend.Name, err)
+		}
+		if n == 0 {
+			upstreams.Odoo = upstream
+		} else {
+			upstreams.Backends[backend.Name] = upstream
+		}
+	}
+	return upstreams, nil
+}
+
+// runReload runs the reload hook through the shell
+func (w *Writer) runReload() error {
+	if w.reload == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), reloadTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "sh", "-c", w.reload).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %v: %s", ErrReloadFailed, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// parseUpstream checks an upstream URL such as http://odoo:8069
+func parseUpstream(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%q is not an http(s) URL", raw)
+	}
+	return u, nil
+}
@@ -0,0 +1,129 @@
+package routing
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Traefik service and middleware names shared by the routers. Tenants of
+// backends other than the default one use the service odoo-<backend>.
+const (
+	traefikOdoo        = "odoo"
+	traefikMaintenance = "maintenance"
+)
+
+// traefikConfig and the types below mirror the parts of Traefik's dynamic
+// configuration the routers use
+type traefikConfig struct {
+	HTTP traefikHTTP `yaml:"http"`
+}
+
+type traefikHTTP struct {
+	Routers     map[string]traefikRouter     `yaml:"routers"`
+	Middlewares map[string]traefikMiddleware `yaml:"middlewares"`
+	Services    map[string]traefikService    `yaml:"services"`
+}
+
+type traefikRouter struct {
+	Rule        string      `yaml:"rule"`
+	Service     string      `yaml:"service"`
+	Middlewares []string    `yaml:"middlewares,omitempty"`
+	TLS         *traefikTLS `yaml:"tls,omitempty"`
+}
+
+type traefikTLS struct {
+	CertResolver string `yaml:"certResolver,omitempty"`
+}
+
+type traefikMiddleware struct {
+	Headers     *traefikHeaders     `yaml:"headers,omitempty"`
+	ReplacePath *traefikReplacePath `yaml:"replacePath,omitempty"`
+}
+
+type traefikHeaders struct {
+	CustomRequestHeaders map[string]string `yaml:"customRequestHeaders"`
+}
+
+type traefikReplacePath struct {
+	Path string `yaml:"path"`
+}
+
+type traefikService struct {
+	LoadBalancer traefikLoadBalancer `yaml:"loadBalancer"`
+}
+
+type traefikLoadBalancer struct {
+	Servers        []traefikServer `yaml:"servers"`
+	PassHostHeader bool            `yaml:"passHostHeader"`
+}
+
+type traefikServer struct {
+	URL string `yaml:"url"`
+}
+
+// Traefik renders a dynamic configuration for the file provider, which
+// reloads it on change when watch is enabled. Routers get TLS from the
+// certificate resolver when one is set.
+func Traefik(routes []Route, upstreams Upstreams) ([]byte, error) {
+	config := traefikConfig{HTTP: traefikHTTP{
+		Routers: map[string]traefikRouter{},
+		Middlewares: map[string]traefikMiddleware{
+			traefikMaintenance: {ReplacePath: &traefikReplacePath{Path: MaintenancePath}},
+		},
+		Services: map[string]traefikService{
+			traefikOdoo:        traefikBackend(upstreams.Odoo.String()),
+			traefikMaintenance: traefikBackend(upstreams.Maintenance.String()),
+		},
+	}}
+
+	for _, group := range byDatabase(routes) {
+		rules := make([]string, 0, len(group.Hosts))
+		for _, host := range group.Hosts {
+			rules = append(rules, fmt.Sprintf("Host(`%s`)", host))
+		}
+
+		name := "tenant-" + group.Database
+		router := traefikRouter{Rule: strings.Join(rules, " || ")}
+		if group.Suspended {
+			router.Service = traefikMaintenance
+			router.Middlewares = []string{traefikMaintenance}
+		} else {
+			router.Service = traefikOdoo
+			if upstream, ok := upstreams.Backends[group.Backend]; ok {
+				router.Service = traefikOdoo + "-" + group.Backend
+				config.HTTP.Services[router.Service] = traefikBackend(upstream.String())
+			}
+			router.Middlewares = []string{name}
+			config.HTTP.Middlewares[name] = traefikMiddleware{Headers: &traefikHeaders{
+				CustomRequestHeaders: map[string]string{DBFilterHeader: dbfilter(group.Database)},
+			}}
+		}
+		if upstreams.CertResolver != "" {
+			router.TLS = &traefikTLS{CertResolver: upstreams.CertResolver}
+		}
+		config.HTTP.Routers[name] = router
+	}
+
+	var b bytes.Buffer
+	b.WriteString("# Tenant routing, generated by odoo-signup; do not edit\n")
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(config); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// traefikBackend returns a load balancer for one server URL
+func traefikBackend(url string) traefikService {
+	return traefikService{LoadBalancer: traefikLoadBalancer{
+		Servers:        []traefikServer{{URL: url}},
+		PassHostHeader: true,
+	}}
+}
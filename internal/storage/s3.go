@@ -0,0 +1,358 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// partSize is the size of the parts of multipart uploads. Objects smaller
+// than one part are sent in a single request; larger ones are streamed part
+// by part, so memory use stays bounded whatever the size of the backup.
+const partSize = 16 << 20
+
+// S3Config configures an S3-compatible object store
+type S3Config struct {
+	Endpoint  string // e.g. https://s3.eu-west-1.amazonaws.com or http://localhost:9000 for MinIO
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	Prefix    string // Prepended to every key, e.g. "backups/"
+}
+
+// S3 stores objects in a bucket of an S3-compatible service, addressed with
+// path-style URLs and signed with AWS Signature Version 4
+type S3 struct {
+	config S3Config
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewS3 creates an S3 storage
+func NewS3(config S3Config) (*S3, error) {
+	if config.Endpoint == "" || config.Bucket == "" || config.AccessKey == "" || config.SecretKey == "" {
+		return nil, errors.New("S3 storage needs an endpoint, bucket, access key and secret key")
+	}
+	if _, err := url.Parse(config.Endpoint); err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint: %w", err)
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	config.Endpoint = strings.TrimRight(config.Endpoint, "/")
+
+	return &S3{config: config, client: &http.Client{}, now: time.Now}, nil
+}
+
+// Put uploads an object, in parts when it is larger than one part
+func (s *S3) Put(key string, r io.Reader) (int64, error) {
+	first, err := readPart(r)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	if len(first) < partSize {
+		if _, err := s.do(http.MethodPut, s.objectPath(key), nil, first); err != nil {
+			return 0, fmt.Errorf("failed to upload %s: %w", key, err)
+		}
+		return int64(len(first)), nil
+	}
+
+	return s.putMultipart(key, first, r)
+}
+
+// putMultipart streams an object as a multipart upload, aborting it on failure
+func (s *S3) putMultipart(key string, first []byte, r io.Reader) (int64, error) {
+	path := s.objectPath(key)
+
+	body, err := s.do(http.MethodPost, path, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start upload of %s: %w", key, err)
+	}
+	var initiated struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.Unmarshal(body, &initiated); err != nil || initiated.UploadID == "" {
+		return 0, fmt.Errorf("invalid response starting upload of %s", key)
+	}
+	uploadID := initiated.UploadID
+
+	type completedPart struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	}
+	var parts []completedPart
+	var size int64
+
+	abort := func(err error) (int64, error) {
+		if _, abortErr := s.do(http.MethodDelete, path, url.Values{"uploadId": {uploadID}}, nil); abortErr != nil {
+			err = fmt.Errorf("%w (abort failed: %v)", err, abortErr)
+		}
+		return 0, err
+	}
+
+	part := first
+	for number := 1; len(part) > 0; number++ {
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+		resp, err := s.send(http.MethodPut, path, query, part)
+		if err != nil {
+			return abort(fmt.Errorf("failed to upload part %d of %s: %w", number, key, err))
+		}
+		resp.Body.Close()
+		parts = append(parts, completedPart{PartNumber: number, ETag: resp.Header.Get("ETag")})
+		size += int64(len(part))
+
+		if len(part) < partSize {
+			break
+		}
+		if part, err = readPart(r); err != nil {
+			return abort(fmt.Errorf("failed to read %s: %w", key, err))
+		}
+	}
+
+	complete, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return abort(err)
+	}
+	if _, err := s.do(http.MethodPost, path, url.Values{"uploadId": {uploadID}}, complete); err != nil {
+		return abort(fmt.Errorf("failed to complete upload of %s: %w", key, err))
+	}
+
+	return size, nil
+}
+
+// Get downloads an object
+func (s *S3) Get(key string) (io.ReadCloser, error) {
+	resp, err := s.send(http.MethodGet, s.objectPath(key), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Stat reads the size and modification time of an object
+func (s *S3) Stat(key string) (Object, error) {
+	resp, err := s.send(http.MethodHead, s.objectPath(key), nil, nil)
+	if err != nil {
+		return Object{}, err
+	}
+	resp.Body.Close()
+
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return Object{Key: key, Size: resp.ContentLength, ModTime: modTime.UTC()}, nil
+}
+
+// List pages through the objects below the prefix
+func (s *S3) List(prefix string) ([]Object, error) {
+	objects := []Object{}
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {s.config.Prefix + prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		body, err := s.do(http.MethodGet, "/"+s.config.Bucket, query, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", prefix, err)
+		}
+
+		var result struct {
+			Contents []struct {
+				Key          string    `xml:"Key"`
+				Size         int64     `xml:"Size"`
+				LastModified time.Time `xml:"LastModified"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		if err := xml.Unmarshal(body, &result); err != nil {
+			return nil, fmt.Errorf("invalid listing of %s: %w", prefix, err)
+		}
+
+		for _, c := range result.Contents {
+			objects = append(objects, Object{
+				Key:     strings.TrimPrefix(c.Key, s.config.Prefix),
+				Size:    c.Size,
+				ModTime: c.LastModified.UTC(),
+			})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// Delete removes an object
+func (s *S3) Delete(key string) error {
+	if _, err := s.do(http.MethodDelete, s.objectPath(key), nil, nil); err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	return nil
+}
+
+// Location returns the s3:// URL of an object
+func (s *S3) Location(key string) string {
+	return "s3://" + s.config.Bucket + "/" + s.config.Prefix + key
+}
+
+// objectPath returns the request path of a key
+func (s *S3) objectPath(key string) string {
+	return "/" + s.config.Bucket + "/" + s.config.Prefix + key
+}
+
+// do sends a request and returns the response body
+func (s *S3) do(method, path string, query url.Values, payload []byte) ([]byte, error) {
+	resp, err := s.send(method, path, query, payload)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// send signs and sends a request. Error responses are closed and returned as errors.
+func (s *S3) send(method, path string, query url.Values, payload []byte) (*http.Response, error) {
+	target := s.config.Endpoint + uriEncode(path, false)
+	if encoded := canonicalQuery(query); encoded != "" {
+		target += "?" + encoded
+	}
+
+	req, err := http.NewRequest(method, target, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(payload))
+	s.sign(req, path, query, payload)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+
+	var s3Err struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal(body, &s3Err) == nil && s3Err.Code != "" {
+		return nil, fmt.Errorf("S3 returned %d %s: %s", resp.StatusCode, s3Err.Code, s3Err.Message)
+	}
+	return nil, fmt.Errorf("S3 returned %d", resp.StatusCode)
+}
+
+// sign adds an AWS Signature Version 4 authorization header
+func (s *S3) sign(req *http.Request, path string, query url.Values, payload []byte) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+
+	payloadHash := sha256.Sum256(payload)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(path, false),
+		canonicalQuery(query),
+		"host:" + req.URL.Host + "\n" +
+			"x-amz-content-sha256:" + hex.EncodeToString(payloadHash[:]) + "\n" +
+			"x-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := day + "/" + s.config.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretKey), day)
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKey, scope, signedHeaders, signature))
+}
+
+// readPart reads up to one part from r
+func readPart(r io.Reader) ([]byte, error) {
+	buf := make([]byte, partSize)
+	n, err := io.ReadFull(r, buf)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		err = nil
+	}
+	return buf[:n], err
+}
+
+// canonicalQuery encodes query parameters sorted by name, as signing requires
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range query[k] {
+			pairs = append(pairs, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes everything but unreserved characters, and
+// slashes unless encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
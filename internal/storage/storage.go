@@ -0,0 +1,36 @@
+// Package storage stores backup archives on the local filesystem or in an
+// S3-compatible object store
+package storage
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNotFound is returned when an object does not exist
+var ErrNotFound = errors.New("object not found")
+
+// Object describes a stored object
+type Object struct {
+	Key     string    `json:"key"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// Storage keeps objects under slash separated keys
+type Storage interface {
+	// Put stores the content read from r under key and returns its size.
+	// Readers are consumed as a stream; an object is only visible once complete.
+	Put(key string, r io.Reader) (int64, error)
+	// Get opens an object for reading
+	Get(key string) (io.ReadCloser, error)
+	// Stat describes an object
+	Stat(key string) (Object, error)
+	// List returns the objects whose key starts with prefix, ordered by key
+	List(prefix string) ([]Object, error)
+	// Delete removes an object; deleting a missing object is not an error
+	Delete(key string) error
+	// Location describes where an object is stored, for logs and operators
+	Location(key string) string
+}
@@ -0,0 +1,167 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// File persists a value as JSON, replacing the file atomically on every save
+type File struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFile creates a JSON file store at the given path
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+// Path returns the location of the file
+func (f *File) Path() string {
+	return f.path
+}
+
+// Load decodes the file into v. A missing file leaves v untouched.
+func (f *File) Load(v interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", f.path, err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", f.path, err)
+	}
+
+	return nil
+}
+
+// Save encodes v and writes it through a temporary file and rename
+func (f *File) Save(v interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", f.path, err)
+	}
+
+	return f.write(data, 0o600)
+}
+
+// WriteRaw replaces the file with data through a temporary file and rename,
+// for files read by other programs such as proxy configuration
+func (f *File) WriteRaw(data []byte, perm os.FileMode) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.write(data, perm)
+}
+
+// write replaces the file atomically; the caller holds the lock. Each write
+// goes through its own temporary file, so writers in other processes never
+// share one.
+func (f *File) write(data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(f.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", f.path, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create a temporary file for %s: %w", f.path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmp.Name(), err)
+	}
+
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", f.path, err)
+	}
+
+	return nil
+}
+
+// Lock takes an exclusive lock on the file that other processes using it
+// honour too, and returns the function releasing it. Holding it from Load to
+// Save keeps processes sharing the file from overwriting each other's changes.
+func (f *File) Lock() (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for %s: %w", f.path, err)
+	}
+	lock, err := os.OpenFile(f.path+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock of %s: %w", f.path, err)
+	}
+	if err := lockFile(lock); err != nil {
+		lock.Close()
+		return nil, fmt.Errorf("failed to lock %s: %w", f.path, err)
+	}
+	return func() {
+		unlockFile(lock)
+		lock.Close()
+	}, nil
+}
+
+// Version identifies what was last saved to the file. Every save replaces the
+// file, so a new save is told apart even within the same modification time.
+type Version struct {
+	info os.FileInfo
+}
+
+// Version returns the version of the file; it is the zero Version while the
+// file does not exist
+func (f *File) Version() (Version, error) {
+	info, err := os.Stat(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Version{}, nil
+	}
+	if err != nil {
+		return Version{}, fmt.Errorf("failed to stat %s: %w", f.path, err)
+	}
+	return Version{info: info}, nil
+}
+
+// Exists reports whether the file existed
+func (v Version) Exists() bool {
+	return v.info != nil
+}
+
+// Same reports whether both are the same saved version of an existing file
+func (v Version) Same(other Version) bool {
+	if v.info == nil || other.info == nil {
+		return false
+	}
+	return os.SameFile(v.info, other.info) && v.info.ModTime().Equal(other.info.ModTime()) && v.info.Size() == other.info.Size()
+}
+
+// ModTime returns the modification time of the file, or the zero time when it does not exist
+func (f *File) ModTime() (time.Time, error) {
+	info, err := os.Stat(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to stat %s: %w", f.path, err)
+	}
+	return info.ModTime(), nil
+}
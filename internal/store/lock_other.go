@@ -0,0 +1,15 @@
+//go:build !unix
+
+package store
+
+import "os"
+
+// lockFile does nothing where flock is unavailable: only the in-process
+// locks of the stores apply, so the server and the CLI must not write the
+// same files at once there
+func lockFile(*os.File) error {
+	return nil
+}
+
+// unlockFile releases the lock taken by lockFile
+func unlockFile(*os.File) {}
@@ -0,0 +1,44 @@
+package subscribers
+
+import (
+	"fmt"
+
+	"odoo-signup/internal/events"
+	"odoo-signup/internal/integration/operator"
+	"odoo-signup/internal/outbox"
+)
+
+// CRMSync queues the provisioning outcome for the operator database
+func CRMSync(messageOutbox *outbox.Outbox) events.Handler {
+	return func(event events.Event) error {
+		var tenant events.Tenant
+		var status, failure string
+
+		switch e := event.(type) {
+		case events.TenantProvisioned:
+			tenant, status = e.Tenant, operator.StatusProvisioned
+		case events.ProvisioningFailed:
+			tenant, status, failure = e.Tenant, operator.StatusFailed, e.Error
+		default:
+			return nil
+		}
+
+		lead := operator.Lead{
+			ContactName: fmt.Sprintf("%s %s", tenant.FirstName, tenant.LastName),
+			Email:       tenant.Email,
+			Phone:       tenant.Phone,
+			CompanyName: tenant.CompanyName,
+			CountryCode: tenant.CountryCode,
+			Industry:    tenant.Industry,
+			CompanySize: tenant.CompanySize,
+			Plan:        tenant.Plan,
+			UTM:         tenant.UTM,
+			InstanceURL: tenant.InstanceURL,
+			Database:    tenant.Database,
+			Status:      status,
+			Error:       failure,
+		}
+
+		return messageOutbox.Enqueue(operator.SyncKind, tenant.Database, lead)
+	}
+}
@@ -0,0 +1,36 @@
+package subscribers
+
+import (
+	"context"
+	"time"
+
+	"odoo-signup/internal/dns"
+	"odoo-signup/internal/events"
+)
+
+// dnsTimeout bounds one call to the DNS provider
+const dnsTimeout = 30 * time.Second
+
+// DNSRecords keeps the DNS records of tenant hosts in line with the registry:
+// the record of a dropped tenant is deleted, and a renamed tenant gets the
+// record of its new host in place of the old one. Signups create records
+// while provisioning.
+func DNSRecords(provider dns.Provider, recordFor func(database string) dns.Record) events.Handler {
+	return func(event events.Event) error {
+		ctx, cancel := context.WithTimeout(context.Background(), dnsTimeout)
+		defer cancel()
+
+		switch e := event.(type) {
+		case events.TenantDeleted:
+			return provider.Delete(ctx, recordFor(e.Database))
+		case events.TenantErased:
+			return provider.Delete(ctx, recordFor(e.Database))
+		case events.TenantRenamed:
+			if err := provider.Upsert(ctx, recordFor(e.Database)); err != nil {
+				return err
+			}
+			return provider.Delete(ctx, recordFor(e.From))
+		}
+		return nil
+	}
+}
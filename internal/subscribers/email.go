@@ -0,0 +1,92 @@
+package subscribers
+
+import (
+	"fmt"
+
+	"odoo-signup/internal/events"
+	"odoo-signup/internal/mailer"
+	"odoo-signup/internal/outbox"
+)
+
+// dateLayout formats dates in customer emails
+const dateLayout = "January 2, 2006"
+
+// Email queues the welcome email when a tenant is ready, and the trial
+// reminders and expiry notices
+func Email(messageOutbox *outbox.Outbox, companyName string) events.Handler {
+	return func(event events.Event) error {
+		var msg mailer.Message
+		var database string
+
+		switch e := event.(type) {
+		case events.TenantProvisioned:
+			if e.Email == "" {
+				return nil
+			}
+			trial := ""
+			if e.TrialEndsAt != nil {
+				trial = fmt.Sprintf("Your trial runs until %s.\n\n", e.TrialEndsAt.Format(dateLayout))
+			}
+			database = e.Database
+			msg = mailer.Message{
+				To:      e.Email,
+				Subject: fmt.Sprintf("Your %s Odoo instance is ready", companyName),
+				Body: fmt.Sprintf("Hello %s,\n\n"+
+					"Your Odoo instance for %s is ready at:\n\n"+
+					"    https://%s\n\n"+
+					"Log in with %s and the password you chose during signup.\n\n"+
+					"%s"+
+					"The %s team\n",
+					e.FirstName, e.CompanyName, e.InstanceURL, e.Email, trial, companyName),
+			}
+
+		case events.TrialEnding:
+			if e.Email == "" {
+				return nil
+			}
+			database = e.Database
+			msg = mailer.Message{
+				To:      e.Email,
+				Subject: fmt.Sprintf("Your %s trial ends in %s", companyName, days(e.DaysLeft)),
+				Body: fmt.Sprintf("Hello,\n\n"+
+					"The trial of your Odoo instance for %s at https://%s ends on %s.\n\n"+
+					"Contact us before then to keep your instance and its data.\n\n"+
+					"The %s team\n",
+					e.CompanyName, e.InstanceURL, e.EndsAt.Format(dateLayout), companyName),
+			}
+
+		case events.TrialExpired:
+			if e.Email == "" {
+				return nil
+			}
+			deletion := "Your data is kept until you contact us.\n\n"
+			if e.DeleteAt != nil {
+				deletion = fmt.Sprintf("Your data is kept until %s and deleted after that date.\n\n", e.DeleteAt.Format(dateLayout))
+			}
+			database = e.Database
+			msg = mailer.Message{
+				To:      e.Email,
+				Subject: fmt.Sprintf("Your %s trial has ended", companyName),
+				Body: fmt.Sprintf("Hello,\n\n"+
+					"The trial of your Odoo instance for %s at https://%s ended, and the instance is suspended.\n\n"+
+					"%s"+
+					"Contact us to reactivate it.\n\n"+
+					"The %s team\n",
+					e.CompanyName, e.InstanceURL, deletion, companyName),
+			}
+
+		default:
+			return nil
+		}
+
+		return messageOutbox.Enqueue(mailer.SendKind, database, msg)
+	}
+}
+
+// days formats a number of days
+func days(n int) string {
+	if n == 1 {
+		return "1 day"
+	}
+	return fmt.Sprintf("%d days", n)
+}
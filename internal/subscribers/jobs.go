@@ -0,0 +1,40 @@
+package subscribers
+
+import (
+	"odoo-signup/internal/events"
+	"odoo-signup/internal/jobs"
+)
+
+// Jobs records the steps and timings of provisioning runs in their job, with
+// the backend the tenant was placed on, the DNS record of the tenant host and
+// its propagation status
+func Jobs(store *jobs.Store) events.Handler {
+	return func(event events.Event) error {
+		switch e := event.(type) {
+		case events.SignupRequested:
+			if e.JobID != "" && e.Backend != "" {
+				return store.SetResult(e.JobID, "backend", e.Backend)
+			}
+		case events.StepStarted:
+			if e.JobID != "" {
+				return store.StartStep(e.JobID, e.Step, e.At)
+			}
+		case events.StepCompleted:
+			if e.JobID != "" {
+				return store.FinishStep(e.JobID, e.Step, "", e.Duration)
+			}
+		case events.StepFailed:
+			if e.JobID != "" {
+				return store.FinishStep(e.JobID, e.Step, e.Error, e.Duration)
+			}
+		case events.DNSRecordUpdated:
+			if e.JobID != "" {
+				if err := store.SetResult(e.JobID, "dnsRecord", e.Record); err != nil {
+					return err
+				}
+				return store.SetResult(e.JobID, "dnsStatus", e.Status)
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,63 @@
+package subscribers
+
+import (
+	"odoo-signup/internal/events"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Logging logs provisioning progress and outcomes
+func Logging() events.Handler {
+	return func(event events.Event) error {
+		logger := logrus.WithFields(logrus.Fields{
+			"event":    event.Name(),
+			"database": event.TenantKey(),
+		})
+
+		switch e := event.(type) {
+		case events.SignupRequested:
+			logger.WithFields(logrus.Fields{"db_mode": e.DbMode, "backend": e.Backend}).Info("Signup requested")
+		case events.StepCompleted:
+			logger.WithFields(logrus.Fields{
+				"step":        e.Step,
+				"duration_ms": e.Duration.Milliseconds(),
+			}).Debug("Provisioning step completed")
+		case events.StepFailed:
+			logger.WithFields(logrus.Fields{
+				"step":        e.Step,
+				"duration_ms": e.Duration.Milliseconds(),
+			}).Warn("Provisioning step failed: " + e.Error)
+		case events.DNSRecordUpdated:
+			logger.WithFields(logrus.Fields{"record": e.Record, "status": e.Status}).Info("DNS record " + e.Status)
+		case events.TenantProvisioned:
+			logger.WithFields(logrus.Fields{
+				"db_mode":     e.DbMode,
+				"instanceURL": e.InstanceURL,
+				"duration_ms": e.Duration.Milliseconds(),
+			}).Info("Signup completed successfully")
+		case events.ProvisioningFailed:
+			logger.WithFields(logrus.Fields{
+				"step":        e.Step,
+				"duration_ms": e.Duration.Milliseconds(),
+			}).Error("Signup failed: " + e.Error)
+		case events.TenantDeleted:
+			logger.Info("Tenant deleted")
+		case events.TenantErased:
+			logger.Info("Tenant erased on the owner's request")
+		case events.TenantRenamed:
+			logger.WithFields(logrus.Fields{"from": e.From, "actor": e.Actor}).Info("Tenant renamed")
+		case events.TenantMigrated:
+			logger.WithFields(logrus.Fields{"from": e.From, "to": e.Backend, "actor": e.Actor}).Info("Tenant migrated")
+		case events.DomainVerified:
+			logger.WithFields(logrus.Fields{"domain": e.Domain, "actor": e.Actor}).Info("Custom domain routed")
+		case events.DomainRemoved:
+			logger.WithFields(logrus.Fields{"domain": e.Domain, "actor": e.Actor}).Info("Custom domain unrouted")
+		case events.TrialEnding:
+			logger.WithField("days_left", e.DaysLeft).Info("Trial ending")
+		case events.TrialExpired:
+			logger.WithField("delete_at", e.DeleteAt).Info("Trial expired")
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,35 @@
+package subscribers
+
+import (
+	"odoo-signup/internal/events"
+	"odoo-signup/internal/metrics"
+)
+
+// Metrics counts signups and records provisioning step durations
+func Metrics(registry *metrics.Registry) events.Handler {
+	registry.Describe("signup_requests_total", "Signups that started provisioning")
+	registry.Describe("tenants_provisioned_total", "Tenants provisioned successfully")
+	registry.Describe("tenant_provisioning_failures_total", "Provisioning runs that failed, by step")
+	registry.Describe("tenants_deleted_total", "Tenant databases deleted")
+	registry.Describe("provisioning_step_duration_seconds", "Duration of provisioning steps")
+	registry.Describe("provisioning_duration_seconds", "Duration of successful provisioning runs")
+
+	return func(event events.Event) error {
+		switch e := event.(type) {
+		case events.SignupRequested:
+			registry.Inc("signup_requests_total", metrics.Labels{"db_mode": e.DbMode})
+		case events.StepCompleted:
+			registry.Observe("provisioning_step_duration_seconds", metrics.Labels{"step": e.Step, "result": "success"}, e.Duration.Seconds())
+		case events.StepFailed:
+			registry.Observe("provisioning_step_duration_seconds", metrics.Labels{"step": e.Step, "result": "failure"}, e.Duration.Seconds())
+		case events.TenantProvisioned:
+			registry.Inc("tenants_provisioned_total", metrics.Labels{"db_mode": e.DbMode})
+			registry.Observe("provisioning_duration_seconds", metrics.Labels{"db_mode": e.DbMode}, e.Duration.Seconds())
+		case events.ProvisioningFailed:
+			registry.Inc("tenant_provisioning_failures_total", metrics.Labels{"step": e.Step})
+		case events.TenantDeleted, events.TenantErased:
+			registry.Inc("tenants_deleted_total", nil)
+		}
+		return nil
+	}
+}
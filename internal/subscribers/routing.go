@@ -0,0 +1,22 @@
+package subscribers
+
+import (
+	"odoo-signup/internal/events"
+	"odoo-signup/internal/routing"
+)
+
+// Routing rewrites the proxy routing files whenever a host is added to or
+// removed from a tenant, a tenant moves to or from the maintenance page, or
+// to another backend
+func Routing(writer *routing.Writer) events.Handler {
+	return func(event events.Event) error {
+		switch event.(type) {
+		case events.TenantProvisioned, events.TenantDeleted, events.TenantErased,
+			events.TenantSuspended, events.TenantResumed,
+			events.TenantRenamed, events.TenantMigrated, events.DomainVerified, events.DomainRemoved:
+			_, err := writer.Write()
+			return err
+		}
+		return nil
+	}
+}
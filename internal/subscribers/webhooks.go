@@ -0,0 +1,70 @@
+package subscribers
+
+import (
+	"time"
+
+	"odoo-signup/internal/events"
+	"odoo-signup/internal/models"
+	"odoo-signup/internal/webhooks"
+)
+
+// Webhooks forwards signup lifecycle events to the webhook endpoints
+func Webhooks(dispatcher *webhooks.Dispatcher) events.Handler {
+	return func(event events.Event) error {
+		var tenant events.Tenant
+		var eventType, failure, reason, previous, domain string
+		var deleteAt *time.Time
+
+		switch e := event.(type) {
+		case events.SignupRequested:
+			tenant, eventType = e.Tenant, webhooks.EventSignupRequested
+		case events.TenantProvisioned:
+			tenant, eventType = e.Tenant, webhooks.EventTenantProvisioned
+		case events.ProvisioningFailed:
+			tenant, eventType, failure = e.Tenant, webhooks.EventTenantProvisioningFailed, e.Error
+		case events.TenantDeleted:
+			tenant, eventType = e.Tenant, webhooks.EventTenantDeleted
+		case events.TenantErased:
+			tenant, eventType = e.Tenant, webhooks.EventTenantErased
+		case events.TenantRenamed:
+			tenant, eventType, previous = e.Tenant, webhooks.EventTenantRenamed, e.From
+		case events.DomainVerified:
+			tenant, eventType, domain = e.Tenant, webhooks.EventDomainVerified, e.Domain
+		case events.DomainRemoved:
+			tenant, eventType, domain = e.Tenant, webhooks.EventDomainRemoved, e.Domain
+		case events.TenantSuspended:
+			// The lockout of a migration is not a suspension of the account
+			if e.Migration {
+				return nil
+			}
+			tenant, eventType, reason = e.Tenant, webhooks.EventTenantSuspended, e.Reason
+		case events.TenantResumed:
+			if e.Migration {
+				return nil
+			}
+			tenant, eventType = e.Tenant, webhooks.EventTenantResumed
+		case events.TrialEnding:
+			tenant, eventType = e.Tenant, webhooks.EventTrialEnding
+		case events.TrialExpired:
+			tenant, eventType, deleteAt = e.Tenant, webhooks.EventTrialExpired, e.DeleteAt
+		default:
+			return nil
+		}
+
+		return dispatcher.Publish(eventType, tenant.Database, models.TenantEventData{
+			Database:         tenant.Database,
+			InstanceURL:      tenant.InstanceURL,
+			Email:            tenant.Email,
+			CompanyName:      tenant.CompanyName,
+			CountryCode:      tenant.CountryCode,
+			Plan:             tenant.Plan,
+			DbMode:           tenant.DbMode,
+			Error:            failure,
+			Reason:           reason,
+			TrialEndsAt:      tenant.TrialEndsAt,
+			DeleteAt:         deleteAt,
+			PreviousDatabase: previous,
+			Domain:           domain,
+		})
+	}
+}
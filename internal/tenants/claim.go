@@ -0,0 +1,93 @@
+package tenants
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"odoo-signup/internal/jobs"
+	"odoo-signup/internal/util"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Operations that claim a tenant
+const (
+	OperationMigration = "migration"
+	OperationRestore   = "restore"
+	OperationRename    = "rename"
+	OperationDrop      = "drop"
+)
+
+// claimTTL is how long a claim without a job holds the tenant, in case the
+// process that took it stopped before releasing it
+const claimTTL = 6 * time.Hour
+
+// ErrClaimed is returned while another operation has the tenant to itself
+var ErrClaimed = errors.New("another operation on the tenant is in progress")
+
+// Claim takes a registered tenant for an operation, in one registry update,
+// so of two operations started at the same time in the server and the CLI
+// only one proceeds; the other gets ErrClaimed. A claim of a job holds the
+// tenant while the job is queued or running, and a retry of the job takes it
+// over. Other claims hold it for claimTTL. Unclaim releases it. Claim returns
+// the claimed record.
+func (m *Manager) Claim(database, operation, jobID string) (Tenant, Claim, error) {
+	claim := Claim{
+		ID:        util.NewID()[:16],
+		Operation: operation,
+		JobID:     jobID,
+		At:        time.Now().UTC(),
+	}
+	tenant, err := m.registry.Update(database, func(tenant *Tenant) error {
+		if held := tenant.Claim; held != nil && !m.claimOver(*held, jobID) {
+			if held.JobID != "" {
+				return fmt.Errorf("%w: %s job %s since %s", ErrClaimed, held.Operation, held.JobID, held.At.Format(time.RFC3339))
+			}
+			return fmt.Errorf("%w: %s since %s", ErrClaimed, held.Operation, held.At.Format(time.RFC3339))
+		}
+		tenant.Claim = &claim
+		return nil
+	})
+	if err != nil {
+		return tenant, Claim{}, err
+	}
+	return tenant, claim, nil
+}
+
+// Unclaim releases a claim taken by Claim. A tenant renamed or dropped
+// meanwhile has nothing left to release.
+func (m *Manager) Unclaim(database string, claim Claim) {
+	_, err := m.registry.Update(database, func(tenant *Tenant) error {
+		if tenant.Claim != nil && tenant.Claim.ID == claim.ID {
+			tenant.Claim = nil
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, ErrNotRegistered) {
+		logrus.WithError(err).WithFields(logrus.Fields{"database": database, "operation": claim.Operation}).Error("Failed to release the tenant")
+	}
+}
+
+// claimOver reports whether a claim no longer holds the tenant for the job
+// jobID, or for an operation without a job when jobID is empty
+func (m *Manager) claimOver(claim Claim, jobID string) bool {
+	if claim.JobID == "" {
+		return time.Since(claim.At) > claimTTL
+	}
+	if claim.JobID == jobID {
+		return true
+	}
+	if m.jobs == nil {
+		return false
+	}
+	job, err := m.jobs.Get(claim.JobID)
+	if errors.Is(err, jobs.ErrNotFound) {
+		return true
+	}
+	if err != nil {
+		logrus.WithError(err).WithField("job_id", claim.JobID).Warn("Failed to read the job holding a tenant")
+		return false
+	}
+	return job.Status != jobs.StatusQueued && job.Status != jobs.StatusRunning
+}
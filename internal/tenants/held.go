@@ -0,0 +1,106 @@
+package tenants
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ReleaseHeld drops the copies migrations left on former backends once their
+// hold period ended as of now, and returns the copies released
+func (m *Manager) ReleaseHeld(now time.Time) ([]HeldCopy, error) {
+	var released []HeldCopy
+	var errs []error
+	for _, tenant := range m.registry.List() {
+		if len(tenant.Held) == 0 {
+			continue
+		}
+
+		dropped, _, err := m.dropHeld(tenant, func(held HeldCopy) bool { return !held.Until.After(now) })
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", tenant.Database, err))
+		}
+		if len(dropped) == 0 {
+			continue
+		}
+
+		// Copies added meanwhile by another migration are kept
+		_, err = m.registry.Update(tenant.Database, func(t *Tenant) error {
+			var held []HeldCopy
+			for _, source := range t.Held {
+				if !containsHeld(dropped, source) {
+					held = append(held, source)
+				}
+			}
+			t.Held = held
+			return nil
+		})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, held := range dropped {
+			logrus.WithFields(logrus.Fields{
+				"database": tenant.Database,
+				"backend":  held.Backend,
+				"copy":     held.Database,
+			}).Info("Migration source released")
+		}
+		released = append(released, dropped...)
+	}
+	return released, errors.Join(errs...)
+}
+
+// dropHeld drops the held copies of a tenant selected by due. It returns the
+// copies dropped and those kept; copies already gone count as dropped.
+func (m *Manager) dropHeld(tenant Tenant, due func(HeldCopy) bool) (dropped, kept []HeldCopy, err error) {
+	var drop []HeldCopy
+	for _, held := range tenant.Held {
+		if due(held) {
+			drop = append(drop, held)
+		} else {
+			kept = append(kept, held)
+		}
+	}
+	if len(drop) == 0 {
+		return nil, kept, nil
+	}
+
+	databases, err := m.backends.Databases()
+	if err != nil {
+		return nil, tenant.Held, err
+	}
+
+	var errs []error
+	for _, held := range drop {
+		exists := false
+		for _, name := range databases[held.Backend] {
+			exists = exists || name == held.Database
+		}
+		if exists {
+			client, clientErr := m.backends.Client(held.Backend)
+			if clientErr == nil {
+				clientErr = client.DropDatabase(held.Database, newRPCID())
+			}
+			if clientErr != nil {
+				errs = append(errs, fmt.Errorf("failed to drop the copy on %s: %w", held.Backend, clientErr))
+				kept = append(kept, held)
+				continue
+			}
+		}
+		dropped = append(dropped, held)
+	}
+	return dropped, kept, errors.Join(errs...)
+}
+
+// containsHeld reports whether list holds the source
+func containsHeld(list []HeldCopy, source HeldCopy) bool {
+	for _, held := range list {
+		if held.Backend == source.Backend && held.Database == source.Database && held.JobID == source.JobID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,808 @@
+package tenants
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"odoo-signup/internal/events"
+	"odoo-signup/internal/integration/odoo"
+	"odoo-signup/internal/jobs"
+	"odoo-signup/internal/models"
+	"odoo-signup/internal/password"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Errors returned by lifecycle operations
+var (
+	ErrProtected      = errors.New("the template database cannot be managed")
+	ErrSuspended      = errors.New("tenant is already suspended")
+	ErrNotSuspended   = errors.New("tenant is not suspended")
+	ErrOwnerUnknown   = errors.New("tenant owner is unknown")
+	ErrWeakPassword   = errors.New("password does not meet the password policy")
+	ErrOwnerNotFound  = errors.New("tenant owner has no user in the database")
+	ErrExists         = errors.New("database already exists")
+	ErrNotRegistered  = errors.New("tenant is not registered")
+	ErrReasonRequired = errors.New("a reason is required")
+	ErrInvalidName    = errors.New("tenant names are 3 to 20 lowercase letters and digits, and not reserved")
+	ErrJobActive      = errors.New("a job of the tenant is queued or running")
+	ErrBackupsExist   = errors.New("backups of another database are stored under that name")
+)
+
+// TrialsActor is recorded as the suspender of tenants whose trial expired
+const TrialsActor = "trials"
+
+// Details is a tenant with metadata read from its database
+type Details struct {
+	Tenant
+	OdooVersion       string `json:"odooVersion,omitempty"`
+	DatabaseCreatedAt string `json:"databaseCreatedAt,omitempty"`
+	FilestoreBytes    int64  `json:"filestoreBytes"` // Total size of ir.attachment records
+	ActiveUsers       int    `json:"activeUsers"`
+}
+
+// Backends gives the Odoo clients of the servers tenant databases live on
+type Backends interface {
+	// Client returns the client of a backend; the default backend for an empty name
+	Client(name string) (*odoo.Client, error)
+	// Databases lists the databases of each backend by backend name
+	Databases() (map[string][]string, error)
+	// DefaultName returns the backend of tenants registered without one
+	DefaultName() string
+	// Templates returns the template databases backends clone, which are never tenants
+	Templates() []string
+}
+
+// Backups keeps the stored backups of tenants, which follow a renamed tenant
+type Backups interface {
+	// HasBackups reports whether backups are stored under a database name
+	HasBackups(database string) (bool, error)
+	// MoveBackups copies the backups of a database to a new name, skipping
+	// those copied before, and with remove deletes the originals
+	MoveBackups(from, to string, remove bool) error
+}
+
+// Manager runs lifecycle operations on tenant databases. It signs in to each
+// database with the admin service account, which cloned databases inherit from
+// the template and created databases receive during provisioning.
+type Manager struct {
+	config   *models.Config
+	backends Backends
+	registry Store
+	backups  Backups
+	jobs     *jobs.Store
+	bus      *events.Bus
+}
+
+// session holds the credentials of one operation on a tenant database
+type session struct {
+	database string
+	client   *odoo.Client // Client of the backend hosting the database
+	uid      int
+	rpcID    int
+	logger   *logrus.Entry
+}
+
+// NewManager creates a tenant manager reaching each tenant on its backend.
+// backups and jobs may be nil for managers that never rename tenants.
+func NewManager(config *models.Config, backends Backends, registry Store, backups Backups, jobs *jobs.Store, bus *events.Bus) *Manager {
+	return &Manager{
+		config:   config,
+		backends: backends,
+		registry: registry,
+		backups:  backups,
+		jobs:     jobs,
+		bus:      bus,
+	}
+}
+
+// List returns registered tenants and unregistered databases found on the backends
+func (m *Manager) List() ([]Tenant, error) {
+	databases, err := m.backends.Databases()
+	if err != nil {
+		return nil, err
+	}
+
+	list := m.registry.List()
+	registered := make(map[string]bool, len(list))
+	for _, tenant := range list {
+		registered[tenant.Database] = true
+	}
+
+	ignored := make(map[string]bool)
+	for _, name := range m.ignoredDatabases() {
+		ignored[name] = true
+	}
+
+	for _, backend := range sortedKeys(databases) {
+		for _, name := range databases[backend] {
+			if !registered[name] && !ignored[name] {
+				list = append(list, Tenant{Database: name, Backend: backend, Status: StatusUnregistered})
+			}
+		}
+	}
+
+	return list, nil
+}
+
+// Reconcile compares the registry with the database list of each backend
+func (m *Manager) Reconcile() (*Report, error) {
+	databases, err := m.backends.Databases()
+	if err != nil {
+		return nil, err
+	}
+
+	// A provisioning run cannot outlast the polling timeout by much
+	staleAfter := 2 * time.Duration(m.config.TimeoutSeconds) * time.Second
+	return Reconcile(m.registry.List(), databases, m.backends.DefaultName(), m.ignoredDatabases(), staleAfter), nil
+}
+
+// Get returns a tenant with metadata read from its database
+func (m *Manager) Get(database string) (*Details, error) {
+	tenant, err := m.resolve(database)
+	if err != nil {
+		return nil, err
+	}
+
+	details := &Details{Tenant: tenant}
+
+	s, err := m.login(tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	if version, err := s.client.ServerVersion(s.rpcID); err == nil {
+		details.OdooVersion = version
+	}
+
+	if result, err := m.execute(s, "ir.config_parameter", "get_param", "database.create_date"); err == nil {
+		details.DatabaseCreatedAt, _ = result.(string)
+	}
+
+	// read_group with no groupby returns a single row holding the aggregate
+	if result, err := m.execute(s, "ir.attachment", "read_group", []interface{}{}, []interface{}{"file_size:sum"}, []interface{}{}); err == nil {
+		if rows, ok := result.([]interface{}); ok && len(rows) > 0 {
+			row, _ := rows[0].(map[string]interface{})
+			size, _ := row["file_size"].(float64)
+			details.FilestoreBytes = int64(size)
+		}
+	}
+
+	if result, err := m.execute(s, "res.users", "search_count", []interface{}{[]interface{}{"share", "=", false}}); err == nil {
+		count, _ := result.(float64)
+		details.ActiveUsers = int(count)
+	}
+
+	return details, nil
+}
+
+// Suspend archives every internal user except the service account so nobody
+// can sign in, recording who suspended the tenant and why. The archived users
+// are recorded before they are archived, so Resume reactivates exactly those.
+func (m *Manager) Suspend(database, actor, reason string) (Tenant, error) {
+	return m.suspend(database, actor, reason, false)
+}
+
+// SuspendForMigration locks out the users of a tenant while a migration
+// copies it. The events are marked as part of the migration, so they are not
+// forwarded to webhooks.
+func (m *Manager) SuspendForMigration(database, actor, reason string) (Tenant, error) {
+	return m.suspend(database, actor, reason, true)
+}
+
+func (m *Manager) suspend(database, actor, reason string, migration bool) (Tenant, error) {
+	tenant, err := m.resolve(database)
+	if err != nil {
+		return tenant, err
+	}
+	if tenant.Status == StatusSuspended {
+		return tenant, ErrSuspended
+	}
+	if tenant.Status == StatusUnregistered {
+		return tenant, ErrNotRegistered
+	}
+	if strings.TrimSpace(reason) == "" {
+		return tenant, ErrReasonRequired
+	}
+
+	s, err := m.login(tenant)
+	if err != nil {
+		return tenant, err
+	}
+
+	domain := []interface{}{
+		[]interface{}{"share", "=", false},
+		[]interface{}{"id", "!=", s.uid},
+	}
+	result, err := m.execute(s, "res.users", "search", domain)
+	if err != nil {
+		return tenant, fmt.Errorf("failed to search users: %w", err)
+	}
+	ids := toInts(result)
+
+	previous := tenant
+	now := time.Now().UTC()
+	tenant, err = m.registry.Update(database, func(t *Tenant) error {
+		if t.Status == StatusSuspended {
+			return ErrSuspended
+		}
+		t.Status = StatusSuspended
+		t.SuspendedUsers = ids
+		t.SuspendedBy = actor
+		t.SuspendReason = strings.TrimSpace(reason)
+		t.SuspendedAt = &now
+		return nil
+	})
+	if err != nil {
+		return previous, err
+	}
+
+	if len(ids) > 0 {
+		if _, err := m.execute(s, "res.users", "write", toArgs(ids), map[string]interface{}{"active": false}); err != nil {
+			if _, revertErr := m.registry.Update(database, func(t *Tenant) error {
+				t.Status = previous.Status
+				t.SuspendedUsers = previous.SuspendedUsers
+				t.SuspendedBy = previous.SuspendedBy
+				t.SuspendReason = previous.SuspendReason
+				t.SuspendedAt = previous.SuspendedAt
+				return nil
+			}); revertErr != nil {
+				s.logger.WithError(revertErr).Error("Failed to revert the registry after a failed suspension")
+			}
+			return previous, fmt.Errorf("failed to archive users: %w", err)
+		}
+	}
+
+	m.bus.Publish(events.TenantSuspended{Tenant: EventTenant(tenant), Actor: actor, Reason: tenant.SuspendReason, Migration: migration, At: now})
+	s.logger.WithFields(logrus.Fields{"users": len(ids), "actor": actor, "reason": tenant.SuspendReason}).Info("Tenant suspended")
+	return tenant, nil
+}
+
+// Resume reactivates the users archived by Suspend. Users the tenant archived
+// itself before the suspension stay archived.
+func (m *Manager) Resume(database, actor string) (Tenant, error) {
+	return m.resume(database, actor, false)
+}
+
+// ResumeAfterMigration reactivates the users locked out by SuspendForMigration
+func (m *Manager) ResumeAfterMigration(database, actor string) (Tenant, error) {
+	return m.resume(database, actor, true)
+}
+
+func (m *Manager) resume(database, actor string, migration bool) (Tenant, error) {
+	tenant, err := m.resolve(database)
+	if err != nil {
+		return tenant, err
+	}
+	if tenant.Status != StatusSuspended {
+		return tenant, ErrNotSuspended
+	}
+
+	s, err := m.login(tenant)
+	if err != nil {
+		return tenant, err
+	}
+
+	if len(tenant.SuspendedUsers) > 0 {
+		if _, err := m.execute(s, "res.users", "write", toArgs(tenant.SuspendedUsers), map[string]interface{}{"active": true}); err != nil {
+			return tenant, fmt.Errorf("failed to reactivate users: %w", err)
+		}
+	}
+
+	users := len(tenant.SuspendedUsers)
+	tenant, err = m.registry.Update(database, func(t *Tenant) error {
+		t.Status = StatusActive
+		t.SuspendedUsers = nil
+		t.SuspendedBy = ""
+		t.SuspendReason = ""
+		t.SuspendedAt = nil
+		return nil
+	})
+	if err != nil {
+		return tenant, err
+	}
+
+	m.bus.Publish(events.TenantResumed{Tenant: EventTenant(tenant), Actor: actor, Migration: migration, At: time.Now().UTC()})
+	s.logger.WithFields(logrus.Fields{"users": users, "actor": actor}).Info("Tenant resumed")
+	return tenant, nil
+}
+
+// Relock archives the users of a suspended tenant again after its database
+// was restored from a backup taken before the suspension. The users active in
+// the restored database join the ones Resume reactivates.
+func (m *Manager) Relock(database string) (Tenant, error) {
+	tenant, err := m.resolve(database)
+	if err != nil {
+		return tenant, err
+	}
+	if tenant.Status != StatusSuspended {
+		return tenant, ErrNotSuspended
+	}
+
+	s, err := m.login(tenant)
+	if err != nil {
+		return tenant, err
+	}
+
+	domain := []interface{}{
+		[]interface{}{"share", "=", false},
+		[]interface{}{"id", "!=", s.uid},
+	}
+	result, err := m.execute(s, "res.users", "search", domain)
+	if err != nil {
+		return tenant, fmt.Errorf("failed to search users: %w", err)
+	}
+	ids := toInts(result)
+	if len(ids) == 0 {
+		return tenant, nil
+	}
+
+	// The registry is updated first, so Resume knows the users even when
+	// archiving them fails halfway
+	tenant, err = m.registry.Update(database, func(t *Tenant) error {
+		known := make(map[int]bool, len(t.SuspendedUsers))
+		for _, id := range t.SuspendedUsers {
+			known[id] = true
+		}
+		for _, id := range ids {
+			if !known[id] {
+				t.SuspendedUsers = append(t.SuspendedUsers, id)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return tenant, err
+	}
+	if _, err := m.execute(s, "res.users", "write", toArgs(ids), map[string]interface{}{"active": false}); err != nil {
+		return tenant, fmt.Errorf("failed to archive users: %w", err)
+	}
+
+	s.logger.WithField("users", len(ids)).Info("Suspension applied to the restored database")
+	return tenant, nil
+}
+
+// SetTrial moves the end of a tenant's trial; nil ends the trial and keeps
+// the tenant permanently. A tenant suspended because its trial expired is
+// resumed when the new end lies ahead; other suspensions are kept.
+func (m *Manager) SetTrial(database, actor string, endsAt *time.Time) (Tenant, error) {
+	tenant, err := m.resolve(database)
+	if err != nil {
+		return tenant, err
+	}
+	if tenant.Status == StatusUnregistered {
+		return tenant, ErrNotRegistered
+	}
+
+	now := time.Now().UTC()
+	expired := tenant.Status == StatusSuspended && tenant.SuspendedBy == TrialsActor
+
+	if endsAt != nil {
+		end := endsAt.UTC()
+		endsAt = &end
+	}
+	tenant, err = m.registry.Update(database, func(t *Tenant) error {
+		t.TrialEndsAt = endsAt
+		t.TrialReminder = 0
+		return nil
+	})
+	if err != nil {
+		return tenant, err
+	}
+
+	logger := logrus.WithFields(logrus.Fields{"database": database, "trial_ends_at": endsAt, "actor": actor})
+	logger.Info("Tenant trial updated")
+
+	if expired && (endsAt == nil || endsAt.After(now)) {
+		return m.Resume(database, actor)
+	}
+	return tenant, nil
+}
+
+// Rename renames the tenant database and moves its registry record and
+// backups, so the tenant is served at the instance URL of the new name. It is
+// refused while a job of the tenant is queued or running. Jobs keep the old name.
+func (m *Manager) Rename(database, newName, actor string) (Tenant, error) {
+	newName = strings.ToLower(strings.TrimSpace(newName))
+	if !ValidName(newName) {
+		return Tenant{}, ErrInvalidName
+	}
+	if newName == m.config.TemplateDatabase {
+		return Tenant{}, ErrProtected
+	}
+
+	tenant, err := m.resolve(database)
+	if err != nil {
+		return tenant, err
+	}
+	if tenant.Status == StatusUnregistered {
+		return tenant, ErrNotRegistered
+	}
+	if tenant.Status != StatusActive && tenant.Status != StatusSuspended {
+		return tenant, fmt.Errorf("a %s tenant cannot be renamed", tenant.Status)
+	}
+
+	if m.backups == nil || m.jobs == nil {
+		return tenant, errors.New("renaming needs the backup storage and the job history")
+	}
+	for _, name := range []string{database, newName} {
+		active, err := m.jobs.Unfinished(name)
+		if err != nil {
+			return tenant, err
+		}
+		if err := ActiveJob(name, active); err != nil {
+			return tenant, err
+		}
+	}
+
+	if _, err := m.resolve(newName); err == nil {
+		return tenant, ErrExists
+	} else if !errors.Is(err, ErrNotFound) {
+		return tenant, err
+	}
+
+	// A migration, restore or drop started meanwhile holds the tenant or waits for it
+	tenant, claim, err := m.Claim(database, OperationRename, "")
+	if err != nil {
+		return tenant, err
+	}
+	defer m.Unclaim(database, claim)
+
+	// Backups of a dropped tenant of that name would mix with the renamed tenant's
+	if exists, err := m.backups.HasBackups(newName); err != nil {
+		return tenant, err
+	} else if exists {
+		return tenant, ErrBackupsExist
+	}
+
+	client, err := m.backends.Client(tenant.Backend)
+	if err != nil {
+		return tenant, err
+	}
+
+	logger := logrus.WithFields(logrus.Fields{"database": newName, "previous_database": database, "actor": actor})
+
+	// The backups are copied first, so a failed rename leaves them as they were
+	if err := m.backups.MoveBackups(database, newName, false); err != nil {
+		m.dropBackupCopies(newName, database, logger)
+		return tenant, err
+	}
+
+	renamed := tenant
+	renamed.Database = newName
+	renamed.InstanceURL = fmt.Sprintf("%s.%s", newName, m.config.Domain)
+	renamed.FormerNames = append(without(tenant.FormerNames, newName), database)
+	renamed.Claim = nil
+	if err := m.registry.Rename(database, renamed); err != nil {
+		m.dropBackupCopies(newName, database, logger)
+		return tenant, err
+	}
+	if err := client.RenameDatabase(database, newName, newRPCID()); err != nil {
+		if undoErr := m.registry.Rename(newName, tenant); undoErr != nil {
+			logger.WithError(undoErr).Error("Failed to restore the registry record after a failed rename")
+		}
+		m.dropBackupCopies(newName, database, logger)
+		return tenant, fmt.Errorf("failed to rename database: %w", err)
+	}
+
+	// Backups made under the old name meanwhile are moved too, and the originals deleted.
+	// Backups left behind stay covered by the former names in the registry.
+	if err := m.backups.MoveBackups(database, newName, true); err != nil {
+		logger.WithError(err).Error("Failed to move all backups to the new name")
+	}
+
+	if err := m.SetBaseURL(renamed); err != nil {
+		logger.WithError(err).Warn("Failed to update web.base.url after the rename")
+	}
+
+	m.bus.Publish(events.TenantRenamed{Tenant: EventTenant(renamed), From: database, Actor: actor, At: time.Now().UTC()})
+	logger.Info("Tenant renamed")
+	return renamed, nil
+}
+
+// dropBackupCopies deletes the copies a failed rename made of a tenant's backups
+func (m *Manager) dropBackupCopies(copied, original string, logger *logrus.Entry) {
+	if err := m.backups.MoveBackups(copied, original, true); err != nil {
+		logger.WithError(err).Error("Failed to delete the backup copies of a failed rename")
+	}
+}
+
+// without returns names without name
+func without(names []string, name string) []string {
+	kept := []string{}
+	for _, n := range names {
+		if n != name {
+			kept = append(kept, n)
+		}
+	}
+	return kept
+}
+
+// ActiveJob returns ErrJobActive naming the first of the queued or running
+// jobs of a tenant, or nil when there are none
+func ActiveJob(name string, active []jobs.Job) error {
+	if len(active) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s job %s of %s", ErrJobActive, active[0].Kind, active[0].ID, name)
+}
+
+// SetBaseURL writes the tenant's base URL to its web.base.url system
+// parameter and freezes it, so Odoo builds links and redirects with it
+func (m *Manager) SetBaseURL(tenant Tenant) error {
+	s, err := m.login(tenant)
+	if err != nil {
+		return err
+	}
+
+	baseURL := tenant.BaseURL()
+	if _, err := m.execute(s, "ir.config_parameter", "set_param", "web.base.url", baseURL); err != nil {
+		return fmt.Errorf("failed to set web.base.url: %w", err)
+	}
+	if _, err := m.execute(s, "ir.config_parameter", "set_param", "web.base.url.freeze", "True"); err != nil {
+		return fmt.Errorf("failed to freeze web.base.url: %w", err)
+	}
+
+	s.logger.WithField("base_url", baseURL).Info("Tenant base URL updated")
+	return nil
+}
+
+// Delete drops the tenant database, with the copies held after a migration,
+// and removes it from the registry. It is refused while a job of the tenant
+// is queued or running.
+func (m *Manager) Delete(database string) error {
+	tenant, err := m.resolve(database)
+	if err != nil {
+		return err
+	}
+	// A migration or restore would recreate or move the database meanwhile
+	if m.jobs != nil {
+		active, err := m.jobs.Unfinished(database)
+		if err != nil {
+			return err
+		}
+		if err := ActiveJob(database, active); err != nil {
+			return err
+		}
+	}
+	// Dropping the record releases the claim
+	if tenant.Status != StatusUnregistered {
+		var claim Claim
+		if tenant, claim, err = m.Claim(database, OperationDrop, ""); err != nil {
+			return err
+		}
+		defer m.Unclaim(database, claim)
+	}
+
+	client, err := m.backends.Client(tenant.Backend)
+	if err != nil {
+		return err
+	}
+	if _, _, err := m.dropHeld(tenant, func(HeldCopy) bool { return true }); err != nil {
+		return err
+	}
+	if err := client.DropDatabase(database, newRPCID()); err != nil {
+		return fmt.Errorf("failed to drop database: %w", err)
+	}
+
+	if err := m.registry.Delete(database); err != nil {
+		return err
+	}
+
+	m.bus.Publish(events.TenantDeleted{Tenant: EventTenant(tenant), At: time.Now().UTC()})
+
+	return nil
+}
+
+// Erase drops a tenant database and its registry record on the owner's
+// request. Unlike Delete it tolerates a tenant already partly erased, so an
+// interrupted erasure can run again, and it publishes nothing: the caller
+// publishes TenantErased once every other record is purged. The database is
+// dropped from every backend holding a copy. It reports whether the database
+// and the registry record existed.
+func (m *Manager) Erase(database string) (dropped, unregistered bool, err error) {
+	if database == m.config.TemplateDatabase {
+		return false, false, ErrProtected
+	}
+
+	databases, err := m.backends.Databases()
+	if err != nil {
+		return false, false, err
+	}
+	for _, backend := range sortedKeys(databases) {
+		for _, name := range databases[backend] {
+			if name != database {
+				continue
+			}
+			client, err := m.backends.Client(backend)
+			if err != nil {
+				return dropped, false, err
+			}
+			if err := client.DropDatabase(database, newRPCID()); err != nil {
+				return dropped, false, fmt.Errorf("failed to drop database on %s: %w", backend, err)
+			}
+			dropped = true
+		}
+	}
+
+	if tenant, registered := m.registry.Get(database); registered {
+		// Copies held after a migration may carry a former name
+		if _, _, err := m.dropHeld(tenant, func(HeldCopy) bool { return true }); err != nil {
+			return dropped, false, err
+		}
+		if err := m.registry.Delete(database); err != nil {
+			return dropped, false, err
+		}
+		unregistered = true
+	}
+	return dropped, unregistered, nil
+}
+
+// ResetOwnerPassword sets a new password for the tenant owner. A random
+// password is generated when none is given. The password in effect is returned.
+func (m *Manager) ResetOwnerPassword(database, newPassword string) (string, error) {
+	tenant, err := m.resolve(database)
+	if err != nil {
+		return "", err
+	}
+	if tenant.OwnerEmail == "" {
+		return "", ErrOwnerUnknown
+	}
+
+	if newPassword == "" {
+		newPassword, err = GeneratePassword()
+		if err != nil {
+			return "", err
+		}
+	} else {
+		policy := password.Policy{MinLength: m.config.PasswordMinLength, MinScore: m.config.PasswordMinScore}
+		if !policy.Evaluate(newPassword, tenant.OwnerEmail, tenant.CompanyName).Acceptable {
+			return "", ErrWeakPassword
+		}
+	}
+
+	s, err := m.login(tenant)
+	if err != nil {
+		return "", err
+	}
+
+	userID, err := s.client.SearchID(database, s.uid, m.config.AdminPassword, "res.users",
+		[]interface{}{[]interface{}{"login", "=", tenant.OwnerEmail}}, s.rpcID)
+	if err != nil {
+		return "", err
+	}
+	if userID == 0 {
+		return "", ErrOwnerNotFound
+	}
+
+	if _, err := m.execute(s, "res.users", "write", []interface{}{userID}, map[string]interface{}{"password": newPassword}); err != nil {
+		return "", fmt.Errorf("failed to update password: %w", err)
+	}
+
+	s.logger.Info("Tenant owner password reset")
+	return newPassword, nil
+}
+
+// Lookup returns the registered tenant, or an unregistered entry for databases
+// only a backend knows, without signing in to the database
+func (m *Manager) Lookup(database string) (Tenant, error) {
+	return m.resolve(database)
+}
+
+// resolve returns the registered tenant, or an unregistered entry for databases only a backend knows
+func (m *Manager) resolve(database string) (Tenant, error) {
+	if database == m.config.TemplateDatabase {
+		return Tenant{}, ErrProtected
+	}
+
+	if tenant, ok := m.registry.Get(database); ok {
+		return tenant, nil
+	}
+
+	databases, err := m.backends.Databases()
+	if err != nil {
+		return Tenant{}, err
+	}
+	for _, backend := range sortedKeys(databases) {
+		for _, name := range databases[backend] {
+			if name == database {
+				return Tenant{Database: database, Backend: backend, Status: StatusUnregistered}, nil
+			}
+		}
+	}
+
+	return Tenant{}, ErrNotFound
+}
+
+// ignoredDatabases returns databases on the server that are not tenants
+func (m *Manager) ignoredDatabases() []string {
+	ignored := append([]string{m.config.TemplateDatabase}, m.backends.Templates()...)
+	if m.config.OperatorDatabase != "" && m.config.OperatorURL == m.config.OdooURL {
+		ignored = append(ignored, m.config.OperatorDatabase)
+	}
+	return ignored
+}
+
+// login signs in to a tenant database on its backend with the service account
+func (m *Manager) login(tenant Tenant) (*session, error) {
+	database := tenant.Database
+	client, err := m.backends.Client(tenant.Backend)
+	if err != nil {
+		return nil, err
+	}
+
+	rpcID := newRPCID()
+	uid, err := client.Login(database, m.config.AdminUser, m.config.AdminPassword, rpcID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign in to %s: %w", database, err)
+	}
+
+	return &session{
+		database: database,
+		client:   client,
+		uid:      uid,
+		rpcID:    rpcID,
+		logger:   logrus.WithField("database", database),
+	}, nil
+}
+
+// execute calls a model method in the tenant database with the service account
+func (m *Manager) execute(s *session, model, method string, args ...interface{}) (interface{}, error) {
+	return s.client.ExecuteKw(s.database, s.uid, m.config.AdminPassword, model, method, args, s.rpcID)
+}
+
+// EventTenant builds the event view of a registered tenant
+func EventTenant(tenant Tenant) events.Tenant {
+	return events.Tenant{
+		Database:    tenant.Database,
+		InstanceURL: tenant.InstanceURL,
+		DbMode:      tenant.DbMode,
+		Template:    tenant.Template,
+		Backend:     tenant.Backend,
+		SourceIP:    tenant.SourceIP,
+		Email:       tenant.OwnerEmail,
+		CompanyName: tenant.CompanyName,
+		CountryCode: tenant.CountryCode,
+		Plan:        tenant.Plan,
+		TrialEndsAt: tenant.TrialEndsAt,
+	}
+}
+
+// newRPCID returns an RPC ID for a single operation
+func newRPCID() int {
+	return int(time.Now().UnixNano() % 1000000)
+}
+
+// GeneratePassword returns a random URL-safe password
+func GeneratePassword() (string, error) {
+	buf := make([]byte, 18)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate password: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// toInts converts a JSON-RPC list of IDs
+func toInts(v interface{}) []int {
+	rows, _ := v.([]interface{})
+	ids := make([]int, 0, len(rows))
+	for _, row := range rows {
+		if id, ok := odoo.ToInt(row); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// toArgs converts IDs to a JSON-RPC list
+func toArgs(ids []int) []interface{} {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return args
+}
@@ -0,0 +1,130 @@
+package tenants
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Finding kinds reported by Reconcile
+const (
+	FindingOrphan  = "orphan"  // Database exists in Odoo but is not registered
+	FindingMissing = "missing" // Registered tenant whose database is gone
+	FindingDrift   = "drift"   // Registry status contradicts the database list
+)
+
+// Finding is one difference between the registry and Odoo
+type Finding struct {
+	Database string `json:"database"`
+	Kind     string `json:"kind"`
+	Backend  string `json:"backend,omitempty"` // Backend the database was expected on or found on
+	Status   string `json:"status,omitempty"`  // Registry status, empty for orphans
+	Detail   string `json:"detail"`
+}
+
+// Report is the result of comparing the registry with Odoo's database list
+type Report struct {
+	Registered int       `json:"registered"`
+	Databases  int       `json:"databases"`
+	Findings   []Finding `json:"findings"`
+	CheckedAt  time.Time `json:"checkedAt"`
+}
+
+// Reconcile compares registered tenants with the databases each backend
+// lists, by backend name. Tenants registered without a backend are expected
+// on defaultBackend. Databases in ignore (e.g. the template) and copies held
+// after a migration are never reported as orphans, and tenants provisioning
+// for longer than staleAfter are reported as drift.
+func Reconcile(registered []Tenant, databases map[string][]string, defaultBackend string, ignore []string, staleAfter time.Duration) *Report {
+	now := time.Now().UTC()
+	report := &Report{
+		Registered: len(registered),
+		Findings:   []Finding{},
+		CheckedAt:  now,
+	}
+
+	// Backends holding each database
+	exists := make(map[string]map[string]bool)
+	for backend, names := range databases {
+		report.Databases += len(names)
+		for _, name := range names {
+			if exists[name] == nil {
+				exists[name] = make(map[string]bool)
+			}
+			exists[name][backend] = true
+		}
+	}
+
+	skip := make(map[string]bool, len(ignore))
+	for _, name := range ignore {
+		skip[name] = true
+	}
+
+	// Backend each registered database belongs to, and copies held by migrations
+	known := make(map[string]string, len(registered))
+	held := make(map[string]bool)
+	for _, tenant := range registered {
+		for _, source := range tenant.Held {
+			held[source.Backend+"/"+source.Database] = true
+		}
+		backend := tenant.Backend
+		if backend == "" {
+			backend = defaultBackend
+		}
+		known[tenant.Database] = backend
+		finding := Finding{Database: tenant.Database, Backend: backend, Status: tenant.Status}
+
+		switch {
+		case tenant.Status == StatusFailed && exists[tenant.Database][backend]:
+			finding.Kind = FindingDrift
+			finding.Detail = "provisioning failed but the database exists"
+		case tenant.Status == StatusFailed:
+			continue
+		case tenant.Status == StatusProvisioning && now.Sub(tenant.UpdatedAt) > staleAfter:
+			finding.Kind = FindingDrift
+			finding.Detail = fmt.Sprintf("provisioning since %s", tenant.UpdatedAt.Format(time.RFC3339))
+		case tenant.Status == StatusProvisioning:
+			continue
+		case !exists[tenant.Database][backend]:
+			finding.Kind = FindingMissing
+			finding.Detail = "registered tenant has no database"
+			if others := sortedKeys(exists[tenant.Database]); len(others) > 0 {
+				finding.Detail += fmt.Sprintf(" on %s, found on %s", backend, strings.Join(others, ", "))
+			}
+		default:
+			continue
+		}
+
+		report.Findings = append(report.Findings, finding)
+	}
+
+	for _, backend := range sortedKeys(databases) {
+		for _, name := range databases[backend] {
+			if skip[name] || known[name] == backend || held[backend+"/"+name] {
+				continue
+			}
+			finding := Finding{Database: name, Backend: backend, Kind: FindingOrphan, Detail: "database is not in the registry"}
+			if owner, ok := known[name]; ok {
+				finding.Detail = fmt.Sprintf("the registry places the tenant on %s", owner)
+			}
+			report.Findings = append(report.Findings, finding)
+		}
+	}
+
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		return report.Findings[i].Database < report.Findings[j].Database
+	})
+
+	return report
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,436 @@
+package tenants
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"odoo-signup/internal/store"
+
+	"github.com/sirupsen/logrus"
+	_ "modernc.org/sqlite"
+)
+
+// schema lists the migrations of the registry database in order; the
+// database records how many were applied in its user_version
+var schema = []string{
+	`CREATE TABLE tenants (
+		database        TEXT PRIMARY KEY,
+		instance_url    TEXT NOT NULL DEFAULT '',
+		owner_email     TEXT NOT NULL DEFAULT '',
+		company_name    TEXT NOT NULL DEFAULT '',
+		country_code    TEXT NOT NULL DEFAULT '',
+		plan            TEXT NOT NULL DEFAULT '',
+		db_mode         TEXT NOT NULL DEFAULT '',
+		template        TEXT NOT NULL DEFAULT '',
+		backend         TEXT NOT NULL DEFAULT '',
+		source_ip       TEXT NOT NULL DEFAULT '',
+		status          TEXT NOT NULL,
+		error           TEXT NOT NULL DEFAULT '',
+		suspended_users TEXT NOT NULL DEFAULT '[]',
+		suspended_by    TEXT NOT NULL DEFAULT '',
+		suspend_reason  TEXT NOT NULL DEFAULT '',
+		suspended_at    TEXT,
+		trial_ends_at   TEXT,
+		trial_reminder  INTEGER NOT NULL DEFAULT 0,
+		domains         TEXT NOT NULL DEFAULT '[]',
+		held            TEXT NOT NULL DEFAULT '[]',
+		created_at      TEXT NOT NULL,
+		updated_at      TEXT NOT NULL
+	)`,
+	`ALTER TABLE tenants ADD COLUMN job_id TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE tenants ADD COLUMN former_names TEXT NOT NULL DEFAULT '[]'`,
+	`ALTER TABLE tenants ADD COLUMN claim TEXT`,
+}
+
+// columns are the tenant columns in the order scan and values use them
+const columns = `database, instance_url, owner_email, company_name, country_code, plan, db_mode, template,
+	backend, source_ip, status, error, suspended_users, suspended_by, suspend_reason, suspended_at,
+	trial_ends_at, trial_reminder, domains, held, created_at, updated_at, job_id, former_names, claim`
+
+// SQLiteStore is the embedded Store keeping the registry in a SQLite
+// database. The CLI and the server share the file: writes run in immediate
+// transactions, so they wait for each other instead of losing updates.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens the registry database at path, creating it when
+// missing. A JSON registry of an earlier version next to it, with the same
+// name and a .json extension, is imported into an empty database.
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+
+	dsn := "file:" + path + "?_txlock=immediate&_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	s := &SQLiteStore{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to prepare %s: %w", path, err)
+	}
+	if err := s.importJSON(strings.TrimSuffix(path, filepath.Ext(path)) + ".json"); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close closes the database
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// Get returns a registered tenant
+func (s *SQLiteStore) Get(database string) (Tenant, bool) {
+	tenant, err := scan(s.db.QueryRow(`SELECT `+columns+` FROM tenants WHERE database = ?`, database))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Tenant{}, false
+	}
+	if err != nil {
+		logrus.WithError(err).WithField("database", database).Error("Failed to read tenant registry")
+		return Tenant{}, false
+	}
+	return tenant, true
+}
+
+// List returns all registered tenants ordered by database name
+func (s *SQLiteStore) List() []Tenant {
+	list := []Tenant{}
+	rows, err := s.db.Query(`SELECT ` + columns + ` FROM tenants ORDER BY database`)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to read tenant registry")
+		return list
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		tenant, err := scan(rows)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to read tenant registry")
+			continue
+		}
+		list = append(list, tenant)
+	}
+	if err := rows.Err(); err != nil {
+		logrus.WithError(err).Error("Failed to read tenant registry")
+	}
+	return list
+}
+
+// Put adds or replaces a tenant, keeping the creation time of an existing record
+func (s *SQLiteStore) Put(tenant Tenant) error {
+	return s.transaction(func(tx *sql.Tx) error {
+		if tenant.CreatedAt.IsZero() {
+			existing, err := scan(tx.QueryRow(`SELECT `+columns+` FROM tenants WHERE database = ?`, tenant.Database))
+			switch {
+			case err == nil:
+				tenant.CreatedAt = existing.CreatedAt
+			case !errors.Is(err, sql.ErrNoRows):
+				return err
+			}
+		}
+		return write(tx, tenant)
+	})
+}
+
+// Create adds a tenant unless the name is registered, in which case it
+// returns ErrExists. It is how a signup claims its database name.
+func (s *SQLiteStore) Create(tenant Tenant) error {
+	return s.transaction(func(tx *sql.Tx) error {
+		var exists bool
+		if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM tenants WHERE database = ?)`, tenant.Database).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return ErrExists
+		}
+		tenant.CreatedAt = time.Time{}
+		return write(tx, tenant)
+	})
+}
+
+// Update changes a registered tenant in one transaction: fn gets the stored
+// record, and its changes are saved unless it returns an error
+func (s *SQLiteStore) Update(database string, fn func(tenant *Tenant) error) (Tenant, error) {
+	var tenant Tenant
+	err := s.transaction(func(tx *sql.Tx) error {
+		var err error
+		tenant, err = scan(tx.QueryRow(`SELECT `+columns+` FROM tenants WHERE database = ?`, database))
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotRegistered
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := fn(&tenant); err != nil {
+			return err
+		}
+		if tenant.Database != database {
+			return fmt.Errorf("the registry record of %s cannot be renamed by an update", database)
+		}
+		return write(tx, tenant)
+	})
+	return tenant, err
+}
+
+// Rename replaces the record of a tenant by renamed in one transaction. It
+// returns ErrExists when the new name is registered and ErrNotRegistered when
+// the tenant is not.
+func (s *SQLiteStore) Rename(database string, renamed Tenant) error {
+	return s.transaction(func(tx *sql.Tx) error {
+		var exists bool
+		if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM tenants WHERE database = ?)`, renamed.Database).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return ErrExists
+		}
+
+		result, err := tx.Exec(`DELETE FROM tenants WHERE database = ?`, database)
+		if err != nil {
+			return fmt.Errorf("failed to delete %s from the registry: %w", database, err)
+		}
+		if deleted, err := result.RowsAffected(); err != nil {
+			return err
+		} else if deleted == 0 {
+			return ErrNotRegistered
+		}
+		return write(tx, renamed)
+	})
+}
+
+// Delete removes a tenant
+func (s *SQLiteStore) Delete(database string) error {
+	if _, err := s.db.Exec(`DELETE FROM tenants WHERE database = ?`, database); err != nil {
+		return fmt.Errorf("failed to delete %s from the registry: %w", database, err)
+	}
+	return nil
+}
+
+// transaction runs fn in an immediate transaction, committed when fn succeeds
+func (s *SQLiteStore) transaction(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin registry transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit registry transaction: %w", err)
+	}
+	return nil
+}
+
+// migrate applies the schema migrations the database has not seen yet
+func (s *SQLiteStore) migrate() error {
+	return s.transaction(func(tx *sql.Tx) error {
+		var version int
+		if err := tx.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+			return err
+		}
+		for ; version < len(schema); version++ {
+			if _, err := tx.Exec(schema[version]); err != nil {
+				return fmt.Errorf("migration %d: %w", version+1, err)
+			}
+		}
+		_, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, version))
+		return err
+	})
+}
+
+// importJSON imports the JSON registry of an earlier version into an empty
+// database and renames the file, so it is imported once
+func (s *SQLiteStore) importJSON(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+
+	imported := 0
+	err := s.transaction(func(tx *sql.Tx) error {
+		var count int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM tenants`).Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+
+		legacy := make(map[string]Tenant)
+		if err := store.NewFile(path).Load(&legacy); err != nil {
+			return err
+		}
+		for _, tenant := range legacy {
+			if err := write(tx, tenant); err != nil {
+				return err
+			}
+			imported++
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to import %s: %w", path, err)
+	}
+	if imported == 0 {
+		return nil
+	}
+
+	if err := os.Rename(path, path+".imported"); err != nil {
+		return fmt.Errorf("failed to rename %s after its import: %w", path, err)
+	}
+	logrus.WithFields(logrus.Fields{"path": path, "tenants": imported}).Info("JSON tenant registry imported")
+	return nil
+}
+
+// write inserts or replaces a tenant row, stamping its update time
+func write(tx *sql.Tx, tenant Tenant) error {
+	now := time.Now().UTC()
+	if tenant.CreatedAt.IsZero() {
+		tenant.CreatedAt = now
+	}
+	tenant.UpdatedAt = now
+
+	suspendedUsers, err := json.Marshal(orEmpty(tenant.SuspendedUsers))
+	if err != nil {
+		return err
+	}
+	domains, err := json.Marshal(orEmpty(tenant.Domains))
+	if err != nil {
+		return err
+	}
+	held, err := json.Marshal(orEmpty(tenant.Held))
+	if err != nil {
+		return err
+	}
+	formerNames, err := json.Marshal(orEmpty(tenant.FormerNames))
+	if err != nil {
+		return err
+	}
+	var claim any
+	if tenant.Claim != nil {
+		data, err := json.Marshal(tenant.Claim)
+		if err != nil {
+			return err
+		}
+		claim = string(data)
+	}
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO tenants (`+columns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		tenant.Database, tenant.InstanceURL, tenant.OwnerEmail, tenant.CompanyName, tenant.CountryCode,
+		tenant.Plan, tenant.DbMode, tenant.Template, tenant.Backend, tenant.SourceIP, tenant.Status,
+		tenant.Error, string(suspendedUsers), tenant.SuspendedBy, tenant.SuspendReason,
+		formatTime(tenant.SuspendedAt), formatTime(tenant.TrialEndsAt), tenant.TrialReminder,
+		string(domains), string(held), formatTime(&tenant.CreatedAt), formatTime(&tenant.UpdatedAt), tenant.JobID,
+		string(formerNames), claim)
+	if err != nil {
+		return fmt.Errorf("failed to write %s to the registry: %w", tenant.Database, err)
+	}
+	return nil
+}
+
+// row is a single result of QueryRow or the current one of Query
+type row interface {
+	Scan(dest ...any) error
+}
+
+// scan reads a tenant from a row selected with columns
+func scan(r row) (Tenant, error) {
+	var tenant Tenant
+	var suspendedUsers, domains, held, formerNames string
+	var suspendedAt, trialEndsAt, claim sql.NullString
+	var createdAt, updatedAt string
+
+	err := r.Scan(&tenant.Database, &tenant.InstanceURL, &tenant.OwnerEmail, &tenant.CompanyName,
+		&tenant.CountryCode, &tenant.Plan, &tenant.DbMode, &tenant.Template, &tenant.Backend,
+		&tenant.SourceIP, &tenant.Status, &tenant.Error, &suspendedUsers, &tenant.SuspendedBy,
+		&tenant.SuspendReason, &suspendedAt, &trialEndsAt, &tenant.TrialReminder, &domains, &held,
+		&createdAt, &updatedAt, &tenant.JobID, &formerNames, &claim)
+	if err != nil {
+		return tenant, err
+	}
+
+	if err := json.Unmarshal([]byte(suspendedUsers), &tenant.SuspendedUsers); err != nil {
+		return tenant, fmt.Errorf("invalid suspended users of %s: %w", tenant.Database, err)
+	}
+	if err := json.Unmarshal([]byte(domains), &tenant.Domains); err != nil {
+		return tenant, fmt.Errorf("invalid domains of %s: %w", tenant.Database, err)
+	}
+	if err := json.Unmarshal([]byte(held), &tenant.Held); err != nil {
+		return tenant, fmt.Errorf("invalid held copies of %s: %w", tenant.Database, err)
+	}
+	if err := json.Unmarshal([]byte(formerNames), &tenant.FormerNames); err != nil {
+		return tenant, fmt.Errorf("invalid former names of %s: %w", tenant.Database, err)
+	}
+	if claim.Valid {
+		if err := json.Unmarshal([]byte(claim.String), &tenant.Claim); err != nil {
+			return tenant, fmt.Errorf("invalid claim of %s: %w", tenant.Database, err)
+		}
+	}
+	if len(tenant.SuspendedUsers) == 0 {
+		tenant.SuspendedUsers = nil
+	}
+	if len(tenant.Domains) == 0 {
+		tenant.Domains = nil
+	}
+	if len(tenant.Held) == 0 {
+		tenant.Held = nil
+	}
+	if len(tenant.FormerNames) == 0 {
+		tenant.FormerNames = nil
+	}
+
+	if tenant.SuspendedAt, err = parseTime(suspendedAt); err != nil {
+		return tenant, err
+	}
+	if tenant.TrialEndsAt, err = parseTime(trialEndsAt); err != nil {
+		return tenant, err
+	}
+	if tenant.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return tenant, err
+	}
+	if tenant.UpdatedAt, err = time.Parse(time.RFC3339Nano, updatedAt); err != nil {
+		return tenant, err
+	}
+	return tenant, nil
+}
+
+// formatTime stores a time as RFC 3339 text, or NULL when unset
+func formatTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// parseTime reads a time stored by formatTime
+func parseTime(value sql.NullString) (*time.Time, error) {
+	if !value.Valid {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// orEmpty encodes nil slices as empty JSON arrays
+func orEmpty[T any](values []T) []T {
+	if values == nil {
+		return []T{}
+	}
+	return values
+}
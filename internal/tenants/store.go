@@ -0,0 +1,142 @@
+package tenants
+
+import (
+	"errors"
+	"regexp"
+	"time"
+)
+
+// Tenant statuses
+const (
+	StatusProvisioning = "provisioning"
+	StatusActive       = "active"
+	StatusSuspended    = "suspended"
+	StatusFailed       = "failed"
+	StatusUnregistered = "unregistered" // Database exists in Odoo but not in the registry
+)
+
+// ReservedNames cannot be used as tenant names
+var ReservedNames = map[string]bool{
+	"admin": true,
+	"www":   true,
+}
+
+// namePattern matches tenant names, which are also the subdomain of the instance URL
+var namePattern = regexp.MustCompile(`^[a-z0-9]{3,20}$`)
+
+// ValidName reports whether a tenant could be named name
+func ValidName(name string) bool {
+	return namePattern.MatchString(name) && !ReservedNames[name]
+}
+
+// ErrNotFound is returned for databases that are neither registered nor known to Odoo
+var ErrNotFound = errors.New("tenant not found")
+
+// Tenant is a database provisioned by this service
+type Tenant struct {
+	Database       string     `json:"database"`
+	InstanceURL    string     `json:"instanceUrl,omitempty"`
+	OwnerEmail     string     `json:"ownerEmail,omitempty"`
+	CompanyName    string     `json:"companyName,omitempty"`
+	CountryCode    string     `json:"countryCode,omitempty"`
+	Plan           string     `json:"plan,omitempty"`
+	DbMode         string     `json:"dbMode,omitempty"`
+	Template       string     `json:"template,omitempty"` // Template database for cloned tenants
+	Backend        string     `json:"backend,omitempty"`  // Odoo server hosting the database; the default backend when empty
+	SourceIP       string     `json:"sourceIp,omitempty"`
+	Status         string     `json:"status"`
+	Error          string     `json:"error,omitempty"`          // Reason of a failed provisioning
+	SuspendedUsers []int      `json:"suspendedUsers,omitempty"` // res.users archived by Suspend
+	SuspendedBy    string     `json:"suspendedBy,omitempty"`    // Operator, API key or process that suspended the tenant
+	SuspendReason  string     `json:"suspendReason,omitempty"`
+	SuspendedAt    *time.Time `json:"suspendedAt,omitempty"`
+	TrialEndsAt    *time.Time `json:"trialEndsAt,omitempty"`   // Unset for permanent tenants
+	TrialReminder  int        `json:"trialReminder,omitempty"` // Days before the trial end of the last reminder sent
+	Domains        []Domain   `json:"domains,omitempty"`       // Custom domains, verified or awaiting their DNS challenge
+	Held           []HeldCopy `json:"held,omitempty"`          // Copies left on former backends by migrations
+	JobID          string     `json:"jobId,omitempty"`         // Provisioning job that created the tenant
+	FormerNames    []string   `json:"formerNames,omitempty"`   // Names the tenant had before it was renamed
+	Claim          *Claim     `json:"claim,omitempty"`         // Operation that has the tenant to itself
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
+
+// Domain is a custom domain attached to a tenant. It is routed to the tenant
+// once the TXT record with its challenge was found in DNS.
+type Domain struct {
+	Name       string     `json:"name"`
+	Challenge  string     `json:"challenge"`
+	Primary    bool       `json:"primary,omitempty"` // Used as web.base.url instead of the instance URL
+	VerifiedAt *time.Time `json:"verifiedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// HeldCopy is the source database of a migration, kept on the backend the
+// tenant moved from until its hold period ends, so the move can be undone
+type HeldCopy struct {
+	Backend  string    `json:"backend"`
+	Database string    `json:"database"` // Name at the time of the migration
+	JobID    string    `json:"jobId,omitempty"`
+	Until    time.Time `json:"until"`
+}
+
+// Claim marks a tenant as taken by an operation that must not overlap with
+// another one, such as a migration and a rename. See Manager.Claim.
+type Claim struct {
+	ID        string    `json:"id"`
+	Operation string    `json:"operation"`       // migration, restore, rename or drop
+	JobID     string    `json:"jobId,omitempty"` // Job running the operation
+	At        time.Time `json:"at"`
+}
+
+// Verified reports whether the domain passed its DNS challenge
+func (d Domain) Verified() bool {
+	return d.VerifiedAt != nil
+}
+
+// Hosts returns the instance URL and the verified custom domains of a tenant
+func (t Tenant) Hosts() []string {
+	var hosts []string
+	if t.InstanceURL != "" {
+		hosts = append(hosts, t.InstanceURL)
+	}
+	for _, domain := range t.Domains {
+		if domain.Verified() {
+			hosts = append(hosts, domain.Name)
+		}
+	}
+	return hosts
+}
+
+// BaseURL returns the URL users reach the tenant at: its primary custom
+// domain, or the instance URL
+func (t Tenant) BaseURL() string {
+	for _, domain := range t.Domains {
+		if domain.Primary && domain.Verified() {
+			return "https://" + domain.Name
+		}
+	}
+	return "https://" + t.InstanceURL
+}
+
+// Store persists the tenant registry
+type Store interface {
+	// Get returns a registered tenant
+	Get(database string) (Tenant, bool)
+	// List returns all registered tenants ordered by database name
+	List() []Tenant
+	// Put adds or replaces a tenant, keeping the creation time of an existing record
+	Put(tenant Tenant) error
+	// Create adds a tenant, or returns ErrExists when the name is registered
+	Create(tenant Tenant) error
+	// Update changes a registered tenant in one transaction: fn gets the
+	// stored record, and its changes are saved unless it returns an error.
+	// It returns ErrNotRegistered when the tenant is not registered.
+	Update(database string, fn func(tenant *Tenant) error) (Tenant, error)
+	// Rename replaces the record of a tenant by renamed in one transaction.
+	// It returns ErrExists when the new name is registered and
+	// ErrNotRegistered when the tenant is not.
+	Rename(database string, renamed Tenant) error
+	// Delete removes a tenant
+	Delete(database string) error
+}
@@ -0,0 +1,194 @@
+package trials
+
+import (
+	"math"
+	"time"
+
+	"odoo-signup/internal/backups"
+	"odoo-signup/internal/events"
+	"odoo-signup/internal/tenants"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RunResult lists what a check of all trials did, by database
+type RunResult struct {
+	CheckedAt time.Time         `json:"checkedAt"`
+	Reminded  []string          `json:"reminded"`
+	Suspended []string          `json:"suspended"`
+	Deleted   []string          `json:"deleted"`
+	Failures  map[string]string `json:"failures,omitempty"` // Error by database
+}
+
+// Enforcer checks the registry for trials to remind, suspend and delete
+type Enforcer struct {
+	policy   Policy
+	registry tenants.Store
+	manager  *tenants.Manager
+	backups  *backups.Scheduler
+	bus      *events.Bus
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewEnforcer creates an enforcer. Reminders and expiry are published on the
+// bus for the email and webhook subscribers; tenants are backed up with the
+// backup scheduler before they are dropped.
+func NewEnforcer(policy Policy, registry tenants.Store, manager *tenants.Manager, backupScheduler *backups.Scheduler, bus *events.Bus) *Enforcer {
+	return &Enforcer{
+		policy:   policy,
+		registry: registry,
+		manager:  manager,
+		backups:  backupScheduler,
+		bus:      bus,
+	}
+}
+
+// Start checks all trials now and then every interval
+func (e *Enforcer) Start(interval time.Duration) {
+	e.stop = make(chan struct{})
+	e.done = make(chan struct{})
+
+	go func() {
+		defer close(e.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			e.Run(time.Now())
+			select {
+			case <-e.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops the checks and waits for a running check to finish
+func (e *Enforcer) Stop() {
+	if e.stop == nil {
+		return
+	}
+	close(e.stop)
+	<-e.done
+}
+
+// Run checks every trial tenant once as of now
+func (e *Enforcer) Run(now time.Time) RunResult {
+	now = now.UTC()
+	result := RunResult{
+		CheckedAt: now,
+		Reminded:  []string{},
+		Suspended: []string{},
+		Deleted:   []string{},
+		Failures:  make(map[string]string),
+	}
+
+	for _, tenant := range e.registry.List() {
+		if tenant.TrialEndsAt == nil {
+			continue
+		}
+
+		var err error
+		switch tenant.Status {
+		case tenants.StatusActive:
+			if tenant.TrialEndsAt.After(now) {
+				var reminded bool
+				if reminded, err = e.remind(tenant, now); reminded {
+					result.Reminded = append(result.Reminded, tenant.Database)
+				}
+			} else if err = e.expire(tenant); err == nil {
+				result.Suspended = append(result.Suspended, tenant.Database)
+			}
+		case tenants.StatusSuspended:
+			// Tenants an operator suspended are left to the operator
+			if tenant.SuspendedBy == tenants.TrialsActor && e.policy.Grace > 0 && !tenant.TrialEndsAt.Add(e.policy.Grace).After(now) {
+				if err = e.delete(tenant); err == nil {
+					result.Deleted = append(result.Deleted, tenant.Database)
+				}
+			}
+		}
+
+		if err != nil {
+			logrus.WithError(err).WithField("database", tenant.Database).Error("Trial check failed")
+			result.Failures[tenant.Database] = err.Error()
+		}
+	}
+
+	if len(result.Reminded)+len(result.Suspended)+len(result.Deleted)+len(result.Failures) > 0 {
+		logrus.WithFields(logrus.Fields{
+			"reminded":  len(result.Reminded),
+			"suspended": len(result.Suspended),
+			"deleted":   len(result.Deleted),
+			"failures":  len(result.Failures),
+		}).Info("Trials checked")
+	}
+	return result
+}
+
+// remind publishes the reminder due for an active trial, if any
+func (e *Enforcer) remind(tenant tenants.Tenant, now time.Time) (bool, error) {
+	left := tenant.TrialEndsAt.Sub(now)
+	days := e.policy.Reminder(left, tenant.TrialReminder)
+	if days == 0 {
+		return false, nil
+	}
+
+	// Recorded first, so a failing bus never repeats a reminder
+	tenant, err := e.registry.Update(tenant.Database, func(t *tenants.Tenant) error {
+		t.TrialReminder = days
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	e.publish(events.TrialEnding{
+		Tenant:   tenants.EventTenant(tenant),
+		EndsAt:   *tenant.TrialEndsAt,
+		DaysLeft: int(math.Ceil(left.Hours() / 24)),
+	})
+	logrus.WithFields(logrus.Fields{"database": tenant.Database, "trial_ends_at": tenant.TrialEndsAt}).Info("Trial reminder sent")
+	return true, nil
+}
+
+// expire suspends a tenant whose trial ended
+func (e *Enforcer) expire(tenant tenants.Tenant) error {
+	if _, err := e.manager.Suspend(tenant.Database, tenants.TrialsActor, "trial expired"); err != nil {
+		return err
+	}
+
+	expired := events.TrialExpired{Tenant: tenants.EventTenant(tenant), EndsAt: *tenant.TrialEndsAt}
+	if e.policy.Grace > 0 {
+		deleteAt := tenant.TrialEndsAt.Add(e.policy.Grace)
+		expired.DeleteAt = &deleteAt
+	}
+	e.publish(expired)
+
+	logrus.WithFields(logrus.Fields{"database": tenant.Database, "delete_at": expired.DeleteAt}).Info("Trial expired, tenant suspended")
+	return nil
+}
+
+// delete backs up a tenant whose grace period ended, then drops it. Without
+// a backup the tenant is kept.
+func (e *Enforcer) delete(tenant tenants.Tenant) error {
+	archive, err := e.backups.Snapshot(tenant.Database)
+	if err != nil {
+		return err
+	}
+	if err := e.manager.Delete(tenant.Database); err != nil {
+		return err
+	}
+
+	logrus.WithFields(logrus.Fields{"database": tenant.Database, "backup": archive.Location}).Info("Expired trial deleted")
+	return nil
+}
+
+// publish sends an event when a bus is set
+func (e *Enforcer) publish(event events.Event) {
+	if e.bus != nil {
+		e.bus.Publish(event)
+	}
+}
@@ -0,0 +1,95 @@
+// Package trials ends trial tenants: it reminds owners before a trial ends,
+// suspends the tenant at expiry and drops it after a grace period
+package trials
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"odoo-signup/internal/models"
+)
+
+// Policy sets the trial length of each plan and what happens around expiry
+type Policy struct {
+	Days      int            // Trial length of plans not listed in Plans; 0 means no trial
+	Plans     map[string]int // Trial length by plan; 0 means no trial
+	Reminders []int          // Days before the end a reminder is sent, ascending
+	Grace     time.Duration  // From expiry to deletion; 0 keeps expired trials suspended
+}
+
+// NewPolicy reads the trial policy from the configuration
+func NewPolicy(config *models.Config) (Policy, error) {
+	policy := Policy{
+		Days:  config.TrialDays,
+		Plans: make(map[string]int),
+		Grace: time.Duration(config.TrialGraceDays) * 24 * time.Hour,
+	}
+
+	for _, pair := range strings.Split(config.TrialPlans, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		plan, value, ok := strings.Cut(pair, "=")
+		days, err := strconv.Atoi(strings.TrimSpace(value))
+		if !ok || err != nil || days < 0 {
+			return Policy{}, fmt.Errorf("invalid trial plan %q, expected plan=days", pair)
+		}
+		policy.Plans[strings.TrimSpace(plan)] = days
+	}
+
+	for _, value := range strings.Split(config.TrialReminderDays, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		days, err := strconv.Atoi(value)
+		if err != nil || days <= 0 {
+			return Policy{}, fmt.Errorf("invalid trial reminder %q, expected a number of days", value)
+		}
+		policy.Reminders = append(policy.Reminders, days)
+	}
+	sort.Ints(policy.Reminders)
+
+	return policy, nil
+}
+
+// Length returns the trial length of a plan in days; 0 means no trial
+func (p Policy) Length(plan string) int {
+	if days, ok := p.Plans[plan]; ok {
+		return days
+	}
+	return p.Days
+}
+
+// EndsAt returns when a trial of the plan started at from ends, or nil when
+// the plan has no trial
+func (p Policy) EndsAt(plan string, from time.Time) *time.Time {
+	days := p.Length(plan)
+	if days <= 0 {
+		return nil
+	}
+	end := from.UTC().AddDate(0, 0, days)
+	return &end
+}
+
+// Reminder returns the reminder due for a trial ending in left, as days
+// before the end, or 0 when none is due. A reminder is only due once: sent
+// holds the last reminder sent.
+func (p Policy) Reminder(left time.Duration, sent int) int {
+	for _, days := range p.Reminders {
+		if left > time.Duration(days)*24*time.Hour {
+			continue
+		}
+		if sent == 0 || days < sent {
+			return days
+		}
+		return 0
+	}
+	return 0
+}
@@ -0,0 +1,72 @@
+package webhooks
+
+import (
+	"sync"
+	"time"
+
+	"odoo-signup/internal/store"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxLogEntries bounds the persisted delivery log
+const maxLogEntries = 1000
+
+// Delivery records one attempt to deliver an event to an endpoint
+type Delivery struct {
+	ID          string    `json:"id"`
+	Endpoint    string    `json:"endpoint"`
+	EventType   string    `json:"eventType"`
+	StatusCode  int       `json:"statusCode,omitempty"`
+	Success     bool      `json:"success"`
+	Error       string    `json:"error,omitempty"`
+	DurationMs  int64     `json:"durationMs"`
+	AttemptedAt time.Time `json:"attemptedAt"`
+}
+
+// DeliveryLog keeps the most recent delivery attempts on disk
+type DeliveryLog struct {
+	mu      sync.Mutex
+	file    *store.File
+	entries []Delivery
+}
+
+// NewDeliveryLog loads the delivery log persisted at path
+func NewDeliveryLog(path string) (*DeliveryLog, error) {
+	l := &DeliveryLog{file: store.NewFile(path)}
+	if err := l.file.Load(&l.entries); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Record appends an attempt, dropping the oldest entries beyond the limit
+func (l *DeliveryLog) Record(entry Delivery) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = append(l.entries, entry)
+	if len(l.entries) > maxLogEntries {
+		l.entries = l.entries[len(l.entries)-maxLogEntries:]
+	}
+
+	if err := l.file.Save(l.entries); err != nil {
+		logrus.WithError(err).Error("Failed to persist webhook delivery log")
+	}
+}
+
+// Recent returns up to limit attempts, newest first
+func (l *DeliveryLog) Recent(limit int) []Delivery {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if limit <= 0 || limit > len(l.entries) {
+		limit = len(l.entries)
+	}
+
+	result := make([]Delivery, 0, limit)
+	for i := len(l.entries) - 1; i >= 0 && len(result) < limit; i-- {
+		result = append(result, l.entries[i])
+	}
+	return result
+}
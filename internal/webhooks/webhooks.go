@@ -0,0 +1,205 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"odoo-signup/internal/outbox"
+	"odoo-signup/internal/util"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DeliveryKind is the outbox message kind for webhook deliveries
+const DeliveryKind = "webhook.delivery"
+
+// Signup lifecycle event types
+const (
+	EventSignupRequested          = "signup.requested"
+	EventTenantProvisioned        = "tenant.provisioned"
+	EventTenantProvisioningFailed = "tenant.provisioning_failed"
+	EventTenantDeleted            = "tenant.deleted"
+	EventTenantErased             = "tenant.erased"
+	EventTenantRenamed            = "tenant.renamed"
+	EventDomainVerified           = "tenant.domain_verified"
+	EventDomainRemoved            = "tenant.domain_removed"
+	EventTenantSuspended          = "tenant.suspended"
+	EventTenantResumed            = "tenant.resumed"
+	EventTrialEnding              = "tenant.trial_ending"
+	EventTrialExpired             = "tenant.trial_expired"
+)
+
+// Headers sent with every delivery
+const (
+	HeaderEvent     = "X-Signup-Event"
+	HeaderDelivery  = "X-Signup-Delivery"
+	HeaderTimestamp = "X-Signup-Timestamp"
+	HeaderSignature = "X-Signup-Signature"
+)
+
+// Event is the JSON body posted to webhook endpoints
+type Event struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Data      interface{} `json:"data"`
+}
+
+// delivery is the outbox payload for one event and endpoint
+type delivery struct {
+	ID       string          `json:"id"`
+	Endpoint string          `json:"endpoint"`
+	Event    json.RawMessage `json:"event"`
+	Type     string          `json:"type"`
+}
+
+// Dispatcher signs and delivers events to the configured endpoints
+type Dispatcher struct {
+	endpoints  []string
+	secret     string
+	outbox     *outbox.Outbox
+	httpClient *http.Client
+	log        *DeliveryLog
+}
+
+// NewDispatcher creates a dispatcher that queues deliveries in the outbox
+func NewDispatcher(endpoints []string, secret string, messageOutbox *outbox.Outbox, log *DeliveryLog, timeoutSeconds int) *Dispatcher {
+	return &Dispatcher{
+		endpoints: endpoints,
+		secret:    secret,
+		outbox:    messageOutbox,
+		log:       log,
+		httpClient: &http.Client{
+			Timeout: time.Duration(timeoutSeconds) * time.Second,
+		},
+	}
+}
+
+// Publish queues an event for every endpoint. key identifies the tenant.
+func (d *Dispatcher) Publish(eventType, key string, data interface{}) error {
+	if len(d.endpoints) == 0 {
+		return nil
+	}
+
+	event, err := json.Marshal(Event{
+		ID:        util.NewID(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+
+	for _, endpoint := range d.endpoints {
+		msg := delivery{
+			ID:       util.NewID(),
+			Endpoint: endpoint,
+			Event:    event,
+			Type:     eventType,
+		}
+		if err := d.outbox.Enqueue(DeliveryKind, key, msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// HandleMessage is the outbox handler for DeliveryKind messages
+func (d *Dispatcher) HandleMessage(payload json.RawMessage) error {
+	var msg delivery
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return fmt.Errorf("failed to decode webhook delivery: %w", err)
+	}
+
+	started := time.Now()
+	statusCode, err := d.post(msg)
+
+	entry := Delivery{
+		ID:          msg.ID,
+		Endpoint:    msg.Endpoint,
+		EventType:   msg.Type,
+		StatusCode:  statusCode,
+		DurationMs:  time.Since(started).Milliseconds(),
+		Success:     err == nil,
+		AttemptedAt: started.UTC(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	d.log.Record(entry)
+
+	logrus.WithFields(logrus.Fields{
+		"delivery_id": msg.ID,
+		"endpoint":    msg.Endpoint,
+		"event":       msg.Type,
+		"status_code": statusCode,
+	}).Info("Webhook delivery attempted")
+
+	return err
+}
+
+// Deliveries returns the most recent delivery attempts, newest first
+func (d *Dispatcher) Deliveries(limit int) []Delivery {
+	return d.log.Recent(limit)
+}
+
+// post sends one signed delivery and returns the HTTP status code
+func (d *Dispatcher) post(msg delivery) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest("POST", msg.Endpoint, bytes.NewReader(msg.Event))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, msg.Type)
+	req.Header.Set(HeaderDelivery, msg.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, "sha256="+Sign(d.secret, timestamp, msg.Event))
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook endpoint returned %s", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Sign computes the hex HMAC-SHA256 of "<timestamp>.<body>". Receivers should
+// recompute it and reject stale timestamps to prevent replays.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ParseEndpoints splits a comma-separated endpoint list
+func ParseEndpoints(value string) []string {
+	var endpoints []string
+	for _, endpoint := range strings.Split(value, ",") {
+		if endpoint = strings.TrimSpace(endpoint); endpoint != "" {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints
+}